@@ -45,6 +45,26 @@ func main() {
 		}
 	}
 
+	// Start the sweeper for abandoned multipart uploads
+	if err := c.UploadSweeper.Start(context.Background()); err != nil {
+		c.Logger.Warn("Multipart upload sweeper not started", "error", err)
+	}
+
+	// Start the worker that applies bucket lifecycle rules
+	if err := c.LifecycleWorker.Start(context.Background()); err != nil {
+		c.Logger.Warn("Lifecycle worker not started", "error", err)
+	}
+
+	// Start the compactor of the change feed
+	if err := c.ChangeCompactor.Start(context.Background()); err != nil {
+		c.Logger.Warn("Change compactor not started", "error", err)
+	}
+
+	// Start delivering bucket event notifications
+	if err := c.NotificationService.Start(context.Background()); err != nil {
+		c.Logger.Warn("Notification delivery not started", "error", err)
+	}
+
 	// Set Gin mode
 	gin.SetMode(cfg.Server.Mode)
 
@@ -83,6 +103,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Stop the multipart upload sweeper
+	if err := c.UploadSweeper.Stop(); err != nil {
+		c.Logger.Warn("failed stopping multipart upload sweeper", "error", err)
+	}
+
+	// Stop the lifecycle worker
+	if err := c.LifecycleWorker.Stop(); err != nil {
+		c.Logger.Warn("failed stopping lifecycle worker", "error", err)
+	}
+
+	// Stop the change compactor
+	if err := c.ChangeCompactor.Stop(); err != nil {
+		c.Logger.Warn("failed stopping change compactor", "error", err)
+	}
+
 	// Stop indexing service if running
 	if c.IndexingService != nil {
 		if err := c.IndexingService.Stop(); err != nil {
@@ -92,6 +127,24 @@ func main() {
 		}
 	}
 
+	// Stop notification delivery, leaving undelivered events queued for the next start
+	if err := c.NotificationService.Stop(); err != nil {
+		c.Logger.Warn("failed stopping notification delivery", "error", err)
+	}
+	if err := c.NotificationQueue.Close(); err != nil {
+		c.Logger.Warn("failed closing notification queue", "error", err)
+	}
+
+	// Close the change log once nothing writes to storage any more
+	if err := c.ChangeLog.Close(); err != nil {
+		c.Logger.Warn("failed closing change log", "error", err)
+	}
+
+	// Close the credential store
+	if err := c.CredentialRepo.Close(); err != nil {
+		c.Logger.Warn("failed closing credential store", "error", err)
+	}
+
 	// Close vector storage if initialized
 	if c.VectorStorage != nil {
 		if err := c.VectorStorage.Close(); err != nil {
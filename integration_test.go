@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
 	"github.com/8fs-io/core/internal/config"
@@ -16,6 +17,14 @@ import (
 )
 
 func TestIntegrationNewArchitecture(t *testing.T) {
+	// Keep storage and SQLite databases out of the working tree
+	tmp := t.TempDir()
+	t.Setenv("STORAGE_BASE_PATH", tmp)
+	t.Setenv("CREDENTIALS_DB_PATH", filepath.Join(tmp, "credentials.db"))
+	t.Setenv("NOTIFY_QUEUE_PATH", filepath.Join(tmp, "notifications.db"))
+	t.Setenv("CHANGES_DB_PATH", filepath.Join(tmp, "changes.db"))
+	t.Setenv("VECTOR_DB_PATH", filepath.Join(tmp, "vectors.db"))
+
 	// Load config
 	cfg, err := config.Load()
 	assert.NoError(t, err)
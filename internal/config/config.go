@@ -1,9 +1,11 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -11,16 +13,17 @@ import (
 
 // Config holds all application configuration
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Storage  StorageConfig  `yaml:"storage"`
-	Auth     AuthConfig     `yaml:"auth"`
-	Metrics  MetricsConfig  `yaml:"metrics"`
-	Audit    AuditConfig    `yaml:"audit"`
-	Logger   LoggerConfig   `yaml:"logger"`
-	Vector   VectorConfig   `yaml:"vector"`
-	AI       AIConfig       `yaml:"ai"`
-	Indexing IndexingConfig `yaml:"indexing"`
-	RAG      RAGConfig      `yaml:"rag"`
+	Server        ServerConfig        `yaml:"server"`
+	Storage       StorageConfig       `yaml:"storage"`
+	Auth          AuthConfig          `yaml:"auth"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Metrics       MetricsConfig       `yaml:"metrics"`
+	Audit         AuditConfig         `yaml:"audit"`
+	Logger        LoggerConfig        `yaml:"logger"`
+	Vector        VectorConfig        `yaml:"vector"`
+	AI            AIConfig            `yaml:"ai"`
+	Indexing      IndexingConfig      `yaml:"indexing"`
+	RAG           RAGConfig           `yaml:"rag"`
 }
 
 type ServerConfig struct {
@@ -29,13 +32,66 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `yaml:"read_timeout"`
 	WriteTimeout time.Duration `yaml:"write_timeout"`
 	IdleTimeout  time.Duration `yaml:"idle_timeout"`
-	Mode         string        `yaml:"mode"` // gin.ReleaseMode, gin.DebugMode, gin.TestMode
+	Mode         string        `yaml:"mode"`   // gin.ReleaseMode, gin.DebugMode, gin.TestMode
+	Domain       string        `yaml:"domain"` // base domain of virtual-hosted-style S3 requests
 }
 
 type StorageConfig struct {
-	Driver   string   `yaml:"driver"`    // filesystem, s3, memory
-	BasePath string   `yaml:"base_path"` // for filesystem driver
-	S3Config S3Config `yaml:"s3"`
+	Driver     string           `yaml:"driver"`    // filesystem, s3, memory
+	BasePath   string           `yaml:"base_path"` // for filesystem driver
+	S3Config   S3Config         `yaml:"s3"`
+	Multipart  MultipartConfig  `yaml:"multipart"`
+	Lifecycle  LifecycleConfig  `yaml:"lifecycle"`
+	Encryption EncryptionConfig `yaml:"encryption"`
+	Changes    ChangesConfig    `yaml:"changes"`
+}
+
+// MultipartConfig holds multipart upload configuration
+type MultipartConfig struct {
+	MinPartSize   int64         `yaml:"min_part_size"`  // bytes, applies to every part but the last
+	UploadExpiry  time.Duration `yaml:"upload_expiry"`  // incomplete uploads older than this are aborted
+	SweepInterval time.Duration `yaml:"sweep_interval"` // how often to look for expired uploads
+}
+
+// LifecycleConfig holds bucket lifecycle configuration
+type LifecycleConfig struct {
+	SweepInterval time.Duration `yaml:"sweep_interval"` // how often lifecycle rules are applied
+}
+
+// ChangesConfig holds change feed configuration
+type ChangesConfig struct {
+	DBPath          string        `yaml:"db_path"`
+	Retention       time.Duration `yaml:"retention"`        // changes older than this are dropped, 0 keeps them forever
+	CompactAfter    time.Duration `yaml:"compact_after"`    // changes older than this are dropped once a later change to the same key exists, 0 never compacts
+	CompactInterval time.Duration `yaml:"compact_interval"` // how often retention and compaction are applied
+}
+
+// EncryptionConfig holds server-side encryption configuration
+type EncryptionConfig struct {
+	MasterKey     string `yaml:"master_key"`      // base64 encoded 256-bit key wrapping object data keys
+	MasterKeyFile string `yaml:"master_key_file"` // file holding the master key, read instead of master_key
+	Default       bool   `yaml:"default"`         // encrypt objects stored without encryption headers
+}
+
+// Key returns the master key, or nil when none is configured
+func (e EncryptionConfig) Key() ([]byte, error) {
+	encoded := e.MasterKey
+	if e.MasterKeyFile != "" {
+		data, err := os.ReadFile(e.MasterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption master key file: %w", err)
+		}
+		encoded = strings.TrimSpace(string(data))
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("encryption master key must be 32 bytes encoded as base64")
+	}
+	return key, nil
 }
 
 type S3Config struct {
@@ -49,16 +105,42 @@ type S3Config struct {
 }
 
 type AuthConfig struct {
-	Enabled    bool          `yaml:"enabled"`
-	Driver     string        `yaml:"driver"` // signature, jwt, none
-	JWTSecret  string        `yaml:"jwt_secret"`
-	TokenTTL   time.Duration `yaml:"token_ttl"`
-	DefaultKey struct {
+	Enabled           bool          `yaml:"enabled"`
+	Driver            string        `yaml:"driver"` // signature, jwt, none
+	JWTSecret         string        `yaml:"jwt_secret"`
+	TokenTTL          time.Duration `yaml:"token_ttl"`
+	CredentialsDBPath string        `yaml:"credentials_db_path"` // access keys beyond the default key
+	DefaultKey        struct {
 		AccessKey string `yaml:"access_key"`
 		SecretKey string `yaml:"secret_key"`
 	} `yaml:"default_key"`
 }
 
+const (
+	// defaultJWTSecret is the placeholder secret shipped in the defaults; the
+	// jwt auth driver refuses to start with it
+	defaultJWTSecret = "change-me-in-production"
+	// minJWTSecretLength is the shortest HS256 secret the jwt auth driver accepts
+	minJWTSecretLength = 32
+)
+
+// NotificationsConfig holds bucket event notification configuration
+type NotificationsConfig struct {
+	QueuePath     string          `yaml:"queue_path"`     // events waiting to be delivered, kept across restarts
+	RetryInterval time.Duration   `yaml:"retry_interval"` // delay before the first retry, doubled after each failure
+	MaxAttempts   int             `yaml:"max_attempts"`   // attempts before an event is dropped, 0 retries until delivered
+	Timeout       time.Duration   `yaml:"timeout"`        // per-request webhook timeout
+	Webhooks      []WebhookConfig `yaml:"webhooks"`
+}
+
+// WebhookConfig is an HTTP endpoint that bucket events can be sent to,
+// referenced in notification configurations as arn:8fs:sqs::<id>:webhook
+type WebhookConfig struct {
+	ID        string `yaml:"id"`
+	Endpoint  string `yaml:"endpoint"`
+	AuthToken string `yaml:"auth_token"` // sent as a bearer token when set
+}
+
 type MetricsConfig struct {
 	Enabled              bool          `yaml:"enabled"`
 	Path                 string        `yaml:"path"`
@@ -234,6 +316,7 @@ func loadFromEnv() *Config {
 			WriteTimeout: getEnvOrDefaultDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
 			IdleTimeout:  getEnvOrDefaultDuration("SERVER_IDLE_TIMEOUT", 120*time.Second),
 			Mode:         getEnvOrDefault("SERVER_MODE", "release"),
+			Domain:       getEnvOrDefault("SERVER_DOMAIN", ""),
 		},
 		Storage: StorageConfig{
 			Driver:   getEnvOrDefault("STORAGE_DRIVER", "filesystem"),
@@ -247,12 +330,32 @@ func loadFromEnv() *Config {
 				UseSSL:         getEnvOrDefaultBool("S3_USE_SSL", true),
 				ForcePathStyle: getEnvOrDefaultBool("S3_FORCE_PATH_STYLE", false),
 			},
+			Multipart: MultipartConfig{
+				MinPartSize:   int64(getEnvOrDefaultInt("MULTIPART_MIN_PART_SIZE", 5*1024*1024)),
+				UploadExpiry:  getEnvOrDefaultDuration("MULTIPART_UPLOAD_EXPIRY", 7*24*time.Hour),
+				SweepInterval: getEnvOrDefaultDuration("MULTIPART_SWEEP_INTERVAL", time.Hour),
+			},
+			Lifecycle: LifecycleConfig{
+				SweepInterval: getEnvOrDefaultDuration("LIFECYCLE_SWEEP_INTERVAL", time.Hour),
+			},
+			Encryption: EncryptionConfig{
+				MasterKey:     getEnvOrDefault("STORAGE_ENCRYPTION_MASTER_KEY", ""),
+				MasterKeyFile: getEnvOrDefault("STORAGE_ENCRYPTION_MASTER_KEY_FILE", ""),
+				Default:       getEnvOrDefaultBool("STORAGE_ENCRYPTION_DEFAULT", false),
+			},
+			Changes: ChangesConfig{
+				DBPath:          getEnvOrDefault("CHANGES_DB_PATH", "data/changes.db"),
+				Retention:       getEnvOrDefaultDuration("CHANGES_RETENTION", 7*24*time.Hour),
+				CompactAfter:    getEnvOrDefaultDuration("CHANGES_COMPACT_AFTER", 24*time.Hour),
+				CompactInterval: getEnvOrDefaultDuration("CHANGES_COMPACT_INTERVAL", time.Hour),
+			},
 		},
 		Auth: AuthConfig{
-			Enabled:   determineAuthEnabled(),
-			Driver:    getEnvOrDefault("AUTH_DRIVER", "signature"),
-			JWTSecret: getEnvOrDefault("JWT_SECRET", "change-me-in-production"),
-			TokenTTL:  getEnvOrDefaultDuration("JWT_TOKEN_TTL", 24*time.Hour),
+			Enabled:           determineAuthEnabled(),
+			Driver:            getEnvOrDefault("AUTH_DRIVER", "signature"),
+			JWTSecret:         getEnvOrDefault("JWT_SECRET", defaultJWTSecret),
+			TokenTTL:          getEnvOrDefaultDuration("JWT_TOKEN_TTL", 24*time.Hour),
+			CredentialsDBPath: getEnvOrDefault("CREDENTIALS_DB_PATH", "data/credentials.db"),
 			DefaultKey: struct {
 				AccessKey string `yaml:"access_key"`
 				SecretKey string `yaml:"secret_key"`
@@ -261,6 +364,13 @@ func loadFromEnv() *Config {
 				SecretKey: getEnvOrDefault("DEFAULT_SECRET_KEY", "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"),
 			},
 		},
+		Notifications: NotificationsConfig{
+			QueuePath:     getEnvOrDefault("NOTIFY_QUEUE_PATH", "data/notifications.db"),
+			RetryInterval: getEnvOrDefaultDuration("NOTIFY_RETRY_INTERVAL", 5*time.Second),
+			MaxAttempts:   getEnvOrDefaultInt("NOTIFY_MAX_ATTEMPTS", 0),
+			Timeout:       getEnvOrDefaultDuration("NOTIFY_TIMEOUT", 10*time.Second),
+			Webhooks:      parseWebhooks(os.Getenv("NOTIFY_WEBHOOKS")),
+		},
 		Metrics: MetricsConfig{
 			Enabled:              getEnvOrDefaultBool("METRICS_ENABLED", true),
 			Path:                 getEnvOrDefault("METRICS_PATH", "/metrics"),
@@ -373,6 +483,9 @@ func mergeWithEnv(cfg *Config) {
 	if mode := os.Getenv("SERVER_MODE"); mode != "" {
 		cfg.Server.Mode = mode
 	}
+	if domain := os.Getenv("SERVER_DOMAIN"); domain != "" {
+		cfg.Server.Domain = domain
+	}
 
 	// Storage config
 	if driver := os.Getenv("STORAGE_DRIVER"); driver != "" {
@@ -409,6 +522,63 @@ func mergeWithEnv(cfg *Config) {
 		}
 	}
 
+	// Multipart config
+	if minPartSize := os.Getenv("MULTIPART_MIN_PART_SIZE"); minPartSize != "" {
+		if size, err := strconv.ParseInt(minPartSize, 10, 64); err == nil {
+			cfg.Storage.Multipart.MinPartSize = size
+		}
+	}
+	if expiry := os.Getenv("MULTIPART_UPLOAD_EXPIRY"); expiry != "" {
+		if duration, err := time.ParseDuration(expiry); err == nil {
+			cfg.Storage.Multipart.UploadExpiry = duration
+		}
+	}
+	if interval := os.Getenv("MULTIPART_SWEEP_INTERVAL"); interval != "" {
+		if duration, err := time.ParseDuration(interval); err == nil {
+			cfg.Storage.Multipart.SweepInterval = duration
+		}
+	}
+
+	// Lifecycle config
+	if interval := os.Getenv("LIFECYCLE_SWEEP_INTERVAL"); interval != "" {
+		if duration, err := time.ParseDuration(interval); err == nil {
+			cfg.Storage.Lifecycle.SweepInterval = duration
+		}
+	}
+
+	// Encryption config
+	if masterKey := os.Getenv("STORAGE_ENCRYPTION_MASTER_KEY"); masterKey != "" {
+		cfg.Storage.Encryption.MasterKey = masterKey
+	}
+	if masterKeyFile := os.Getenv("STORAGE_ENCRYPTION_MASTER_KEY_FILE"); masterKeyFile != "" {
+		cfg.Storage.Encryption.MasterKeyFile = masterKeyFile
+	}
+	if encryptDefault := os.Getenv("STORAGE_ENCRYPTION_DEFAULT"); encryptDefault != "" {
+		if defaultBool, err := strconv.ParseBool(encryptDefault); err == nil {
+			cfg.Storage.Encryption.Default = defaultBool
+		}
+	}
+
+	// Change feed config
+	if dbPath := os.Getenv("CHANGES_DB_PATH"); dbPath != "" {
+		cfg.Storage.Changes.DBPath = dbPath
+	}
+	if retention := os.Getenv("CHANGES_RETENTION"); retention != "" {
+		if duration, err := time.ParseDuration(retention); err == nil {
+			cfg.Storage.Changes.Retention = duration
+		}
+	}
+	if compactAfter := os.Getenv("CHANGES_COMPACT_AFTER"); compactAfter != "" {
+		if duration, err := time.ParseDuration(compactAfter); err == nil {
+			cfg.Storage.Changes.CompactAfter = duration
+		}
+	}
+	if interval := os.Getenv("CHANGES_COMPACT_INTERVAL"); interval != "" {
+		if duration, err := time.ParseDuration(interval); err == nil {
+			cfg.Storage.Changes.CompactInterval = duration
+		}
+	}
+
 	// Auth config - use our smart auth detection
 	cfg.Auth.Enabled = determineAuthEnabled()
 	if driver := os.Getenv("AUTH_DRIVER"); driver != "" {
@@ -422,6 +592,9 @@ func mergeWithEnv(cfg *Config) {
 			cfg.Auth.TokenTTL = duration
 		}
 	}
+	if dbPath := os.Getenv("CREDENTIALS_DB_PATH"); dbPath != "" {
+		cfg.Auth.CredentialsDBPath = dbPath
+	}
 	if accessKey := os.Getenv("DEFAULT_ACCESS_KEY"); accessKey != "" {
 		cfg.Auth.DefaultKey.AccessKey = accessKey
 	}
@@ -429,6 +602,29 @@ func mergeWithEnv(cfg *Config) {
 		cfg.Auth.DefaultKey.SecretKey = secretKey
 	}
 
+	// Notifications config
+	if queuePath := os.Getenv("NOTIFY_QUEUE_PATH"); queuePath != "" {
+		cfg.Notifications.QueuePath = queuePath
+	}
+	if interval := os.Getenv("NOTIFY_RETRY_INTERVAL"); interval != "" {
+		if duration, err := time.ParseDuration(interval); err == nil {
+			cfg.Notifications.RetryInterval = duration
+		}
+	}
+	if maxAttempts := os.Getenv("NOTIFY_MAX_ATTEMPTS"); maxAttempts != "" {
+		if attempts, err := strconv.Atoi(maxAttempts); err == nil {
+			cfg.Notifications.MaxAttempts = attempts
+		}
+	}
+	if timeout := os.Getenv("NOTIFY_TIMEOUT"); timeout != "" {
+		if duration, err := time.ParseDuration(timeout); err == nil {
+			cfg.Notifications.Timeout = duration
+		}
+	}
+	if webhooks := os.Getenv("NOTIFY_WEBHOOKS"); webhooks != "" {
+		cfg.Notifications.Webhooks = parseWebhooks(webhooks)
+	}
+
 	// AI config
 	if enabled := os.Getenv("AI_ENABLED"); enabled != "" {
 		if enabledBool, err := strconv.ParseBool(enabled); err == nil {
@@ -622,10 +818,52 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("unsupported storage driver: %s", c.Storage.Driver)
 	}
 
+	masterKey, err := c.Storage.Encryption.Key()
+	if err != nil {
+		return err
+	}
+	if c.Storage.Encryption.Default && masterKey == nil {
+		return fmt.Errorf("default encryption requires an encryption master key")
+	}
+
+	if c.Storage.Changes.Retention < 0 || c.Storage.Changes.CompactAfter < 0 {
+		return fmt.Errorf("change feed retention and compaction age cannot be negative")
+	}
+
 	if c.Auth.Driver != "signature" && c.Auth.Driver != "jwt" && c.Auth.Driver != "none" {
 		return fmt.Errorf("unsupported auth driver: %s", c.Auth.Driver)
 	}
 
+	if c.Auth.Enabled && c.Auth.Driver == "jwt" {
+		if c.Auth.JWTSecret == "" {
+			return fmt.Errorf("jwt_secret is required by the jwt auth driver")
+		}
+		// Tokens signed with a public or guessable secret can be forged
+		if c.Auth.JWTSecret == defaultJWTSecret {
+			return fmt.Errorf("jwt_secret must be changed from the default for the jwt auth driver")
+		}
+		if len(c.Auth.JWTSecret) < minJWTSecretLength {
+			return fmt.Errorf("jwt_secret must be at least %d bytes for the jwt auth driver", minJWTSecretLength)
+		}
+		if c.Auth.TokenTTL <= 0 {
+			return fmt.Errorf("invalid token ttl: %s", c.Auth.TokenTTL)
+		}
+	}
+
+	webhooks := make(map[string]bool)
+	for _, webhook := range c.Notifications.Webhooks {
+		if webhook.ID == "" || strings.Contains(webhook.ID, ":") {
+			return fmt.Errorf("invalid webhook id: %q", webhook.ID)
+		}
+		if webhooks[webhook.ID] {
+			return fmt.Errorf("duplicate webhook id: %s", webhook.ID)
+		}
+		webhooks[webhook.ID] = true
+		if !strings.HasPrefix(webhook.Endpoint, "http://") && !strings.HasPrefix(webhook.Endpoint, "https://") {
+			return fmt.Errorf("webhook %s requires an http or https endpoint", webhook.ID)
+		}
+	}
+
 	if c.Logger.Level != "debug" && c.Logger.Level != "info" && c.Logger.Level != "warn" && c.Logger.Level != "error" {
 		return fmt.Errorf("unsupported log level: %s", c.Logger.Level)
 	}
@@ -642,6 +880,19 @@ func (c *Config) Address() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
 
+// parseWebhooks reads webhooks from a comma-separated list of id=endpoint pairs
+func parseWebhooks(value string) []WebhookConfig {
+	var webhooks []WebhookConfig
+	for _, pair := range strings.Split(value, ",") {
+		id, endpoint, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		webhooks = append(webhooks, WebhookConfig{ID: strings.TrimSpace(id), Endpoint: strings.TrimSpace(endpoint)})
+	}
+	return webhooks
+}
+
 // Helper functions for environment variable parsing
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
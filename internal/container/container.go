@@ -1,31 +1,45 @@
 package container
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/8fs-io/core/internal/config"
 	"github.com/8fs-io/core/internal/domain/ai"
+	"github.com/8fs-io/core/internal/domain/credentials"
 	"github.com/8fs-io/core/internal/domain/indexing"
+	"github.com/8fs-io/core/internal/domain/notifications"
 	"github.com/8fs-io/core/internal/domain/rag"
 	"github.com/8fs-io/core/internal/domain/storage"
 	"github.com/8fs-io/core/internal/domain/vectors"
+	credentialsInfra "github.com/8fs-io/core/internal/infrastructure/credentials"
+	notificationsInfra "github.com/8fs-io/core/internal/infrastructure/notifications"
 	storageInfra "github.com/8fs-io/core/internal/infrastructure/storage"
 	"github.com/8fs-io/core/pkg/logger"
 )
 
 // Container holds all application dependencies
 type Container struct {
-	Config          *config.Config
-	Logger          logger.Logger
-	AuditLogger     *logger.AuditLogger
-	StorageRepo     storage.Repository
-	StorageService  storage.Service
-	Validator       storage.Validator
-	VectorStorage   *vectors.SQLiteVecStorage
-	AIService       ai.Service
-	IndexingService indexing.Service
-	RAGService      rag.Service
+	Config              *config.Config
+	Logger              logger.Logger
+	AuditLogger         *logger.AuditLogger
+	StorageRepo         storage.Repository
+	ChangeLog           storage.ChangeLog
+	StorageService      storage.Service
+	Validator           storage.Validator
+	UploadSweeper       *storage.MultipartSweeper
+	LifecycleWorker     *storage.LifecycleWorker
+	ChangeCompactor     *storage.ChangeCompactor
+	CredentialRepo      credentials.Repository
+	CredentialService   credentials.Service
+	NotificationQueue   notifications.Queue
+	NotificationService notifications.Service
+	VectorStorage       *vectors.SQLiteVecStorage
+	AIService           ai.Service
+	IndexingService     indexing.Service
+	RAGService          rag.Service
 }
 
 // NewContainer creates a new dependency injection container
@@ -70,17 +84,77 @@ func NewContainer(cfg *config.Config) (*Container, error) {
 	}
 
 	// Initialize storage service
-	storageService := storage.NewService(storageRepo, validator, appLogger)
+	storageCfg := storage.DefaultConfig()
+	if cfg.Storage.Multipart.MinPartSize > 0 {
+		storageCfg.MinPartSize = cfg.Storage.Multipart.MinPartSize
+	}
+	storageCfg.MasterKey, err = cfg.Storage.Encryption.Key()
+	if err != nil {
+		return nil, err
+	}
+	storageCfg.EncryptByDefault = cfg.Storage.Encryption.Default
+
+	// Initialize the change log recording every change to objects
+	changeLog, err := storageInfra.NewSQLiteChangeLog(cfg.Storage.Changes.DBPath, appLogger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize change log: %w", err)
+	}
+	storageService := storage.NewService(storageRepo, changeLog, validator, appLogger, storageCfg)
+
+	// Initialize the sweeper for abandoned multipart uploads
+	uploadSweeper := storage.NewMultipartSweeper(storageService,
+		cfg.Storage.Multipart.SweepInterval, cfg.Storage.Multipart.UploadExpiry, appLogger)
+
+	// Initialize the compactor that keeps the change log from growing forever
+	changeCompactor := storage.NewChangeCompactor(storageService, cfg.Storage.Changes.CompactInterval,
+		cfg.Storage.Changes.Retention, cfg.Storage.Changes.CompactAfter, appLogger)
+
+	// Initialize the credential store holding access keys beyond the default key
+	credentialRepo, err := credentialsInfra.NewSQLiteRepository(cfg.Auth.CredentialsDBPath, appLogger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize credential store: %w", err)
+	}
+
+	// Initialize the queue of bucket event notifications waiting for delivery
+	notificationQueue, err := notificationsInfra.NewSQLiteQueue(cfg.Notifications.QueuePath, appLogger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize notification queue: %w", err)
+	}
+	notificationCfg := &notifications.Config{
+		Region:        cfg.Storage.S3Config.Region,
+		RetryInterval: cfg.Notifications.RetryInterval,
+		MaxAttempts:   cfg.Notifications.MaxAttempts,
+		Timeout:       cfg.Notifications.Timeout,
+	}
+	for _, webhook := range cfg.Notifications.Webhooks {
+		notificationCfg.Targets = append(notificationCfg.Targets, notifications.Target{
+			ID:        webhook.ID,
+			Endpoint:  webhook.Endpoint,
+			AuthToken: webhook.AuthToken,
+		})
+	}
 
 	c := &Container{
-		Config:         cfg,
-		Logger:         appLogger,
-		AuditLogger:    auditLogger,
-		StorageRepo:    storageRepo,
-		StorageService: storageService,
-		Validator:      validator,
+		Config:              cfg,
+		Logger:              appLogger,
+		AuditLogger:         auditLogger,
+		StorageRepo:         storageRepo,
+		ChangeLog:           changeLog,
+		StorageService:      storageService,
+		Validator:           validator,
+		UploadSweeper:       uploadSweeper,
+		ChangeCompactor:     changeCompactor,
+		CredentialRepo:      credentialRepo,
+		CredentialService:   credentials.NewService(credentialRepo, appLogger),
+		NotificationQueue:   notificationQueue,
+		NotificationService: notifications.NewService(storageRepo, validator, notificationQueue, notificationCfg, appLogger),
 	}
 
+	// Initialize the lifecycle worker, which drops the embeddings of the
+	// objects it expires
+	c.LifecycleWorker = storage.NewLifecycleWorker(storageService,
+		cfg.Storage.Lifecycle.SweepInterval, c.deleteExpiredVectors, appLogger)
+
 	// Initialize vector storage if enabled
 	if cfg.Vector.Enabled {
 		vecCfg := vectors.SQLiteVecConfig{Path: cfg.Vector.DBPath, Dimension: cfg.Vector.Dimension}
@@ -109,7 +183,7 @@ func NewContainer(cfg *config.Config) (*Container, error) {
 				CleanupAfter:  cfg.Indexing.CleanupAfter,
 				StatusEnabled: cfg.Indexing.StatusEnabled,
 			}
-			c.IndexingService = indexing.NewService(indexingCfg, c.AIService, appLogger)
+			c.IndexingService = indexing.NewService(indexingCfg, c.AIService, c.notifyIndexed, appLogger)
 
 			// Initialize RAG service
 			ragConfig := &rag.Config{
@@ -127,6 +201,40 @@ func NewContainer(cfg *config.Config) (*Container, error) {
 	return c, nil
 }
 
+// deleteExpiredVectors removes the vector embeddings of an expired object
+func (c *Container) deleteExpiredVectors(ctx context.Context, object storage.ExpiredObject) {
+	if c.AIService == nil {
+		return
+	}
+
+	objectID := fmt.Sprintf("%s/%s", object.Bucket, object.Key)
+	if err := c.AIService.DeleteDocument(ctx, objectID); err != nil {
+		c.Logger.Warn("Failed to delete vectors for expired object", "object_id", objectID, "error", err)
+		return
+	}
+	c.Logger.Info("Vectors deleted for expired object", "object_id", objectID)
+}
+
+// notifyIndexed sends the bucket notifications of an object whose vector
+// embeddings were stored
+func (c *Container) notifyIndexed(ctx context.Context, job indexing.IndexingJob) {
+	bucket, key, ok := strings.Cut(job.ObjectID, "/")
+	if !ok {
+		return
+	}
+
+	event := notifications.ObjectEvent{
+		Name:   notifications.EventObjectIndexedCompleted,
+		Bucket: bucket,
+		Key:    key,
+		Time:   time.Now(),
+	}
+	if size, ok := job.Metadata["size"].(int64); ok {
+		event.Size = size
+	}
+	c.NotificationService.Notify(ctx, event)
+}
+
 // initAIService creates an AI service based on the configured provider
 func initAIService(cfg *config.Config, vectorStorage *vectors.SQLiteVecStorage, logger logger.Logger) (ai.Service, error) {
 	if !cfg.AI.Enabled {
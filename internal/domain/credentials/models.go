@@ -0,0 +1,116 @@
+package credentials
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/policy"
+)
+
+// Credential statuses
+const (
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+)
+
+// Credential roles. Admin keys may manage other keys.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Credential is an access key pair together with the scope it is allowed to
+// act in. An empty Buckets or Actions list places no restriction.
+type Credential struct {
+	AccessKey   string     `json:"access_key"`
+	SecretKey   string     `json:"secret_key,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Buckets     []string   `json:"buckets,omitempty"`
+	Actions     []string   `json:"actions,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// IsAdmin reports whether the credential has the admin role
+func (c *Credential) IsAdmin() bool {
+	return c.Role == RoleAdmin
+}
+
+// Redacted returns a copy of the credential without its secret key
+func (c *Credential) Redacted() *Credential {
+	redacted := *c
+	redacted.SecretKey = ""
+	return &redacted
+}
+
+// Expired reports whether the credential has expired as of now
+func (c *Credential) Expired(now time.Time) bool {
+	return c.ExpiresAt != nil && !now.Before(*c.ExpiresAt)
+}
+
+// Allows reports whether the credential may perform action on bucket. Bucket
+// names and actions may use the * and ? wildcards, actions matching without
+// regard to case.
+func (c *Credential) Allows(bucket, action string) bool {
+	return c.AllowsBucket(bucket) && c.AllowsAction(action)
+}
+
+// AllowsBucket reports whether the credential may act on bucket
+func (c *Credential) AllowsBucket(bucket string) bool {
+	return allowedBy(c.Buckets, bucket, false)
+}
+
+// AllowsAction reports whether the credential may perform action
+func (c *Credential) AllowsAction(action string) bool {
+	return allowedBy(c.Actions, action, true)
+}
+
+// allowedBy reports whether value matches one of patterns, or whether there
+// are no patterns at all
+func allowedBy(patterns []string, value string, foldCase bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if foldCase && policy.Match(strings.ToLower(pattern), strings.ToLower(value)) {
+			return true
+		}
+		if !foldCase && policy.Match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository defines the credential store interface
+type Repository interface {
+	CreateCredential(ctx context.Context, credential *Credential) error
+	GetCredential(ctx context.Context, accessKey string) (*Credential, error)
+	ListCredentials(ctx context.Context) ([]*Credential, error)
+	UpdateCredential(ctx context.Context, credential *Credential) error
+	DeleteCredential(ctx context.Context, accessKey string) error
+	Close() error
+}
+
+// Service defines the credential service interface
+type Service interface {
+	// Authenticate returns the credential of an access key if it is active
+	// and has not expired
+	Authenticate(ctx context.Context, accessKey string) (*Credential, error)
+
+	// CreateCredential stores a new credential, generating the access key
+	// and secret key when they are not given
+	CreateCredential(ctx context.Context, credential *Credential) (*Credential, error)
+	GetCredential(ctx context.Context, accessKey string) (*Credential, error)
+	ListCredentials(ctx context.Context) ([]*Credential, error)
+	DeleteCredential(ctx context.Context, accessKey string) error
+
+	// RotateCredential replaces the secret key of a credential and returns
+	// the credential with its new secret
+	RotateCredential(ctx context.Context, accessKey string) (*Credential, error)
+	SetCredentialStatus(ctx context.Context, accessKey, status string) (*Credential, error)
+}
@@ -0,0 +1,214 @@
+package credentials
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/logger"
+)
+
+var (
+	accessKeyPattern = regexp.MustCompile(`^[A-Z0-9]{16,128}$`)
+	secretKeyPattern = regexp.MustCompile(`^[A-Za-z0-9/+=]{16,128}$`)
+)
+
+// service implements the Service interface
+type service struct {
+	repo   Repository
+	logger logger.Logger
+	now    func() time.Time
+}
+
+// NewService creates a new credential service
+func NewService(repo Repository, logger logger.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Authenticate returns the credential of an access key if it can be used
+func (s *service) Authenticate(ctx context.Context, accessKey string) (*Credential, error) {
+	credential, err := s.repo.GetCredential(ctx, accessKey)
+	if err != nil {
+		if errors.IsErrorCode(err, errors.ErrCodeAccessKeyNotFound) {
+			return nil, errors.New(errors.ErrCodeInvalidCredentials, "Invalid access key")
+		}
+		return nil, err
+	}
+
+	if credential.Status != StatusActive {
+		return nil, errors.New(errors.ErrCodeInvalidCredentials, "Access key is disabled")
+	}
+	if credential.Expired(s.now()) {
+		return nil, errors.New(errors.ErrCodeInvalidCredentials, "Access key has expired")
+	}
+	return credential, nil
+}
+
+// CreateCredential validates and stores a new credential. New credentials
+// are active ordinary keys unless a status or role is given.
+func (s *service) CreateCredential(ctx context.Context, credential *Credential) (*Credential, error) {
+	if credential.Status == "" {
+		credential.Status = StatusActive
+	}
+	if credential.Role == "" {
+		credential.Role = RoleUser
+	}
+	if credential.AccessKey == "" {
+		accessKey, err := generateAccessKey()
+		if err != nil {
+			return nil, err
+		}
+		credential.AccessKey = accessKey
+	}
+	if credential.SecretKey == "" {
+		secretKey, err := generateSecretKey()
+		if err != nil {
+			return nil, err
+		}
+		credential.SecretKey = secretKey
+	}
+	if err := validateCredential(credential); err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	credential.CreatedAt = now
+	credential.UpdatedAt = now
+
+	if err := s.repo.CreateCredential(ctx, credential); err != nil {
+		if !errors.IsErrorCode(err, errors.ErrCodeAccessKeyExists) {
+			s.logger.Error("Failed to store credential", "access_key", credential.AccessKey, "error", err)
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Credential created", "access_key", credential.AccessKey)
+	return credential, nil
+}
+
+// GetCredential returns a stored credential
+func (s *service) GetCredential(ctx context.Context, accessKey string) (*Credential, error) {
+	return s.repo.GetCredential(ctx, accessKey)
+}
+
+// ListCredentials returns all stored credentials ordered by access key
+func (s *service) ListCredentials(ctx context.Context) ([]*Credential, error) {
+	return s.repo.ListCredentials(ctx)
+}
+
+// DeleteCredential removes a stored credential
+func (s *service) DeleteCredential(ctx context.Context, accessKey string) error {
+	if err := s.repo.DeleteCredential(ctx, accessKey); err != nil {
+		return err
+	}
+
+	s.logger.Info("Credential deleted", "access_key", accessKey)
+	return nil
+}
+
+// RotateCredential replaces the secret key of a credential
+func (s *service) RotateCredential(ctx context.Context, accessKey string) (*Credential, error) {
+	secretKey, err := generateSecretKey()
+	if err != nil {
+		return nil, err
+	}
+	credential, err := s.updateCredential(ctx, accessKey, func(credential *Credential) {
+		credential.SecretKey = secretKey
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Credential rotated", "access_key", accessKey)
+	return credential, nil
+}
+
+// SetCredentialStatus enables or disables a credential
+func (s *service) SetCredentialStatus(ctx context.Context, accessKey, status string) (*Credential, error) {
+	if status != StatusActive && status != StatusDisabled {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Status must be active or disabled").
+			WithContext("status", status)
+	}
+	credential, err := s.updateCredential(ctx, accessKey, func(credential *Credential) {
+		credential.Status = status
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Credential status changed", "access_key", accessKey, "status", status)
+	return credential, nil
+}
+
+// updateCredential applies change to a stored credential and stores it back
+func (s *service) updateCredential(ctx context.Context, accessKey string, change func(*Credential)) (*Credential, error) {
+	credential, err := s.repo.GetCredential(ctx, accessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	change(credential)
+	credential.UpdatedAt = s.now().UTC()
+	if err := s.repo.UpdateCredential(ctx, credential); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// generateAccessKey returns a random access key in the AWS format
+func generateAccessKey() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(errors.ErrCodeInternalError, "Failed to generate access key", err)
+	}
+	return "AKIA" + base32.StdEncoding.EncodeToString(b)[:16], nil
+}
+
+// generateSecretKey returns a random 40 character secret key
+func generateSecretKey() (string, error) {
+	b := make([]byte, 30)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(errors.ErrCodeInternalError, "Failed to generate secret key", err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// validateCredential checks the fields of a credential
+func validateCredential(credential *Credential) error {
+	if !accessKeyPattern.MatchString(credential.AccessKey) {
+		return errors.New(errors.ErrCodeInvalidParameter, "Access keys must be 16 to 128 uppercase letters and digits").
+			WithContext("access_key", credential.AccessKey)
+	}
+	if !secretKeyPattern.MatchString(credential.SecretKey) {
+		return errors.New(errors.ErrCodeInvalidParameter, "Secret keys must be 16 to 128 base64 characters")
+	}
+	if credential.Role != RoleUser && credential.Role != RoleAdmin {
+		return errors.New(errors.ErrCodeInvalidParameter, "Role must be user or admin").
+			WithContext("role", credential.Role)
+	}
+	if credential.Status != StatusActive && credential.Status != StatusDisabled {
+		return errors.New(errors.ErrCodeInvalidParameter, "Status must be active or disabled").
+			WithContext("status", credential.Status)
+	}
+	for _, bucket := range credential.Buckets {
+		if bucket == "" {
+			return errors.New(errors.ErrCodeInvalidParameter, "Bucket patterns cannot be empty")
+		}
+	}
+	for _, action := range credential.Actions {
+		if action != "*" && !strings.HasPrefix(strings.ToLower(action), "s3:") {
+			return errors.New(errors.ErrCodeInvalidParameter, "Actions must be * or start with s3:").
+				WithContext("action", action)
+		}
+	}
+	return nil
+}
@@ -93,9 +93,10 @@ type Stats struct {
 
 // service implements Service interface
 type service struct {
-	config    *Config
-	aiService ai.Service
-	logger    logger.Logger
+	config     *Config
+	aiService  ai.Service
+	onComplete func(context.Context, IndexingJob)
+	logger     logger.Logger
 
 	// Job management
 	jobs      map[string]*IndexingJob
@@ -119,18 +120,20 @@ type worker struct {
 	service *service
 }
 
-// NewService creates a new async indexing service
-func NewService(config *Config, aiService ai.Service, logger logger.Logger) Service {
+// NewService creates a new async indexing service. onComplete, if not nil,
+// is called with each job whose document was stored.
+func NewService(config *Config, aiService ai.Service, onComplete func(context.Context, IndexingJob), logger logger.Logger) Service {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
 	return &service{
-		config:    config,
-		aiService: aiService,
-		logger:    logger,
-		jobs:      make(map[string]*IndexingJob),
-		jobQueue:  make(chan *IndexingJob, config.QueueSize),
+		config:     config,
+		aiService:  aiService,
+		onComplete: onComplete,
+		logger:     logger,
+		jobs:       make(map[string]*IndexingJob),
+		jobQueue:   make(chan *IndexingJob, config.QueueSize),
 		stats: &Stats{
 			LastProcessed: time.Now(),
 		},
@@ -155,6 +158,9 @@ func (s *service) SubmitJob(ctx context.Context, objectID, text string, metadata
 			CreatedAt:   time.Now(),
 			CompletedAt: &[]time.Time{time.Now()}[0],
 		}
+		if s.onComplete != nil {
+			s.onComplete(ctx, *job)
+		}
 
 		return job, nil
 	}
@@ -316,6 +322,14 @@ func (w *worker) processJob(ctx context.Context, job *IndexingJob) {
 	// Process the document
 	err := w.service.aiService.ProcessAndStoreDocument(ctx, job.ObjectID, job.Text, job.Metadata)
 
+	// Report completion once the job lock is released
+	var completed *IndexingJob
+	defer func() {
+		if completed != nil && w.service.onComplete != nil {
+			w.service.onComplete(ctx, *completed)
+		}
+	}()
+
 	w.service.jobsMutex.Lock()
 	defer w.service.jobsMutex.Unlock()
 
@@ -357,6 +371,8 @@ func (w *worker) processJob(ctx context.Context, job *IndexingJob) {
 		// Success
 		job.Status = JobStatusCompleted
 		job.CompletedAt = &now
+		snapshot := *job
+		completed = &snapshot
 		w.service.logger.Info("Indexing job completed successfully", "worker_id", w.id, "job_id", job.ID, "object_id", job.ObjectID)
 
 		w.service.updateStats(func(stats *Stats) {
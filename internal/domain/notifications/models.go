@@ -0,0 +1,191 @@
+package notifications
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Event types of bucket notifications. A configuration subscribes to every
+// event of a kind with a trailing wildcard, e.g. s3:ObjectCreated:*.
+const (
+	EventObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	EventObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	EventObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	EventObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	EventObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+
+	// EventObjectIndexedCompleted is an 8fs extension sent once the vector
+	// embeddings of an object are stored
+	EventObjectIndexedCompleted = "s3:ObjectIndexed:Completed"
+)
+
+// eventTypes lists the events a configuration can subscribe to
+var eventTypes = []string{
+	EventObjectCreatedPut,
+	EventObjectCreatedCopy,
+	EventObjectCreatedCompleteMultipartUpload,
+	EventObjectRemovedDelete,
+	EventObjectRemovedDeleteMarkerCreated,
+	EventObjectIndexedCompleted,
+}
+
+// Configuration holds the notification rules of a bucket. An event is sent
+// once for every rule it matches.
+type Configuration struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule sends the events it subscribes to, for keys that match its prefix and
+// suffix, to a webhook target
+type Rule struct {
+	ID     string   `json:"id"`
+	Target string   `json:"target"` // ARN of the webhook
+	Events []string `json:"events"`
+	Prefix string   `json:"prefix,omitempty"`
+	Suffix string   `json:"suffix,omitempty"`
+}
+
+// Matches reports whether the rule subscribes to event for key
+func (r Rule) Matches(event, key string) bool {
+	if !strings.HasPrefix(key, r.Prefix) || !strings.HasSuffix(key, r.Suffix) {
+		return false
+	}
+	for _, pattern := range r.Events {
+		if pattern == event || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(event, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// ObjectEvent is a change to an object that notifications are sent for
+type ObjectEvent struct {
+	Name      string // one of the event types
+	Bucket    string
+	Key       string
+	Size      int64
+	ETag      string
+	VersionID string
+	Principal string // access key of the requester, if any
+	SourceIP  string
+	RequestID string
+	Time      time.Time
+}
+
+// Message is the body POSTed to webhooks, in the structure of S3 event
+// notification messages
+type Message struct {
+	Records []Record `json:"Records"`
+}
+
+// Record describes a single event
+type Record struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AWSRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"` // the event type without its s3: prefix
+	UserIdentity      Identity          `json:"userIdentity"`
+	RequestParameters RequestParameters `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                S3Entity          `json:"s3"`
+}
+
+type Identity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+type RequestParameters struct {
+	SourceIPAddress string `json:"sourceIPAddress"`
+}
+
+type S3Entity struct {
+	SchemaVersion   string       `json:"s3SchemaVersion"`
+	ConfigurationID string       `json:"configurationId"` // ID of the rule the event matched
+	Bucket          BucketEntity `json:"bucket"`
+	Object          ObjectEntity `json:"object"`
+}
+
+type BucketEntity struct {
+	Name string `json:"name"`
+	ARN  string `json:"arn"`
+}
+
+type ObjectEntity struct {
+	Key       string `json:"key"` // URL encoded
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"` // orders the events of a key
+}
+
+// Target is a webhook that messages are delivered to
+type Target struct {
+	ID        string
+	Endpoint  string
+	AuthToken string // sent as a bearer token when set
+}
+
+// ARN returns the name that notification rules refer to the target by
+func (t Target) ARN() string {
+	return "arn:8fs:sqs::" + t.ID + ":webhook"
+}
+
+// Delivery is a message waiting in the queue to be accepted by its target
+type Delivery struct {
+	ID          int64
+	Target      string // ARN of the webhook
+	Payload     []byte // JSON encoded Message
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+	CreatedAt   time.Time
+}
+
+// Queue persists deliveries until they succeed, so that events survive
+// restarts and webhook outages
+type Queue interface {
+	Enqueue(ctx context.Context, delivery *Delivery) error
+	// Due returns up to limit deliveries to attempt at now, oldest first.
+	// A webhook's deliveries are only due once all of its older ones are, so
+	// that each webhook receives its messages in order.
+	Due(ctx context.Context, now time.Time, limit int) ([]*Delivery, error)
+	// Reschedule stores the attempts, next attempt and last error of a delivery
+	Reschedule(ctx context.Context, delivery *Delivery) error
+	Delete(ctx context.Context, id int64) error
+	Close() error
+}
+
+// Config holds bucket notification configuration
+type Config struct {
+	Region        string
+	Targets       []Target
+	RetryInterval time.Duration // delay before the first retry, doubled after each failure
+	MaxAttempts   int           // attempts before a delivery is dropped, 0 retries until delivered
+	Timeout       time.Duration // per-request webhook timeout
+}
+
+// Service manages the notification configuration of buckets and delivers
+// their events to webhooks
+type Service interface {
+	// PutBucketNotification replaces the notification configuration of a
+	// bucket; a configuration without rules turns notifications off
+	PutBucketNotification(ctx context.Context, bucket string, config *Configuration) error
+	// GetBucketNotification returns the notification configuration of a
+	// bucket, which has no rules if none was set
+	GetBucketNotification(ctx context.Context, bucket string) (*Configuration, error)
+
+	// Notify queues a message for each rule of the bucket that matches the
+	// event. Failures are logged rather than returned, as the change the
+	// event describes has already happened.
+	Notify(ctx context.Context, event ObjectEvent)
+	// Dispatch attempts every delivery that is due now and returns how many
+	// were accepted
+	Dispatch(ctx context.Context) (int, error)
+
+	// Start starts delivering queued messages in the background
+	Start(ctx context.Context) error
+	// Stop stops background delivery and waits for it to exit
+	Stop() error
+}
@@ -0,0 +1,484 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// bucketConfigNotification names the stored notification configuration of a bucket
+const bucketConfigNotification = "notification"
+
+// maxNotificationRules is the maximum number of rules in a notification configuration
+const maxNotificationRules = 100
+
+// dispatchBatchSize is how many deliveries are read from the queue at a time
+const dispatchBatchSize = 100
+
+// maxConcurrentTargets is how many webhooks are delivered to at the same time
+const maxConcurrentTargets = 8
+
+// maxRetryInterval caps the delay between attempts to deliver a message
+const maxRetryInterval = time.Hour
+
+// service implements Service over the bucket configuration store of the
+// storage repository and a persistent delivery queue
+type service struct {
+	repo      storage.Repository
+	validator storage.Validator
+	queue     Queue
+	config    *Config
+	targets   map[string]Target // by ID
+	client    *http.Client
+	logger    logger.Logger
+
+	wake   chan struct{}
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewService creates a new notification service
+func NewService(repo storage.Repository, validator storage.Validator, queue Queue, config *Config, logger logger.Logger) Service {
+	targets := make(map[string]Target, len(config.Targets))
+	for _, target := range config.Targets {
+		targets[target.ID] = target
+	}
+
+	return &service{
+		repo:      repo,
+		validator: validator,
+		queue:     queue,
+		config:    config,
+		targets:   targets,
+		client:    &http.Client{Timeout: config.Timeout},
+		logger:    logger,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// PutBucketNotification replaces the notification configuration of a bucket
+func (s *service) PutBucketNotification(ctx context.Context, bucket string, config *Configuration) error {
+	if err := s.validate(config); err != nil {
+		return err
+	}
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	if len(config.Rules) == 0 {
+		if err := s.repo.DeleteBucketConfig(ctx, bucket, bucketConfigNotification); err != nil {
+			s.logger.Error("Failed to delete bucket notification configuration", "bucket", bucket, "error", err)
+			return errors.Wrap(errors.ErrCodeInternalError, "Failed to delete bucket notification configuration", err)
+		}
+		s.logger.Info("Bucket notification configuration deleted", "bucket", bucket)
+		return nil
+	}
+
+	if err := s.repo.PutBucketConfig(ctx, bucket, bucketConfigNotification, config); err != nil {
+		s.logger.Error("Failed to store bucket notification configuration", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to store bucket notification configuration", err)
+	}
+
+	s.logger.Info("Bucket notification configuration updated", "bucket", bucket, "rules", len(config.Rules))
+	return nil
+}
+
+// GetBucketNotification returns the notification configuration of a bucket
+func (s *service) GetBucketNotification(ctx context.Context, bucket string) (*Configuration, error) {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	config, err := s.bucketNotification(ctx, bucket)
+	if err != nil {
+		s.logger.Error("Failed to read bucket notification configuration", "bucket", bucket, "error", err)
+		return nil, err
+	}
+	return config, nil
+}
+
+// Notify queues a message for each rule of the bucket that matches the event
+func (s *service) Notify(ctx context.Context, event ObjectEvent) {
+	if s.validator.ValidateBucketName(event.Bucket) != nil {
+		return
+	}
+	config, err := s.bucketNotification(ctx, event.Bucket)
+	if err != nil {
+		s.logger.Warn("Failed to read bucket notification configuration", "bucket", event.Bucket, "error", err)
+		return
+	}
+
+	queued := false
+	for _, rule := range config.Rules {
+		if !rule.Matches(event.Name, event.Key) {
+			continue
+		}
+
+		payload, err := json.Marshal(Message{Records: []Record{s.record(event, rule.ID)}})
+		if err != nil {
+			s.logger.Error("Failed to encode event notification", "bucket", event.Bucket, "key", event.Key, "error", err)
+			return
+		}
+		now := time.Now()
+		delivery := &Delivery{Target: rule.Target, Payload: payload, NextAttempt: now, CreatedAt: now}
+		if err := s.queue.Enqueue(ctx, delivery); err != nil {
+			s.logger.Error("Failed to queue event notification", "bucket", event.Bucket, "key", event.Key, "event", event.Name, "target", rule.Target, "error", err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dispatch attempts every delivery that is due now
+func (s *service) Dispatch(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		due, err := s.queue.Due(ctx, time.Now(), dispatchBatchSize)
+		if err != nil {
+			return delivered, errors.Wrap(errors.ErrCodeInternalError, "Failed to read notification queue", err)
+		}
+
+		n, err := s.dispatchBatch(ctx, due)
+		delivered += n
+		if err != nil {
+			return delivered, errors.Wrap(errors.ErrCodeInternalError, "Failed to update notification queue", err)
+		}
+
+		// Failed deliveries are rescheduled into the future and hold back the
+		// rest of their webhook's, so a full batch means more deliveries are due
+		if len(due) < dispatchBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// dispatchBatch attempts a batch of due deliveries. Each webhook is sent its
+// messages in order by a worker of its own, so that a slow or unreachable
+// webhook does not hold up the others. It returns the number delivered and
+// the first queue error.
+func (s *service) dispatchBatch(ctx context.Context, due []*Delivery) (int, error) {
+	var targets []string
+	byTarget := make(map[string][]*Delivery)
+	for _, delivery := range due {
+		if _, ok := byTarget[delivery.Target]; !ok {
+			targets = append(targets, delivery.Target)
+		}
+		byTarget[delivery.Target] = append(byTarget[delivery.Target], delivery)
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		delivered int
+		firstErr  error
+	)
+	slots := make(chan struct{}, maxConcurrentTargets)
+	for _, target := range targets {
+		deliveries := byTarget[target]
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			n, err := s.deliverTarget(ctx, deliveries)
+			mu.Lock()
+			defer mu.Unlock()
+			delivered += n
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+	wg.Wait()
+	return delivered, firstErr
+}
+
+// deliverTarget sends the due messages of one webhook in order. Once one
+// fails the rest are left in the queue, rather than each waiting out the
+// webhook's timeout; the queue holds them back until the failed one is due
+// again, and their attempts are left unchanged.
+func (s *service) deliverTarget(ctx context.Context, deliveries []*Delivery) (int, error) {
+	delivered := 0
+	for _, delivery := range deliveries {
+		ok, err := s.deliver(ctx, delivery)
+		if err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+			continue
+		}
+		if _, configured := s.target(delivery.Target); !configured {
+			continue
+		}
+		return delivered, nil
+	}
+	return delivered, nil
+}
+
+// Start starts the background delivery loop, which first sends the messages
+// left in the queue by a previous run
+func (s *service) Start(ctx context.Context) error {
+	if s.config.RetryInterval <= 0 {
+		return fmt.Errorf("notification delivery requires a positive retry interval")
+	}
+	if s.stopCh != nil {
+		return fmt.Errorf("notification delivery already started")
+	}
+
+	s.stopCh = make(chan struct{})
+	s.wg.Add(1)
+	go s.run(ctx)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	s.logger.Info("Notification delivery started", "webhooks", len(s.targets), "retry_interval", s.config.RetryInterval)
+	return nil
+}
+
+// Stop stops the background delivery loop and waits for it to exit
+func (s *service) Stop() error {
+	if s.stopCh == nil {
+		return nil
+	}
+	close(s.stopCh)
+	s.wg.Wait()
+	s.stopCh = nil
+	return nil
+}
+
+func (s *service) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+
+		if delivered, err := s.Dispatch(ctx); err != nil {
+			s.logger.Warn("Notification delivery failed", "error", err)
+		} else if delivered > 0 {
+			s.logger.Debug("Notifications delivered", "delivered", delivered)
+		}
+	}
+}
+
+// deliver sends a queued message to its webhook, removing it from the queue
+// once accepted and rescheduling it otherwise. It reports whether the webhook
+// accepted the message; the error is that of the queue.
+func (s *service) deliver(ctx context.Context, delivery *Delivery) (bool, error) {
+	target, ok := s.target(delivery.Target)
+	if !ok {
+		s.logger.Warn("Dropping event notification for a webhook that is no longer configured", "target", delivery.Target, "id", delivery.ID)
+		return false, s.queue.Delete(ctx, delivery.ID)
+	}
+
+	err := s.post(ctx, target, delivery.Payload)
+	if err == nil {
+		return true, s.queue.Delete(ctx, delivery.ID)
+	}
+
+	delivery.Attempts++
+	delivery.LastError = err.Error()
+	if s.config.MaxAttempts > 0 && delivery.Attempts >= s.config.MaxAttempts {
+		s.logger.Error("Dropping event notification after repeated failures", "target", delivery.Target, "id", delivery.ID, "attempts", delivery.Attempts, "error", err)
+		return false, s.queue.Delete(ctx, delivery.ID)
+	}
+
+	delivery.NextAttempt = time.Now().Add(s.retryInterval(delivery.Attempts))
+	s.logger.Warn("Event notification delivery failed, retrying", "target", delivery.Target, "id", delivery.ID, "attempts", delivery.Attempts, "next_attempt", delivery.NextAttempt, "error", err)
+	return false, s.queue.Reschedule(ctx, delivery)
+}
+
+// post sends a message to a webhook, which accepts it with any 2xx response
+func (s *service) post(ctx context.Context, target Target, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if target.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+target.AuthToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// retryInterval returns the delay before the next attempt of a delivery
+// that failed attempts times
+func (s *service) retryInterval(attempts int) time.Duration {
+	interval := s.config.RetryInterval
+	for i := 1; i < attempts && interval < maxRetryInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxRetryInterval {
+		interval = maxRetryInterval
+	}
+	return interval
+}
+
+// record builds the message record of an event matched by a rule
+func (s *service) record(event ObjectEvent, ruleID string) Record {
+	// Keys are URL encoded like form values, keeping their slashes
+	key := strings.ReplaceAll(url.QueryEscape(event.Key), "%2F", "/")
+
+	return Record{
+		EventVersion:      "2.1",
+		EventSource:       "aws:s3",
+		AWSRegion:         s.config.Region,
+		EventTime:         event.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		EventName:         strings.TrimPrefix(event.Name, "s3:"),
+		UserIdentity:      Identity{PrincipalID: event.Principal},
+		RequestParameters: RequestParameters{SourceIPAddress: event.SourceIP},
+		ResponseElements:  map[string]string{"x-amz-request-id": event.RequestID},
+		S3: S3Entity{
+			SchemaVersion:   "1.0",
+			ConfigurationID: ruleID,
+			Bucket:          BucketEntity{Name: event.Bucket, ARN: "arn:aws:s3:::" + event.Bucket},
+			Object: ObjectEntity{
+				Key:       key,
+				Size:      event.Size,
+				ETag:      strings.Trim(event.ETag, `"`),
+				VersionID: event.VersionID,
+				Sequencer: fmt.Sprintf("%016X", event.Time.UnixNano()),
+			},
+		},
+	}
+}
+
+// bucketNotification returns the notification configuration of a bucket
+func (s *service) bucketNotification(ctx context.Context, bucket string) (*Configuration, error) {
+	var config Configuration
+	if _, err := s.repo.GetBucketConfig(ctx, bucket, bucketConfigNotification, &config); err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read bucket notification configuration", err)
+	}
+	return &config, nil
+}
+
+// requireBucket checks that a bucket exists
+func (s *service) requireBucket(ctx context.Context, bucket string) error {
+	if err := s.validator.ValidateBucketName(bucket); err != nil {
+		return err
+	}
+
+	exists, err := s.repo.BucketExists(ctx, bucket)
+	if err != nil {
+		s.logger.Error("Failed to check bucket existence", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to check bucket existence", err)
+	}
+	if !exists {
+		return errors.ErrBucketNotFound.WithContext("bucket", bucket)
+	}
+	return nil
+}
+
+// target returns the configured webhook an ARN refers to. The region and
+// account fields of the ARN are ignored, as the AWS SDKs may fill them in.
+func (s *service) target(arn string) (Target, bool) {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[1] != "8fs" || parts[2] != "sqs" || parts[5] != "webhook" {
+		return Target{}, false
+	}
+	target, ok := s.targets[parts[4]]
+	return target, ok
+}
+
+// validate checks a notification configuration supplied by a client, filling
+// in missing rule IDs and normalizing target ARNs
+func (s *service) validate(config *Configuration) error {
+	if config == nil {
+		return errors.New(errors.ErrCodeMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	if len(config.Rules) > maxNotificationRules {
+		return errors.New(errors.ErrCodeInvalidParameter, "Notification configuration cannot have more than 100 rules").
+			WithContext("rules", len(config.Rules))
+	}
+
+	ids := make(map[string]bool, len(config.Rules))
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.ID == "" {
+			rule.ID = uuid.New().String()
+		}
+		if ids[rule.ID] {
+			return errors.New(errors.ErrCodeInvalidParameter, "Notification rule IDs must be unique").
+				WithContext("id", rule.ID)
+		}
+		ids[rule.ID] = true
+
+		target, ok := s.target(rule.Target)
+		if !ok {
+			return errors.New(errors.ErrCodeInvalidParameter, "A specified destination ARN does not exist or is not well-formed. Verify the destination ARN.").
+				WithContext("target", rule.Target)
+		}
+		rule.Target = target.ARN()
+
+		if len(rule.Events) == 0 {
+			return errors.New(errors.ErrCodeInvalidParameter, "A notification rule must specify at least one event").
+				WithContext("id", rule.ID)
+		}
+		for _, event := range rule.Events {
+			if !validEvent(event) {
+				return errors.New(errors.ErrCodeInvalidParameter, "The event is not supported for notifications").
+					WithContext("event", event)
+			}
+		}
+	}
+	return nil
+}
+
+// validEvent reports whether an event type, or a wildcard over the events of
+// a kind, can be subscribed to
+func validEvent(event string) bool {
+	kind, isWildcard := strings.CutSuffix(event, ":*")
+	for _, eventType := range eventTypes {
+		if eventType == event {
+			return true
+		}
+		if isWildcard && strings.Count(kind, ":") == 1 && strings.HasPrefix(eventType, kind+":") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Decision is the outcome of evaluating a policy for a request
+type Decision int
+
+const (
+	// NotMatched means no statement applies to the request
+	NotMatched Decision = iota
+	// Allowed means an Allow statement applies and no Deny statement does
+	Allowed
+	// Denied means a Deny statement applies
+	Denied
+)
+
+// Request describes an operation to authorize against a bucket policy
+type Request struct {
+	// Principal is the access key of the caller, empty for anonymous callers
+	Principal string
+	// Action is the S3 action, e.g. "s3:GetObject"
+	Action string
+	// Resource is the ARN of the bucket or object
+	Resource string
+	// SourceIP is the address of the client
+	SourceIP string
+	// SecureTransport reports whether the request was sent over TLS
+	SecureTransport bool
+	// Prefix is the listing prefix; nil for requests other than listings
+	Prefix *string
+}
+
+// BucketResource returns the ARN of a bucket
+func BucketResource(bucket string) string {
+	return ResourcePrefix + bucket
+}
+
+// ObjectResource returns the ARN of an object
+func ObjectResource(bucket, key string) string {
+	return ResourcePrefix + bucket + "/" + key
+}
+
+// Evaluate evaluates the policy for a request. An explicit Deny overrides any
+// Allow.
+func (p *Policy) Evaluate(req Request) Decision {
+	decision := NotMatched
+	for i := range p.Statement {
+		statement := &p.Statement[i]
+		if !statement.matches(req) {
+			continue
+		}
+		if statement.Effect == EffectDeny {
+			return Denied
+		}
+		decision = Allowed
+	}
+	return decision
+}
+
+// matches reports whether a statement applies to a request
+func (s *Statement) matches(req Request) bool {
+	if !s.matchesPrincipal(req.Principal) {
+		return false
+	}
+	if !matchAny(s.Action, req.Action, true) {
+		return false
+	}
+	if !matchAny(s.Resource, req.Resource, false) {
+		return false
+	}
+	for operator, conditions := range s.Condition {
+		for key, values := range conditions {
+			if !evaluateCondition(operator, key, values, req) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesPrincipal reports whether the statement names the caller. Access
+// keys may be given as they are or as IAM user ARNs.
+func (s *Statement) matchesPrincipal(principal string) bool {
+	for _, candidate := range s.Principal.AWS {
+		if candidate == "*" {
+			return true
+		}
+		if principal == "" {
+			continue
+		}
+		if candidate == principal || strings.HasSuffix(candidate, ":user/"+principal) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateCondition evaluates one condition key of a statement. A missing key
+// fails positive operators and satisfies negated ones.
+func evaluateCondition(operator, key string, values []string, req Request) bool {
+	switch operator {
+	case "IpAddress", "NotIpAddress":
+		ip := net.ParseIP(req.SourceIP)
+		in := ip != nil && inNetworks(values, ip)
+		if operator == "IpAddress" {
+			return in
+		}
+		return ip == nil || !in
+	case "Bool":
+		actual := strconv.FormatBool(req.SecureTransport)
+		for _, value := range values {
+			if value == actual {
+				return true
+			}
+		}
+		return false
+	case "StringEquals", "StringNotEquals", "StringLike", "StringNotLike":
+		if req.Prefix == nil {
+			return strings.HasPrefix(operator, "StringNot")
+		}
+		like := strings.HasSuffix(operator, "Like")
+		matched := false
+		for _, value := range values {
+			if (like && Match(value, *req.Prefix)) || (!like && value == *req.Prefix) {
+				matched = true
+				break
+			}
+		}
+		if strings.HasPrefix(operator, "StringNot") {
+			return !matched
+		}
+		return matched
+	}
+	return false
+}
+
+// inNetworks reports whether ip belongs to one of the CIDR blocks
+func inNetworks(blocks []string, ip net.IP) bool {
+	for _, block := range blocks {
+		network, err := parseCIDR(block)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny reports whether value matches one of the patterns
+func matchAny(patterns []string, value string, foldCase bool) bool {
+	for _, pattern := range patterns {
+		if foldCase {
+			if Match(strings.ToLower(pattern), strings.ToLower(value)) {
+				return true
+			}
+		} else if Match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether value matches pattern, where "*" matches any sequence
+// of characters and "?" matches a single character
+func Match(pattern, value string) bool {
+	p, v := []rune(pattern), []rune(value)
+	star, mark := -1, 0
+	i, j := 0, 0
+	for j < len(v) {
+		switch {
+		case i < len(p) && (p[i] == '?' || p[i] == v[j]):
+			i++
+			j++
+		case i < len(p) && p[i] == '*':
+			star, mark = i, j
+			i++
+		case star >= 0:
+			i = star + 1
+			mark++
+			j = mark
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
@@ -0,0 +1,248 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// MaxSize is the maximum size of a bucket policy document in bytes
+const MaxSize = 20 * 1024
+
+// Statement effects
+const (
+	EffectAllow = "Allow"
+	EffectDeny  = "Deny"
+)
+
+// ResourcePrefix prefixes the ARN of every S3 resource
+const ResourcePrefix = "arn:aws:s3:::"
+
+// Condition keys understood by the evaluator
+const (
+	KeySourceIP        = "aws:SourceIp"
+	KeySecureTransport = "aws:SecureTransport"
+	KeyPrefix          = "s3:prefix"
+)
+
+// Policy is an IAM-style bucket policy document
+type Policy struct {
+	Version   string      `json:"Version,omitempty"`
+	ID        string      `json:"Id,omitempty"`
+	Statement []Statement `json:"Statement"`
+}
+
+// Statement grants or denies a set of actions on a set of resources to a set
+// of principals, optionally under conditions
+type Statement struct {
+	Sid       string                          `json:"Sid,omitempty"`
+	Effect    string                          `json:"Effect"`
+	Principal Principal                       `json:"Principal"`
+	Action    StringSet                       `json:"Action"`
+	Resource  StringSet                       `json:"Resource"`
+	Condition map[string]map[string]StringSet `json:"Condition,omitempty"`
+}
+
+// Principal lists the access keys a statement applies to. "*" matches every
+// caller, including anonymous ones.
+type Principal struct {
+	AWS StringSet `json:"AWS,omitempty"`
+}
+
+// UnmarshalJSON accepts both "Principal": "*" and "Principal": {"AWS": ...}
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		if wildcard != "*" {
+			return fmt.Errorf("invalid principal %q", wildcard)
+		}
+		p.AWS = StringSet{"*"}
+		return nil
+	}
+
+	var principal struct {
+		AWS StringSet `json:"AWS"`
+	}
+	if err := json.Unmarshal(data, &principal); err != nil {
+		return err
+	}
+	p.AWS = principal.AWS
+	return nil
+}
+
+// StringSet is a JSON value that may be written as a single string or as an
+// array of strings
+type StringSet []string
+
+// UnmarshalJSON accepts a string or an array of strings
+func (s *StringSet) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = StringSet{single}
+		return nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("expected a string or an array of strings")
+	}
+	*s = values
+	return nil
+}
+
+// statements accepts a single statement object as well as an array
+type statements []Statement
+
+func (s *statements) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var single Statement
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		*s = statements{single}
+		return nil
+	}
+
+	var list []Statement
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// conditionOperators lists the supported condition operators and the keys
+// each of them may be used with
+var conditionOperators = map[string][]string{
+	"IpAddress":       {KeySourceIP},
+	"NotIpAddress":    {KeySourceIP},
+	"StringEquals":    {KeyPrefix},
+	"StringNotEquals": {KeyPrefix},
+	"StringLike":      {KeyPrefix},
+	"StringNotLike":   {KeyPrefix},
+	"Bool":            {KeySecureTransport},
+}
+
+// Parse parses and validates the policy document of bucket. Every resource
+// must refer to the bucket or to objects within it.
+func Parse(document []byte, bucket string) (*Policy, error) {
+	if len(document) > MaxSize {
+		return nil, malformed("Policies must be less than 20 KB")
+	}
+
+	var raw struct {
+		Version   string     `json:"Version"`
+		ID        string     `json:"Id"`
+		Statement statements `json:"Statement"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, malformed("This policy contains invalid Json").WithCause(err)
+	}
+	if len(raw.Statement) == 0 {
+		return nil, malformed("Missing required field Statement")
+	}
+
+	p := &Policy{Version: raw.Version, ID: raw.ID, Statement: raw.Statement}
+	for i := range p.Statement {
+		if err := p.Statement[i].validate(bucket); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// validate checks a statement of the policy of bucket
+func (s *Statement) validate(bucket string) *errors.AppError {
+	if s.Effect != EffectAllow && s.Effect != EffectDeny {
+		return malformed("Invalid effect: " + s.Effect)
+	}
+	if len(s.Principal.AWS) == 0 {
+		return malformed("Missing required field Principal")
+	}
+	if len(s.Action) == 0 {
+		return malformed("Missing required field Action")
+	}
+	for _, action := range s.Action {
+		if action != "*" && !strings.HasPrefix(strings.ToLower(action), "s3:") {
+			return malformed("Policy has invalid action")
+		}
+	}
+	if len(s.Resource) == 0 {
+		return malformed("Missing required field Resource")
+	}
+	for _, resource := range s.Resource {
+		name, _, _ := strings.Cut(strings.TrimPrefix(resource, ResourcePrefix), "/")
+		if !strings.HasPrefix(resource, ResourcePrefix) || !Match(name, bucket) {
+			return malformed("Action does not apply to any resource(s) in statement")
+		}
+	}
+
+	for operator, conditions := range s.Condition {
+		keys, ok := conditionOperators[operator]
+		if !ok {
+			return malformed("Invalid Condition operator: " + operator)
+		}
+		for key, values := range conditions {
+			if !containsFold(keys, key) {
+				return malformed("Policy has an invalid condition key: " + key)
+			}
+			for _, value := range values {
+				if err := validateConditionValue(operator, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateConditionValue checks that a condition value suits its operator
+func validateConditionValue(operator, value string) *errors.AppError {
+	switch operator {
+	case "IpAddress", "NotIpAddress":
+		if _, err := parseCIDR(value); err != nil {
+			return malformed("Invalid IP address or CIDR block: " + value)
+		}
+	case "Bool":
+		if value != "true" && value != "false" {
+			return malformed("Invalid Bool condition value: " + value)
+		}
+	}
+	return nil
+}
+
+// parseCIDR parses a CIDR block or a single IP address
+func parseCIDR(value string) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", value)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	return network, err
+}
+
+func malformed(message string) *errors.AppError {
+	return errors.New(errors.ErrCodeMalformedPolicy, message)
+}
+
+func containsFold(values []string, target string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -16,8 +16,9 @@ type Service interface {
 	// Chat performs RAG-based completion with retrieval and generation
 	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
 
-	// SearchContext retrieves relevant context for a query
-	SearchContext(ctx context.Context, query string, limit int) (*ContextResponse, error)
+	// SearchContext retrieves relevant context for a query, restricted to
+	// documents whose metadata matches filter when it is not empty
+	SearchContext(ctx context.Context, query string, limit int, filter map[string]string) (*ContextResponse, error)
 
 	// GenerateWithContext generates text using provided context
 	GenerateWithContext(ctx context.Context, req GenerationRequest) (*GenerationResponse, error)
@@ -158,7 +159,7 @@ func (s *service) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, err
 	}
 
 	// Step 1: Retrieve relevant context
-	contextResp, err := s.SearchContext(ctx, req.Query, req.TopK)
+	contextResp, err := s.SearchContext(ctx, req.Query, req.TopK, req.Metadata)
 	if err != nil {
 		s.logger.Error("failed to retrieve context", "request_id", requestID, "error", err)
 		return nil, fmt.Errorf("context retrieval failed: %w", err)
@@ -209,7 +210,7 @@ func (s *service) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, err
 }
 
 // SearchContext retrieves relevant context for a query
-func (s *service) SearchContext(ctx context.Context, query string, limit int) (*ContextResponse, error) {
+func (s *service) SearchContext(ctx context.Context, query string, limit int, filter map[string]string) (*ContextResponse, error) {
 	if limit <= 0 {
 		limit = s.config.DefaultTopK
 	}
@@ -221,7 +222,7 @@ func (s *service) SearchContext(ctx context.Context, query string, limit int) (*
 	}
 
 	// Search for similar vectors
-	results, err := s.vectorStorage.Search(queryEmbedding, limit)
+	results, err := s.vectorStorage.SearchWithFilter(queryEmbedding, limit, filter)
 	if err != nil {
 		return nil, fmt.Errorf("vector search failed: %w", err)
 	}
@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/logger"
+)
+
+// Change types recorded in the change feed
+const (
+	ChangePut      = "put"      // a version was written by a put or a completed multipart upload
+	ChangeCopy     = "copy"     // a version was written by a server side copy
+	ChangeDelete   = "delete"   // a version was removed, or a delete marker placed
+	ChangeMetadata = "metadata" // the tags, retention or legal hold of a version changed
+)
+
+// Change feed limits
+const (
+	DefaultChangeLimit = 100
+	MaxChangeLimit     = 1000
+
+	// changePollInterval bounds how long a waiting reader can miss changes
+	// appended by another process sharing the change log
+	changePollInterval = time.Second
+)
+
+// Change is an entry of the change feed. Null versions, including the
+// versions of objects in unversioned buckets, have an empty VersionID.
+type Change struct {
+	Sequence     int64     `json:"sequence"`
+	Type         string    `json:"type"`
+	Bucket       string    `json:"bucket"`
+	Key          string    `json:"key"`
+	VersionID    string    `json:"version_id,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	Size         int64     `json:"size,omitempty"`
+	DeleteMarker bool      `json:"delete_marker,omitempty"` // set when a delete placed a delete marker
+	Time         time.Time `json:"time"`
+}
+
+// ChangeFeedOptions selects the changes returned by ListChanges
+type ChangeFeedOptions struct {
+	Bucket string        // only changes to this bucket, all buckets if empty
+	Since  int64         // cursor returned by an earlier call, 0 for the oldest retained change
+	Limit  int           // maximum number of changes returned
+	Wait   time.Duration // how long to wait for a change if there is none after Since
+
+	// Allow reports whether the changes to a bucket may be returned, all of
+	// them if nil. The changes it leaves out are skipped by the cursor and
+	// do not end a wait.
+	Allow func(bucket string) (bool, error)
+}
+
+// ChangeFeed is a page of the change feed
+type ChangeFeed struct {
+	Changes []Change `json:"changes"`
+	Cursor  int64    `json:"cursor"` // passed as Since to continue after the changes returned
+	HasMore bool     `json:"has_more"`
+}
+
+// ChangeLog persists the change feed in sequence order
+type ChangeLog interface {
+	// Append stores a change and sets its sequence number, which is greater
+	// than that of every change appended before it
+	Append(ctx context.Context, change *Change) error
+	// Since returns up to limit changes with a greater sequence number than
+	// after, oldest first, only of bucket if it is not empty
+	Since(ctx context.Context, bucket string, after int64, limit int) ([]Change, error)
+	// Horizon returns the highest sequence number dropped by Truncate.
+	// Readers behind it may have missed changes.
+	Horizon(ctx context.Context) (int64, error)
+	// Truncate drops every change recorded before the given time
+	Truncate(ctx context.Context, before time.Time) (int, error)
+	// Compact drops changes recorded before the given time that a later
+	// change to the same version supersedes
+	Compact(ctx context.Context, before time.Time) (int, error)
+	Close() error
+}
+
+// recordChange appends a change to the change feed and wakes its waiting
+// readers. It is called with the key's lock held so that the changes of a
+// key are recorded in the order they were made. A failure is logged rather
+// than returned, as the change has already happened.
+func (s *service) recordChange(ctx context.Context, change Change) {
+	if s.changes == nil {
+		return
+	}
+	if change.VersionID == NullVersionID {
+		change.VersionID = ""
+	}
+	change.Time = time.Now().UTC()
+
+	if err := s.changes.Append(ctx, &change); err != nil {
+		s.logger.Error("Failed to record change", "bucket", change.Bucket, "key", change.Key, "type", change.Type, "error", err)
+		return
+	}
+
+	s.changedMu.Lock()
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.changedMu.Unlock()
+}
+
+// changeSignal returns a channel that is closed once the next change is recorded
+func (s *service) changeSignal() <-chan struct{} {
+	s.changedMu.Lock()
+	defer s.changedMu.Unlock()
+	return s.changed
+}
+
+// ListChanges returns the changes after a cursor in the order they were
+// made. If there are none it waits up to opts.Wait for the next one.
+// Cursors behind the retention horizon are rejected, since the changes
+// that followed them may be gone.
+func (s *service) ListChanges(ctx context.Context, opts ChangeFeedOptions) (*ChangeFeed, error) {
+	if s.changes == nil {
+		return nil, errors.New(errors.ErrCodeNotImplemented, "The change feed is not enabled")
+	}
+	if opts.Bucket != "" {
+		if err := s.validator.ValidateBucketName(opts.Bucket); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Since < 0 {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Invalid cursor").WithContext("since", opts.Since)
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultChangeLimit
+	}
+	if opts.Limit > MaxChangeLimit {
+		opts.Limit = MaxChangeLimit
+	}
+
+	if opts.Since > 0 {
+		horizon, err := s.changes.Horizon(ctx)
+		if err != nil {
+			s.logger.Error("Failed to read change log horizon", "error", err)
+			return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read changes", err)
+		}
+		if opts.Since < horizon {
+			return nil, errors.ErrCursorExpired.WithContext("since", opts.Since).WithContext("horizon", horizon)
+		}
+	}
+
+	var deadline <-chan time.Time
+	if opts.Wait > 0 {
+		timer := time.NewTimer(opts.Wait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		// Take the signal before reading so that a change recorded in
+		// between still wakes the wait below
+		signal := s.changeSignal()
+
+		// One change more than asked for tells whether there are more
+		changes, err := s.changes.Since(ctx, opts.Bucket, opts.Since, opts.Limit+1)
+		if err != nil {
+			s.logger.Error("Failed to read changes", "since", opts.Since, "error", err)
+			return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read changes", err)
+		}
+		feed := &ChangeFeed{Changes: changes, Cursor: opts.Since}
+		if len(changes) > opts.Limit {
+			feed.Changes, feed.HasMore = changes[:opts.Limit], true
+		}
+		if len(feed.Changes) > 0 {
+			feed.Cursor = feed.Changes[len(feed.Changes)-1].Sequence
+		}
+		if opts.Allow != nil {
+			if feed.Changes, err = allowedChanges(feed.Changes, opts.Allow); err != nil {
+				return nil, err
+			}
+		}
+		if len(feed.Changes) > 0 || deadline == nil {
+			return feed, nil
+		}
+
+		// Every change read was left out, so wait for the next one after them
+		opts.Since = feed.Cursor
+		if feed.HasMore {
+			continue
+		}
+
+		select {
+		case <-signal:
+		case <-time.After(changePollInterval):
+		case <-deadline:
+			deadline = nil
+		case <-ctx.Done():
+			return &ChangeFeed{Changes: []Change{}, Cursor: opts.Since}, nil
+		}
+	}
+}
+
+// allowedChanges filters changes in place down to the buckets allow accepts
+func allowedChanges(changes []Change, allow func(bucket string) (bool, error)) ([]Change, error) {
+	allowed := make(map[string]bool)
+	visible := changes[:0]
+	for _, change := range changes {
+		ok, seen := allowed[change.Bucket]
+		if !seen {
+			var err error
+			if ok, err = allow(change.Bucket); err != nil {
+				return nil, err
+			}
+			allowed[change.Bucket] = ok
+		}
+		if ok {
+			visible = append(visible, change)
+		}
+	}
+	return visible, nil
+}
+
+// CompactChanges drops the changes recorded before dropBefore, and those
+// recorded before compactBefore that a later change to the same version
+// supersedes. A zero time skips either step.
+func (s *service) CompactChanges(ctx context.Context, dropBefore, compactBefore time.Time) (int, error) {
+	if s.changes == nil {
+		return 0, nil
+	}
+
+	var removed int
+	if !dropBefore.IsZero() {
+		dropped, err := s.changes.Truncate(ctx, dropBefore)
+		if err != nil {
+			s.logger.Error("Failed to truncate change log", "error", err)
+			return 0, errors.Wrap(errors.ErrCodeInternalError, "Failed to truncate change log", err)
+		}
+		removed += dropped
+	}
+	if !compactBefore.IsZero() {
+		compacted, err := s.changes.Compact(ctx, compactBefore)
+		if err != nil {
+			s.logger.Error("Failed to compact change log", "error", err)
+			return removed, errors.Wrap(errors.ErrCodeInternalError, "Failed to compact change log", err)
+		}
+		removed += compacted
+	}
+	return removed, nil
+}
+
+// ChangeCompactor periodically drops changes older than the retention
+// period, and superseded changes older than compactAfter. A zero duration
+// turns the respective step off.
+type ChangeCompactor struct {
+	service      Service
+	interval     time.Duration
+	retention    time.Duration
+	compactAfter time.Duration
+	logger       logger.Logger
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewChangeCompactor creates a compactor of the change feed that runs every interval
+func NewChangeCompactor(service Service, interval, retention, compactAfter time.Duration, logger logger.Logger) *ChangeCompactor {
+	return &ChangeCompactor{
+		service:      service,
+		interval:     interval,
+		retention:    retention,
+		compactAfter: compactAfter,
+		logger:       logger,
+	}
+}
+
+// Start starts the background compaction loop
+func (w *ChangeCompactor) Start(ctx context.Context) error {
+	if w.interval <= 0 {
+		return fmt.Errorf("change compactor requires a positive interval")
+	}
+	if w.retention <= 0 && w.compactAfter <= 0 {
+		return fmt.Errorf("change compactor has neither retention nor compaction configured")
+	}
+	if w.stopCh != nil {
+		return fmt.Errorf("change compactor already started")
+	}
+
+	w.stopCh = make(chan struct{})
+	w.wg.Add(1)
+	go w.run(ctx)
+
+	w.logger.Info("Change compactor started", "interval", w.interval, "retention", w.retention, "compact_after", w.compactAfter)
+	return nil
+}
+
+// Stop stops the background compaction loop and waits for it to exit
+func (w *ChangeCompactor) Stop() error {
+	if w.stopCh == nil {
+		return nil
+	}
+	close(w.stopCh)
+	w.wg.Wait()
+	w.stopCh = nil
+	return nil
+}
+
+// Compact applies retention and compaction as of now and returns how many
+// changes were removed
+func (w *ChangeCompactor) Compact(ctx context.Context) (int, error) {
+	now := time.Now()
+	var dropBefore, compactBefore time.Time
+	if w.retention > 0 {
+		dropBefore = now.Add(-w.retention)
+	}
+	if w.compactAfter > 0 {
+		compactBefore = now.Add(-w.compactAfter)
+	}
+	return w.service.CompactChanges(ctx, dropBefore, compactBefore)
+}
+
+func (w *ChangeCompactor) run(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+			if removed, err := w.Compact(ctx); err != nil {
+				w.logger.Warn("Change log compaction failed", "error", err)
+			} else if removed > 0 {
+				w.logger.Info("Change log compaction completed", "removed", removed)
+			}
+		}
+	}
+}
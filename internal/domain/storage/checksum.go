@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// Checksum algorithms of the x-amz-checksum-* headers
+const (
+	ChecksumCRC32  = "CRC32"
+	ChecksumCRC32C = "CRC32C"
+	ChecksumSHA1   = "SHA1"
+	ChecksumSHA256 = "SHA256"
+)
+
+// ChecksumAlgorithms lists the supported checksum algorithms
+var ChecksumAlgorithms = []string{ChecksumCRC32, ChecksumCRC32C, ChecksumSHA1, ChecksumSHA256}
+
+// Checksums maps checksum algorithms to base64 encoded digests of an
+// object's content
+type Checksums map[string]string
+
+// NewChecksumHash returns the hash computing algorithm, or nil if it is not
+// supported
+func NewChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case ChecksumCRC32:
+		return crc32.NewIEEE()
+	case ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case ChecksumSHA1:
+		return sha1.New()
+	case ChecksumSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// validateChecksums checks the digests a client supplied are well formed.
+// An empty digest asks for the checksum to be computed without checking it.
+func validateChecksums(contentMD5 string, checksums Checksums) error {
+	if contentMD5 != "" {
+		digest, err := base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(digest) != md5.Size {
+			return errors.New(errors.ErrCodeInvalidDigest, "The Content-MD5 you specified was invalid.")
+		}
+	}
+
+	for algorithm, value := range checksums {
+		h := NewChecksumHash(algorithm)
+		if h == nil {
+			return errors.New(errors.ErrCodeInvalidRequest, "Checksum algorithm provided is unsupported. Please try again with any of the valid types: [CRC32, CRC32C, SHA1, SHA256]").
+				WithContext("algorithm", algorithm)
+		}
+		if value == "" {
+			continue
+		}
+		digest, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(digest) != h.Size() {
+			return errors.New(errors.ErrCodeInvalidRequest, "Value for x-amz-checksum-"+strings.ToLower(algorithm)+" header is invalid.")
+		}
+	}
+	return nil
+}
+
+// checksumReader computes the checksums of the data read through it. At the
+// end of the data it fails with BadDigest if one of them does not match the
+// digest the client supplied, and otherwise fills in the digests left empty.
+type checksumReader struct {
+	data       io.Reader
+	contentMD5 string
+	md5        hash.Hash
+	checksums  Checksums
+	hashes     map[string]hash.Hash
+}
+
+// newChecksumReader verifies data against contentMD5 and checksums, both of
+// which may be empty
+func newChecksumReader(data io.Reader, contentMD5 string, checksums Checksums) *checksumReader {
+	r := &checksumReader{
+		data:       data,
+		contentMD5: contentMD5,
+		checksums:  make(Checksums, len(checksums)),
+		hashes:     make(map[string]hash.Hash, len(checksums)),
+	}
+	if contentMD5 != "" {
+		r.md5 = md5.New()
+	}
+	for algorithm, value := range checksums {
+		r.checksums[algorithm] = value
+		r.hashes[algorithm] = NewChecksumHash(algorithm)
+	}
+	return r
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if r.md5 != nil {
+		r.md5.Write(p[:n])
+	}
+	for _, h := range r.hashes {
+		h.Write(p[:n])
+	}
+	if err == io.EOF {
+		if verifyErr := r.verify(); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+	return n, err
+}
+
+func (r *checksumReader) verify() error {
+	if r.md5 != nil && base64.StdEncoding.EncodeToString(r.md5.Sum(nil)) != r.contentMD5 {
+		return errors.ErrBadDigest
+	}
+
+	for _, algorithm := range ChecksumAlgorithms {
+		h, ok := r.hashes[algorithm]
+		if !ok {
+			continue
+		}
+		sum := base64.StdEncoding.EncodeToString(h.Sum(nil))
+		if expected := r.checksums[algorithm]; expected != "" && sum != expected {
+			return errors.New(errors.ErrCodeBadDigest, "The "+algorithm+" you specified did not match the calculated checksum.")
+		}
+		r.checksums[algorithm] = sum
+	}
+	return nil
+}
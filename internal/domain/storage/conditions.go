@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// Conditions holds the preconditions of a conditional request. Empty fields
+// are not checked.
+type Conditions struct {
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   time.Time
+	IfUnmodifiedSince time.Time
+}
+
+// IsEmpty reports whether no precondition is set
+func (c *Conditions) IsEmpty() bool {
+	return c == nil || (c.IfMatch == "" && c.IfNoneMatch == "" && c.IfModifiedSince.IsZero() && c.IfUnmodifiedSince.IsZero())
+}
+
+// CheckRead evaluates the preconditions of a GET or HEAD with S3 semantics:
+// If-Match takes precedence over If-Unmodified-Since and If-None-Match over
+// If-Modified-Since. It returns ErrPreconditionFailed or ErrNotModified.
+func (c *Conditions) CheckRead(etag string, lastModified time.Time) error {
+	if c.IsEmpty() {
+		return nil
+	}
+	lastModified = lastModified.Truncate(time.Second)
+
+	if c.IfMatch != "" {
+		if !matchETag(c.IfMatch, etag) {
+			return errors.ErrPreconditionFailed.WithContext("condition", "If-Match")
+		}
+	} else if !c.IfUnmodifiedSince.IsZero() && lastModified.After(c.IfUnmodifiedSince) {
+		return errors.ErrPreconditionFailed.WithContext("condition", "If-Unmodified-Since")
+	}
+
+	if c.IfNoneMatch != "" {
+		if matchETag(c.IfNoneMatch, etag) {
+			return errors.ErrNotModified
+		}
+	} else if !c.IfModifiedSince.IsZero() && !lastModified.After(c.IfModifiedSince) {
+		return errors.ErrNotModified
+	}
+
+	return nil
+}
+
+// CheckWrite evaluates the preconditions of a conditional write against the
+// current object, which is nil when the key does not exist yet
+func (c *Conditions) CheckWrite(current *ObjectInfo) error {
+	if c.IsEmpty() {
+		return nil
+	}
+
+	if c.IfNoneMatch != "" && current != nil && matchETag(c.IfNoneMatch, current.ETag) {
+		return errors.ErrPreconditionFailed.WithContext("condition", "If-None-Match")
+	}
+	if c.IfMatch != "" {
+		if current == nil {
+			return errors.ErrObjectNotFound.WithContext("condition", "If-Match")
+		}
+		if !matchETag(c.IfMatch, current.ETag) {
+			return errors.ErrPreconditionFailed.WithContext("condition", "If-Match")
+		}
+	}
+	return nil
+}
+
+// matchETag reports whether etag matches a comma separated list of entity
+// tags or the wildcard
+func matchETag(list, etag string) bool {
+	etag = trimETag(etag)
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || trimETag(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// keyLocks serializes writers of the same object so that conditional writes
+// are checked and applied atomically, and likewise the changes to a multipart
+// upload
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu   sync.RWMutex
+	refs int
+}
+
+// lock acquires the lock for bucket/key and returns the function releasing it
+func (l *keyLocks) lock(bucket, key string) func() {
+	kl, release := l.acquire(bucket + "/" + key)
+	kl.mu.Lock()
+	return func() {
+		kl.mu.Unlock()
+		release()
+	}
+}
+
+// rlock acquires the lock for bucket/key shared with other rlock holders and
+// returns the function releasing it
+func (l *keyLocks) rlock(bucket, key string) func() {
+	kl, release := l.acquire(bucket + "/" + key)
+	kl.mu.RLock()
+	return func() {
+		kl.mu.RUnlock()
+		release()
+	}
+}
+
+// acquire returns the lock of name, which stays in use until release is called
+func (l *keyLocks) acquire(name string) (*keyLock, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks == nil {
+		l.locks = make(map[string]*keyLock)
+	}
+	kl, ok := l.locks[name]
+	if !ok {
+		kl = &keyLock{}
+		l.locks[name] = kl
+	}
+	kl.refs++
+
+	return kl, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		kl.refs--
+		if kl.refs == 0 {
+			delete(l.locks, name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// CopyObject copies an object server side, streaming the source into the
+// destination without buffering it
+func (s *service) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyObjectOptions) (*Object, error) {
+	directive := opts.MetadataDirective
+	if directive == "" {
+		directive = MetadataDirectiveCopy
+	}
+	if directive != MetadataDirectiveCopy && directive != MetadataDirectiveReplace {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Unknown metadata directive.").
+			WithContext("metadata_directive", directive)
+	}
+	tagging := opts.TaggingDirective
+	if tagging == "" {
+		tagging = MetadataDirectiveCopy
+	}
+	if tagging != MetadataDirectiveCopy && tagging != MetadataDirectiveReplace {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Unknown tagging directive.").
+			WithContext("tagging_directive", tagging)
+	}
+	if srcBucket == dstBucket && srcKey == dstKey && opts.SourceVersionID == "" && directive == MetadataDirectiveCopy && tagging == MetadataDirectiveCopy && opts.Encryption == nil {
+		return nil, errors.New(errors.ErrCodeInvalidRequest, "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata.")
+	}
+
+	source, err := s.openCopySource(ctx, srcBucket, srcKey, opts.SourceVersionID, opts.SourceConditions, opts.SourceEncryption)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Body.Close()
+
+	// Like S3, the copy is encrypted as requested for the destination
+	// rather than the way the source is
+	putOpts := PutObjectOptions{
+		ContentType: source.ContentType,
+		Metadata:    source.Metadata,
+		Tags:        source.Tags,
+		Conditions:  opts.Conditions,
+		Encryption:  opts.Encryption,
+		Checksums:   source.Checksums, // checked again against the data copied
+	}
+	if directive == MetadataDirectiveReplace {
+		putOpts.ContentType = opts.ContentType
+		putOpts.Metadata = opts.Metadata
+	}
+	if tagging == MetadataDirectiveReplace {
+		putOpts.Tags = opts.Tags
+	}
+
+	object, err := s.putObject(ctx, dstBucket, dstKey, source.Body, putOpts, ChangeCopy)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Object copied successfully", "source_bucket", srcBucket, "source_key", srcKey, "bucket", dstBucket, "key", dstKey, "size", object.Size)
+	return object, nil
+}
+
+// UploadPartCopy stages a part of a multipart upload from an existing object,
+// or from a byte range of it
+func (s *service) UploadPartCopy(ctx context.Context, srcBucket, srcKey, bucket, key, uploadID string, partNumber int, opts CopyPartOptions) (*Part, error) {
+	source, err := s.openCopySource(ctx, srcBucket, srcKey, opts.SourceVersionID, opts.SourceConditions, opts.SourceEncryption)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Body.Close()
+
+	var data io.Reader = source.Body
+	if opts.SourceRange != "" {
+		byteRange, err := ParseCopySourceRange(opts.SourceRange, source.Size)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := source.Body.Seek(byteRange.Start, io.SeekStart); err != nil {
+			return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read copy source", err)
+		}
+		data = io.LimitReader(source.Body, byteRange.Length())
+	}
+
+	return s.UploadPart(ctx, bucket, key, uploadID, partNumber, data, UploadPartOptions{Encryption: opts.Encryption})
+}
+
+// openCopySource opens the source of a copy and checks its preconditions.
+// Unlike a GET, a copy source that was not modified fails the request.
+func (s *service) openCopySource(ctx context.Context, bucket, key, versionID string, conditions *Conditions, sse *ServerSideEncryption) (*Object, error) {
+	source, err := s.GetObjectVersion(ctx, bucket, key, versionID, sse)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conditions.CheckRead(source.ETag, source.LastModified); err != nil {
+		source.Body.Close()
+		if errors.IsErrorCode(err, errors.ErrCodeNotModified) {
+			return nil, errors.ErrPreconditionFailed.WithContext("condition", "x-amz-copy-source-if-none-match")
+		}
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// ParseCopySourceRange parses x-amz-copy-source-range which, unlike a Range
+// header, must be of the form bytes=first-last and lie within the source
+func ParseCopySourceRange(spec string, size int64) (*ByteRange, error) {
+	invalid := errors.New(errors.ErrCodeInvalidParameter,
+		"The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy").
+		WithContext("range", spec)
+
+	if !strings.HasPrefix(spec, "bytes=") {
+		return nil, invalid
+	}
+	first, last, ok := strings.Cut(strings.TrimPrefix(spec, "bytes="), "-")
+	if !ok {
+		return nil, invalid
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, invalid
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return nil, invalid
+	}
+	if end >= size {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Range specified is not valid for source object").
+			WithContext("range", spec).WithContext("size", size)
+	}
+
+	return &ByteRange{Start: start, End: end}, nil
+}
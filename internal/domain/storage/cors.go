@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/8fs-io/core/internal/domain/policy"
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// bucketConfigCors names the stored CORS configuration of a bucket
+const bucketConfigCors = "cors"
+
+// maxCORSRules is the maximum number of rules in a CORS configuration
+const maxCORSRules = 100
+
+// PutBucketCors replaces the CORS configuration of a bucket
+func (s *service) PutBucketCors(ctx context.Context, bucket string, config *CORSConfiguration) error {
+	if err := validateCors(config); err != nil {
+		return err
+	}
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	if err := s.repo.PutBucketConfig(ctx, bucket, bucketConfigCors, config); err != nil {
+		s.logger.Error("Failed to store bucket CORS configuration", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to store bucket CORS configuration", err)
+	}
+
+	s.logger.Info("Bucket CORS configuration updated", "bucket", bucket, "rules", len(config.Rules))
+	return nil
+}
+
+// GetBucketCors returns the CORS configuration of a bucket
+func (s *service) GetBucketCors(ctx context.Context, bucket string) (*CORSConfiguration, error) {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	var config CORSConfiguration
+	found, err := s.repo.GetBucketConfig(ctx, bucket, bucketConfigCors, &config)
+	if err != nil {
+		s.logger.Error("Failed to read bucket CORS configuration", "bucket", bucket, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read bucket CORS configuration", err)
+	}
+	if !found {
+		return nil, errors.ErrNoSuchCORS.WithContext("bucket", bucket)
+	}
+	return &config, nil
+}
+
+// DeleteBucketCors removes the CORS configuration of a bucket
+func (s *service) DeleteBucketCors(ctx context.Context, bucket string) error {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteBucketConfig(ctx, bucket, bucketConfigCors); err != nil {
+		s.logger.Error("Failed to delete bucket CORS configuration", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to delete bucket CORS configuration", err)
+	}
+
+	s.logger.Info("Bucket CORS configuration deleted", "bucket", bucket)
+	return nil
+}
+
+// BucketCors returns the CORS configuration of a bucket, or nil when the
+// bucket has none or does not exist
+func (s *service) BucketCors(ctx context.Context, bucket string) (*CORSConfiguration, error) {
+	if s.validator.ValidateBucketName(bucket) != nil {
+		return nil, nil
+	}
+
+	var config CORSConfiguration
+	found, err := s.repo.GetBucketConfig(ctx, bucket, bucketConfigCors, &config)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read bucket CORS configuration", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &config, nil
+}
+
+// validateCors checks a CORS configuration the way S3 does
+func validateCors(config *CORSConfiguration) error {
+	if config == nil || len(config.Rules) == 0 {
+		return errors.New(errors.ErrCodeMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	if len(config.Rules) > maxCORSRules {
+		return errors.New(errors.ErrCodeInvalidParameter, "The CORS configuration must not contain more than 100 rules")
+	}
+
+	for _, rule := range config.Rules {
+		if len(rule.ID) > 255 {
+			return errors.New(errors.ErrCodeInvalidParameter, "ID length should not exceed allowed limit of 255")
+		}
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 {
+			return errors.New(errors.ErrCodeMalformedXML, "A CORS rule must have at least one AllowedOrigin and one AllowedMethod")
+		}
+		for _, method := range rule.AllowedMethods {
+			switch method {
+			case http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodHead:
+			default:
+				return errors.New(errors.ErrCodeInvalidRequest, "Found unsupported HTTP method in CORS config").
+					WithContext("method", method)
+			}
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if strings.Count(origin, "*") > 1 {
+				return errors.New(errors.ErrCodeInvalidRequest, "AllowedOrigin can not have more than one wildcard").
+					WithContext("origin", origin)
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(header, "*") > 1 {
+				return errors.New(errors.ErrCodeInvalidRequest, "AllowedHeader can not have more than one wildcard").
+					WithContext("header", header)
+			}
+		}
+		if rule.MaxAgeSeconds < 0 {
+			return errors.New(errors.ErrCodeInvalidParameter, "MaxAgeSeconds must not be negative")
+		}
+	}
+	return nil
+}
+
+// MatchRule returns the first rule allowing a request from origin using
+// method and sending headers, or nil when no rule does. Header names are
+// compared without regard to case.
+func (c *CORSConfiguration) MatchRule(origin, method string, headers []string) *CORSRule {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.allowsOrigin(origin) && rule.allowsMethod(method) && rule.allowsHeaders(headers) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (r *CORSRule) allowsOrigin(origin string) bool {
+	for _, allowed := range r.AllowedOrigins {
+		if policy.Match(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CORSRule) allowsMethod(method string) bool {
+	for _, allowed := range r.AllowedMethods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CORSRule) allowsHeaders(headers []string) bool {
+	for _, header := range headers {
+		allowed := false
+		for _, pattern := range r.AllowedHeaders {
+			if policy.Match(strings.ToLower(pattern), strings.ToLower(header)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
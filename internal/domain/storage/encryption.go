@@ -0,0 +1,450 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// Encrypted data is stored as streams: a random salt, from which the stream
+// key is derived with HMAC-SHA256 under the data key of the object, followed
+// by the plaintext in chunks sealed with AES-256-GCM. A chunk nonce holds
+// the chunk index and marks the last chunk, so chunks can be neither
+// reordered nor dropped. Objects are a single stream and multipart objects
+// one stream per part, which lets any plaintext offset be located in the
+// ciphertext without reading what precedes it.
+const (
+	encryptionChunkSize = 64 * 1024
+	encryptionSaltSize  = 32
+	encryptionTagSize   = 16
+
+	// EncryptionKeySize is the size of master, customer and data keys
+	EncryptionKeySize = 32
+)
+
+// EncryptionAES256 is the server-side encryption algorithm objects are
+// encrypted with, AES-256-GCM
+const EncryptionAES256 = "AES256"
+
+// Encryption describes how an object, or the parts of a multipart upload,
+// are encrypted at rest. Each object has its own data key, stored sealed
+// with the master key, or with the customer key for SSE-C.
+type Encryption struct {
+	Algorithm      string `json:"algorithm"`
+	CustomerKeyMD5 string `json:"customer_key_md5,omitempty"` // base64 MD5 of the SSE-C key
+	SealedKey      []byte `json:"sealed_key"`
+
+	dataKey []byte // unsealed data key, set while data is written
+}
+
+// ServerSideEncryption requests encryption of an object, or supplies the
+// key to read one. CustomerKey is set for SSE-C; without it the object is
+// encrypted with the master key (SSE-S3).
+type ServerSideEncryption struct {
+	CustomerKey []byte
+}
+
+// CustomerKeyMD5 returns the base64 MD5 of a customer key, as sent in the
+// x-amz-server-side-encryption-customer-key-MD5 header
+func CustomerKeyMD5(key []byte) string {
+	sum := md5.Sum(key)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// IsCustomerKey reports whether data is encrypted with a customer key
+func (e *Encryption) IsCustomerKey() bool {
+	return e != nil && e.CustomerKeyMD5 != ""
+}
+
+// CheckKey checks that sse supplies the key needed to read data encrypted
+// as e describes. Only SSE-C needs a key; supplying one for anything else
+// is an error too.
+func (e *Encryption) CheckKey(sse *ServerSideEncryption) error {
+	provided := sse != nil && sse.CustomerKey != nil
+	if !e.IsCustomerKey() {
+		if provided {
+			return errors.New(errors.ErrCodeInvalidRequest, "The encryption parameters are not applicable to this object.")
+		}
+		return nil
+	}
+	if !provided {
+		return errors.New(errors.ErrCodeInvalidRequest, "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.")
+	}
+	if subtle.ConstantTimeCompare([]byte(CustomerKeyMD5(sse.CustomerKey)), []byte(e.CustomerKeyMD5)) != 1 {
+		return errors.New(errors.ErrCodeAccessDenied, "The provided customer key does not match the key the object was stored with")
+	}
+	return nil
+}
+
+// newEncryption creates the encryption of new data under bucket/key,
+// generating its data key. It returns nil when sse is nil and the service
+// does not encrypt by default.
+func (s *service) newEncryption(bucket, key string, sse *ServerSideEncryption) (*Encryption, error) {
+	if sse == nil {
+		if !s.config.EncryptByDefault {
+			return nil, nil
+		}
+		sse = &ServerSideEncryption{}
+	}
+
+	encryption := &Encryption{Algorithm: EncryptionAES256}
+	kek := s.config.MasterKey
+	if sse.CustomerKey != nil {
+		kek = sse.CustomerKey
+		encryption.CustomerKeyMD5 = CustomerKeyMD5(sse.CustomerKey)
+	}
+	if len(kek) != EncryptionKeySize {
+		if sse.CustomerKey != nil {
+			return nil, errors.New(errors.ErrCodeInvalidParameter, "The secret key was invalid for the specified algorithm.")
+		}
+		return nil, errors.New(errors.ErrCodeNotImplemented, "Server-side encryption with a master key is not configured")
+	}
+
+	encryption.dataKey = make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(encryption.dataKey); err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to generate data key", err)
+	}
+	sealed, err := sealDataKey(kek, encryption.dataKey, bucket, key)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to seal data key", err)
+	}
+	encryption.SealedKey = sealed
+	return encryption, nil
+}
+
+// unsealEncryption checks the key supplied by sse and unseals the data key
+// of e, which belongs to data under bucket/key
+func (s *service) unsealEncryption(e *Encryption, bucket, key string, sse *ServerSideEncryption) error {
+	if err := e.CheckKey(sse); err != nil {
+		return err
+	}
+	if e == nil || e.dataKey != nil {
+		return nil
+	}
+
+	kek := s.config.MasterKey
+	if e.IsCustomerKey() {
+		kek = sse.CustomerKey
+	}
+	if len(kek) != EncryptionKeySize {
+		return errors.New(errors.ErrCodeInternalError, "The master key needed to decrypt the object is not configured")
+	}
+
+	dataKey, err := unsealDataKey(kek, e.SealedKey, bucket, key)
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to unseal data key", err)
+	}
+	e.dataKey = dataKey
+	return nil
+}
+
+// decryptObject replaces the Body of an encrypted object with its plaintext,
+// using the key supplied by sse for SSE-C objects
+func (s *service) decryptObject(object *Object, sse *ServerSideEncryption) error {
+	if err := s.unsealEncryption(object.Encryption, object.Bucket, object.Key, sse); err != nil {
+		return err
+	}
+	if object.Encryption == nil {
+		return nil
+	}
+
+	body, err := newDecryptReader(object.Body, object.Encryption.dataKey, object.Size, object.PartSizes)
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to decrypt object", err)
+	}
+	object.Body = body
+	return nil
+}
+
+// sealDataKey encrypts a data key with a key-encryption key, binding it to
+// the bucket and key of the data
+func sealDataKey(kek, dataKey []byte, bucket, key string) ([]byte, error) {
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(bucket+"/"+key)), nil
+}
+
+// unsealDataKey decrypts a data key sealed by sealDataKey
+func unsealDataKey(kek, sealed []byte, bucket, key string) ([]byte, error) {
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed key too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(bucket+"/"+key))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// streamCipher derives the cipher of the stream with the given salt
+func streamCipher(dataKey, salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write(salt)
+	return newGCM(mac.Sum(nil))
+}
+
+// chunkNonce returns the nonce of chunk index of a stream
+func chunkNonce(index int64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, uint64(index))
+	if last {
+		nonce[8] = 1
+	}
+	return nonce
+}
+
+// chunkCount returns the number of chunks a stream of size bytes is sealed
+// in. An empty stream still has its (empty) last chunk.
+func chunkCount(size int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + encryptionChunkSize - 1) / encryptionChunkSize
+}
+
+// encryptedSize returns the size of the stream a plaintext of size bytes is
+// encrypted to
+func encryptedSize(size int64) int64 {
+	return encryptionSaltSize + size + chunkCount(size)*encryptionTagSize
+}
+
+// NewWriter returns a writer that encrypts data to w as one stream. The
+// stream is only complete once the writer is closed, which does not close w.
+func (e *Encryption) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if e.dataKey == nil {
+		return nil, fmt.Errorf("data key is sealed")
+	}
+
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := streamCipher(e.dataKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, encryptionChunkSize),
+	}, nil
+}
+
+// encryptWriter seals data in chunks. A full chunk is held back until more
+// data arrives, as only Close knows which chunk is the last.
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	buf    []byte
+	sealed []byte
+	index  int64
+	closed bool
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("write to closed encryption stream")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the last chunk
+func (w *encryptWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+func (w *encryptWriter) seal(last bool) error {
+	w.sealed = w.aead.Seal(w.sealed[:0], chunkNonce(w.index, last), w.buf, nil)
+	w.index++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(w.sealed)
+	return err
+}
+
+// encryptedStream locates one stream of an encrypted object
+type encryptedStream struct {
+	offset       int64 // plaintext offset of the stream in the object
+	size         int64 // plaintext size
+	cipherOffset int64
+	aead         cipher.AEAD // derived once the salt has been read
+}
+
+// decryptReader reads the plaintext of an encrypted object. Seeking is on
+// the plaintext and only decrypts the chunk the new offset falls in.
+type decryptReader struct {
+	r       io.ReadSeekCloser
+	dataKey []byte
+	streams []encryptedStream
+	size    int64
+	offset  int64
+
+	chunk      []byte // the decrypted chunk at chunkStart, if any
+	chunkStart int64
+	buf        []byte
+}
+
+// newDecryptReader decrypts the object of size bytes in r, stored as one
+// stream per part when partSizes is set
+func newDecryptReader(r io.ReadSeekCloser, dataKey []byte, size int64, partSizes []int64) (io.ReadSeekCloser, error) {
+	if len(partSizes) == 0 {
+		partSizes = []int64{size}
+	}
+
+	d := &decryptReader{r: r, dataKey: dataKey, size: size}
+	var offset, cipherOffset int64
+	for _, partSize := range partSizes {
+		d.streams = append(d.streams, encryptedStream{offset: offset, size: partSize, cipherOffset: cipherOffset})
+		offset += partSize
+		cipherOffset += encryptedSize(partSize)
+	}
+	if offset != size {
+		return nil, fmt.Errorf("part sizes add up to %d, not %d", offset, size)
+	}
+	return d, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	if d.offset >= d.size {
+		return 0, io.EOF
+	}
+	if d.chunk == nil || d.offset < d.chunkStart || d.offset >= d.chunkStart+int64(len(d.chunk)) {
+		if err := d.load(d.offset); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.chunk[d.offset-d.chunkStart:])
+	d.offset += int64(n)
+	return n, nil
+}
+
+// load decrypts the chunk holding the plaintext at offset
+func (d *decryptReader) load(offset int64) error {
+	var stream *encryptedStream
+	for i := range d.streams {
+		if s := &d.streams[i]; offset >= s.offset && offset < s.offset+s.size {
+			stream = s
+			break
+		}
+	}
+	if stream == nil {
+		return fmt.Errorf("offset %d is outside of the object", offset)
+	}
+
+	if stream.aead == nil {
+		salt := make([]byte, encryptionSaltSize)
+		if err := d.readAt(salt, stream.cipherOffset); err != nil {
+			return err
+		}
+		aead, err := streamCipher(d.dataKey, salt)
+		if err != nil {
+			return err
+		}
+		stream.aead = aead
+	}
+
+	index := (offset - stream.offset) / encryptionChunkSize
+	length := stream.size - index*encryptionChunkSize
+	if length > encryptionChunkSize {
+		length = encryptionChunkSize
+	}
+	sealed := d.buf[:0]
+	if int64(cap(sealed)) < length+encryptionTagSize {
+		sealed = make([]byte, 0, encryptionChunkSize+encryptionTagSize)
+	}
+	sealed = sealed[:length+encryptionTagSize]
+	position := stream.cipherOffset + encryptionSaltSize + index*(encryptionChunkSize+encryptionTagSize)
+	if err := d.readAt(sealed, position); err != nil {
+		return err
+	}
+	d.buf = sealed
+
+	last := index == chunkCount(stream.size)-1
+	chunk, err := stream.aead.Open(d.chunk[:0], chunkNonce(index, last), sealed, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt object data: %w", err)
+	}
+	d.chunk = chunk
+	d.chunkStart = stream.offset + index*encryptionChunkSize
+	return nil
+}
+
+func (d *decryptReader) readAt(p []byte, offset int64) error {
+	if _, err := d.r.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(d.r, p); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.offset
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	d.offset = offset
+	return offset, nil
+}
+
+func (d *decryptReader) Close() error {
+	return d.r.Close()
+}
@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/logger"
+)
+
+// bucketConfigLifecycle names the stored lifecycle configuration of a bucket
+const bucketConfigLifecycle = "lifecycle"
+
+// maxLifecycleRules is the maximum number of rules in a lifecycle configuration
+const maxLifecycleRules = 1000
+
+// PutBucketLifecycle replaces the lifecycle configuration of a bucket
+func (s *service) PutBucketLifecycle(ctx context.Context, bucket string, config *LifecycleConfiguration) error {
+	if err := s.validateLifecycle(config); err != nil {
+		return err
+	}
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	if err := s.repo.PutBucketConfig(ctx, bucket, bucketConfigLifecycle, config); err != nil {
+		s.logger.Error("Failed to store bucket lifecycle", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to store bucket lifecycle", err)
+	}
+
+	s.logger.Info("Bucket lifecycle updated", "bucket", bucket, "rules", len(config.Rules))
+	return nil
+}
+
+// GetBucketLifecycle returns the lifecycle configuration of a bucket
+func (s *service) GetBucketLifecycle(ctx context.Context, bucket string) (*LifecycleConfiguration, error) {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	var config LifecycleConfiguration
+	found, err := s.repo.GetBucketConfig(ctx, bucket, bucketConfigLifecycle, &config)
+	if err != nil {
+		s.logger.Error("Failed to read bucket lifecycle", "bucket", bucket, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read bucket lifecycle", err)
+	}
+	if !found {
+		return nil, errors.ErrNoSuchLifecycle.WithContext("bucket", bucket)
+	}
+	return &config, nil
+}
+
+// DeleteBucketLifecycle removes the lifecycle configuration of a bucket
+func (s *service) DeleteBucketLifecycle(ctx context.Context, bucket string) error {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteBucketConfig(ctx, bucket, bucketConfigLifecycle); err != nil {
+		s.logger.Error("Failed to delete bucket lifecycle", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to delete bucket lifecycle", err)
+	}
+
+	s.logger.Info("Bucket lifecycle deleted", "bucket", bucket)
+	return nil
+}
+
+// ExpireObjects applies the enabled lifecycle rules of every bucket. Expired
+// objects are deleted the way a client delete would: buckets with versioning
+// configured keep them in the history behind a delete marker. A bucket that
+// cannot be processed is logged and skipped.
+func (s *service) ExpireObjects(ctx context.Context, now time.Time) ([]ExpiredObject, error) {
+	buckets, err := s.repo.ListBucketNames(ctx)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to list buckets", err)
+	}
+
+	var expired []ExpiredObject
+	for _, bucket := range buckets {
+		if ctx.Err() != nil {
+			return expired, ctx.Err()
+		}
+		objects, err := s.expireBucket(ctx, bucket, now)
+		if err != nil {
+			s.logger.Warn("Failed to apply bucket lifecycle", "bucket", bucket, "error", err)
+		}
+		expired = append(expired, objects...)
+	}
+
+	return expired, nil
+}
+
+// expireBucket applies the lifecycle rules of one bucket
+func (s *service) expireBucket(ctx context.Context, bucket string, now time.Time) ([]ExpiredObject, error) {
+	var config LifecycleConfiguration
+	found, err := s.repo.GetBucketConfig(ctx, bucket, bucketConfigLifecycle, &config)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var rules []LifecycleRule
+	for _, rule := range config.Rules {
+		if rule.Status == LifecycleEnabled {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var expired []ExpiredObject
+	opts := ListOptions{Prefix: commonRulePrefix(rules), MaxKeys: 1000}
+	for {
+		result, err := s.repo.ListObjects(ctx, bucket, opts)
+		if err != nil {
+			return expired, err
+		}
+
+		for i := range result.Objects {
+			rule := expiringRule(rules, &result.Objects[i], now)
+			if rule == nil {
+				continue
+			}
+			ok, err := s.expireObject(ctx, bucket, result.Objects[i].Key, rule, now)
+			if err != nil {
+				s.logger.Warn("Failed to expire object", "bucket", bucket, "key", result.Objects[i].Key, "rule", rule.ID, "error", err)
+				continue
+			}
+			if ok {
+				expired = append(expired, ExpiredObject{Bucket: bucket, Key: result.Objects[i].Key, RuleID: rule.ID})
+			}
+		}
+
+		if !result.IsTruncated {
+			return expired, nil
+		}
+		opts.Marker = result.NextMarker
+	}
+}
+
+// expireObject deletes the current version of an object once its key's lock
+// is held, re-checking the rule in case the object was replaced since it was
+// listed
+func (s *service) expireObject(ctx context.Context, bucket, key string, rule *LifecycleRule, now time.Time) (bool, error) {
+	status, err := s.GetBucketVersioning(ctx, bucket)
+	if err != nil {
+		return false, err
+	}
+
+	unlock := s.locks.lock(bucket, key)
+	defer unlock()
+
+	current, err := s.currentObject(ctx, bucket, key)
+	if err != nil || current == nil {
+		return false, err
+	}
+	current.Key = key
+	if !rule.matches(current) || !rule.expired(current, now) {
+		return false, nil
+	}
+
+	change := Change{Type: ChangeDelete, Bucket: bucket, Key: key}
+	if status == "" {
+		err = s.deleteCurrent(ctx, bucket, key, current, false)
+	} else {
+		var result *DeleteResult
+		if result, err = s.putDeleteMarker(ctx, bucket, key, status, current); err == nil {
+			change.VersionID, change.DeleteMarker = result.VersionID, true
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	s.recordChange(ctx, change)
+
+	s.logger.Info("Object expired by lifecycle rule", "bucket", bucket, "key", key, "rule", rule.ID)
+	return true, nil
+}
+
+// validateLifecycle checks a lifecycle configuration supplied by a client
+func (s *service) validateLifecycle(config *LifecycleConfiguration) error {
+	if config == nil || len(config.Rules) == 0 {
+		return errors.New(errors.ErrCodeMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	if len(config.Rules) > maxLifecycleRules {
+		return errors.New(errors.ErrCodeInvalidParameter, "Configuration must not contain more than 1000 rules")
+	}
+
+	ids := make(map[string]bool, len(config.Rules))
+	for _, rule := range config.Rules {
+		if len(rule.ID) > 255 {
+			return errors.New(errors.ErrCodeInvalidParameter, "ID length should not exceed allowed limit of 255")
+		}
+		if rule.ID != "" {
+			if ids[rule.ID] {
+				return errors.New(errors.ErrCodeInvalidParameter, "Rule ID must be unique. Found same ID for more than one rule").
+					WithContext("id", rule.ID)
+			}
+			ids[rule.ID] = true
+		}
+		if rule.Status != LifecycleEnabled && rule.Status != LifecycleDisabled {
+			return errors.New(errors.ErrCodeMalformedXML, "The lifecycle rule status must be Enabled or Disabled").
+				WithContext("status", rule.Status)
+		}
+
+		expiration := rule.Expiration
+		switch {
+		case expiration.Days != 0 && expiration.Date != nil:
+			return errors.New(errors.ErrCodeMalformedXML, "An Expiration action cannot specify both Days and Date")
+		case expiration.Days < 0:
+			return errors.New(errors.ErrCodeInvalidParameter, "'Days' for Expiration action must be a positive integer")
+		case expiration.Days == 0 && expiration.Date == nil:
+			return errors.New(errors.ErrCodeInvalidParameter, "At least one action needs to be specified in a rule")
+		case expiration.Date != nil && !expiration.Date.Equal(midnight(*expiration.Date)):
+			return errors.New(errors.ErrCodeInvalidParameter, "'Date' must be at midnight GMT")
+		}
+
+		if err := s.validator.ValidateTags(rule.Filter.Tags); err != nil {
+			return err
+		}
+		if err := s.validator.ValidateMetadata(rule.Filter.Metadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether an object falls under the rule's filter
+func (r *LifecycleRule) matches(info *ObjectInfo) bool {
+	if !strings.HasPrefix(info.Key, r.Filter.Prefix) {
+		return false
+	}
+	for key, value := range r.Filter.Tags {
+		if tag, ok := info.Tags[key]; !ok || tag != value {
+			return false
+		}
+	}
+	for key, value := range r.Filter.Metadata {
+		if !hasMetadata(info.Metadata, key, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// expired reports whether an object is due for expiration at now. Objects
+// expire at midnight UTC following the configured number of days, as in S3.
+func (r *LifecycleRule) expired(info *ObjectInfo, now time.Time) bool {
+	if r.Expiration.Date != nil {
+		return !now.Before(*r.Expiration.Date)
+	}
+
+	at := info.LastModified.UTC().AddDate(0, 0, r.Expiration.Days)
+	if day := midnight(at); !day.Equal(at) {
+		at = day.AddDate(0, 0, 1)
+	}
+	return !now.Before(at)
+}
+
+// expiringRule returns the first rule under which an object has expired
+func expiringRule(rules []LifecycleRule, info *ObjectInfo, now time.Time) *LifecycleRule {
+	for i := range rules {
+		if rules[i].matches(info) && rules[i].expired(info, now) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// commonRulePrefix returns the longest key prefix shared by all rules, which
+// bounds the objects that have to be listed
+func commonRulePrefix(rules []LifecycleRule) string {
+	prefix := rules[0].Filter.Prefix
+	for _, rule := range rules[1:] {
+		for !strings.HasPrefix(rule.Filter.Prefix, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// hasMetadata reports whether user metadata holds value under key. Keys are
+// compared case-insensitively since they arrive as HTTP header names.
+func hasMetadata(metadata map[string]string, key, value string) bool {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) && v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// midnight truncates t to the start of its day in UTC
+func midnight(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// LifecycleWorker periodically applies the lifecycle rules of every bucket.
+// onExpire, if set, is called for each object it expires so that state kept
+// outside of storage, such as vector embeddings, can follow.
+type LifecycleWorker struct {
+	service  Service
+	interval time.Duration
+	onExpire func(context.Context, ExpiredObject)
+	logger   logger.Logger
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewLifecycleWorker creates a worker that applies lifecycle rules every interval
+func NewLifecycleWorker(service Service, interval time.Duration, onExpire func(context.Context, ExpiredObject), logger logger.Logger) *LifecycleWorker {
+	return &LifecycleWorker{
+		service:  service,
+		interval: interval,
+		onExpire: onExpire,
+		logger:   logger,
+	}
+}
+
+// Start starts the background expiration loop
+func (w *LifecycleWorker) Start(ctx context.Context) error {
+	if w.interval <= 0 {
+		return fmt.Errorf("lifecycle worker requires a positive interval")
+	}
+	if w.stopCh != nil {
+		return fmt.Errorf("lifecycle worker already started")
+	}
+
+	w.stopCh = make(chan struct{})
+	w.wg.Add(1)
+	go w.run(ctx)
+
+	w.logger.Info("Lifecycle worker started", "interval", w.interval)
+	return nil
+}
+
+// Stop stops the background expiration loop and waits for it to exit
+func (w *LifecycleWorker) Stop() error {
+	if w.stopCh == nil {
+		return nil
+	}
+	close(w.stopCh)
+	w.wg.Wait()
+	w.stopCh = nil
+	return nil
+}
+
+// Sweep expires every object that is due now and returns how many were expired
+func (w *LifecycleWorker) Sweep(ctx context.Context) (int, error) {
+	expired, err := w.service.ExpireObjects(ctx, time.Now())
+	if w.onExpire != nil {
+		for _, object := range expired {
+			w.onExpire(ctx, object)
+		}
+	}
+	return len(expired), err
+}
+
+func (w *LifecycleWorker) run(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+			if expired, err := w.Sweep(ctx); err != nil {
+				w.logger.Warn("Lifecycle sweep failed", "error", err)
+			} else if expired > 0 {
+				w.logger.Info("Lifecycle sweep completed", "expired", expired)
+			}
+		}
+	}
+}
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"context"
+	"io"
 	"time"
+
+	"github.com/8fs-io/core/internal/domain/policy"
 )
 
 // Bucket represents a storage bucket
@@ -14,7 +17,114 @@ type Bucket struct {
 	Size        int64             `json:"size"` // total size in bytes
 }
 
-// Object represents a storage object
+// Bucket versioning states
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+)
+
+// VersioningConfiguration is the versioning state of a bucket. Status is
+// empty until versioning is first configured.
+type VersioningConfiguration struct {
+	Status string `json:"status"`
+}
+
+// Lifecycle rule states
+const (
+	LifecycleEnabled  = "Enabled"
+	LifecycleDisabled = "Disabled"
+)
+
+// LifecycleConfiguration holds the lifecycle rules of a bucket
+type LifecycleConfiguration struct {
+	Rules []LifecycleRule `json:"rules"`
+}
+
+// LifecycleRule expires the current version of the objects matched by Filter
+type LifecycleRule struct {
+	ID         string              `json:"id,omitempty"`
+	Status     string              `json:"status"`
+	Filter     LifecycleFilter     `json:"filter"`
+	Expiration LifecycleExpiration `json:"expiration"`
+}
+
+// LifecycleFilter selects objects by key prefix, tags and user metadata. An
+// object matches when it satisfies every condition that is set.
+type LifecycleFilter struct {
+	Prefix   string            `json:"prefix,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"` // x-amz-meta-* values, keys compared case-insensitively
+}
+
+// LifecycleExpiration sets when matched objects expire: a number of Days
+// after they were last modified, or on a fixed Date
+type LifecycleExpiration struct {
+	Days int        `json:"days,omitempty"`
+	Date *time.Time `json:"date,omitempty"`
+}
+
+// ExpiredObject identifies an object removed by a lifecycle rule
+type ExpiredObject struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	RuleID string `json:"rule_id,omitempty"`
+}
+
+// CORSConfiguration holds the CORS rules of a bucket. The first rule that
+// matches a request applies.
+type CORSConfiguration struct {
+	Rules []CORSRule `json:"rules"`
+}
+
+// CORSRule allows cross-origin requests from AllowedOrigins using
+// AllowedMethods. Origins and headers may contain one * wildcard.
+type CORSRule struct {
+	ID             string   `json:"id,omitempty"`
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers,omitempty"`
+	ExposeHeaders  []string `json:"expose_headers,omitempty"`
+	MaxAgeSeconds  int      `json:"max_age_seconds,omitempty"`
+}
+
+// ObjectLockEnabled is the status of a bucket with Object Lock enabled
+const ObjectLockEnabled = "Enabled"
+
+// Object Lock retention modes. GOVERNANCE retention can be lifted by callers
+// allowed to bypass it; COMPLIANCE retention can only ever be extended.
+const (
+	RetentionGovernance = "GOVERNANCE"
+	RetentionCompliance = "COMPLIANCE"
+)
+
+// ObjectLockConfiguration enables Object Lock on a bucket, optionally with a
+// retention applied to new object versions that do not set their own
+type ObjectLockConfiguration struct {
+	ObjectLockEnabled string            `json:"object_lock_enabled"`
+	DefaultRetention  *DefaultRetention `json:"default_retention,omitempty"`
+}
+
+// DefaultRetention retains new object versions for a number of Days or Years
+// from when they are written
+type DefaultRetention struct {
+	Mode  string `json:"mode"`
+	Days  int    `json:"days,omitempty"`
+	Years int    `json:"years,omitempty"`
+}
+
+// ObjectRetention protects an object version from deletion and overwrite
+// until RetainUntil
+type ObjectRetention struct {
+	Mode        string    `json:"mode"`
+	RetainUntil time.Time `json:"retain_until"`
+}
+
+// NullVersionID identifies the version of an object written while
+// versioning was not enabled
+const NullVersionID = "null"
+
+// Object represents a storage object. Body is only set by GetObject, which
+// hands ownership of it to the caller; it must be closed once read.
 type Object struct {
 	Key          string            `json:"key"`
 	Bucket       string            `json:"bucket"`
@@ -23,7 +133,14 @@ type Object struct {
 	ETag         string            `json:"etag"`
 	LastModified time.Time         `json:"last_modified"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
-	Data         []byte            `json:"-"` // Actual object data
+	PartSizes    []int64           `json:"part_sizes,omitempty"` // set for objects uploaded in parts
+	VersionID    string            `json:"version_id,omitempty"` // set in buckets that have versioning configured
+	Tags         map[string]string `json:"tags,omitempty"`
+	Encryption   *Encryption       `json:"encryption,omitempty"` // set for objects encrypted at rest
+	Checksums    Checksums         `json:"checksums,omitempty"`  // x-amz-checksum-* digests stored with the object
+	Retention    *ObjectRetention  `json:"retention,omitempty"`  // Object Lock retention of the version
+	LegalHold    bool              `json:"legal_hold,omitempty"` // protects the version until released
+	Body         io.ReadSeekCloser `json:"-"`                    // Object data, streamed from storage
 }
 
 // ObjectInfo represents object metadata without data
@@ -34,6 +151,75 @@ type ObjectInfo struct {
 	ETag         string            `json:"etag"`
 	LastModified time.Time         `json:"last_modified"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
+	PartSizes    []int64           `json:"part_sizes,omitempty"`
+	VersionID    string            `json:"version_id,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Encryption   *Encryption       `json:"encryption,omitempty"`
+	Checksums    Checksums         `json:"checksums,omitempty"`
+	Retention    *ObjectRetention  `json:"retention,omitempty"`
+	LegalHold    bool              `json:"legal_hold,omitempty"`
+	DeleteMarker bool              `json:"delete_marker,omitempty"`
+	IsLatest     bool              `json:"is_latest,omitempty"` // only set when listing versions
+}
+
+// DeleteResult describes the effect of deleting an object or one of its versions
+type DeleteResult struct {
+	VersionID    string `json:"version_id,omitempty"`    // the version removed, or the delete marker created
+	DeleteMarker bool   `json:"delete_marker,omitempty"` // whether that version is a delete marker
+}
+
+// PutObjectOptions represents options for storing an object
+type PutObjectOptions struct {
+	ContentType string                `json:"content_type,omitempty"`
+	Metadata    map[string]string     `json:"metadata,omitempty"`
+	Tags        map[string]string     `json:"tags,omitempty"`
+	Conditions  *Conditions           `json:"-"` // If-Match / If-None-Match preconditions
+	Encryption  *ServerSideEncryption `json:"-"` // the service default if nil
+	ContentMD5  string                `json:"-"` // base64 MD5 the content must match
+	Checksums   Checksums             `json:"-"` // digests the content must match, computed and stored if empty
+	Retention   *ObjectRetention      `json:"-"` // the bucket's default retention if nil
+	LegalHold   bool                  `json:"-"`
+}
+
+// MaxObjectTags is the maximum number of tags on an object
+const MaxObjectTags = 10
+
+// Metadata and tagging directives of a copy request
+const (
+	MetadataDirectiveCopy    = "COPY"
+	MetadataDirectiveReplace = "REPLACE"
+)
+
+// CopyObjectOptions represents options for a server-side copy. ContentType and
+// Metadata are only used with MetadataDirectiveReplace, Tags only with a
+// TaggingDirective of REPLACE.
+type CopyObjectOptions struct {
+	SourceVersionID   string                `json:"source_version_id,omitempty"` // the current version if empty
+	MetadataDirective string                `json:"metadata_directive,omitempty"`
+	ContentType       string                `json:"content_type,omitempty"`
+	Metadata          map[string]string     `json:"metadata,omitempty"`
+	TaggingDirective  string                `json:"tagging_directive,omitempty"`
+	Tags              map[string]string     `json:"tags,omitempty"`
+	SourceConditions  *Conditions           `json:"-"` // x-amz-copy-source-if-* preconditions
+	Conditions        *Conditions           `json:"-"` // preconditions on the destination
+	SourceEncryption  *ServerSideEncryption `json:"-"` // key of an SSE-C source
+	Encryption        *ServerSideEncryption `json:"-"` // encryption of the destination
+}
+
+// UploadPartOptions represents options for uploading a part
+type UploadPartOptions struct {
+	Encryption *ServerSideEncryption `json:"-"` // key of an SSE-C upload
+	ContentMD5 string                `json:"-"` // base64 MD5 the part must match
+	Checksums  Checksums             `json:"-"` // digests the part must match, computed if empty
+}
+
+// CopyPartOptions represents options for copying a source object into a part
+type CopyPartOptions struct {
+	SourceVersionID  string                `json:"source_version_id,omitempty"`
+	SourceRange      string                `json:"source_range,omitempty"` // bytes=first-last, the whole source if empty
+	SourceConditions *Conditions           `json:"-"`
+	SourceEncryption *ServerSideEncryption `json:"-"` // key of an SSE-C source
+	Encryption       *ServerSideEncryption `json:"-"` // key of an SSE-C upload
 }
 
 // ListOptions represents options for listing operations
@@ -52,6 +238,84 @@ type ListResult struct {
 	NextMarker     string       `json:"next_marker,omitempty"`
 }
 
+// ListVersionsOptions represents options for listing object versions
+type ListVersionsOptions struct {
+	Prefix          string `json:"prefix,omitempty"`
+	Delimiter       string `json:"delimiter,omitempty"`
+	KeyMarker       string `json:"key_marker,omitempty"`
+	VersionIDMarker string `json:"version_id_marker,omitempty"`
+	MaxKeys         int    `json:"max_keys,omitempty"`
+}
+
+// ListVersionsResult represents the result of listing object versions.
+// Versions holds both object versions and delete markers, ordered by key and
+// then from newest to oldest.
+type ListVersionsResult struct {
+	Versions            []ObjectInfo `json:"versions"`
+	CommonPrefixes      []string     `json:"common_prefixes,omitempty"`
+	IsTruncated         bool         `json:"is_truncated"`
+	NextKeyMarker       string       `json:"next_key_marker,omitempty"`
+	NextVersionIDMarker string       `json:"next_version_id_marker,omitempty"`
+}
+
+// MultipartUpload represents an in-progress multipart upload
+type MultipartUpload struct {
+	UploadID    string            `json:"upload_id"`
+	Bucket      string            `json:"bucket"`
+	Key         string            `json:"key"`
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Initiated   time.Time         `json:"initiated"`
+	Encryption  *Encryption       `json:"encryption,omitempty"`
+}
+
+// Part represents an uploaded part of a multipart upload
+type Part struct {
+	PartNumber   int       `json:"part_number"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Checksums    Checksums `json:"-"` // verified on upload and returned, not stored
+}
+
+// CompletedPart identifies a part in a complete multipart upload request
+type CompletedPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// ListPartsOptions represents options for listing the parts of an upload
+type ListPartsOptions struct {
+	PartNumberMarker int `json:"part_number_marker,omitempty"`
+	MaxParts         int `json:"max_parts,omitempty"`
+}
+
+// ListPartsResult represents the result of listing the parts of an upload
+type ListPartsResult struct {
+	Upload               *MultipartUpload `json:"upload"`
+	Parts                []Part           `json:"parts"`
+	IsTruncated          bool             `json:"is_truncated"`
+	NextPartNumberMarker int              `json:"next_part_number_marker,omitempty"`
+}
+
+// ListUploadsOptions represents options for listing multipart uploads
+type ListUploadsOptions struct {
+	Prefix         string `json:"prefix,omitempty"`
+	Delimiter      string `json:"delimiter,omitempty"`
+	KeyMarker      string `json:"key_marker,omitempty"`
+	UploadIDMarker string `json:"upload_id_marker,omitempty"`
+	MaxUploads     int    `json:"max_uploads,omitempty"`
+}
+
+// ListUploadsResult represents the result of listing multipart uploads
+type ListUploadsResult struct {
+	Uploads            []*MultipartUpload `json:"uploads"`
+	CommonPrefixes     []string           `json:"common_prefixes,omitempty"`
+	IsTruncated        bool               `json:"is_truncated"`
+	NextKeyMarker      string             `json:"next_key_marker,omitempty"`
+	NextUploadIDMarker string             `json:"next_upload_id_marker,omitempty"`
+}
+
 // Repository defines the storage repository interface
 type Repository interface {
 	// Bucket operations
@@ -59,16 +323,59 @@ type Repository interface {
 	DeleteBucket(ctx context.Context, name string) error
 	GetBucket(ctx context.Context, name string) (*Bucket, error)
 	ListBuckets(ctx context.Context) ([]*Bucket, error)
+	ListBucketNames(ctx context.Context) ([]string, error)
 	BucketExists(ctx context.Context, name string) (bool, error)
 
-	// Object operations
-	PutObject(ctx context.Context, object *Object) error
+	// Bucket configuration operations. Subresources such as versioning are
+	// stored per bucket under a name, GetBucketConfig reporting whether one
+	// was found.
+	GetBucketConfig(ctx context.Context, bucket, name string, v interface{}) (bool, error)
+	PutBucketConfig(ctx context.Context, bucket, name string, v interface{}) error
+	DeleteBucketConfig(ctx context.Context, bucket, name string) error
+
+	// Object operations. PutObject streams data to storage and sets the
+	// Size and ETag of object from what was written. Objects with an
+	// Encryption are encrypted as they are written, and read back as
+	// stored: decrypting their Body is left to the caller.
+	PutObject(ctx context.Context, object *Object, data io.Reader) error
 	GetObject(ctx context.Context, bucket, key string) (*Object, error)
 	GetObjectInfo(ctx context.Context, bucket, key string) (*ObjectInfo, error)
 	DeleteObject(ctx context.Context, bucket, key string) error
 	ListObjects(ctx context.Context, bucket string, opts ListOptions) (*ListResult, error)
 	ObjectExists(ctx context.Context, bucket, key string) (bool, error)
 
+	// Version history operations. The current version of a key is the object
+	// itself; noncurrent versions and delete markers are kept in its history,
+	// newest first, and addressed by version ID.
+	ArchiveObject(ctx context.Context, bucket, key string) (string, error)
+	PutDeleteMarker(ctx context.Context, bucket string, marker *ObjectInfo) error
+	GetObjectVersion(ctx context.Context, bucket, key, versionID string) (*Object, error)
+	GetObjectVersionInfo(ctx context.Context, bucket, key, versionID string) (*ObjectInfo, error)
+	DeleteObjectVersion(ctx context.Context, bucket, key, versionID string) error
+	RestoreObjectVersion(ctx context.Context, bucket, key, versionID string) error
+	ListObjectHistory(ctx context.Context, bucket, key string) ([]ObjectInfo, error)
+	ListObjectVersions(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error)
+
+	// PutObjectTags replaces the tags of the current version of an object, or
+	// of the noncurrent version versionID
+	PutObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) error
+
+	// PutObjectLock replaces the retention and legal hold of a version,
+	// addressed the same way as by PutObjectTags
+	PutObjectLock(ctx context.Context, bucket, key, versionID string, retention *ObjectRetention, legalHold bool) error
+
+	// Multipart upload operations. Parts are staged until the upload is
+	// completed, at which point they are concatenated into object. Parts of
+	// encrypted uploads are encrypted with the unsealed encryption passed
+	// to PutPart.
+	CreateMultipartUpload(ctx context.Context, upload *MultipartUpload) error
+	GetMultipartUpload(ctx context.Context, uploadID string) (*MultipartUpload, error)
+	ListMultipartUploads(ctx context.Context, bucket string) ([]*MultipartUpload, error)
+	PutPart(ctx context.Context, uploadID string, partNumber int, data io.Reader, encryption *Encryption) (*Part, error)
+	ListParts(ctx context.Context, uploadID string) ([]Part, error)
+	CompleteMultipartUpload(ctx context.Context, uploadID string, parts []Part, object *Object) error
+	AbortMultipartUpload(ctx context.Context, uploadID string) error
+
 	// Utility operations
 	GetStorageStats(ctx context.Context) (map[string]interface{}, error)
 	HealthCheck(ctx context.Context) error
@@ -81,13 +388,82 @@ type Service interface {
 	DeleteBucket(ctx context.Context, name string) error
 	GetBucket(ctx context.Context, name string) (*Bucket, error)
 	ListBuckets(ctx context.Context) ([]*Bucket, error)
+	PutBucketVersioning(ctx context.Context, bucket, status string) error
+	GetBucketVersioning(ctx context.Context, bucket string) (string, error)
 
-	// Object operations
-	PutObject(ctx context.Context, bucket, key string, data []byte, contentType string, metadata map[string]string) (*Object, error)
+	// Bucket lifecycle operations. ExpireObjects applies the lifecycle rules
+	// of every bucket as of now and reports the objects it expired.
+	PutBucketLifecycle(ctx context.Context, bucket string, config *LifecycleConfiguration) error
+	GetBucketLifecycle(ctx context.Context, bucket string) (*LifecycleConfiguration, error)
+	DeleteBucketLifecycle(ctx context.Context, bucket string) error
+	ExpireObjects(ctx context.Context, now time.Time) ([]ExpiredObject, error)
+
+	// Bucket policy operations. Documents are stored as given;
+	// BucketPolicy returns the parsed policy to evaluate requests against,
+	// or nil when the bucket has none.
+	PutBucketPolicy(ctx context.Context, bucket, document string) error
+	GetBucketPolicy(ctx context.Context, bucket string) (string, error)
+	DeleteBucketPolicy(ctx context.Context, bucket string) error
+	BucketPolicy(ctx context.Context, bucket string) (*policy.Policy, error)
+
+	// Bucket CORS operations. BucketCors returns the configuration to match
+	// cross-origin requests against, or nil when the bucket has none.
+	PutBucketCors(ctx context.Context, bucket string, config *CORSConfiguration) error
+	GetBucketCors(ctx context.Context, bucket string) (*CORSConfiguration, error)
+	DeleteBucketCors(ctx context.Context, bucket string) error
+	BucketCors(ctx context.Context, bucket string) (*CORSConfiguration, error)
+
+	// Bucket Object Lock operations. Object Lock can only be enabled on a
+	// bucket with versioning enabled, and cannot be disabled again.
+	PutObjectLockConfiguration(ctx context.Context, bucket string, config *ObjectLockConfiguration) error
+	GetObjectLockConfiguration(ctx context.Context, bucket string) (*ObjectLockConfiguration, error)
+
+	// Object operations. GetObject decrypts objects encrypted with the
+	// master key; SSE-C objects can only be read with GetObjectVersion.
+	PutObject(ctx context.Context, bucket, key string, data io.Reader, opts PutObjectOptions) (*Object, error)
 	GetObject(ctx context.Context, bucket, key string) (*Object, error)
 	GetObjectInfo(ctx context.Context, bucket, key string) (*ObjectInfo, error)
 	DeleteObject(ctx context.Context, bucket, key string) error
 	ListObjects(ctx context.Context, bucket string, opts ListOptions) (*ListResult, error)
+	CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyObjectOptions) (*Object, error)
+
+	// Versioned object operations. An empty versionID addresses the current
+	// version; deleting it in a versioned bucket creates a delete marker.
+	// Reading an SSE-C object takes the key it was stored with in sse.
+	GetObjectVersion(ctx context.Context, bucket, key, versionID string, sse *ServerSideEncryption) (*Object, error)
+	GetObjectVersionInfo(ctx context.Context, bucket, key, versionID string) (*ObjectInfo, error)
+	DeleteObjectVersion(ctx context.Context, bucket, key, versionID string, bypassGovernance bool) (*DeleteResult, error)
+	ListObjectVersions(ctx context.Context, bucket string, opts ListVersionsOptions) (*ListVersionsResult, error)
+
+	// Object tagging operations, returning the version that was tagged
+	PutObjectTagging(ctx context.Context, bucket, key, versionID string, tags map[string]string) (*ObjectInfo, error)
+	DeleteObjectTagging(ctx context.Context, bucket, key, versionID string) (*ObjectInfo, error)
+
+	// Object retention and legal hold operations, returning the version that
+	// was updated. Versions under retention or legal hold cannot be deleted;
+	// bypassGovernance lets callers allowed to do so shorten or remove
+	// GOVERNANCE retention and delete versions under it.
+	PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *ObjectRetention, bypassGovernance bool) (*ObjectInfo, error)
+	GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*ObjectRetention, error)
+	PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, on bool) (*ObjectInfo, error)
+	GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (bool, error)
+
+	// Multipart upload operations. Parts of an SSE-C upload must be sent
+	// with the key the upload was created with.
+	CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string, sse *ServerSideEncryption) (*MultipartUpload, error)
+	UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, data io.Reader, opts UploadPartOptions) (*Part, error)
+	UploadPartCopy(ctx context.Context, srcBucket, srcKey, bucket, key, uploadID string, partNumber int, opts CopyPartOptions) (*Part, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []CompletedPart) (*Object, error)
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
+	ListParts(ctx context.Context, bucket, key, uploadID string, opts ListPartsOptions) (*ListPartsResult, error)
+	ListMultipartUploads(ctx context.Context, bucket string, opts ListUploadsOptions) (*ListUploadsResult, error)
+	AbortExpiredUploads(ctx context.Context, olderThan time.Time) (int, error)
+
+	// Change feed operations. ListChanges returns the puts, copies, deletes
+	// and metadata changes after a cursor in the order they were made;
+	// CompactChanges drops old and superseded changes.
+	ListChanges(ctx context.Context, opts ChangeFeedOptions) (*ChangeFeed, error)
+	CompactChanges(ctx context.Context, dropBefore, compactBefore time.Time) (int, error)
 
 	// Utility operations
 	GetStorageStats(ctx context.Context) (map[string]interface{}, error)
@@ -99,4 +475,5 @@ type Validator interface {
 	ValidateBucketName(name string) error
 	ValidateObjectKey(key string) error
 	ValidateMetadata(metadata map[string]string) error
+	ValidateTags(tags map[string]string) error
 }
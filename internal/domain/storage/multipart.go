@@ -0,0 +1,504 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/logger"
+)
+
+const (
+	// MaxPartNumber is the highest part number S3 accepts
+	MaxPartNumber = 10000
+
+	defaultMaxParts   = 1000
+	defaultMaxUploads = 1000
+)
+
+// CreateMultipartUpload starts a new multipart upload
+func (s *service) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string, sse *ServerSideEncryption) (*MultipartUpload, error) {
+	if err := s.validator.ValidateObjectKey(key); err != nil {
+		return nil, err
+	}
+	if err := s.validator.ValidateMetadata(metadata); err != nil {
+		return nil, err
+	}
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+	encryption, err := s.newEncryption(bucket, key, sse)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadID, err := generateUploadID()
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to generate upload ID", err)
+	}
+
+	upload := &MultipartUpload{
+		UploadID:    uploadID,
+		Bucket:      bucket,
+		Key:         key,
+		ContentType: contentType,
+		Metadata:    metadata,
+		Initiated:   time.Now().UTC(),
+		Encryption:  encryption,
+	}
+
+	if err := s.repo.CreateMultipartUpload(ctx, upload); err != nil {
+		s.logger.Error("Failed to create multipart upload", "bucket", bucket, "key", key, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to create multipart upload", err)
+	}
+
+	s.logger.Info("Multipart upload created", "bucket", bucket, "key", key, "upload_id", uploadID)
+	return upload, nil
+}
+
+// UploadPart stages a single part of a multipart upload, encrypted like the
+// upload is, after checking it against the digests in opts
+func (s *service) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, data io.Reader, opts UploadPartOptions) (*Part, error) {
+	if partNumber < 1 || partNumber > MaxPartNumber {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Part number must be an integer between 1 and 10000, inclusive").
+			WithContext("part_number", partNumber)
+	}
+	if err := validateChecksums(opts.ContentMD5, opts.Checksums); err != nil {
+		return nil, err
+	}
+
+	unlock := s.uploads.rlock(bucket, uploadID)
+	defer unlock()
+
+	upload, err := s.getUpload(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.unsealEncryption(upload.Encryption, bucket, key, opts.Encryption); err != nil {
+		return nil, err
+	}
+
+	var verified *checksumReader
+	if opts.ContentMD5 != "" || len(opts.Checksums) > 0 {
+		verified = newChecksumReader(data, opts.ContentMD5, opts.Checksums)
+		data = verified
+	}
+
+	part, err := s.repo.PutPart(ctx, uploadID, partNumber, data, upload.Encryption)
+	if err != nil {
+		var appErr *errors.AppError
+		if errors.As(err, &appErr) && appErr.Code != errors.ErrCodeInternalError {
+			return nil, err
+		}
+		s.logger.Error("Failed to store part", "upload_id", uploadID, "part_number", partNumber, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to store part", err)
+	}
+	if verified != nil {
+		part.Checksums = verified.checksums
+	}
+
+	return part, nil
+}
+
+// CompleteMultipartUpload assembles the listed parts into the final object.
+// The upload is loaded under the lock of the key and of the upload, so that
+// it cannot be aborted, completed again or have its parts replaced meanwhile.
+func (s *service) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []CompletedPart) (*Object, error) {
+	unlock := s.locks.lock(bucket, key)
+	defer unlock()
+	unlockUpload := s.uploads.lock(bucket, uploadID)
+	defer unlockUpload()
+
+	upload, err := s.getUpload(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, errors.New(errors.ErrCodeMalformedXML, "You must specify at least one part")
+	}
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	staged, err := s.repo.ListParts(ctx, uploadID)
+	if err != nil {
+		s.logger.Error("Failed to list parts", "upload_id", uploadID, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to list parts", err)
+	}
+	byNumber := make(map[int]Part, len(staged))
+	for _, part := range staged {
+		byNumber[part.PartNumber] = part
+	}
+
+	for i := 1; i < len(parts); i++ {
+		if parts[i].PartNumber <= parts[i-1].PartNumber {
+			return nil, errors.ErrInvalidPartOrder.WithContext("part_number", parts[i].PartNumber)
+		}
+	}
+
+	// Validate the requested parts and build the multipart ETag, which is the
+	// MD5 of the concatenated binary part MD5s followed by the part count
+	selected := make([]Part, 0, len(parts))
+	partSizes := make([]int64, 0, len(parts))
+	digests := md5.New()
+	var size int64
+	for i, requested := range parts {
+		part, ok := byNumber[requested.PartNumber]
+		if !ok || trimETag(part.ETag) != trimETag(requested.ETag) {
+			return nil, errors.ErrInvalidPart.WithContext("part_number", requested.PartNumber)
+		}
+		if i < len(parts)-1 && part.Size < s.config.MinPartSize {
+			return nil, errors.ErrEntityTooSmall.WithContext("part_number", part.PartNumber)
+		}
+		sum, err := hex.DecodeString(trimETag(part.ETag))
+		if err != nil {
+			return nil, errors.ErrInvalidPart.WithContext("part_number", requested.PartNumber)
+		}
+		digests.Write(sum)
+		size += part.Size
+		selected = append(selected, part)
+		partSizes = append(partSizes, part.Size)
+	}
+
+	object := &Object{
+		Key:          upload.Key,
+		Bucket:       upload.Bucket,
+		Size:         size,
+		ContentType:  upload.ContentType,
+		ETag:         fmt.Sprintf("\"%x-%d\"", digests.Sum(nil), len(selected)),
+		LastModified: time.Now().UTC(),
+		Metadata:     upload.Metadata,
+		PartSizes:    partSizes,
+		Encryption:   upload.Encryption,
+	}
+	if object.Retention, err = s.newRetention(ctx, bucket, nil, false, object.LastModified); err != nil {
+		return nil, err
+	}
+
+	versionID, archived, err := s.prepareVersion(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	object.VersionID = versionID
+
+	if err := s.repo.CompleteMultipartUpload(ctx, uploadID, selected, object); err != nil {
+		s.abandonVersion(ctx, bucket, key, archived)
+		s.logger.Error("Failed to complete multipart upload", "upload_id", uploadID, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to complete multipart upload", err)
+	}
+	s.recordChange(ctx, Change{Type: ChangePut, Bucket: bucket, Key: key, VersionID: object.VersionID, ETag: object.ETag, Size: object.Size})
+
+	s.logger.Info("Multipart upload completed", "bucket", bucket, "key", key, "upload_id", uploadID, "parts", len(selected), "size", size)
+	return object, nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its staged parts
+func (s *service) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	unlock := s.uploads.lock(bucket, uploadID)
+	defer unlock()
+
+	if _, err := s.getUpload(ctx, bucket, key, uploadID); err != nil {
+		return err
+	}
+
+	if err := s.repo.AbortMultipartUpload(ctx, uploadID); err != nil {
+		s.logger.Error("Failed to abort multipart upload", "upload_id", uploadID, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to abort multipart upload", err)
+	}
+
+	s.logger.Info("Multipart upload aborted", "bucket", bucket, "key", key, "upload_id", uploadID)
+	return nil
+}
+
+// ListParts lists the parts staged for a multipart upload
+func (s *service) ListParts(ctx context.Context, bucket, key, uploadID string, opts ListPartsOptions) (*ListPartsResult, error) {
+	upload, err := s.getUpload(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := s.repo.ListParts(ctx, uploadID)
+	if err != nil {
+		s.logger.Error("Failed to list parts", "upload_id", uploadID, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to list parts", err)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	maxParts := opts.MaxParts
+	if maxParts <= 0 || maxParts > defaultMaxParts {
+		maxParts = defaultMaxParts
+	}
+
+	result := &ListPartsResult{Upload: upload, Parts: []Part{}}
+	for _, part := range parts {
+		if part.PartNumber <= opts.PartNumberMarker {
+			continue
+		}
+		if len(result.Parts) == maxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, part)
+	}
+	if result.IsTruncated {
+		result.NextPartNumberMarker = result.Parts[len(result.Parts)-1].PartNumber
+	}
+
+	return result, nil
+}
+
+// ListMultipartUploads lists in-progress multipart uploads in a bucket
+func (s *service) ListMultipartUploads(ctx context.Context, bucket string, opts ListUploadsOptions) (*ListUploadsResult, error) {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	uploads, err := s.repo.ListMultipartUploads(ctx, bucket)
+	if err != nil {
+		s.logger.Error("Failed to list multipart uploads", "bucket", bucket, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to list multipart uploads", err)
+	}
+
+	// Uploads are ordered by key, then by initiation time
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		if !uploads[i].Initiated.Equal(uploads[j].Initiated) {
+			return uploads[i].Initiated.Before(uploads[j].Initiated)
+		}
+		return uploads[i].UploadID < uploads[j].UploadID
+	})
+
+	maxUploads := opts.MaxUploads
+	if maxUploads <= 0 || maxUploads > defaultMaxUploads {
+		maxUploads = defaultMaxUploads
+	}
+
+	result := &ListUploadsResult{Uploads: []*MultipartUpload{}}
+	seenPrefixes := map[string]bool{}
+	pastUploadMarker := false
+	var lastKey, lastUploadID string
+	for _, upload := range uploads {
+		if !strings.HasPrefix(upload.Key, opts.Prefix) {
+			continue
+		}
+		if opts.KeyMarker != "" {
+			if upload.Key < opts.KeyMarker {
+				continue
+			}
+			// Without an upload ID marker the whole marker key is skipped
+			if upload.Key == opts.KeyMarker && (opts.UploadIDMarker == "" || !pastUploadMarker) {
+				pastUploadMarker = upload.UploadID == opts.UploadIDMarker
+				continue
+			}
+			// A common prefix marker skips every key rolled up under it
+			if opts.Delimiter != "" && strings.HasSuffix(opts.KeyMarker, opts.Delimiter) && strings.HasPrefix(upload.Key, opts.KeyMarker) {
+				continue
+			}
+		}
+
+		entry := upload.Key
+		if opts.Delimiter != "" {
+			rest := strings.TrimPrefix(upload.Key, opts.Prefix)
+			if idx := strings.Index(rest, opts.Delimiter); idx >= 0 {
+				entry = opts.Prefix + rest[:idx+len(opts.Delimiter)]
+				if seenPrefixes[entry] {
+					continue
+				}
+			}
+		}
+
+		if len(result.Uploads)+len(result.CommonPrefixes) == maxUploads {
+			result.IsTruncated = true
+			result.NextKeyMarker = lastKey
+			result.NextUploadIDMarker = lastUploadID
+			break
+		}
+
+		if entry != upload.Key {
+			seenPrefixes[entry] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, entry)
+			lastKey, lastUploadID = entry, ""
+			continue
+		}
+		result.Uploads = append(result.Uploads, upload)
+		lastKey, lastUploadID = upload.Key, upload.UploadID
+	}
+
+	return result, nil
+}
+
+// AbortExpiredUploads aborts every multipart upload initiated before olderThan
+// and returns how many were removed
+func (s *service) AbortExpiredUploads(ctx context.Context, olderThan time.Time) (int, error) {
+	uploads, err := s.repo.ListMultipartUploads(ctx, "")
+	if err != nil {
+		return 0, errors.Wrap(errors.ErrCodeInternalError, "Failed to list multipart uploads", err)
+	}
+
+	aborted := 0
+	for _, upload := range uploads {
+		if !upload.Initiated.Before(olderThan) {
+			continue
+		}
+		unlock := s.uploads.lock(upload.Bucket, upload.UploadID)
+		err := s.repo.AbortMultipartUpload(ctx, upload.UploadID)
+		unlock()
+		if errors.IsErrorCode(err, errors.ErrCodeNoSuchUpload) {
+			continue // completed or aborted since it was listed
+		}
+		if err != nil {
+			s.logger.Warn("Failed to abort expired multipart upload", "upload_id", upload.UploadID, "error", err)
+			continue
+		}
+		s.logger.Info("Expired multipart upload aborted", "bucket", upload.Bucket, "key", upload.Key, "upload_id", upload.UploadID)
+		aborted++
+	}
+
+	return aborted, nil
+}
+
+// getUpload loads an upload and checks that it belongs to bucket/key
+func (s *service) getUpload(ctx context.Context, bucket, key, uploadID string) (*MultipartUpload, error) {
+	if err := s.validator.ValidateBucketName(bucket); err != nil {
+		return nil, err
+	}
+	if err := s.validator.ValidateObjectKey(key); err != nil {
+		return nil, err
+	}
+	if uploadID == "" {
+		return nil, errors.ErrNoSuchUpload.WithContext("upload_id", uploadID)
+	}
+
+	upload, err := s.repo.GetMultipartUpload(ctx, uploadID)
+	if err != nil {
+		if errors.IsErrorCode(err, errors.ErrCodeNoSuchUpload) {
+			return nil, err
+		}
+		s.logger.Error("Failed to get multipart upload", "upload_id", uploadID, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to get multipart upload", err)
+	}
+	if upload.Bucket != bucket || upload.Key != key {
+		return nil, errors.ErrNoSuchUpload.WithContext("upload_id", uploadID)
+	}
+
+	return upload, nil
+}
+
+// requireBucket validates the bucket name and checks that the bucket exists
+func (s *service) requireBucket(ctx context.Context, bucket string) error {
+	if err := s.validator.ValidateBucketName(bucket); err != nil {
+		return err
+	}
+
+	exists, err := s.repo.BucketExists(ctx, bucket)
+	if err != nil {
+		s.logger.Error("Failed to check bucket existence", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to check bucket existence", err)
+	}
+	if !exists {
+		return errors.ErrBucketNotFound.WithContext("bucket", bucket)
+	}
+	return nil
+}
+
+// generateUploadID returns a random, URL-safe upload identifier
+func generateUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// trimETag strips the surrounding quotes from an ETag
+func trimETag(etag string) string {
+	return strings.Trim(strings.TrimSpace(etag), "\"")
+}
+
+// MultipartSweeper periodically aborts multipart uploads that were never
+// completed, reclaiming the disk space held by their staged parts
+type MultipartSweeper struct {
+	service  Service
+	interval time.Duration
+	expiry   time.Duration
+	logger   logger.Logger
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewMultipartSweeper creates a sweeper that removes uploads older than expiry
+// every interval
+func NewMultipartSweeper(service Service, interval, expiry time.Duration, logger logger.Logger) *MultipartSweeper {
+	return &MultipartSweeper{
+		service:  service,
+		interval: interval,
+		expiry:   expiry,
+		logger:   logger,
+	}
+}
+
+// Start starts the background sweep loop
+func (m *MultipartSweeper) Start(ctx context.Context) error {
+	if m.interval <= 0 || m.expiry <= 0 {
+		return fmt.Errorf("multipart sweeper requires a positive interval and expiry")
+	}
+	if m.stopCh != nil {
+		return fmt.Errorf("multipart sweeper already started")
+	}
+
+	m.stopCh = make(chan struct{})
+	m.wg.Add(1)
+	go m.run(ctx)
+
+	m.logger.Info("Multipart upload sweeper started", "interval", m.interval, "expiry", m.expiry)
+	return nil
+}
+
+// Stop stops the background sweep loop and waits for it to exit
+func (m *MultipartSweeper) Stop() error {
+	if m.stopCh == nil {
+		return nil
+	}
+	close(m.stopCh)
+	m.wg.Wait()
+	m.stopCh = nil
+	return nil
+}
+
+// Sweep aborts all uploads older than the configured expiry
+func (m *MultipartSweeper) Sweep(ctx context.Context) (int, error) {
+	return m.service.AbortExpiredUploads(ctx, time.Now().Add(-m.expiry))
+}
+
+func (m *MultipartSweeper) run(ctx context.Context) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			if aborted, err := m.Sweep(ctx); err != nil {
+				m.logger.Warn("Multipart upload sweep failed", "error", err)
+			} else if aborted > 0 {
+				m.logger.Info("Multipart upload sweep completed", "aborted", aborted)
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// bucketConfigObjectLock names the stored Object Lock configuration of a bucket
+const bucketConfigObjectLock = "object-lock"
+
+// PutObjectLockConfiguration enables Object Lock on a bucket and replaces its
+// default retention. Object Lock relies on versioning to keep the versions
+// that writes replace, so versioning must already be enabled, and cannot be
+// suspended from then on.
+func (s *service) PutObjectLockConfiguration(ctx context.Context, bucket string, config *ObjectLockConfiguration) error {
+	if err := validateObjectLock(config); err != nil {
+		return err
+	}
+	status, err := s.GetBucketVersioning(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if status != VersioningEnabled {
+		return errors.New(errors.ErrCodeInvalidBucketState, "Versioning must be 'Enabled' on the bucket to apply a Object Lock configuration").
+			WithContext("bucket", bucket)
+	}
+
+	if err := s.repo.PutBucketConfig(ctx, bucket, bucketConfigObjectLock, config); err != nil {
+		s.logger.Error("Failed to store bucket Object Lock configuration", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to store bucket Object Lock configuration", err)
+	}
+
+	s.logger.Info("Bucket Object Lock configuration updated", "bucket", bucket, "default_retention", config.DefaultRetention != nil)
+	return nil
+}
+
+// GetObjectLockConfiguration returns the Object Lock configuration of a bucket
+func (s *service) GetObjectLockConfiguration(ctx context.Context, bucket string) (*ObjectLockConfiguration, error) {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	config, err := s.objectLock(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, errors.ErrNoSuchObjectLock.WithContext("bucket", bucket)
+	}
+	return config, nil
+}
+
+// PutObjectRetention replaces the retention of an object version, removing it
+// if retention is nil. Unexpired COMPLIANCE retention can only be extended;
+// GOVERNANCE retention can only be shortened or removed with bypassGovernance.
+func (s *service) PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *ObjectRetention, bypassGovernance bool) (*ObjectInfo, error) {
+	now := time.Now()
+	if retention != nil {
+		if err := validateRetention(retention, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.setObjectLock(ctx, bucket, key, versionID, func(info *ObjectInfo) error {
+		if err := checkRetentionChange(info.Retention, retention, bypassGovernance, now); err != nil {
+			return err
+		}
+		info.Retention = retention
+		return nil
+	})
+}
+
+// GetObjectRetention returns the retention of an object version
+func (s *service) GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*ObjectRetention, error) {
+	info, err := s.lockedVersionInfo(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if info.Retention == nil {
+		return nil, errors.ErrNoSuchRetention.WithContext("bucket", bucket).WithContext("key", key)
+	}
+	return info.Retention, nil
+}
+
+// PutObjectLegalHold places or releases a legal hold on an object version
+func (s *service) PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, on bool) (*ObjectInfo, error) {
+	return s.setObjectLock(ctx, bucket, key, versionID, func(info *ObjectInfo) error {
+		info.LegalHold = on
+		return nil
+	})
+}
+
+// GetObjectLegalHold reports whether an object version is under legal hold
+func (s *service) GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (bool, error) {
+	info, err := s.lockedVersionInfo(ctx, bucket, key, versionID)
+	if err != nil {
+		return false, err
+	}
+	return info.LegalHold, nil
+}
+
+// setObjectLock applies update to the retention and legal hold of a version
+// and stores them, holding the key's lock so that a concurrent write cannot
+// move the version into the history midway
+func (s *service) setObjectLock(ctx context.Context, bucket, key, versionID string, update func(info *ObjectInfo) error) (*ObjectInfo, error) {
+	if err := s.validator.ValidateBucketName(bucket); err != nil {
+		return nil, err
+	}
+	if err := s.validator.ValidateObjectKey(key); err != nil {
+		return nil, err
+	}
+	if versionID != "" {
+		if err := s.validateVersion(bucket, key, versionID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.requireObjectLock(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	unlock := s.locks.lock(bucket, key)
+	defer unlock()
+
+	info, versionID, err := s.versionToUpdate(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(info); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.PutObjectLock(ctx, bucket, key, versionID, info.Retention, info.LegalHold); err != nil {
+		s.logger.Error("Failed to store object lock", "bucket", bucket, "key", key, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to store object lock", err)
+	}
+	s.recordChange(ctx, Change{Type: ChangeMetadata, Bucket: bucket, Key: key, VersionID: info.VersionID, ETag: info.ETag, Size: info.Size})
+
+	s.logger.Info("Object lock updated", "bucket", bucket, "key", key, "retention", info.Retention != nil, "legal_hold", info.LegalHold)
+	return info, nil
+}
+
+// lockedVersionInfo returns the metadata of a version in a bucket with
+// Object Lock enabled
+func (s *service) lockedVersionInfo(ctx context.Context, bucket, key, versionID string) (*ObjectInfo, error) {
+	if err := s.validator.ValidateBucketName(bucket); err != nil {
+		return nil, err
+	}
+	if err := s.validator.ValidateObjectKey(key); err != nil {
+		return nil, err
+	}
+	if err := s.requireObjectLock(ctx, bucket); err != nil {
+		return nil, err
+	}
+	return s.GetObjectVersionInfo(ctx, bucket, key, versionID)
+}
+
+// requireObjectLock checks that a bucket exists and has Object Lock enabled
+func (s *service) requireObjectLock(ctx context.Context, bucket string) error {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+	config, err := s.objectLock(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return errors.New(errors.ErrCodeInvalidRequest, "Bucket is missing Object Lock Configuration").
+			WithContext("bucket", bucket)
+	}
+	return nil
+}
+
+// objectLock returns the Object Lock configuration of a bucket, or nil when
+// Object Lock is not enabled on it
+func (s *service) objectLock(ctx context.Context, bucket string) (*ObjectLockConfiguration, error) {
+	var config ObjectLockConfiguration
+	found, err := s.repo.GetBucketConfig(ctx, bucket, bucketConfigObjectLock, &config)
+	if err != nil {
+		s.logger.Error("Failed to read bucket Object Lock configuration", "bucket", bucket, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read bucket Object Lock configuration", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &config, nil
+}
+
+// newRetention returns the retention to store a new version with: the one
+// requested, or else the default retention of the bucket, counted from now
+func (s *service) newRetention(ctx context.Context, bucket string, requested *ObjectRetention, legalHold bool, now time.Time) (*ObjectRetention, error) {
+	config, err := s.objectLock(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		if requested != nil || legalHold {
+			return nil, errors.New(errors.ErrCodeInvalidRequest, "Bucket is missing Object Lock Configuration").
+				WithContext("bucket", bucket)
+		}
+		return nil, nil
+	}
+
+	if requested != nil {
+		return requested, validateRetention(requested, now)
+	}
+	if d := config.DefaultRetention; d != nil {
+		return &ObjectRetention{Mode: d.Mode, RetainUntil: now.AddDate(d.Years, 0, d.Days).UTC()}, nil
+	}
+	return nil, nil
+}
+
+// checkObjectLock returns an error if a version is protected from removal by
+// a legal hold or unexpired retention. GOVERNANCE retention does not protect
+// it from callers allowed to bypass it.
+func checkObjectLock(info *ObjectInfo, bypassGovernance bool, now time.Time) error {
+	if info == nil || info.DeleteMarker {
+		return nil
+	}
+	if info.LegalHold {
+		return errors.ErrObjectLocked.WithContext("key", info.Key).WithContext("legal_hold", true)
+	}
+	if r := info.Retention; r != nil && r.RetainUntil.After(now) {
+		if r.Mode == RetentionGovernance && bypassGovernance {
+			return nil
+		}
+		return errors.ErrObjectLocked.WithContext("key", info.Key).WithContext("retain_until", r.RetainUntil)
+	}
+	return nil
+}
+
+// checkRetentionChange checks that replacing the retention of a version with
+// next does not weaken unexpired retention it is not allowed to
+func checkRetentionChange(current, next *ObjectRetention, bypassGovernance bool, now time.Time) error {
+	if current == nil || !current.RetainUntil.After(now) {
+		return nil
+	}
+
+	weakened := next == nil || next.RetainUntil.Before(current.RetainUntil)
+	switch current.Mode {
+	case RetentionCompliance:
+		if weakened || next.Mode != RetentionCompliance {
+			return errors.ErrObjectLocked.WithContext("retain_until", current.RetainUntil)
+		}
+	case RetentionGovernance:
+		if weakened && !bypassGovernance {
+			return errors.ErrObjectLocked.WithContext("retain_until", current.RetainUntil)
+		}
+	}
+	return nil
+}
+
+// validateObjectLock checks an Object Lock configuration supplied by a client
+func validateObjectLock(config *ObjectLockConfiguration) error {
+	if config == nil || config.ObjectLockEnabled != ObjectLockEnabled {
+		return errors.New(errors.ErrCodeMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+
+	d := config.DefaultRetention
+	if d == nil {
+		return nil
+	}
+	if !validRetentionMode(d.Mode) {
+		return errors.New(errors.ErrCodeMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema").
+			WithContext("mode", d.Mode)
+	}
+	if d.Days < 0 || d.Years < 0 || (d.Days == 0 && d.Years == 0) {
+		return errors.New(errors.ErrCodeInvalidParameter, "Default retention period must be a positive integer value")
+	}
+	if d.Days > 0 && d.Years > 0 {
+		return errors.New(errors.ErrCodeMalformedXML, "Default retention period must specify either Days or Years, but not both")
+	}
+	return nil
+}
+
+// validateRetention checks the retention of an object version supplied by a client
+func validateRetention(retention *ObjectRetention, now time.Time) error {
+	if !validRetentionMode(retention.Mode) {
+		return errors.New(errors.ErrCodeMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema").
+			WithContext("mode", retention.Mode)
+	}
+	if !retention.RetainUntil.After(now) {
+		return errors.New(errors.ErrCodeInvalidParameter, "The retain until date must be in the future!").
+			WithContext("retain_until", retention.RetainUntil)
+	}
+	return nil
+}
+
+func validRetentionMode(mode string) bool {
+	return mode == RetentionGovernance || mode == RetentionCompliance
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/8fs-io/core/internal/domain/policy"
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// bucketConfigPolicy names the stored policy document of a bucket
+const bucketConfigPolicy = "policy"
+
+// PutBucketPolicy validates and stores the policy document of a bucket
+func (s *service) PutBucketPolicy(ctx context.Context, bucket, document string) error {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+	if _, err := policy.Parse([]byte(document), bucket); err != nil {
+		return err
+	}
+
+	if err := s.repo.PutBucketConfig(ctx, bucket, bucketConfigPolicy, json.RawMessage(document)); err != nil {
+		s.logger.Error("Failed to store bucket policy", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to store bucket policy", err)
+	}
+
+	s.logger.Info("Bucket policy updated", "bucket", bucket)
+	return nil
+}
+
+// GetBucketPolicy returns the policy document of a bucket
+func (s *service) GetBucketPolicy(ctx context.Context, bucket string) (string, error) {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return "", err
+	}
+
+	var document json.RawMessage
+	found, err := s.repo.GetBucketConfig(ctx, bucket, bucketConfigPolicy, &document)
+	if err != nil {
+		s.logger.Error("Failed to read bucket policy", "bucket", bucket, "error", err)
+		return "", errors.Wrap(errors.ErrCodeInternalError, "Failed to read bucket policy", err)
+	}
+	if !found {
+		return "", errors.ErrNoSuchBucketPolicy.WithContext("bucket", bucket)
+	}
+	return string(document), nil
+}
+
+// DeleteBucketPolicy removes the policy document of a bucket
+func (s *service) DeleteBucketPolicy(ctx context.Context, bucket string) error {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteBucketConfig(ctx, bucket, bucketConfigPolicy); err != nil {
+		s.logger.Error("Failed to delete bucket policy", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to delete bucket policy", err)
+	}
+
+	s.logger.Info("Bucket policy deleted", "bucket", bucket)
+	return nil
+}
+
+// BucketPolicy returns the parsed policy of a bucket, or nil when the bucket
+// has none or does not exist
+func (s *service) BucketPolicy(ctx context.Context, bucket string) (*policy.Policy, error) {
+	if s.validator.ValidateBucketName(bucket) != nil {
+		return nil, nil
+	}
+
+	var document json.RawMessage
+	found, err := s.repo.GetBucketConfig(ctx, bucket, bucketConfigPolicy, &document)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read bucket policy", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return policy.Parse(document, bucket)
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// ByteRange is an inclusive range of bytes within an object
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// Length returns the number of bytes in the range
+func (r *ByteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// ContentRange formats the range as a Content-Range header value
+func (r *ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size)
+}
+
+// ParseRange resolves a Range header against an object of the given size.
+// Like S3, only a single byte range is honored: a missing, malformed or
+// multi-range header yields nil and the whole object is served. A range that
+// cannot be satisfied returns ErrInvalidRange.
+func ParseRange(spec string, size int64) (*ByteRange, error) {
+	spec = strings.TrimSpace(spec)
+	if !strings.HasPrefix(spec, "bytes=") {
+		return nil, nil
+	}
+	spec = strings.TrimSpace(strings.TrimPrefix(spec, "bytes="))
+	if strings.Contains(spec, ",") {
+		return nil, nil
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, nil
+	}
+	first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+	// Suffix range: the final N bytes
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, invalidRange(spec, size)
+		}
+		if n > size {
+			n = size
+		}
+		return &ByteRange{Start: size - n, End: size - 1}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return nil, nil
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return nil, invalidRange(spec, size)
+	}
+	return &ByteRange{Start: start, End: end}, nil
+}
+
+// PartRange returns the byte range of a part of an object. Objects that were
+// not uploaded in parts consist of a single part. A nil range with no error
+// means the part is the whole (empty) object.
+func PartRange(partSizes []int64, size int64, partNumber int) (*ByteRange, error) {
+	if partNumber < 1 || partNumber > MaxPartNumber {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Part number must be an integer between 1 and 10000, inclusive").
+			WithContext("part_number", partNumber)
+	}
+	if len(partSizes) == 0 {
+		partSizes = []int64{size}
+	}
+	if partNumber > len(partSizes) {
+		return nil, errors.ErrInvalidPartNumber.WithContext("part_number", partNumber)
+	}
+
+	var start int64
+	for _, partSize := range partSizes[:partNumber-1] {
+		start += partSize
+	}
+	if partSizes[partNumber-1] == 0 {
+		return nil, nil
+	}
+	return &ByteRange{Start: start, End: start + partSizes[partNumber-1] - 1}, nil
+}
+
+func invalidRange(spec string, size int64) error {
+	return errors.ErrInvalidRange.WithContext("range", spec).WithContext("size", size)
+}
@@ -2,29 +2,58 @@ package storage
 
 import (
 	"context"
-	"crypto/md5"
-	"fmt"
+	"io"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/8fs-io/core/pkg/errors"
 	"github.com/8fs-io/core/pkg/logger"
 )
 
+// Config holds storage service configuration
+type Config struct {
+	MinPartSize      int64  // minimum size of every multipart part but the last
+	MasterKey        []byte // seals the data keys of SSE-S3 objects, which are unavailable without it
+	EncryptByDefault bool   // encrypt objects stored without encryption headers with the master key
+}
+
+// DefaultConfig returns default storage service configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MinPartSize: 5 * 1024 * 1024,
+	}
+}
+
 // service implements the Service interface
 type service struct {
 	repo      Repository
+	changes   ChangeLog
 	validator Validator
 	logger    logger.Logger
+	config    *Config
+	locks     keyLocks
+	uploads   keyLocks // by upload ID, shared by part uploads
+
+	changedMu sync.Mutex
+	changed   chan struct{} // closed and replaced whenever a change is recorded
 }
 
-// NewService creates a new storage service
-func NewService(repo Repository, validator Validator, logger logger.Logger) Service {
+// NewService creates a new storage service. Changes to objects are recorded
+// in changes unless it is nil.
+func NewService(repo Repository, changes ChangeLog, validator Validator, logger logger.Logger, config *Config) Service {
+	if config == nil {
+		config = DefaultConfig()
+	}
 	return &service{
 		repo:      repo,
+		changes:   changes,
 		validator: validator,
 		logger:    logger,
+		config:    config,
+		changed:   make(chan struct{}),
 	}
 }
 
@@ -95,6 +124,16 @@ func (s *service) DeleteBucket(ctx context.Context, name string) error {
 		return errors.ErrBucketNotEmpty.WithContext("bucket", name)
 	}
 
+	// Noncurrent versions and delete markers keep a bucket from being empty too
+	versions, err := s.repo.ListObjectVersions(ctx, name, "")
+	if err != nil {
+		s.logger.Error("Failed to list object versions", "bucket", name, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to check if bucket is empty", err)
+	}
+	if len(versions) > 0 {
+		return errors.ErrBucketNotEmpty.WithContext("bucket", name)
+	}
+
 	// Delete bucket
 	if err := s.repo.DeleteBucket(ctx, name); err != nil {
 		s.logger.Error("Failed to delete bucket", "bucket", name, "error", err)
@@ -134,8 +173,16 @@ func (s *service) ListBuckets(ctx context.Context) ([]*Bucket, error) {
 	return buckets, nil
 }
 
-// PutObject stores an object
-func (s *service) PutObject(ctx context.Context, bucket, key string, data []byte, contentType string, metadata map[string]string) (*Object, error) {
+// PutObject stores an object, streaming data to the repository. Writers of
+// the same key are serialized so preconditions are checked and applied
+// atomically.
+func (s *service) PutObject(ctx context.Context, bucket, key string, data io.Reader, opts PutObjectOptions) (*Object, error) {
+	return s.putObject(ctx, bucket, key, data, opts, ChangePut)
+}
+
+// putObject stores an object and records it in the change feed as a change
+// of the given type
+func (s *service) putObject(ctx context.Context, bucket, key string, data io.Reader, opts PutObjectOptions, changeType string) (*Object, error) {
 	// Validate inputs
 	if err := s.validator.ValidateBucketName(bucket); err != nil {
 		return nil, err
@@ -143,7 +190,17 @@ func (s *service) PutObject(ctx context.Context, bucket, key string, data []byte
 	if err := s.validator.ValidateObjectKey(key); err != nil {
 		return nil, err
 	}
-	if err := s.validator.ValidateMetadata(metadata); err != nil {
+	if err := s.validator.ValidateMetadata(opts.Metadata); err != nil {
+		return nil, err
+	}
+	if err := s.validator.ValidateTags(opts.Tags); err != nil {
+		return nil, err
+	}
+	if err := validateChecksums(opts.ContentMD5, opts.Checksums); err != nil {
+		return nil, err
+	}
+	encryption, err := s.newEncryption(bucket, key, opts.Encryption)
+	if err != nil {
 		return nil, err
 	}
 
@@ -156,33 +213,88 @@ func (s *service) PutObject(ctx context.Context, bucket, key string, data []byte
 	if !exists {
 		return nil, errors.ErrBucketNotFound.WithContext("bucket", bucket)
 	}
+	retention, err := s.newRetention(ctx, bucket, opts.Retention, opts.LegalHold, time.Now())
+	if err != nil {
+		return nil, err
+	}
 
-	// Generate ETag (MD5 hash of content)
-	etag := fmt.Sprintf("\"%x\"", md5.Sum(data))
+	unlock := s.locks.lock(bucket, key)
+	defer unlock()
 
-	// Create object
+	if !opts.Conditions.IsEmpty() {
+		current, err := s.currentObject(ctx, bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		if err := opts.Conditions.CheckWrite(current); err != nil {
+			return nil, err
+		}
+	}
+
+	versionID, archived, err := s.prepareVersion(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create object, the repository fills in the size and ETag (MD5 hash of
+	// content) as the data is written
 	object := &Object{
 		Key:          key,
 		Bucket:       bucket,
-		Size:         int64(len(data)),
-		ContentType:  contentType,
-		ETag:         etag,
+		ContentType:  opts.ContentType,
 		LastModified: time.Now().UTC(),
-		Metadata:     metadata,
-		Data:         data,
-	}
-
-	if err := s.repo.PutObject(ctx, object); err != nil {
+		Metadata:     opts.Metadata,
+		VersionID:    versionID,
+		Tags:         opts.Tags,
+		Encryption:   encryption,
+		Retention:    retention,
+		LegalHold:    opts.LegalHold,
+	}
+
+	// The checksums are verified and filled in as the data is read, before
+	// the repository commits the object
+	if opts.ContentMD5 != "" || len(opts.Checksums) > 0 {
+		verified := newChecksumReader(data, opts.ContentMD5, opts.Checksums)
+		object.Checksums = verified.checksums
+		data = verified
+	}
+
+	if err := s.repo.PutObject(ctx, object, data); err != nil {
+		s.abandonVersion(ctx, bucket, key, archived)
+		var appErr *errors.AppError
+		if errors.As(err, &appErr) && appErr.Code != errors.ErrCodeInternalError {
+			return nil, err
+		}
 		s.logger.Error("Failed to put object", "bucket", bucket, "key", key, "error", err)
 		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to put object", err)
 	}
+	s.recordChange(ctx, Change{Type: changeType, Bucket: bucket, Key: key, VersionID: object.VersionID, ETag: object.ETag, Size: object.Size})
 
-	s.logger.Info("Object stored successfully", "bucket", bucket, "key", key, "size", len(data))
+	s.logger.Info("Object stored successfully", "bucket", bucket, "key", key, "size", object.Size)
 	return object, nil
 }
 
+// currentObject returns the metadata of an object, or nil if it does not exist
+func (s *service) currentObject(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	info, err := s.repo.GetObjectInfo(ctx, bucket, key)
+	if err != nil {
+		if errors.IsErrorCode(err, errors.ErrCodeObjectNotFound) {
+			return nil, nil
+		}
+		s.logger.Error("Failed to get object info", "bucket", bucket, "key", key, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to get object info", err)
+	}
+	return info, nil
+}
+
 // GetObject retrieves an object
 func (s *service) GetObject(ctx context.Context, bucket, key string) (*Object, error) {
+	return s.getObject(ctx, bucket, key, nil)
+}
+
+// getObject retrieves the current version of an object, decrypting it with
+// the key supplied by sse if needed
+func (s *service) getObject(ctx context.Context, bucket, key string, sse *ServerSideEncryption) (*Object, error) {
 	if err := s.validator.ValidateBucketName(bucket); err != nil {
 		return nil, err
 	}
@@ -198,6 +310,10 @@ func (s *service) GetObject(ctx context.Context, bucket, key string) (*Object, e
 		s.logger.Error("Failed to get object", "bucket", bucket, "key", key, "error", err)
 		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to get object", err)
 	}
+	if err := s.decryptObject(object, sse); err != nil {
+		object.Body.Close()
+		return nil, err
+	}
 
 	return object, nil
 }
@@ -223,32 +339,10 @@ func (s *service) GetObjectInfo(ctx context.Context, bucket, key string) (*Objec
 	return objectInfo, nil
 }
 
-// DeleteObject deletes an object
+// DeleteObject deletes the current version of an object
 func (s *service) DeleteObject(ctx context.Context, bucket, key string) error {
-	if err := s.validator.ValidateBucketName(bucket); err != nil {
-		return err
-	}
-	if err := s.validator.ValidateObjectKey(key); err != nil {
-		return err
-	}
-
-	// Check if object exists
-	exists, err := s.repo.ObjectExists(ctx, bucket, key)
-	if err != nil {
-		s.logger.Error("Failed to check object existence", "bucket", bucket, "key", key, "error", err)
-		return errors.Wrap(errors.ErrCodeInternalError, "Failed to check object existence", err)
-	}
-	if !exists {
-		return errors.ErrObjectNotFound.WithContext("bucket", bucket).WithContext("key", key)
-	}
-
-	if err := s.repo.DeleteObject(ctx, bucket, key); err != nil {
-		s.logger.Error("Failed to delete object", "bucket", bucket, "key", key, "error", err)
-		return errors.Wrap(errors.ErrCodeInternalError, "Failed to delete object", err)
-	}
-
-	s.logger.Info("Object deleted successfully", "bucket", bucket, "key", key)
-	return nil
+	_, err := s.DeleteObjectVersion(ctx, bucket, key, "", false)
+	return err
 }
 
 // ListObjects lists objects in a bucket
@@ -352,6 +446,30 @@ func (v *validator) ValidateObjectKey(key string) error {
 	return nil
 }
 
+// tagPattern lists the characters allowed in tag keys and values
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// ValidateTags validates an object tag set
+func (v *validator) ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxObjectTags {
+		return errors.New(errors.ErrCodeInvalidTag, "Object tags cannot be greater than 10")
+	}
+
+	for key, value := range tags {
+		if key == "" || utf8.RuneCountInString(key) > 128 {
+			return errors.New(errors.ErrCodeInvalidTag, "The TagKey you have provided is invalid").WithContext("key", key)
+		}
+		if utf8.RuneCountInString(value) > 256 {
+			return errors.New(errors.ErrCodeInvalidTag, "The TagValue you have provided is invalid").WithContext("key", key)
+		}
+		if !tagPattern.MatchString(key) || !tagPattern.MatchString(value) {
+			return errors.New(errors.ErrCodeInvalidTag, "The TagKey or TagValue you have provided contains invalid characters").WithContext("key", key)
+		}
+	}
+
+	return nil
+}
+
 // ValidateMetadata validates object metadata
 func (v *validator) ValidateMetadata(metadata map[string]string) error {
 	if metadata == nil {
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// PutObjectTagging replaces the tag set of an object version, the current
+// version if versionID is empty, and returns the version that was tagged
+func (s *service) PutObjectTagging(ctx context.Context, bucket, key, versionID string, tags map[string]string) (*ObjectInfo, error) {
+	if err := s.validator.ValidateTags(tags); err != nil {
+		return nil, err
+	}
+	return s.setObjectTags(ctx, bucket, key, versionID, tags)
+}
+
+// DeleteObjectTagging removes every tag from an object version
+func (s *service) DeleteObjectTagging(ctx context.Context, bucket, key, versionID string) (*ObjectInfo, error) {
+	return s.setObjectTags(ctx, bucket, key, versionID, nil)
+}
+
+// setObjectTags stores the tags of a version while holding the key's lock so
+// that a concurrent write cannot move the version into the history midway
+func (s *service) setObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) (*ObjectInfo, error) {
+	if err := s.validator.ValidateBucketName(bucket); err != nil {
+		return nil, err
+	}
+	if err := s.validator.ValidateObjectKey(key); err != nil {
+		return nil, err
+	}
+	if versionID != "" {
+		if err := s.validateVersion(bucket, key, versionID); err != nil {
+			return nil, err
+		}
+	}
+
+	unlock := s.locks.lock(bucket, key)
+	defer unlock()
+
+	info, versionID, err := s.versionToUpdate(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.PutObjectTags(ctx, bucket, key, versionID, tags); err != nil {
+		s.logger.Error("Failed to store object tags", "bucket", bucket, "key", key, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to store object tags", err)
+	}
+
+	info.Tags = tags
+	s.recordChange(ctx, Change{Type: ChangeMetadata, Bucket: bucket, Key: key, VersionID: info.VersionID, ETag: info.ETag, Size: info.Size})
+	s.logger.Info("Object tags updated", "bucket", bucket, "key", key, "tags", len(tags))
+	return info, nil
+}
@@ -0,0 +1,474 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// bucketConfigVersioning names the stored versioning configuration of a bucket
+const bucketConfigVersioning = "versioning"
+
+// versionIDPattern matches the version IDs handed out by newVersionID
+var versionIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// PutBucketVersioning enables or suspends versioning on a bucket. Once
+// versioning has been enabled a bucket can only be suspended, never
+// returned to the unversioned state, and a bucket with Object Lock enabled
+// cannot be suspended either.
+func (s *service) PutBucketVersioning(ctx context.Context, bucket, status string) error {
+	if status != VersioningEnabled && status != VersioningSuspended {
+		return errors.New(errors.ErrCodeMalformedXML, "The versioning status must be Enabled or Suspended").
+			WithContext("status", status)
+	}
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+	if status == VersioningSuspended {
+		config, err := s.objectLock(ctx, bucket)
+		if err != nil {
+			return err
+		}
+		if config != nil {
+			return errors.New(errors.ErrCodeInvalidBucketState, "An Object Lock configuration is present on this bucket, so the versioning state cannot be changed.").
+				WithContext("bucket", bucket)
+		}
+	}
+
+	if err := s.repo.PutBucketConfig(ctx, bucket, bucketConfigVersioning, &VersioningConfiguration{Status: status}); err != nil {
+		s.logger.Error("Failed to update bucket versioning", "bucket", bucket, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to update bucket versioning", err)
+	}
+
+	s.logger.Info("Bucket versioning updated", "bucket", bucket, "status", status)
+	return nil
+}
+
+// GetBucketVersioning returns the versioning status of a bucket, empty if
+// versioning was never configured
+func (s *service) GetBucketVersioning(ctx context.Context, bucket string) (string, error) {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return "", err
+	}
+
+	var config VersioningConfiguration
+	if _, err := s.repo.GetBucketConfig(ctx, bucket, bucketConfigVersioning, &config); err != nil {
+		s.logger.Error("Failed to read bucket versioning", "bucket", bucket, "error", err)
+		return "", errors.Wrap(errors.ErrCodeInternalError, "Failed to read bucket versioning", err)
+	}
+	return config.Status, nil
+}
+
+// GetObjectVersion retrieves a specific version of an object
+func (s *service) GetObjectVersion(ctx context.Context, bucket, key, versionID string, sse *ServerSideEncryption) (*Object, error) {
+	if versionID == "" {
+		return s.getObject(ctx, bucket, key, sse)
+	}
+	if err := s.validateVersion(bucket, key, versionID); err != nil {
+		return nil, err
+	}
+
+	current, err := s.currentObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && versionIDOf(current) == versionID {
+		return s.getObject(ctx, bucket, key, sse)
+	}
+
+	object, err := s.repo.GetObjectVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, s.versionError(err, "Failed to get object version", bucket, key, versionID)
+	}
+	if err := s.decryptObject(object, sse); err != nil {
+		object.Body.Close()
+		return nil, err
+	}
+	return object, nil
+}
+
+// GetObjectVersionInfo retrieves the metadata of a specific version of an object
+func (s *service) GetObjectVersionInfo(ctx context.Context, bucket, key, versionID string) (*ObjectInfo, error) {
+	if versionID == "" {
+		return s.GetObjectInfo(ctx, bucket, key)
+	}
+	if err := s.validateVersion(bucket, key, versionID); err != nil {
+		return nil, err
+	}
+
+	current, err := s.currentObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && versionIDOf(current) == versionID {
+		return current, nil
+	}
+
+	info, err := s.repo.GetObjectVersionInfo(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, s.versionError(err, "Failed to get object version", bucket, key, versionID)
+	}
+	if info.DeleteMarker {
+		return nil, errors.ErrMethodNotAllowed.WithContext("bucket", bucket).WithContext("key", key).WithContext("version_id", versionID)
+	}
+	return info, nil
+}
+
+// DeleteObjectVersion deletes an object or one of its versions. Without a
+// version ID, a bucket with versioning configured keeps the current version
+// in the history and records a delete marker in its place. Deleting a
+// specific version removes it for good; if the current version goes, the
+// newest remaining version takes its place unless that is a delete marker.
+// Versions protected by Object Lock are never removed, although a delete
+// marker can still be placed in front of them.
+func (s *service) DeleteObjectVersion(ctx context.Context, bucket, key, versionID string, bypassGovernance bool) (*DeleteResult, error) {
+	if err := s.validator.ValidateBucketName(bucket); err != nil {
+		return nil, err
+	}
+	if err := s.validator.ValidateObjectKey(key); err != nil {
+		return nil, err
+	}
+	if versionID != "" {
+		if err := s.validateVersion(bucket, key, versionID); err != nil {
+			return nil, err
+		}
+	}
+
+	status, err := s.GetBucketVersioning(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := s.locks.lock(bucket, key)
+	defer unlock()
+
+	current, err := s.currentObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *DeleteResult
+	switch {
+	case versionID != "":
+		result, err = s.deleteVersion(ctx, bucket, key, versionID, current, bypassGovernance)
+	case status == "":
+		result, err = &DeleteResult{}, s.deleteCurrent(ctx, bucket, key, current, bypassGovernance)
+	default:
+		result, err = s.putDeleteMarker(ctx, bucket, key, status, current)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.recordChange(ctx, Change{Type: ChangeDelete, Bucket: bucket, Key: key, VersionID: result.VersionID, DeleteMarker: versionID == "" && result.DeleteMarker})
+
+	s.logger.Info("Object deleted successfully", "bucket", bucket, "key", key, "version_id", result.VersionID, "delete_marker", result.DeleteMarker)
+	return result, nil
+}
+
+// deleteCurrent removes the current version of an unversioned object
+func (s *service) deleteCurrent(ctx context.Context, bucket, key string, current *ObjectInfo, bypassGovernance bool) error {
+	if current == nil {
+		return errors.ErrObjectNotFound.WithContext("bucket", bucket).WithContext("key", key)
+	}
+	if err := checkObjectLock(current, bypassGovernance, time.Now()); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteObject(ctx, bucket, key); err != nil {
+		s.logger.Error("Failed to delete object", "bucket", bucket, "key", key, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to delete object", err)
+	}
+	return nil
+}
+
+// putDeleteMarker moves the current version into the history and records a
+// delete marker as the latest version. With versioning suspended the marker
+// takes the null version ID, replacing any null version.
+func (s *service) putDeleteMarker(ctx context.Context, bucket, key, status string, current *ObjectInfo) (*DeleteResult, error) {
+	versionID, _, err := s.archiveCurrent(ctx, bucket, key, status, current)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		if err := s.repo.DeleteObject(ctx, bucket, key); err != nil {
+			s.logger.Error("Failed to delete object", "bucket", bucket, "key", key, "error", err)
+			return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to delete object", err)
+		}
+	}
+
+	marker := &ObjectInfo{
+		Key:          key,
+		LastModified: time.Now().UTC(),
+		VersionID:    versionID,
+	}
+	if err := s.repo.PutDeleteMarker(ctx, bucket, marker); err != nil {
+		s.logger.Error("Failed to create delete marker", "bucket", bucket, "key", key, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to create delete marker", err)
+	}
+
+	return &DeleteResult{VersionID: versionID, DeleteMarker: true}, nil
+}
+
+// deleteVersion permanently removes one version of an object
+func (s *service) deleteVersion(ctx context.Context, bucket, key, versionID string, current *ObjectInfo, bypassGovernance bool) (*DeleteResult, error) {
+	if current != nil && versionIDOf(current) == versionID {
+		if err := checkObjectLock(current, bypassGovernance, time.Now()); err != nil {
+			return nil, err
+		}
+		if err := s.repo.DeleteObject(ctx, bucket, key); err != nil {
+			s.logger.Error("Failed to delete object", "bucket", bucket, "key", key, "error", err)
+			return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to delete object", err)
+		}
+		return &DeleteResult{VersionID: versionID}, s.promoteLatest(ctx, bucket, key)
+	}
+
+	info, err := s.repo.GetObjectVersionInfo(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, s.versionError(err, "Failed to get object version", bucket, key, versionID)
+	}
+	if err := checkObjectLock(info, bypassGovernance, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeleteObjectVersion(ctx, bucket, key, versionID); err != nil {
+		return nil, s.versionError(err, "Failed to delete object version", bucket, key, versionID)
+	}
+
+	result := &DeleteResult{VersionID: versionID, DeleteMarker: info.DeleteMarker}
+	if current == nil {
+		return result, s.promoteLatest(ctx, bucket, key)
+	}
+	return result, nil
+}
+
+// promoteLatest restores the newest noncurrent version of a key that has no
+// current version, unless the newest entry is a delete marker
+func (s *service) promoteLatest(ctx context.Context, bucket, key string) error {
+	history, err := s.repo.ListObjectHistory(ctx, bucket, key)
+	if err != nil {
+		s.logger.Error("Failed to list object history", "bucket", bucket, "key", key, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to list object history", err)
+	}
+	if len(history) == 0 || history[0].DeleteMarker {
+		return nil
+	}
+
+	if err := s.repo.RestoreObjectVersion(ctx, bucket, key, history[0].VersionID); err != nil {
+		s.logger.Error("Failed to restore object version", "bucket", bucket, "key", key, "version_id", history[0].VersionID, "error", err)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to restore object version", err)
+	}
+	return nil
+}
+
+// prepareVersion readies a key for a new current version and returns the
+// version ID to store it under, along with the ID of any version it
+// archived. Must be called with the key locked.
+func (s *service) prepareVersion(ctx context.Context, bucket, key string) (string, string, error) {
+	status, err := s.GetBucketVersioning(ctx, bucket)
+	if err != nil || status == "" {
+		return "", "", err
+	}
+
+	current, err := s.currentObject(ctx, bucket, key)
+	if err != nil {
+		return "", "", err
+	}
+	return s.archiveCurrent(ctx, bucket, key, status, current)
+}
+
+// abandonVersion drops a version archived by prepareVersion when the write
+// that was to replace it failed, leaving the current version as it was
+func (s *service) abandonVersion(ctx context.Context, bucket, key, archived string) {
+	if archived == "" {
+		return
+	}
+	if err := s.repo.DeleteObjectVersion(ctx, bucket, key, archived); err != nil {
+		s.logger.Warn("Failed to drop archived version", "bucket", bucket, "key", key, "version_id", archived, "error", err)
+	}
+}
+
+// archiveCurrent keeps the current version of a key in its history ahead of
+// it being replaced or deleted. It returns the version ID of what replaces
+// it and the ID of the version archived, if any. With versioning suspended
+// the null version is overwritten rather than kept, and the replacement
+// takes the null version ID.
+func (s *service) archiveCurrent(ctx context.Context, bucket, key, status string, current *ObjectInfo) (string, string, error) {
+	versionID := newVersionID()
+	if status == VersioningSuspended {
+		versionID = NullVersionID
+		if err := s.repo.DeleteObjectVersion(ctx, bucket, key, NullVersionID); err != nil && !errors.IsErrorCode(err, errors.ErrCodeNoSuchVersion) {
+			s.logger.Error("Failed to remove null version", "bucket", bucket, "key", key, "error", err)
+			return "", "", errors.Wrap(errors.ErrCodeInternalError, "Failed to remove null version", err)
+		}
+		if current != nil && versionIDOf(current) == NullVersionID {
+			return versionID, "", nil
+		}
+	}
+	if current == nil {
+		return versionID, "", nil
+	}
+
+	archived, err := s.repo.ArchiveObject(ctx, bucket, key)
+	if err != nil {
+		s.logger.Error("Failed to archive object", "bucket", bucket, "key", key, "error", err)
+		return "", "", errors.Wrap(errors.ErrCodeInternalError, "Failed to archive object", err)
+	}
+	return versionID, archived, nil
+}
+
+// ListObjectVersions lists the versions and delete markers of the objects in a bucket
+func (s *service) ListObjectVersions(ctx context.Context, bucket string, opts ListVersionsOptions) (*ListVersionsResult, error) {
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	versions, err := s.repo.ListObjectVersions(ctx, bucket, opts.Prefix)
+	if err != nil {
+		s.logger.Error("Failed to list object versions", "bucket", bucket, "error", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to list object versions", err)
+	}
+
+	// Without a current version, the newest history entry is the latest
+	for i := range versions {
+		if i == 0 || versions[i].Key != versions[i-1].Key {
+			versions[i].IsLatest = true
+		}
+	}
+
+	maxKeys := opts.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	result := &ListVersionsResult{
+		Versions:       []ObjectInfo{},
+		CommonPrefixes: []string{},
+	}
+
+	// Skip past the markers. A version ID marker resumes within its key,
+	// otherwise the key marker's versions were all listed already.
+	skipping := opts.VersionIDMarker != ""
+	count := 0
+	for _, version := range versions {
+		if version.Key < opts.KeyMarker {
+			continue
+		}
+		if version.Key == opts.KeyMarker {
+			if skipping {
+				skipping = version.VersionID != opts.VersionIDMarker
+				continue
+			}
+			if opts.VersionIDMarker == "" {
+				continue
+			}
+		}
+
+		name := version.Key
+		isPrefix := false
+		if opts.Delimiter != "" {
+			rel := strings.TrimPrefix(version.Key, opts.Prefix)
+			if idx := strings.Index(rel, opts.Delimiter); idx >= 0 {
+				name = opts.Prefix + rel[:idx+len(opts.Delimiter)]
+				isPrefix = true
+				if strings.HasPrefix(opts.KeyMarker, name) {
+					continue
+				}
+				if n := len(result.CommonPrefixes); n > 0 && result.CommonPrefixes[n-1] == name {
+					continue
+				}
+			}
+		}
+
+		if count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		count++
+
+		if isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, name)
+			result.NextKeyMarker, result.NextVersionIDMarker = name, ""
+		} else {
+			result.Versions = append(result.Versions, version)
+			result.NextKeyMarker, result.NextVersionIDMarker = version.Key, version.VersionID
+		}
+	}
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIDMarker = "", ""
+	}
+
+	return result, nil
+}
+
+// versionToUpdate returns the metadata of the version of an object that an
+// update addresses, along with the version ID to pass the repository, which
+// is empty for the current version. Must be called with the key locked.
+func (s *service) versionToUpdate(ctx context.Context, bucket, key, versionID string) (*ObjectInfo, string, error) {
+	current, err := s.currentObject(ctx, bucket, key)
+	if err != nil {
+		return nil, "", err
+	}
+	if versionID == "" || (current != nil && versionIDOf(current) == versionID) {
+		if current == nil {
+			return nil, "", errors.ErrObjectNotFound.WithContext("bucket", bucket).WithContext("key", key)
+		}
+		return current, "", nil
+	}
+
+	info, err := s.repo.GetObjectVersionInfo(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, "", s.versionError(err, "Failed to get object version", bucket, key, versionID)
+	}
+	if info.DeleteMarker {
+		return nil, "", errors.ErrMethodNotAllowed.WithContext("bucket", bucket).WithContext("key", key).WithContext("version_id", versionID)
+	}
+	return info, versionID, nil
+}
+
+// validateVersion checks a version ID supplied by a client
+func (s *service) validateVersion(bucket, key, versionID string) error {
+	if err := s.validator.ValidateBucketName(bucket); err != nil {
+		return err
+	}
+	if err := s.validator.ValidateObjectKey(key); err != nil {
+		return err
+	}
+	if versionID != NullVersionID && !versionIDPattern.MatchString(versionID) {
+		return errors.New(errors.ErrCodeInvalidParameter, "Invalid version id specified").
+			WithContext("version_id", versionID)
+	}
+	return nil
+}
+
+// versionError passes through the client errors of a version lookup and
+// wraps anything else
+func (s *service) versionError(err error, message, bucket, key, versionID string) error {
+	if errors.IsErrorCode(err, errors.ErrCodeNoSuchVersion) || errors.IsErrorCode(err, errors.ErrCodeMethodNotAllowed) {
+		return err
+	}
+	s.logger.Error(message, "bucket", bucket, "key", key, "version_id", versionID, "error", err)
+	return errors.Wrap(errors.ErrCodeInternalError, message, err)
+}
+
+// versionIDOf returns the version ID of an object, which is the null version
+// for objects written before versioning was enabled
+func versionIDOf(info *ObjectInfo) string {
+	if info.VersionID == "" {
+		return NullVersionID
+	}
+	return info.VersionID
+}
+
+// newVersionID returns a new version ID. IDs start with the creation time so
+// that they sort in the order versions were written.
+func newVersionID() string {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		panic(fmt.Sprintf("failed to generate version ID: %v", err))
+	}
+	return fmt.Sprintf("%016x%s", time.Now().UnixNano(), hex.EncodeToString(random))
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
@@ -15,6 +17,7 @@ var (
 	ErrDimensionMismatch    = errors.New("dimension mismatch")
 	ErrInvalidVector        = errors.New("invalid vector")
 	ErrExtensionUnavailable = errors.New("sqlite-vec extension unavailable")
+	ErrInvalidFilter        = errors.New("invalid metadata filter")
 )
 
 // DimensionMismatchError provides detailed dimension mismatch information
@@ -172,6 +175,13 @@ func (s *SQLiteVecStorage) Store(vector *Vector) error {
 
 // Search performs vector similarity search using sqlite-vec
 func (s *SQLiteVecStorage) Search(query []float64, topK int) ([]SearchResult, error) {
+	return s.SearchWithFilter(query, topK, nil)
+}
+
+// SearchWithFilter performs vector similarity search restricted to vectors
+// whose metadata holds every key of filter with the given value. Values are
+// compared as text, so filter{"size": "42"} matches a numeric size of 42.
+func (s *SQLiteVecStorage) SearchWithFilter(query []float64, topK int, filter map[string]string) ([]SearchResult, error) {
 	// Validate query vector
 	vm := NewVectorMath()
 	if err := vm.ValidateDimensions(query); err != nil {
@@ -182,26 +192,33 @@ func (s *SQLiteVecStorage) Search(query []float64, topK int) ([]SearchResult, er
 		topK = 10 // Default
 	}
 
-	return s.vectorSearch(query, topK)
+	return s.vectorSearch(query, topK, filter)
 }
 
 // vectorSearch uses sqlite-vec extension for optimized search
-func (s *SQLiteVecStorage) vectorSearch(query []float64, topK int) ([]SearchResult, error) {
+func (s *SQLiteVecStorage) vectorSearch(query []float64, topK int, filter map[string]string) ([]SearchResult, error) {
 	// Serialize query vector for sqlite-vec using binary format
 	queryData, err := serializeEmbeddingBinary(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize query vector: %w", err)
 	}
 
+	where, filterArgs, err := metadataFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
 	// sqlite-vec query syntax - we don't need to retrieve the embedding data
 	sqlQuery := `
 	SELECT id, metadata,
 		   vec_distance_cosine(embedding, ?) as distance
-	FROM embeddings
+	FROM embeddings` + where + `
 	ORDER BY distance ASC
 	LIMIT ?`
 
-	rows, err := s.db.Query(sqlQuery, queryData, topK)
+	args := append([]interface{}{queryData}, filterArgs...)
+	args = append(args, topK)
+	rows, err := s.db.Query(sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("sqlite-vec query failed: %w", err)
 	}
@@ -247,6 +264,30 @@ func (s *SQLiteVecStorage) vectorSearch(query []float64, topK int) ([]SearchResu
 	return results, nil
 }
 
+// metadataFilter builds the WHERE clause matching metadata against filter
+func metadataFilter(filter map[string]string) (string, []interface{}, error) {
+	if len(filter) == 0 {
+		return "", nil, nil
+	}
+
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		if key == "" || strings.ContainsAny(key, `"\`) {
+			return "", nil, fmt.Errorf("%w: key %q", ErrInvalidFilter, key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conditions := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, 2*len(keys))
+	for _, key := range keys {
+		conditions = append(conditions, "CAST(json_extract(metadata, ?) AS TEXT) = ?")
+		args = append(args, `$."`+key+`"`, filter[key])
+	}
+	return "\n\tWHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
 // Delete removes all vectors associated with a specific document ID
 func (s *SQLiteVecStorage) Delete(documentID string) error {
 	if s.db == nil {
@@ -1,6 +1,7 @@
 package vectors
 
 import (
+	"errors"
 	"math/rand"
 	"path/filepath"
 	"testing"
@@ -71,3 +72,54 @@ func generateRandomVector(dimensions int) []float64 {
 	}
 	return vec
 }
+
+func TestSQLiteVecStorage_SearchWithFilter(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_vectors.db")
+	storage, err := NewSQLiteVecStorage(SQLiteVecConfig{Path: dbPath, Dimension: EmbeddingDim}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create SQLiteVecStorage: %v", err)
+	}
+	defer storage.Close()
+
+	embedding := make([]float64, EmbeddingDim)
+	for i := range embedding {
+		embedding[i] = 0.001 * float64(i%10+1)
+	}
+
+	testVectors := []*Vector{
+		{ID: "train1", Embedding: embedding, Metadata: map[string]interface{}{"tag_dataset": "train", "size": 42}},
+		{ID: "train2", Embedding: embedding, Metadata: map[string]interface{}{"tag_dataset": "train", "size": 7}},
+		{ID: "eval1", Embedding: embedding, Metadata: map[string]interface{}{"tag_dataset": "eval", "size": 42}},
+	}
+	for _, vector := range testVectors {
+		if err := storage.Store(vector); err != nil {
+			t.Fatalf("Failed to store vector %s: %v", vector.ID, err)
+		}
+	}
+
+	results, err := storage.SearchWithFilter(embedding, 10, map[string]string{"tag_dataset": "train"})
+	if err != nil {
+		t.Fatalf("Failed to search vectors: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results for dataset=train, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.Vector.Metadata["tag_dataset"] != "train" {
+			t.Errorf("result %s does not match the filter", result.Vector.ID)
+		}
+	}
+
+	// Filters combine, and non-string values compare as text
+	results, err = storage.SearchWithFilter(embedding, 10, map[string]string{"tag_dataset": "train", "size": "42"})
+	if err != nil {
+		t.Fatalf("Failed to search vectors: %v", err)
+	}
+	if len(results) != 1 || results[0].Vector.ID != "train1" {
+		t.Fatalf("expected only train1, got %v", results)
+	}
+
+	if _, err := storage.SearchWithFilter(embedding, 10, map[string]string{`bad"key`: "x"}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter, got %v", err)
+	}
+}
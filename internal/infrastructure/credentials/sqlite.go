@@ -0,0 +1,248 @@
+package credentials
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/credentials"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/logger"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// sqliteRepository implements credentials.Repository on a SQLite database.
+// Secret keys must be recoverable to verify signatures, so the database file
+// is only readable by its owner.
+type sqliteRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewSQLiteRepository opens or creates the credential database at path
+func NewSQLiteRepository(path string, logger logger.Logger) (credentials.Repository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create credential directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credential database: %w", err)
+	}
+	file.Close()
+
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credential database: %w", err)
+	}
+
+	if _, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS credentials (
+		access_key  TEXT PRIMARY KEY,
+		secret_key  TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		role        TEXT NOT NULL DEFAULT 'user',
+		status      TEXT NOT NULL,
+		expires_at  TIMESTAMP,
+		buckets     TEXT NOT NULL DEFAULT '[]',
+		actions     TEXT NOT NULL DEFAULT '[]',
+		created_at  TIMESTAMP NOT NULL,
+		updated_at  TIMESTAMP NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize credential schema: %w", err)
+	}
+	if err := migrateSchema(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate credential schema: %w", err)
+	}
+
+	logger.Info("Credential store initialized", "path", path)
+	return &sqliteRepository{db: db, logger: logger}, nil
+}
+
+// migrateSchema adds the columns introduced after the first version of the
+// credentials table
+func migrateSchema(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('credentials')`)
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+
+	if !columns["role"] {
+		if _, err := db.Exec(`ALTER TABLE credentials ADD COLUMN role TEXT NOT NULL DEFAULT 'user'`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// credentialColumns lists the columns scanned by scanCredential
+const credentialColumns = `access_key, secret_key, description, role, status, expires_at, buckets, actions, created_at, updated_at`
+
+// CreateCredential stores a new credential
+func (r *sqliteRepository) CreateCredential(ctx context.Context, credential *credentials.Credential) error {
+	buckets, actions, err := encodeScope(credential)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO credentials (`+credentialColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		credential.AccessKey, credential.SecretKey, credential.Description, credential.Role, credential.Status,
+		nullTime(credential.ExpiresAt), buckets, actions, credential.CreatedAt.UTC(), credential.UpdatedAt.UTC())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return errors.ErrAccessKeyExists.WithContext("access_key", credential.AccessKey)
+		}
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to store credential", err)
+	}
+	return nil
+}
+
+// GetCredential returns the credential of an access key
+func (r *sqliteRepository) GetCredential(ctx context.Context, accessKey string) (*credentials.Credential, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+credentialColumns+` FROM credentials WHERE access_key = ?`, accessKey)
+	credential, err := scanCredential(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrAccessKeyNotFound.WithContext("access_key", accessKey)
+	}
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read credential", err)
+	}
+	return credential, nil
+}
+
+// ListCredentials returns all credentials ordered by access key
+func (r *sqliteRepository) ListCredentials(ctx context.Context) ([]*credentials.Credential, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+credentialColumns+` FROM credentials ORDER BY access_key`)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to list credentials", err)
+	}
+	defer rows.Close()
+
+	var list []*credentials.Credential
+	for rows.Next() {
+		credential, err := scanCredential(rows)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read credential", err)
+		}
+		list = append(list, credential)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to list credentials", err)
+	}
+	return list, nil
+}
+
+// UpdateCredential replaces a stored credential
+func (r *sqliteRepository) UpdateCredential(ctx context.Context, credential *credentials.Credential) error {
+	buckets, actions, err := encodeScope(credential)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `UPDATE credentials
+		SET secret_key = ?, description = ?, role = ?, status = ?, expires_at = ?, buckets = ?, actions = ?, updated_at = ?
+		WHERE access_key = ?`,
+		credential.SecretKey, credential.Description, credential.Role, credential.Status, nullTime(credential.ExpiresAt),
+		buckets, actions, credential.UpdatedAt.UTC(), credential.AccessKey)
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to update credential", err)
+	}
+	return requireAffected(result, credential.AccessKey)
+}
+
+// DeleteCredential removes the credential of an access key
+func (r *sqliteRepository) DeleteCredential(ctx context.Context, accessKey string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM credentials WHERE access_key = ?`, accessKey)
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to delete credential", err)
+	}
+	return requireAffected(result, accessKey)
+}
+
+// Close closes the database
+func (r *sqliteRepository) Close() error {
+	return r.db.Close()
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCredential reads a credential selected with credentialColumns
+func scanCredential(row rowScanner) (*credentials.Credential, error) {
+	var credential credentials.Credential
+	var expiresAt sql.NullTime
+	var buckets, actions string
+	if err := row.Scan(&credential.AccessKey, &credential.SecretKey, &credential.Description, &credential.Role, &credential.Status,
+		&expiresAt, &buckets, &actions, &credential.CreatedAt, &credential.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		t := expiresAt.Time.UTC()
+		credential.ExpiresAt = &t
+	}
+	if err := json.Unmarshal([]byte(buckets), &credential.Buckets); err != nil {
+		return nil, fmt.Errorf("invalid buckets of %s: %w", credential.AccessKey, err)
+	}
+	if err := json.Unmarshal([]byte(actions), &credential.Actions); err != nil {
+		return nil, fmt.Errorf("invalid actions of %s: %w", credential.AccessKey, err)
+	}
+	return &credential, nil
+}
+
+// encodeScope encodes the bucket and action lists of a credential
+func encodeScope(credential *credentials.Credential) (string, string, error) {
+	buckets, err := json.Marshal(nonNil(credential.Buckets))
+	if err != nil {
+		return "", "", errors.Wrap(errors.ErrCodeInternalError, "Failed to encode buckets", err)
+	}
+	actions, err := json.Marshal(nonNil(credential.Actions))
+	if err != nil {
+		return "", "", errors.Wrap(errors.ErrCodeInternalError, "Failed to encode actions", err)
+	}
+	return string(buckets), string(actions), nil
+}
+
+// requireAffected reports a missing access key when a statement changed no row
+func requireAffected(result sql.Result, accessKey string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to update credential", err)
+	}
+	if affected == 0 {
+		return errors.ErrAccessKeyNotFound.WithContext("access_key", accessKey)
+	}
+	return nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
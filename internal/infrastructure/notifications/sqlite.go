@@ -0,0 +1,113 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/notifications"
+	"github.com/8fs-io/core/pkg/logger"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// sqliteQueue implements notifications.Queue on a SQLite database. Attempt
+// times are stored as Unix nanoseconds so that due deliveries can be found
+// with an indexed comparison.
+type sqliteQueue struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewSQLiteQueue opens or creates the notification queue at path
+func NewSQLiteQueue(path string, logger logger.Logger) (notifications.Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create notification queue directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open notification queue: %w", err)
+	}
+
+	if _, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS deliveries (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		target       TEXT NOT NULL,
+		payload      BLOB NOT NULL,
+		attempts     INTEGER NOT NULL DEFAULT 0,
+		next_attempt INTEGER NOT NULL,
+		last_error   TEXT NOT NULL DEFAULT '',
+		created_at   TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS deliveries_next_attempt ON deliveries (next_attempt);
+	CREATE INDEX IF NOT EXISTS deliveries_target ON deliveries (target, id)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize notification queue schema: %w", err)
+	}
+
+	logger.Info("Notification queue initialized", "path", path)
+	return &sqliteQueue{db: db, logger: logger}, nil
+}
+
+// Enqueue stores a new delivery and sets its ID
+func (q *sqliteQueue) Enqueue(ctx context.Context, delivery *notifications.Delivery) error {
+	result, err := q.db.ExecContext(ctx, `INSERT INTO deliveries (target, payload, attempts, next_attempt, last_error, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		delivery.Target, delivery.Payload, delivery.Attempts, delivery.NextAttempt.UnixNano(), delivery.LastError, delivery.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to queue delivery: %w", err)
+	}
+	delivery.ID, err = result.LastInsertId()
+	return err
+}
+
+// Due returns up to limit deliveries whose next attempt is not after now and
+// that no older delivery to the same webhook is still waiting on
+func (q *sqliteQueue) Due(ctx context.Context, now time.Time, limit int) ([]*notifications.Delivery, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT id, target, payload, attempts, next_attempt, last_error, created_at
+		FROM deliveries d WHERE next_attempt <= ? AND NOT EXISTS (
+			SELECT 1 FROM deliveries o WHERE o.target = d.target AND o.id < d.id AND o.next_attempt > ?
+		) ORDER BY id LIMIT ?`, now.UnixNano(), now.UnixNano(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read due deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*notifications.Delivery
+	for rows.Next() {
+		var delivery notifications.Delivery
+		var nextAttempt int64
+		if err := rows.Scan(&delivery.ID, &delivery.Target, &delivery.Payload, &delivery.Attempts,
+			&nextAttempt, &delivery.LastError, &delivery.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read delivery: %w", err)
+		}
+		delivery.NextAttempt = time.Unix(0, nextAttempt)
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// Reschedule stores the attempts, next attempt and last error of a delivery
+func (q *sqliteQueue) Reschedule(ctx context.Context, delivery *notifications.Delivery) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE deliveries SET attempts = ?, next_attempt = ?, last_error = ? WHERE id = ?`,
+		delivery.Attempts, delivery.NextAttempt.UnixNano(), delivery.LastError, delivery.ID)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule delivery: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a delivery from the queue
+func (q *sqliteQueue) Delete(ctx context.Context, id int64) error {
+	if _, err := q.db.ExecContext(ctx, `DELETE FROM deliveries WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete delivery: %w", err)
+	}
+	return nil
+}
+
+// Close closes the queue database
+func (q *sqliteQueue) Close() error {
+	return q.db.Close()
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/logger"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// sqliteChangeLog implements storage.ChangeLog on a SQLite database. The
+// AUTOINCREMENT row ID is the sequence number, so numbers are never reused
+// even after every change has been truncated. Record times are stored as
+// Unix nanoseconds so that retention can use an indexed comparison.
+type sqliteChangeLog struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewSQLiteChangeLog opens or creates the change log at path
+func NewSQLiteChangeLog(path string, logger logger.Logger) (storage.ChangeLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create change log directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open change log: %w", err)
+	}
+
+	if _, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS changes (
+		seq           INTEGER PRIMARY KEY AUTOINCREMENT,
+		type          TEXT NOT NULL,
+		bucket        TEXT NOT NULL,
+		key           TEXT NOT NULL,
+		version_id    TEXT NOT NULL DEFAULT '',
+		etag          TEXT NOT NULL DEFAULT '',
+		size          INTEGER NOT NULL DEFAULT 0,
+		delete_marker INTEGER NOT NULL DEFAULT 0,
+		recorded_at   INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS changes_bucket ON changes (bucket, seq);
+	CREATE INDEX IF NOT EXISTS changes_version ON changes (bucket, key, version_id, seq);
+	CREATE INDEX IF NOT EXISTS changes_recorded_at ON changes (recorded_at);
+	CREATE TABLE IF NOT EXISTS change_log_state (
+		id      INTEGER PRIMARY KEY CHECK (id = 1),
+		horizon INTEGER NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize change log schema: %w", err)
+	}
+
+	logger.Info("Change log initialized", "path", path)
+	return &sqliteChangeLog{db: db, logger: logger}, nil
+}
+
+// Append stores a change and sets its sequence number
+func (l *sqliteChangeLog) Append(ctx context.Context, change *storage.Change) error {
+	result, err := l.db.ExecContext(ctx, `INSERT INTO changes (type, bucket, key, version_id, etag, size, delete_marker, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		change.Type, change.Bucket, change.Key, change.VersionID, change.ETag, change.Size, change.DeleteMarker, change.Time.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to append change: %w", err)
+	}
+	change.Sequence, err = result.LastInsertId()
+	return err
+}
+
+// Since returns up to limit changes after the given sequence number
+func (l *sqliteChangeLog) Since(ctx context.Context, bucket string, after int64, limit int) ([]storage.Change, error) {
+	query := `SELECT seq, type, bucket, key, version_id, etag, size, delete_marker, recorded_at
+		FROM changes WHERE seq > ? ORDER BY seq LIMIT ?`
+	args := []interface{}{after, limit}
+	if bucket != "" {
+		query = `SELECT seq, type, bucket, key, version_id, etag, size, delete_marker, recorded_at
+		FROM changes WHERE bucket = ? AND seq > ? ORDER BY seq LIMIT ?`
+		args = []interface{}{bucket, after, limit}
+	}
+
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []storage.Change{}
+	for rows.Next() {
+		var change storage.Change
+		var recordedAt int64
+		if err := rows.Scan(&change.Sequence, &change.Type, &change.Bucket, &change.Key, &change.VersionID,
+			&change.ETag, &change.Size, &change.DeleteMarker, &recordedAt); err != nil {
+			return nil, fmt.Errorf("failed to read change: %w", err)
+		}
+		change.Time = time.Unix(0, recordedAt).UTC()
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// Horizon returns the highest sequence number dropped by Truncate
+func (l *sqliteChangeLog) Horizon(ctx context.Context) (int64, error) {
+	var horizon int64
+	err := l.db.QueryRowContext(ctx, `SELECT horizon FROM change_log_state WHERE id = 1`).Scan(&horizon)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to read change log horizon: %w", err)
+	}
+	return horizon, nil
+}
+
+// Truncate drops every change recorded before the given time and moves the
+// horizon past them
+func (l *sqliteChangeLog) Truncate(ctx context.Context, before time.Time) (int, error) {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin change log truncation: %w", err)
+	}
+	defer tx.Rollback()
+
+	var last sql.NullInt64
+	if err := tx.QueryRowContext(ctx, `SELECT MAX(seq) FROM changes WHERE recorded_at < ?`, before.UnixNano()).Scan(&last); err != nil {
+		return 0, fmt.Errorf("failed to find expired changes: %w", err)
+	}
+	if !last.Valid {
+		return 0, nil
+	}
+
+	// Changes are recorded in sequence order, so everything up to the newest
+	// expired change goes
+	result, err := tx.ExecContext(ctx, `DELETE FROM changes WHERE seq <= ?`, last.Int64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to truncate changes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO change_log_state (id, horizon) VALUES (1, ?)
+		ON CONFLICT (id) DO UPDATE SET horizon = MAX(horizon, excluded.horizon)`, last.Int64); err != nil {
+		return 0, fmt.Errorf("failed to store change log horizon: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit change log truncation: %w", err)
+	}
+
+	dropped, err := result.RowsAffected()
+	return int(dropped), err
+}
+
+// Compact drops changes recorded before the given time that a later change
+// to the same version supersedes. Metadata changes do not supersede the
+// write of the version they describe.
+func (l *sqliteChangeLog) Compact(ctx context.Context, before time.Time) (int, error) {
+	result, err := l.db.ExecContext(ctx, `DELETE FROM changes WHERE recorded_at < ? AND EXISTS (
+		SELECT 1 FROM changes AS later
+		WHERE later.bucket = changes.bucket AND later.key = changes.key AND later.version_id = changes.version_id
+			AND later.seq > changes.seq AND (changes.type = ? OR later.type != ?))`,
+		before.UnixNano(), storage.ChangeMetadata, storage.ChangeMetadata)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compact changes: %w", err)
+	}
+	compacted, err := result.RowsAffected()
+	return int(compacted), err
+}
+
+// Close closes the change log database
+func (l *sqliteChangeLog) Close() error {
+	return l.db.Close()
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// configDir holds bucket configurations as <configDir>/<bucket>/<name>.json,
+// outside of the buckets so they never collide with object keys
+const configDir = ".config"
+
+// GetBucketConfig reads the named configuration of a bucket into v
+func (r *filesystemRepository) GetBucketConfig(ctx context.Context, bucket, name string, v interface{}) (bool, error) {
+	data, err := ioutil.ReadFile(r.bucketConfigPath(bucket, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(errors.ErrCodeInternalError, "Failed to read bucket configuration", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, errors.Wrap(errors.ErrCodeInternalError, "Failed to unmarshal bucket configuration", err)
+	}
+	return true, nil
+}
+
+// PutBucketConfig stores the named configuration of a bucket, replacing it
+// atomically so concurrent readers see either the old or the new version
+func (r *filesystemRepository) PutBucketConfig(ctx context.Context, bucket, name string, v interface{}) error {
+	if _, err := os.Stat(r.bucketPath(bucket)); os.IsNotExist(err) {
+		return errors.ErrBucketNotFound.WithContext("bucket", bucket)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to marshal bucket configuration", err)
+	}
+
+	path := r.bucketConfigPath(bucket, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to create configuration directory", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+name+"-*.tmp")
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write bucket configuration", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write bucket configuration", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to store bucket configuration", err)
+	}
+
+	return nil
+}
+
+// DeleteBucketConfig removes the named configuration of a bucket
+func (r *filesystemRepository) DeleteBucketConfig(ctx context.Context, bucket, name string) error {
+	if err := os.Remove(r.bucketConfigPath(bucket, name)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to remove bucket configuration", err)
+	}
+	return nil
+}
+
+func (r *filesystemRepository) bucketConfigPath(bucket, name string) string {
+	return filepath.Join(r.basePath, configDir, bucket, name+".json")
+}
@@ -2,13 +2,16 @@ package storage
 
 import (
 	"context"
+	"crypto/md5"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/8fs-io/core/internal/domain/storage"
@@ -16,10 +19,18 @@ import (
 	"github.com/8fs-io/core/pkg/logger"
 )
 
+// tmpDir holds objects while they are being written, outside of any bucket
+const tmpDir = ".tmp"
+
 // filesystemRepository implements storage.Repository using filesystem
 type filesystemRepository struct {
 	basePath string
 	logger   logger.Logger
+
+	// commitMu is held exclusively while the data and metadata of an object
+	// are moved into place, and shared while they are read, so that readers
+	// never pair the data of one version with the metadata of another
+	commitMu sync.RWMutex
 }
 
 // NewFilesystemRepository creates a new filesystem-based storage repository
@@ -29,10 +40,12 @@ func NewFilesystemRepository(basePath string, logger logger.Logger) (storage.Rep
 		return nil, fmt.Errorf("failed to create base path: %w", err)
 	}
 
-	return &filesystemRepository{
+	r := &filesystemRepository{
 		basePath: basePath,
 		logger:   logger,
-	}, nil
+	}
+	r.recoverCommits()
+	return r, nil
 }
 
 // CreateBucket creates a new bucket directory
@@ -80,6 +93,11 @@ func (r *filesystemRepository) DeleteBucket(ctx context.Context, name string) er
 	if err := os.RemoveAll(bucketPath); err != nil {
 		return errors.Wrap(errors.ErrCodeInternalError, "Failed to remove bucket directory", err)
 	}
+	for _, dir := range []string{versionsDir, configDir} {
+		if err := os.RemoveAll(filepath.Join(r.basePath, dir, name)); err != nil {
+			r.logger.Warn("Failed to remove bucket data", "bucket", name, "path", dir, "error", err)
+		}
+	}
 
 	return nil
 }
@@ -144,6 +162,23 @@ func (r *filesystemRepository) ListBuckets(ctx context.Context) ([]*storage.Buck
 	return buckets, nil
 }
 
+// ListBucketNames lists the names of all buckets without collecting their
+// statistics
+func (r *filesystemRepository) ListBucketNames(ctx context.Context) ([]string, error) {
+	entries, err := ioutil.ReadDir(r.basePath)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read base directory", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
 // BucketExists checks if a bucket exists
 func (r *filesystemRepository) BucketExists(ctx context.Context, name string) (bool, error) {
 	bucketPath := r.bucketPath(name)
@@ -157,12 +192,59 @@ func (r *filesystemRepository) BucketExists(ctx context.Context, name string) (b
 	return true, nil
 }
 
-// PutObject stores an object
-func (r *filesystemRepository) PutObject(ctx context.Context, object *storage.Object) error {
+// PutObject streams an object to a temporary file, computing its MD5 as it is
+// written, and commits it with its metadata so readers never see a partial
+// object. The size and MD5 are those of the plaintext of encrypted objects.
+func (r *filesystemRepository) PutObject(ctx context.Context, object *storage.Object, data io.Reader) error {
+	tmpPath := filepath.Join(r.basePath, tmpDir)
+	if err := os.MkdirAll(tmpPath, 0755); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to create temporary directory", err)
+	}
+
+	tmp, err := os.CreateTemp(tmpPath, "object-*.tmp")
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to create object file", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Write object data
+	hash := md5.New()
+	size, err := writeData(tmp, data, hash, object.Encryption)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		var appErr *errors.AppError
+		if errors.As(err, &appErr) {
+			return appErr
+		}
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write object data", err)
+	}
+
+	object.Size = size
+	object.ETag = fmt.Sprintf("\"%x\"", hash.Sum(nil))
+
+	return r.commitObject(tmp.Name(), object)
+}
+
+// commitIntent records an object commit in progress. Paths are relative to
+// the base path.
+type commitIntent struct {
+	Data           string `json:"data"`
+	DataTarget     string `json:"data_target"`
+	Metadata       string `json:"metadata"`
+	MetadataTarget string `json:"metadata_target"`
+}
+
+// commitObject moves the data at dataPath into place as the current version
+// of object, together with its metadata sidecar. The metadata is staged and
+// the commit recorded before anything is moved, so that a commit cut short
+// by a crash is completed by recoverCommits on the next start rather than
+// leaving data behind that its metadata does not describe, which for
+// encrypted objects would make them undecryptable.
+func (r *filesystemRepository) commitObject(dataPath string, object *storage.Object) error {
 	objectPath := r.objectPath(object.Bucket, object.Key)
 	metadataPath := r.metadataPath(object.Bucket, object.Key)
-
-	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
 		return errors.Wrap(errors.ErrCodeInternalError, "Failed to create object directory", err)
 	}
@@ -170,12 +252,162 @@ func (r *filesystemRepository) PutObject(ctx context.Context, object *storage.Ob
 		return errors.Wrap(errors.ErrCodeInternalError, "Failed to create metadata directory", err)
 	}
 
-	// Write object data
-	if err := ioutil.WriteFile(objectPath, object.Data, 0644); err != nil {
-		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write object data", err)
+	metadataData, err := marshalObjectMetadata(object)
+	if err != nil {
+		return err
+	}
+	metadataTmp, err := r.writeTemp("metadata-*.json", metadataData)
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write object metadata", err)
+	}
+
+	intent := commitIntent{
+		Data:           r.relativePath(dataPath),
+		DataTarget:     r.relativePath(objectPath),
+		Metadata:       r.relativePath(metadataTmp),
+		MetadataTarget: r.relativePath(metadataPath),
+	}
+	intentData, err := json.Marshal(intent)
+	if err != nil {
+		os.Remove(metadataTmp)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to marshal object commit", err)
+	}
+	intentPath, err := r.writeTemp("commit-*.intent", intentData)
+	if err != nil {
+		os.Remove(metadataTmp)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to record object commit", err)
+	}
+
+	r.commitMu.Lock()
+	defer r.commitMu.Unlock()
+
+	if err := os.Rename(dataPath, objectPath); err != nil {
+		os.Remove(intentPath)
+		os.Remove(metadataTmp)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to store object data", err)
+	}
+	if err := os.Rename(metadataTmp, metadataPath); err != nil {
+		// The intent is kept so that the commit is completed on restart
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write object metadata", err)
+	}
+
+	os.Remove(intentPath)
+	return nil
+}
+
+// recoverCommits completes the object commits interrupted by a crash. An
+// intent that cannot be read was cut short while being written, before
+// anything was moved, and is discarded.
+func (r *filesystemRepository) recoverCommits() {
+	intents, _ := filepath.Glob(filepath.Join(r.basePath, tmpDir, "commit-*.intent"))
+	for _, intentPath := range intents {
+		var intent commitIntent
+		data, err := ioutil.ReadFile(intentPath)
+		if err == nil {
+			err = json.Unmarshal(data, &intent)
+		}
+		if err != nil || intent.Data == "" || intent.Metadata == "" {
+			os.Remove(intentPath)
+			continue
+		}
+
+		moves := [][2]string{{intent.Data, intent.DataTarget}, {intent.Metadata, intent.MetadataTarget}}
+		recovered := true
+		for _, move := range moves {
+			from, to := filepath.Join(r.basePath, move[0]), filepath.Join(r.basePath, move[1])
+			if _, err := os.Stat(from); os.IsNotExist(err) {
+				continue // moved before the crash
+			}
+			if err := os.MkdirAll(filepath.Dir(to), 0755); err == nil {
+				err = os.Rename(from, to)
+			}
+			if err != nil {
+				r.logger.Error("Failed to complete interrupted object commit", "target", move[1], "error", err)
+				recovered = false
+			}
+		}
+		if recovered {
+			r.logger.Info("Completed interrupted object commit", "object", intent.DataTarget)
+			os.Remove(intentPath)
+		}
+	}
+}
+
+// writeTemp writes data to a new file in the temporary directory and returns its path
+func (r *filesystemRepository) writeTemp(pattern string, data []byte) (string, error) {
+	tmpPath := filepath.Join(r.basePath, tmpDir)
+	if err := os.MkdirAll(tmpPath, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(tmpPath, pattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// relativePath returns path relative to the base path
+func (r *filesystemRepository) relativePath(path string) string {
+	if rel, err := filepath.Rel(r.basePath, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// writeData copies data to w, encrypting it when encryption is set, and
+// returns the number of plaintext bytes, which are also written to hash
+func writeData(w io.Writer, data io.Reader, hash io.Writer, encryption *storage.Encryption) (int64, error) {
+	if encryption == nil {
+		return io.Copy(io.MultiWriter(w, hash), data)
 	}
 
-	// Write object metadata
+	encrypted, err := encryption.NewWriter(w)
+	if err != nil {
+		return 0, errors.Wrap(errors.ErrCodeInternalError, "Failed to encrypt object data", err)
+	}
+	size, err := io.Copy(io.MultiWriter(encrypted, hash), data)
+	if closeErr := encrypted.Close(); err == nil {
+		err = closeErr
+	}
+	return size, err
+}
+
+// writeObjectMetadata replaces the ObjectInfo sidecar for object, leaving
+// its data as it is
+func (r *filesystemRepository) writeObjectMetadata(object *storage.Object) error {
+	metadataPath := r.metadataPath(object.Bucket, object.Key)
+	if err := os.MkdirAll(filepath.Dir(metadataPath), 0755); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to create metadata directory", err)
+	}
+
+	metadataData, err := marshalObjectMetadata(object)
+	if err != nil {
+		return err
+	}
+	metadataTmp, err := r.writeTemp("metadata-*.json", metadataData)
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write object metadata", err)
+	}
+
+	r.commitMu.Lock()
+	defer r.commitMu.Unlock()
+	if err := os.Rename(metadataTmp, metadataPath); err != nil {
+		os.Remove(metadataTmp)
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write object metadata", err)
+	}
+	return nil
+}
+
+// marshalObjectMetadata encodes the ObjectInfo sidecar for object
+func marshalObjectMetadata(object *storage.Object) ([]byte, error) {
 	metadata := storage.ObjectInfo{
 		Key:          object.Key,
 		Size:         object.Size,
@@ -183,74 +415,69 @@ func (r *filesystemRepository) PutObject(ctx context.Context, object *storage.Ob
 		ETag:         object.ETag,
 		LastModified: object.LastModified,
 		Metadata:     object.Metadata,
+		PartSizes:    object.PartSizes,
+		VersionID:    object.VersionID,
+		Tags:         object.Tags,
+		Encryption:   object.Encryption,
+		Checksums:    object.Checksums,
+		Retention:    object.Retention,
+		LegalHold:    object.LegalHold,
 	}
 
 	metadataData, err := json.Marshal(metadata)
 	if err != nil {
-		return errors.Wrap(errors.ErrCodeInternalError, "Failed to marshal object metadata", err)
-	}
-
-	if err := ioutil.WriteFile(metadataPath, metadataData, 0644); err != nil {
-		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write object metadata", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to marshal object metadata", err)
 	}
-
-	return nil
+	return metadataData, nil
 }
 
-// GetObject retrieves an object
+// GetObject opens an object for reading. The caller must close the returned Body.
 func (r *filesystemRepository) GetObject(ctx context.Context, bucket, key string) (*storage.Object, error) {
-	objectPath := r.objectPath(bucket, key)
-	metadataPath := r.metadataPath(bucket, key)
-
-	// Check if object exists
-	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-		return nil, errors.ErrObjectNotFound.WithContext("bucket", bucket).WithContext("key", key)
-	}
-
-	// Read object data
-	data, err := ioutil.ReadFile(objectPath)
+	// The metadata and the open file stay paired once the lock is released,
+	// as a commit replaces the data file rather than rewriting it
+	r.commitMu.RLock()
+	objectInfo, err := r.objectInfo(bucket, key)
 	if err != nil {
-		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read object data", err)
+		r.commitMu.RUnlock()
+		return nil, err
 	}
-
-	// Read object metadata
-	metadataData, err := ioutil.ReadFile(metadataPath)
+	file, err := os.Open(r.objectPath(bucket, key))
+	r.commitMu.RUnlock()
 	if err != nil {
-		// If metadata doesn't exist, create basic metadata
 		if os.IsNotExist(err) {
-			return &storage.Object{
-				Key:          key,
-				Bucket:       bucket,
-				Size:         int64(len(data)),
-				ContentType:  "application/octet-stream",
-				ETag:         "\"unknown\"",
-				LastModified: time.Now().UTC(),
-				Metadata:     make(map[string]string),
-				Data:         data,
-			}, nil
+			return nil, errors.ErrObjectNotFound.WithContext("bucket", bucket).WithContext("key", key)
 		}
-		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read object metadata", err)
-	}
-
-	var objectInfo storage.ObjectInfo
-	if err := json.Unmarshal(metadataData, &objectInfo); err != nil {
-		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to unmarshal object metadata", err)
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to open object data", err)
 	}
 
 	return &storage.Object{
-		Key:          objectInfo.Key,
+		Key:          key,
 		Bucket:       bucket,
 		Size:         objectInfo.Size,
 		ContentType:  objectInfo.ContentType,
 		ETag:         objectInfo.ETag,
 		LastModified: objectInfo.LastModified,
 		Metadata:     objectInfo.Metadata,
-		Data:         data,
+		PartSizes:    objectInfo.PartSizes,
+		VersionID:    objectInfo.VersionID,
+		Tags:         objectInfo.Tags,
+		Encryption:   objectInfo.Encryption,
+		Checksums:    objectInfo.Checksums,
+		Retention:    objectInfo.Retention,
+		LegalHold:    objectInfo.LegalHold,
+		Body:         file,
 	}, nil
 }
 
 // GetObjectInfo retrieves object metadata only
 func (r *filesystemRepository) GetObjectInfo(ctx context.Context, bucket, key string) (*storage.ObjectInfo, error) {
+	r.commitMu.RLock()
+	defer r.commitMu.RUnlock()
+	return r.objectInfo(bucket, key)
+}
+
+// objectInfo reads the metadata of an object. The caller holds commitMu.
+func (r *filesystemRepository) objectInfo(bucket, key string) (*storage.ObjectInfo, error) {
 	objectPath := r.objectPath(bucket, key)
 	metadataPath := r.metadataPath(bucket, key)
 
@@ -332,60 +559,63 @@ func (r *filesystemRepository) ListObjects(ctx context.Context, bucket string, o
 	// Ensure deterministic lexicographic order by key
 	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
 
-	// Apply marker (skip up to and including marker)
-	if opts.Marker != "" {
-		filtered := make([]storage.ObjectInfo, 0, len(objects))
-		for _, obj := range objects {
-			if obj.Key > opts.Marker { // lexicographic order filtering
-				filtered = append(filtered, obj)
+	// Roll keys up into common prefixes when a delimiter is given. Objects and
+	// prefixes share one lexicographic ordering so that pagination, which
+	// counts both against max-keys, resumes at the right place.
+	type entry struct {
+		name   string
+		object *storage.ObjectInfo
+	}
+	entries := make([]entry, 0, len(objects))
+	for i := range objects {
+		obj := &objects[i]
+		if opts.Delimiter != "" {
+			rel := strings.TrimPrefix(obj.Key, opts.Prefix)
+			if idx := strings.Index(rel, opts.Delimiter); idx >= 0 {
+				cp := opts.Prefix + rel[:idx+len(opts.Delimiter)]
+				if n := len(entries); n > 0 && entries[n-1].object == nil && entries[n-1].name == cp {
+					continue // keys are sorted, so a prefix's keys are adjacent
+				}
+				entries = append(entries, entry{name: cp})
+				continue
 			}
 		}
-		objects = filtered
+		entries = append(entries, entry{name: obj.Key, object: obj})
 	}
 
-	// Handle delimiter: compute CommonPrefixes and filter objects under those prefixes
-	commonSet := map[string]struct{}{}
-	if opts.Delimiter != "" {
-		filtered := make([]storage.ObjectInfo, 0, len(objects))
-		for _, obj := range objects {
-			rel := obj.Key
-			if opts.Prefix != "" && strings.HasPrefix(rel, opts.Prefix) {
-				rel = strings.TrimPrefix(rel, opts.Prefix)
-			}
-			if idx := strings.Index(rel, opts.Delimiter); idx >= 0 {
-				cp := rel[:idx+len(opts.Delimiter)]
-				if opts.Prefix != "" {
-					cp = opts.Prefix + cp
-				}
-				commonSet[cp] = struct{}{}
-				continue
+	// Apply marker (skip up to and including marker). A common prefix is
+	// skipped when the marker is that prefix or lies within it.
+	if opts.Marker != "" {
+		filtered := entries[:0]
+		for _, e := range entries {
+			if e.name > opts.Marker && (e.object != nil || !strings.HasPrefix(opts.Marker, e.name)) {
+				filtered = append(filtered, e)
 			}
-			filtered = append(filtered, obj)
 		}
-		objects = filtered
+		entries = filtered
 	}
 
-	// Apply max keys limit (objects only)
+	// Apply max keys limit, objects and common prefixes both count
 	maxKeys := opts.MaxKeys
 	if maxKeys <= 0 {
 		maxKeys = 1000 // Default limit
 	}
 
 	result := &storage.ListResult{
-		CommonPrefixes: make([]string, 0, len(commonSet)),
-		IsTruncated:    len(objects) > maxKeys,
-	}
-	for cp := range commonSet {
-		result.CommonPrefixes = append(result.CommonPrefixes, cp)
-	}
-	sort.Strings(result.CommonPrefixes)
-
-	// Always limit the number of objects returned
-	if len(objects) > maxKeys {
-		result.Objects = objects[:maxKeys]
-		result.NextMarker = result.Objects[len(result.Objects)-1].Key
-	} else {
-		result.Objects = objects
+		Objects:        []storage.ObjectInfo{},
+		CommonPrefixes: []string{},
+		IsTruncated:    len(entries) > maxKeys,
+	}
+	if result.IsTruncated {
+		entries = entries[:maxKeys]
+		result.NextMarker = entries[len(entries)-1].name
+	}
+	for _, e := range entries {
+		if e.object != nil {
+			result.Objects = append(result.Objects, *e.object)
+		} else {
+			result.CommonPrefixes = append(result.CommonPrefixes, e.name)
+		}
 	}
 
 	return result, nil
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// Multipart uploads are staged outside of the bucket directories:
+//
+//	<base>/.multipart/<uploadID>/upload.json
+//	<base>/.multipart/<uploadID>/00001.part
+//	<base>/.multipart/<uploadID>/00001.json
+const multipartDir = ".multipart"
+
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{1,64}$`)
+
+// CreateMultipartUpload creates the staging directory for a new upload
+func (r *filesystemRepository) CreateMultipartUpload(ctx context.Context, upload *storage.MultipartUpload) error {
+	if !uploadIDPattern.MatchString(upload.UploadID) {
+		return errors.ErrNoSuchUpload.WithContext("upload_id", upload.UploadID)
+	}
+
+	uploadPath := r.uploadPath(upload.UploadID)
+	if err := os.MkdirAll(uploadPath, 0755); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to create upload directory", err)
+	}
+
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to marshal upload metadata", err)
+	}
+	if err := os.WriteFile(filepath.Join(uploadPath, "upload.json"), data, 0644); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write upload metadata", err)
+	}
+
+	return nil
+}
+
+// GetMultipartUpload reads the metadata of an upload
+func (r *filesystemRepository) GetMultipartUpload(ctx context.Context, uploadID string) (*storage.MultipartUpload, error) {
+	if !uploadIDPattern.MatchString(uploadID) {
+		return nil, errors.ErrNoSuchUpload.WithContext("upload_id", uploadID)
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.uploadPath(uploadID), "upload.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.ErrNoSuchUpload.WithContext("upload_id", uploadID)
+		}
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read upload metadata", err)
+	}
+
+	var upload storage.MultipartUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to unmarshal upload metadata", err)
+	}
+	return &upload, nil
+}
+
+// ListMultipartUploads lists uploads for a bucket, or all uploads if bucket is empty
+func (r *filesystemRepository) ListMultipartUploads(ctx context.Context, bucket string) ([]*storage.MultipartUpload, error) {
+	entries, err := os.ReadDir(filepath.Join(r.basePath, multipartDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read multipart directory", err)
+	}
+
+	var uploads []*storage.MultipartUpload
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		upload, err := r.GetMultipartUpload(ctx, entry.Name())
+		if err != nil {
+			r.logger.Warn("Failed to read multipart upload", "upload_id", entry.Name(), "error", err)
+			continue
+		}
+		if bucket == "" || upload.Bucket == bucket {
+			uploads = append(uploads, upload)
+		}
+	}
+
+	return uploads, nil
+}
+
+// PutPart streams a part to disk, computing its MD5 as it is written. Parts
+// of encrypted uploads are each written as one stream, so that the assembled
+// object can be decrypted from any part onwards.
+func (r *filesystemRepository) PutPart(ctx context.Context, uploadID string, partNumber int, data io.Reader, encryption *storage.Encryption) (*storage.Part, error) {
+	if _, err := r.GetMultipartUpload(ctx, uploadID); err != nil {
+		return nil, err
+	}
+	uploadPath := r.uploadPath(uploadID)
+
+	tmp, err := os.CreateTemp(uploadPath, fmt.Sprintf(".%05d-*.tmp", partNumber))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to create part file", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	size, err := writeData(tmp, data, hash, encryption)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		var appErr *errors.AppError
+		if errors.As(err, &appErr) {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to write part data", err)
+	}
+
+	part := &storage.Part{
+		PartNumber:   partNumber,
+		ETag:         fmt.Sprintf("\"%x\"", hash.Sum(nil)),
+		Size:         size,
+		LastModified: time.Now().UTC(),
+	}
+
+	partData, err := json.Marshal(part)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to marshal part metadata", err)
+	}
+	if err := os.Rename(tmp.Name(), r.partPath(uploadID, partNumber)); err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to store part data", err)
+	}
+	if err := os.WriteFile(r.partMetadataPath(uploadID, partNumber), partData, 0644); err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to write part metadata", err)
+	}
+
+	return part, nil
+}
+
+// ListParts lists the parts staged for an upload
+func (r *filesystemRepository) ListParts(ctx context.Context, uploadID string) ([]storage.Part, error) {
+	if _, err := r.GetMultipartUpload(ctx, uploadID); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(r.uploadPath(uploadID))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read upload directory", err)
+	}
+
+	var parts []storage.Part
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == "upload.json" || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(r.uploadPath(uploadID), name))
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read part metadata", err)
+		}
+		var part storage.Part
+		if err := json.Unmarshal(data, &part); err != nil {
+			return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to unmarshal part metadata", err)
+		}
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+// CompleteMultipartUpload concatenates parts into the object and removes the staging directory
+func (r *filesystemRepository) CompleteMultipartUpload(ctx context.Context, uploadID string, parts []storage.Part, object *storage.Object) error {
+	if _, err := r.GetMultipartUpload(ctx, uploadID); err != nil {
+		return err
+	}
+	uploadPath := r.uploadPath(uploadID)
+
+	// Assemble inside the staging directory so a partial object is never visible
+	assembled, err := os.CreateTemp(uploadPath, ".assembled-*.tmp")
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to create object file", err)
+	}
+	defer os.Remove(assembled.Name())
+
+	for _, part := range parts {
+		if err := appendFile(assembled, r.partPath(uploadID, part.PartNumber)); err != nil {
+			assembled.Close()
+			return errors.Wrap(errors.ErrCodeInternalError, "Failed to assemble object", err)
+		}
+	}
+	if err := assembled.Close(); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to assemble object", err)
+	}
+
+	if err := r.commitObject(assembled.Name(), object); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(uploadPath); err != nil {
+		r.logger.Warn("Failed to remove upload directory", "upload_id", uploadID, "error", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload removes an upload and all of its parts
+func (r *filesystemRepository) AbortMultipartUpload(ctx context.Context, uploadID string) error {
+	if _, err := r.GetMultipartUpload(ctx, uploadID); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(r.uploadPath(uploadID)); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to remove upload directory", err)
+	}
+	return nil
+}
+
+func (r *filesystemRepository) uploadPath(uploadID string) string {
+	return filepath.Join(r.basePath, multipartDir, uploadID)
+}
+
+func (r *filesystemRepository) partPath(uploadID string, partNumber int) string {
+	return filepath.Join(r.uploadPath(uploadID), fmt.Sprintf("%05d.part", partNumber))
+}
+
+func (r *filesystemRepository) partMetadataPath(uploadID string, partNumber int) string {
+	return filepath.Join(r.uploadPath(uploadID), fmt.Sprintf("%05d.json", partNumber))
+}
+
+// appendFile copies the contents of path to w
+func appendFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
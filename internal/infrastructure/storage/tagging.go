@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+)
+
+// PutObjectTags replaces the tags of an object. Tags live in the metadata
+// sidecar of the version, so the object data is never rewritten.
+func (r *filesystemRepository) PutObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) error {
+	return r.updateObjectInfo(ctx, bucket, key, versionID, func(info *storage.ObjectInfo) {
+		info.Tags = tags
+	})
+}
+
+// PutObjectLock replaces the retention and legal hold of an object, which
+// live in the metadata sidecar alongside its tags
+func (r *filesystemRepository) PutObjectLock(ctx context.Context, bucket, key, versionID string, retention *storage.ObjectRetention, legalHold bool) error {
+	return r.updateObjectInfo(ctx, bucket, key, versionID, func(info *storage.ObjectInfo) {
+		info.Retention, info.LegalHold = retention, legalHold
+	})
+}
+
+// updateObjectInfo rewrites the metadata sidecar of the current version of an
+// object, or of the noncurrent version versionID, after applying update
+func (r *filesystemRepository) updateObjectInfo(ctx context.Context, bucket, key, versionID string, update func(*storage.ObjectInfo)) error {
+	if versionID != "" {
+		info, err := r.GetObjectVersionInfo(ctx, bucket, key, versionID)
+		if err != nil {
+			return err
+		}
+		update(info)
+		return r.writeVersionMetadata(bucket, info)
+	}
+
+	info, err := r.GetObjectInfo(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	update(info)
+	return r.writeObjectMetadata(&storage.Object{
+		Key:          key,
+		Bucket:       bucket,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+		Metadata:     info.Metadata,
+		PartSizes:    info.PartSizes,
+		VersionID:    info.VersionID,
+		Tags:         info.Tags,
+		Encryption:   info.Encryption,
+		Checksums:    info.Checksums,
+		Retention:    info.Retention,
+		LegalHold:    info.LegalHold,
+	})
+}
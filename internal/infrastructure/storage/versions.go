@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+)
+
+// versionsDir holds the version history of every bucket as
+// <versionsDir>/<bucket>/<sha256 of key>/<version ID>[.json]. Keys are hashed
+// so that histories of keys nested under one another never collide.
+const versionsDir = ".versions"
+
+// ArchiveObject adds the current version of an object to its history and
+// returns its version ID. The current version is left in place so that
+// readers keep seeing it until it is replaced or removed.
+func (r *filesystemRepository) ArchiveObject(ctx context.Context, bucket, key string) (string, error) {
+	info, err := r.GetObjectInfo(ctx, bucket, key)
+	if err != nil {
+		return "", err
+	}
+	if info.VersionID == "" {
+		info.VersionID = storage.NullVersionID
+	}
+
+	dir := r.versionDir(bucket, key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(errors.ErrCodeInternalError, "Failed to create version directory", err)
+	}
+
+	dataPath := filepath.Join(dir, info.VersionID)
+	os.Remove(dataPath)
+	if err := os.Link(r.objectPath(bucket, key), dataPath); err != nil {
+		// Hard links are not available everywhere, fall back to a copy
+		if err := copyFile(r.objectPath(bucket, key), dataPath); err != nil {
+			return "", errors.Wrap(errors.ErrCodeInternalError, "Failed to archive object data", err)
+		}
+	}
+
+	if err := r.writeVersionMetadata(bucket, info); err != nil {
+		os.Remove(dataPath)
+		return "", err
+	}
+
+	return info.VersionID, nil
+}
+
+// PutDeleteMarker adds a delete marker to the history of marker.Key
+func (r *filesystemRepository) PutDeleteMarker(ctx context.Context, bucket string, marker *storage.ObjectInfo) error {
+	if err := os.MkdirAll(r.versionDir(bucket, marker.Key), 0755); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to create version directory", err)
+	}
+	os.Remove(filepath.Join(r.versionDir(bucket, marker.Key), marker.VersionID))
+
+	marker.DeleteMarker = true
+	return r.writeVersionMetadata(bucket, marker)
+}
+
+// GetObjectVersion opens a noncurrent version for reading. The caller must
+// close the returned Body.
+func (r *filesystemRepository) GetObjectVersion(ctx context.Context, bucket, key, versionID string) (*storage.Object, error) {
+	info, err := r.GetObjectVersionInfo(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if info.DeleteMarker {
+		return nil, errors.ErrMethodNotAllowed.WithContext("bucket", bucket).WithContext("key", key).WithContext("version_id", versionID)
+	}
+
+	file, err := os.Open(filepath.Join(r.versionDir(bucket, key), versionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.ErrNoSuchVersion.WithContext("bucket", bucket).WithContext("key", key).WithContext("version_id", versionID)
+		}
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to open object version", err)
+	}
+
+	return &storage.Object{
+		Key:          key,
+		Bucket:       bucket,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+		Metadata:     info.Metadata,
+		PartSizes:    info.PartSizes,
+		VersionID:    info.VersionID,
+		Tags:         info.Tags,
+		Encryption:   info.Encryption,
+		Checksums:    info.Checksums,
+		Retention:    info.Retention,
+		LegalHold:    info.LegalHold,
+		Body:         file,
+	}, nil
+}
+
+// GetObjectVersionInfo retrieves the metadata of a noncurrent version or delete marker
+func (r *filesystemRepository) GetObjectVersionInfo(ctx context.Context, bucket, key, versionID string) (*storage.ObjectInfo, error) {
+	data, err := ioutil.ReadFile(filepath.Join(r.versionDir(bucket, key), versionID+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.ErrNoSuchVersion.WithContext("bucket", bucket).WithContext("key", key).WithContext("version_id", versionID)
+		}
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read version metadata", err)
+	}
+
+	var info storage.ObjectInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to unmarshal version metadata", err)
+	}
+	return &info, nil
+}
+
+// DeleteObjectVersion permanently removes a noncurrent version or delete marker
+func (r *filesystemRepository) DeleteObjectVersion(ctx context.Context, bucket, key, versionID string) error {
+	dir := r.versionDir(bucket, key)
+	metadataPath := filepath.Join(dir, versionID+".json")
+	if _, err := os.Stat(metadataPath); os.IsNotExist(err) {
+		return errors.ErrNoSuchVersion.WithContext("bucket", bucket).WithContext("key", key).WithContext("version_id", versionID)
+	}
+
+	if err := os.Remove(filepath.Join(dir, versionID)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to remove version data", err)
+	}
+	if err := os.Remove(metadataPath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to remove version metadata", err)
+	}
+
+	// Drop the history directory once it is empty
+	os.Remove(dir)
+	return nil
+}
+
+// RestoreObjectVersion makes a noncurrent version the current version again,
+// removing it from the history
+func (r *filesystemRepository) RestoreObjectVersion(ctx context.Context, bucket, key, versionID string) error {
+	info, err := r.GetObjectVersionInfo(ctx, bucket, key, versionID)
+	if err != nil {
+		return err
+	}
+	if info.DeleteMarker {
+		return errors.ErrMethodNotAllowed.WithContext("bucket", bucket).WithContext("key", key).WithContext("version_id", versionID)
+	}
+
+	object := &storage.Object{
+		Key:          key,
+		Bucket:       bucket,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+		Metadata:     info.Metadata,
+		PartSizes:    info.PartSizes,
+		VersionID:    info.VersionID,
+		Tags:         info.Tags,
+		Encryption:   info.Encryption,
+		Checksums:    info.Checksums,
+		Retention:    info.Retention,
+		LegalHold:    info.LegalHold,
+	}
+	if err := r.commitObject(filepath.Join(r.versionDir(bucket, key), versionID), object); err != nil {
+		return err
+	}
+
+	return r.DeleteObjectVersion(ctx, bucket, key, versionID)
+}
+
+// ListObjectHistory lists the noncurrent versions and delete markers of a
+// key, newest first
+func (r *filesystemRepository) ListObjectHistory(ctx context.Context, bucket, key string) ([]storage.ObjectInfo, error) {
+	history, err := r.readHistory(r.versionDir(bucket, key))
+	if err != nil {
+		return nil, err
+	}
+	sortVersions(history)
+	return history, nil
+}
+
+// ListObjectVersions lists every version of the keys under prefix, ordered
+// by key and then from newest to oldest. Current versions are flagged IsLatest.
+func (r *filesystemRepository) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]storage.ObjectInfo, error) {
+	bucketPath := r.bucketPath(bucket)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		return nil, errors.ErrBucketNotFound.WithContext("bucket", bucket)
+	}
+
+	var versions []storage.ObjectInfo
+	err := r.walkObjects(bucketPath, prefix, func(key string, info storage.ObjectInfo) {
+		if info.VersionID == "" {
+			info.VersionID = storage.NullVersionID
+		}
+		info.Key = key
+		info.IsLatest = true
+		versions = append(versions, info)
+	})
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to list objects", err)
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(r.basePath, versionsDir, bucket))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read version history", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		history, err := r.readHistory(filepath.Join(r.basePath, versionsDir, bucket, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range history {
+			if strings.HasPrefix(info.Key, prefix) {
+				versions = append(versions, info)
+			}
+		}
+	}
+
+	sortVersions(versions)
+	return versions, nil
+}
+
+// readHistory reads the metadata of every version in a history directory
+func (r *filesystemRepository) readHistory(dir string) ([]storage.ObjectInfo, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read version history", err)
+	}
+
+	history := make([]storage.ObjectInfo, 0, len(files))
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // removed concurrently
+			}
+			return nil, errors.Wrap(errors.ErrCodeInternalError, "Failed to read version metadata", err)
+		}
+		var info storage.ObjectInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			r.logger.Warn("Skipping unreadable version metadata", "path", file, "error", err)
+			continue
+		}
+		history = append(history, info)
+	}
+	return history, nil
+}
+
+// writeVersionMetadata stores the metadata of a version in its key's history
+func (r *filesystemRepository) writeVersionMetadata(bucket string, info *storage.ObjectInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to marshal version metadata", err)
+	}
+	path := filepath.Join(r.versionDir(bucket, info.Key), info.VersionID+".json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to write version metadata", err)
+	}
+	return nil
+}
+
+func (r *filesystemRepository) versionDir(bucket, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(r.basePath, versionsDir, bucket, hex.EncodeToString(sum[:]))
+}
+
+// sortVersions orders versions by key, the current version first and then
+// from newest to oldest
+func sortVersions(versions []storage.ObjectInfo) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if a.IsLatest != b.IsLatest {
+			return a.IsLatest
+		}
+		if !a.LastModified.Equal(b.LastModified) {
+			return a.LastModified.After(b.LastModified)
+		}
+		return a.VersionID > b.VersionID
+	})
+}
+
+// copyFile copies the file at src to dst
+func copyFile(src, dst string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := appendFile(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
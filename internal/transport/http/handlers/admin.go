@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/8fs-io/core/internal/container"
+	"github.com/8fs-io/core/internal/domain/credentials"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler handles the access key management API
+type AdminHandler struct {
+	container *container.Container
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(c *container.Container) *AdminHandler {
+	return &AdminHandler{container: c}
+}
+
+// RequireAdmin rejects requests not authenticated with an admin key. It runs
+// after the signature middleware, so with authentication disabled every
+// request is rejected.
+func (h *AdminHandler) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credential := requestCredential(c)
+		if credential == nil || !credential.IsAdmin() {
+			h.container.Logger.Warn("Admin API access denied",
+				"user_id", c.GetString("user_id"),
+				"ip", c.ClientIP(),
+				"path", c.Request.URL.Path,
+			)
+			NewStorageHandler(h.container).handleError(c,
+				errors.New(errors.ErrCodeAccessDenied, "The admin API requires an admin access key"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// createKeyRequest describes a new access key
+type createKeyRequest struct {
+	Description string     `json:"description"`
+	Role        string     `json:"role"`
+	Buckets     []string   `json:"buckets"`
+	Actions     []string   `json:"actions"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// CreateKey creates an access key. The secret key is only ever returned here
+// and by RotateKey.
+func (h *AdminHandler) CreateKey(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req createKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		h.handleError(c, "create", "", errors.New(errors.ErrCodeInvalidParameter, "expires_at must be in the future"))
+		return
+	}
+
+	credential, err := h.container.CredentialService.CreateCredential(ctx, &credentials.Credential{
+		Description: req.Description,
+		Role:        req.Role,
+		Buckets:     req.Buckets,
+		Actions:     req.Actions,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if err != nil {
+		h.handleError(c, "create", "", err)
+		return
+	}
+
+	h.audit(c, "create", credential.AccessKey, http.StatusCreated, nil, map[string]interface{}{
+		"role":    credential.Role,
+		"buckets": credential.Buckets,
+		"actions": credential.Actions,
+	})
+	c.JSON(http.StatusCreated, credential)
+}
+
+// ListKeys lists the stored access keys without their secrets
+func (h *AdminHandler) ListKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	list, err := h.container.CredentialService.ListCredentials(ctx)
+	if err != nil {
+		NewStorageHandler(h.container).handleError(c, err)
+		return
+	}
+
+	keys := make([]*credentials.Credential, 0, len(list))
+	for _, credential := range list {
+		keys = append(keys, credential.Redacted())
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  keys,
+		"count": len(keys),
+	})
+}
+
+// GetKey returns an access key without its secret
+func (h *AdminHandler) GetKey(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	credential, err := h.container.CredentialService.GetCredential(ctx, c.Param("accessKey"))
+	if err != nil {
+		NewStorageHandler(h.container).handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, credential.Redacted())
+}
+
+// RotateKey replaces the secret of an access key and returns the new secret
+func (h *AdminHandler) RotateKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	accessKey := c.Param("accessKey")
+
+	credential, err := h.container.CredentialService.RotateCredential(ctx, accessKey)
+	if err != nil {
+		h.handleError(c, "rotate", accessKey, err)
+		return
+	}
+
+	h.audit(c, "rotate", accessKey, http.StatusOK, nil, nil)
+	c.JSON(http.StatusOK, credential)
+}
+
+// DisableKey disables an access key
+func (h *AdminHandler) DisableKey(c *gin.Context) {
+	h.setStatus(c, "disable", credentials.StatusDisabled)
+}
+
+// EnableKey enables a disabled access key
+func (h *AdminHandler) EnableKey(c *gin.Context) {
+	h.setStatus(c, "enable", credentials.StatusActive)
+}
+
+// setStatus changes the status of an access key
+func (h *AdminHandler) setStatus(c *gin.Context, action, status string) {
+	ctx := c.Request.Context()
+	accessKey := c.Param("accessKey")
+
+	if err := h.requireOtherKey(c, accessKey); err != nil {
+		h.handleError(c, action, accessKey, err)
+		return
+	}
+
+	credential, err := h.container.CredentialService.SetCredentialStatus(ctx, accessKey, status)
+	if err != nil {
+		h.handleError(c, action, accessKey, err)
+		return
+	}
+
+	h.audit(c, action, accessKey, http.StatusOK, nil, nil)
+	c.JSON(http.StatusOK, credential.Redacted())
+}
+
+// DeleteKey deletes an access key
+func (h *AdminHandler) DeleteKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	accessKey := c.Param("accessKey")
+
+	if err := h.requireOtherKey(c, accessKey); err != nil {
+		h.handleError(c, "delete", accessKey, err)
+		return
+	}
+
+	if err := h.container.CredentialService.DeleteCredential(ctx, accessKey); err != nil {
+		h.handleError(c, "delete", accessKey, err)
+		return
+	}
+
+	h.audit(c, "delete", accessKey, http.StatusNoContent, nil, nil)
+	c.Status(http.StatusNoContent)
+}
+
+// requireOtherKey keeps admins from locking themselves out with the key they
+// are using
+func (h *AdminHandler) requireOtherKey(c *gin.Context, accessKey string) error {
+	if accessKey == c.GetString("user_id") {
+		return errors.New(errors.ErrCodeInvalidRequest, "An access key cannot disable or delete itself").
+			WithContext("access_key", accessKey)
+	}
+	return nil
+}
+
+// handleError responds with err and records the failed key change
+func (h *AdminHandler) handleError(c *gin.Context, action, accessKey string, err error) {
+	NewStorageHandler(h.container).handleError(c, err)
+	h.audit(c, action, accessKey, c.Writer.Status(), err, nil)
+}
+
+// audit records a key change. Secrets are never part of the event.
+func (h *AdminHandler) audit(c *gin.Context, action, accessKey string, status int, err error, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata["access_key"] = accessKey
+
+	event := logger.AuditEvent{
+		Timestamp:    time.Now().UTC(),
+		RequestID:    c.GetHeader("X-Request-ID"),
+		EventType:    "access_key",
+		Action:       action,
+		Resource:     "access-key/" + accessKey,
+		UserID:       c.GetString("user_id"),
+		SourceIP:     c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		Success:      err == nil,
+		ResponseCode: status,
+		Metadata:     metadata,
+	}
+	if err != nil {
+		event.ErrorMessage = err.Error()
+	}
+	h.container.AuditLogger.Log(event)
+}
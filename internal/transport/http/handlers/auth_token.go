@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/credentials"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// tokenIssuer is the iss claim of the tokens issued by this server
+const tokenIssuer = "8fs"
+
+// tokenRequest exchanges an access key pair for a bearer token. Buckets
+// optionally narrows the token to part of the key's bucket scope.
+type tokenRequest struct {
+	AccessKey string   `json:"access_key" binding:"required"`
+	SecretKey string   `json:"secret_key" binding:"required"`
+	Buckets   []string `json:"buckets"`
+}
+
+// IssueToken handles POST /api/v1/auth/token, issuing an HS256 token that
+// carries the bucket and action scope of the access key
+func (h *AuthHandler) IssueToken(c *gin.Context) {
+	var req tokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	key, appErr := h.validateCredential(c.Request.Context(), req.AccessKey)
+	if appErr == nil && !hmac.Equal([]byte(key.SecretKey), []byte(req.SecretKey)) {
+		appErr = errors.ErrInvalidCredentials
+	}
+	if appErr != nil {
+		authRequestsTotal.WithLabelValues(req.AccessKey, "failure").Inc()
+		message := appErr.Message
+		if appErr.Code == errors.ErrCodeInvalidCredentials {
+			message = "Invalid access key or secret key"
+		}
+		h.respondAuthError(c, appErr, message)
+		return
+	}
+
+	buckets := key.Buckets
+	if req.Buckets != nil {
+		for _, bucket := range req.Buckets {
+			// A requested pattern is within scope when the key's patterns
+			// match it literally, so "team-a*" narrows "team-*"
+			if bucket == "" || !key.AllowsBucket(bucket) {
+				h.respondAuthError(c, errors.ErrAccessDenied, "Requested buckets are outside of the access key scope")
+				return
+			}
+		}
+		buckets = req.Buckets
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		NewStorageHandler(h.container).handleError(c, errors.Wrap(errors.ErrCodeInternalError, "Failed to issue token", err))
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(h.container.Config.Auth.TokenTTL)
+	token, err := jwt.Sign(&jwt.Claims{
+		Issuer:    tokenIssuer,
+		Subject:   key.AccessKey,
+		ID:        hex.EncodeToString(id),
+		IssuedAt:  jwt.NumericDate(now),
+		ExpiresAt: expiresAt.Unix(),
+		Role:      key.Role,
+		Buckets:   buckets,
+		Actions:   key.Actions,
+	}, []byte(h.container.Config.Auth.JWTSecret))
+	if err != nil {
+		NewStorageHandler(h.container).handleError(c, errors.Wrap(errors.ErrCodeInternalError, "Failed to issue token", err))
+		return
+	}
+
+	authRequestsTotal.WithLabelValues(key.AccessKey, "success").Inc()
+	h.container.Logger.Info("Token issued", "access_key", key.AccessKey, "token_id", hex.EncodeToString(id), "expires_at", expiresAt)
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int64(h.container.Config.Auth.TokenTTL.Seconds()),
+		"expires_at":   expiresAt.UTC(),
+	})
+}
+
+// BearerMiddleware authenticates REST API requests with a token from
+// IssueToken. The access key behind the token must still be usable, and
+// tokens issued before the key was last changed (rotated, disabled, re-scoped)
+// are rejected.
+func (h *AuthHandler) BearerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Skip auth if disabled
+		if !h.container.Config.Auth.Enabled {
+			c.Next()
+			return
+		}
+
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			h.respondAuthError(c, errors.ErrAuthRequired, "Missing Authorization header")
+			return
+		}
+		scheme, token, ok := strings.Cut(authHeader, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			h.respondAuthError(c, errors.ErrMalformedAuth, "Expected a Bearer token")
+			return
+		}
+
+		claims, err := jwt.Parse(strings.TrimSpace(token), []byte(h.container.Config.Auth.JWTSecret), time.Now())
+		if err == jwt.ErrExpired {
+			h.respondAuthError(c, errors.ErrTokenExpired, errors.ErrTokenExpired.Message)
+			return
+		}
+		if err != nil {
+			h.respondAuthError(c, errors.ErrInvalidCredentials, "Invalid bearer token")
+			return
+		}
+
+		key, appErr := h.validateCredential(c.Request.Context(), claims.Subject)
+		// Both times have microsecond precision; a token issued in the same
+		// microsecond as the change is rejected too
+		if appErr == nil && !claims.IssuedTime().After(key.UpdatedAt.Truncate(time.Microsecond)) {
+			appErr = errors.New(errors.ErrCodeInvalidCredentials, "Token was issued before the access key was last changed")
+		}
+		if appErr != nil {
+			h.respondAuthError(c, appErr, appErr.Message)
+			authRequestsTotal.WithLabelValues(claims.Subject, "failure").Inc()
+			return
+		}
+
+		h.authenticated(c, tokenCredential(key, claims))
+	}
+}
+
+// tokenCredential returns the credential a request made with a token acts as:
+// the access key limited to the scope in the token claims
+func tokenCredential(key *credentials.Credential, claims *jwt.Claims) *credentials.Credential {
+	scoped := key.Redacted()
+	scoped.Buckets = claims.Buckets
+	scoped.Actions = claims.Actions
+	return scoped
+}
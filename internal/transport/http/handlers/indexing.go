@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/8fs-io/core/internal/container"
 	"github.com/gin-gonic/gin"
@@ -45,6 +46,11 @@ func (h *IndexingHandler) GetJobStatus(c *gin.Context) {
 		return
 	}
 
+	if err := authorizeBucketScope(c, objectBucket(job.ObjectID), "s3:GetObject"); err != nil {
+		NewStorageHandler(h.container).handleError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"job": job,
 	})
@@ -60,6 +66,11 @@ func (h *IndexingHandler) GetJobsByObject(c *gin.Context) {
 		return
 	}
 
+	if err := authorizeBucketScope(c, objectBucket(objectID), "s3:GetObject"); err != nil {
+		NewStorageHandler(h.container).handleError(c, err)
+		return
+	}
+
 	if h.container.IndexingService == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"error": "indexing service not available",
@@ -83,6 +94,12 @@ func (h *IndexingHandler) GetJobsByObject(c *gin.Context) {
 	})
 }
 
+// objectBucket returns the bucket of an indexed object ID of the form bucket/key
+func objectBucket(objectID string) string {
+	bucket, _, _ := strings.Cut(objectID, "/")
+	return bucket
+}
+
 // GetIndexingStats returns indexing service statistics
 func (h *IndexingHandler) GetIndexingStats(c *gin.Context) {
 	if h.container.IndexingService == nil {
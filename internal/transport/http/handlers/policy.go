@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/8fs-io/core/internal/container"
+	"github.com/8fs-io/core/internal/domain/policy"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// PolicyHandler enforces bucket policies on S3 and storage API requests
+type PolicyHandler struct {
+	container *container.Container
+}
+
+// NewPolicyHandler creates a new policy handler
+func NewPolicyHandler(c *container.Container) *PolicyHandler {
+	return &PolicyHandler{container: c}
+}
+
+// S3Middleware evaluates the bucket policy for S3 requests. Requests that
+// touch several objects, such as DeleteObjects, are checked by their handler.
+func (h *PolicyHandler) S3Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucketName := c.Param("bucket")
+		if bucketName == "" {
+			// Listing buckets only needs the action; the handler leaves out
+			// the buckets outside of the access key scope
+			if credential := requestCredential(c); credential != nil && !credential.AllowsAction("s3:ListAllMyBuckets") {
+				NewS3Handler(h.container).handleS3Error(c, accessDenied("", "s3:ListAllMyBuckets"), c.Request.URL.Path)
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		objectKey := strings.TrimPrefix(c.Param("key"), "/")
+
+		var action string
+		var prefix *string
+		if objectKey == "" {
+			action, prefix = s3BucketAction(c)
+		} else {
+			action = s3ObjectAction(c)
+		}
+		if action == "" {
+			c.Next()
+			return
+		}
+
+		resource := "/" + bucketName
+		if objectKey != "" {
+			resource += "/" + objectKey
+		}
+		if err := h.authorize(c, bucketName, objectKey, action, prefix); err != nil {
+			NewS3Handler(h.container).handleS3Error(c, err, resource)
+			c.Abort()
+			return
+		}
+
+		// Copies also read their source, which may live in another bucket
+		if c.GetHeader("X-Amz-Copy-Source") != "" && c.Request.Method == http.MethodPut {
+			srcBucket, srcKey, srcVersion, err := parseCopySource(c.GetHeader("X-Amz-Copy-Source"))
+			if err == nil {
+				if err := h.authorize(c, srcBucket, srcKey, versionedAction("s3:GetObject", srcVersion), nil); err != nil {
+					NewS3Handler(h.container).handleS3Error(c, err, resource)
+					c.Abort()
+					return
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// StorageMiddleware evaluates the bucket policy for storage API requests,
+// which are mapped to the S3 actions they are equivalent to
+func (h *PolicyHandler) StorageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucketName := c.Param("bucket")
+		if bucketName == "" {
+			c.Next()
+			return
+		}
+		objectKey := strings.TrimPrefix(c.Param("key"), "/")
+
+		var action string
+		var prefix *string
+		switch route := c.FullPath(); {
+		case strings.HasSuffix(route, "/objects/*key"):
+			switch c.Request.Method {
+			case http.MethodPut:
+				action = "s3:PutObject"
+			case http.MethodDelete:
+				action = "s3:DeleteObject"
+			default:
+				action = "s3:GetObject"
+			}
+		case strings.HasSuffix(route, "/objects"):
+			action, prefix = "s3:ListBucket", queryPrefix(c)
+		default:
+			switch c.Request.Method {
+			case http.MethodPost:
+				action = "s3:CreateBucket"
+			case http.MethodDelete:
+				action = "s3:DeleteBucket"
+			default:
+				action = "s3:ListBucket"
+			}
+		}
+
+		if err := h.authorize(c, bucketName, objectKey, action, prefix); err != nil {
+			NewStorageHandler(h.container).handleError(c, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorize evaluates the policy of a bucket for an action on the bucket, or
+// on one of its objects when key is set. An explicit Deny always wins, and so
+// does the scope of the access key the request was signed with; an explicit
+// Allow only grants what both leave open.
+func (h *PolicyHandler) authorize(c *gin.Context, bucket, key, action string, prefix *string) error {
+	bucketPolicy, err := h.container.StorageService.BucketPolicy(c.Request.Context(), bucket)
+	if err != nil {
+		return err
+	}
+
+	resource := policy.BucketResource(bucket)
+	if key != "" {
+		resource = policy.ObjectResource(bucket, key)
+	}
+	principal := c.GetString("user_id")
+
+	decision := policy.NotMatched
+	if bucketPolicy != nil {
+		decision = bucketPolicy.Evaluate(policy.Request{
+			Principal:       principal,
+			Action:          action,
+			Resource:        resource,
+			SourceIP:        c.ClientIP(),
+			SecureTransport: c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+			Prefix:          prefix,
+		})
+	}
+
+	if decision == policy.Denied {
+		h.container.Logger.Warn("Request denied by bucket policy",
+			"bucket", bucket,
+			"action", action,
+			"resource", resource,
+			"principal", principal,
+			"ip", c.ClientIP(),
+		)
+		return accessDenied(bucket, action)
+	}
+
+	// A policy grants access on top of the default, never beyond the scope of
+	// the key the request was signed with
+	if credential := requestCredential(c); credential != nil && !credential.Allows(bucket, action) {
+		h.container.Logger.Warn("Request outside of access key scope",
+			"bucket", bucket,
+			"action", action,
+			"principal", principal,
+		)
+		return accessDenied(bucket, action)
+	}
+	return nil
+}
+
+// accessDenied returns the error for a denied action
+func accessDenied(bucket, action string) error {
+	return errors.New(errors.ErrCodeAccessDenied, "Access Denied").
+		WithContext("bucket", bucket).
+		WithContext("action", action)
+}
+
+// authorizeBucketScope checks a request outside of the storage API that acts
+// on the data of a bucket, such as a vector search, against the scope of the
+// caller's key or token. Callers limited to some buckets must name one.
+func authorizeBucketScope(c *gin.Context, bucket, action string) error {
+	credential := requestCredential(c)
+	if credential == nil {
+		return nil
+	}
+	if bucket == "" && len(credential.Buckets) > 0 {
+		return errors.New(errors.ErrCodeAccessDenied, "Credentials scoped to buckets must filter by bucket").
+			WithContext("action", action)
+	}
+	if !credential.AllowsAction(action) || (bucket != "" && !credential.AllowsBucket(bucket)) {
+		return accessDenied(bucket, action)
+	}
+	return nil
+}
+
+// s3BucketAction resolves the action of a bucket request from its method and
+// subresource, along with the listing prefix of list requests
+func s3BucketAction(c *gin.Context) (string, *string) {
+	has := func(name string) bool {
+		_, ok := c.GetQuery(name)
+		return ok
+	}
+
+	switch c.Request.Method {
+	case http.MethodPut:
+		switch {
+		case has("versioning"):
+			return "s3:PutBucketVersioning", nil
+		case has("lifecycle"):
+			return "s3:PutLifecycleConfiguration", nil
+		case has("policy"):
+			return "s3:PutBucketPolicy", nil
+		case has("cors"):
+			return "s3:PutBucketCORS", nil
+		case has("object-lock"):
+			return "s3:PutBucketObjectLockConfiguration", nil
+		case has("notification"):
+			return "s3:PutBucketNotification", nil
+		}
+		return "s3:CreateBucket", nil
+	case http.MethodGet, http.MethodHead:
+		switch {
+		case has("uploads"):
+			return "s3:ListBucketMultipartUploads", nil
+		case has("versioning"):
+			return "s3:GetBucketVersioning", nil
+		case has("lifecycle"):
+			return "s3:GetLifecycleConfiguration", nil
+		case has("policy"):
+			return "s3:GetBucketPolicy", nil
+		case has("cors"):
+			return "s3:GetBucketCORS", nil
+		case has("object-lock"):
+			return "s3:GetBucketObjectLockConfiguration", nil
+		case has("notification"):
+			return "s3:GetBucketNotification", nil
+		case has("versions"):
+			return "s3:ListBucketVersions", queryPrefix(c)
+		case has("acl"):
+			return "s3:GetBucketAcl", nil
+		case has("location"):
+			return "s3:GetBucketLocation", nil
+		}
+		return "s3:ListBucket", queryPrefix(c)
+	case http.MethodDelete:
+		switch {
+		case has("lifecycle"):
+			// S3 has no separate action for deleting a lifecycle configuration
+			return "s3:PutLifecycleConfiguration", nil
+		case has("policy"):
+			return "s3:DeleteBucketPolicy", nil
+		case has("cors"):
+			// S3 has no separate action for deleting a CORS configuration
+			return "s3:PutBucketCORS", nil
+		}
+		return "s3:DeleteBucket", nil
+	}
+	return "", nil
+}
+
+// s3ObjectAction resolves the action of an object request from its method and
+// subresource
+func s3ObjectAction(c *gin.Context) string {
+	_, tagging := c.GetQuery("tagging")
+	_, upload := c.GetQuery("uploadId")
+	_, retention := c.GetQuery("retention")
+	_, legalHold := c.GetQuery("legal-hold")
+	_, acl := c.GetQuery("acl")
+	versionID := c.Query("versionId")
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		switch {
+		case tagging:
+			return versionedAction("s3:GetObjectTagging", versionID)
+		case retention:
+			return "s3:GetObjectRetention"
+		case legalHold:
+			return "s3:GetObjectLegalHold"
+		case upload:
+			return "s3:ListMultipartUploadParts"
+		case acl:
+			return versionedAction("s3:GetObjectAcl", versionID)
+		}
+		return versionedAction("s3:GetObject", versionID)
+	case http.MethodPut:
+		switch {
+		case tagging:
+			return versionedAction("s3:PutObjectTagging", versionID)
+		case retention:
+			return "s3:PutObjectRetention"
+		case legalHold:
+			return "s3:PutObjectLegalHold"
+		}
+		return "s3:PutObject"
+	case http.MethodPost:
+		// S3 Select reads the object
+		if _, ok := c.GetQuery("select"); ok {
+			return "s3:GetObject"
+		}
+		return "s3:PutObject"
+	case http.MethodDelete:
+		switch {
+		case tagging:
+			return versionedAction("s3:DeleteObjectTagging", versionID)
+		case upload:
+			return "s3:AbortMultipartUpload"
+		}
+		return versionedAction("s3:DeleteObject", versionID)
+	}
+	return ""
+}
+
+// versionedAction returns the version-specific variant of an object action,
+// e.g. s3:GetObjectVersion, when a version is addressed
+func versionedAction(action, versionID string) string {
+	if versionID == "" {
+		return action
+	}
+	for _, suffix := range []string{"Tagging", "Acl"} {
+		if base, ok := strings.CutSuffix(action, suffix); ok {
+			return base + "Version" + suffix
+		}
+	}
+	return action + "Version"
+}
+
+// queryPrefix returns the listing prefix of a request for the s3:prefix
+// condition key
+func queryPrefix(c *gin.Context) *string {
+	prefix := c.Query("prefix")
+	return &prefix
+}
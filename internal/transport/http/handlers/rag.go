@@ -38,6 +38,12 @@ func (h *RAGHandler) ChatCompletions(c *gin.Context) {
 		return
 	}
 
+	// Retrieval of bucket-scoped callers is limited to their buckets
+	if err := authorizeBucketScope(c, req.Metadata["bucket"], "s3:GetObject"); err != nil {
+		NewStorageHandler(h.container).handleError(c, err)
+		return
+	}
+
 	// Set defaults if not provided
 	if req.MaxTokens <= 0 {
 		req.MaxTokens = 4000
@@ -83,11 +89,16 @@ func (h *RAGHandler) SearchContext(c *gin.Context) {
 		return
 	}
 
+	if err := authorizeBucketScope(c, req.Metadata["bucket"], "s3:GetObject"); err != nil {
+		NewStorageHandler(h.container).handleError(c, err)
+		return
+	}
+
 	if req.TopK <= 0 {
 		req.TopK = 10 // Default to 10 context documents
 	}
 
-	response, err := h.ragService.SearchContext(c.Request.Context(), req.Query, req.TopK)
+	response, err := h.ragService.SearchContext(c.Request.Context(), req.Query, req.TopK, req.Metadata)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to search context",
@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/8fs-io/core/internal/container"
+	"github.com/8fs-io/core/internal/domain/notifications"
 	"github.com/8fs-io/core/internal/domain/storage"
 	"github.com/8fs-io/core/pkg/errors"
 	"github.com/gin-gonic/gin"
@@ -51,6 +53,8 @@ type ListBucketResult struct {
 	Name           string      `xml:"Name"`
 	Prefix         string      `xml:"Prefix,omitempty"`
 	Marker         string      `xml:"Marker,omitempty"`
+	Delimiter      string      `xml:"Delimiter,omitempty"`
+	EncodingType   string      `xml:"EncodingType,omitempty"`
 	MaxKeys        int         `xml:"MaxKeys"`
 	IsTruncated    bool        `xml:"IsTruncated"`
 	NextMarker     string      `xml:"NextMarker,omitempty"`
@@ -64,13 +68,21 @@ type ObjectXML struct {
 	ETag         string    `xml:"ETag"`
 	Size         int64     `xml:"Size"`
 	StorageClass string    `xml:"StorageClass"`
-	Owner        Owner     `xml:"Owner"`
+	Owner        *Owner    `xml:"Owner,omitempty"`
 }
 
 type PrefixXML struct {
 	Prefix string `xml:"Prefix"`
 }
 
+type LocationConstraint struct {
+	XMLName xml.Name `xml:"LocationConstraint"`
+	Region  string   `xml:",chardata"`
+}
+
+// defaultRegion is the region of buckets when none is configured
+const defaultRegion = "us-east-1"
+
 type ErrorResponse struct {
 	XMLName   xml.Name `xml:"Error"`
 	Code      string   `xml:"Code"`
@@ -79,6 +91,50 @@ type ErrorResponse struct {
 	RequestID string   `xml:"RequestId,omitempty"`
 }
 
+// s3ErrorCodes maps application error codes to the codes S3 clients expect
+var s3ErrorCodes = map[errors.ErrorCode]string{
+	errors.ErrCodeInvalidCredentials:    "InvalidAccessKeyId",
+	errors.ErrCodeInvalidSignature:      "SignatureDoesNotMatch",
+	errors.ErrCodeRequestTimeTooSkewed:  "RequestTimeTooSkewed",
+	errors.ErrCodeMalformedAuth:         "AuthorizationHeaderMalformed",
+	errors.ErrCodeContentSHA256Mismatch: "XAmzContentSHA256Mismatch",
+	errors.ErrCodeAccessDenied:          "AccessDenied",
+	errors.ErrCodeNoSuchUpload:          "NoSuchUpload",
+	errors.ErrCodeInvalidPart:           "InvalidPart",
+	errors.ErrCodeInvalidPartOrder:      "InvalidPartOrder",
+	errors.ErrCodeEntityTooSmall:        "EntityTooSmall",
+	errors.ErrCodeMalformedXML:          "MalformedXML",
+	errors.ErrCodeInvalidParameter:      "InvalidArgument",
+	errors.ErrCodeNotImplemented:        "NotImplemented",
+	errors.ErrCodeInvalidRange:          "InvalidRange",
+	errors.ErrCodeInvalidPartNumber:     "InvalidPartNumber",
+	errors.ErrCodeInvalidRequest:        "InvalidRequest",
+	errors.ErrCodeNotModified:           "NotModified",
+	errors.ErrCodePreconditionFailed:    "PreconditionFailed",
+	errors.ErrCodeNoSuchVersion:         "NoSuchVersion",
+	errors.ErrCodeMethodNotAllowed:      "MethodNotAllowed",
+	errors.ErrCodeInvalidTag:            "InvalidTag",
+	errors.ErrCodeNoSuchLifecycle:       "NoSuchLifecycleConfiguration",
+	errors.ErrCodeNoSuchBucketPolicy:    "NoSuchBucketPolicy",
+	errors.ErrCodeMalformedPolicy:       "MalformedPolicy",
+	errors.ErrCodeNoSuchCORS:            "NoSuchCORSConfiguration",
+	errors.ErrCodeCORSForbidden:         "AccessForbidden",
+	errors.ErrCodeBadDigest:             "BadDigest",
+	errors.ErrCodeInvalidDigest:         "InvalidDigest",
+	errors.ErrCodeIncompleteBody:        "IncompleteBody",
+	errors.ErrCodeNoSuchObjectLock:      "ObjectLockConfigurationNotFoundError",
+	errors.ErrCodeNoSuchRetention:       "NoSuchObjectLockConfiguration",
+	errors.ErrCodeInvalidBucketState:    "InvalidBucketState",
+}
+
+// s3ErrorCode returns the S3 error code for an application error code
+func s3ErrorCode(code errors.ErrorCode) string {
+	if s3Code, ok := s3ErrorCodes[code]; ok {
+		return s3Code
+	}
+	return string(code)
+}
+
 // ListBuckets handles S3 list buckets request
 func (h *S3Handler) ListBuckets(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -90,8 +146,12 @@ func (h *S3Handler) ListBuckets(c *gin.Context) {
 	}
 
 	// Convert to S3 XML format
+	credential := requestCredential(c)
 	var bucketXMLs []BucketXML
 	for _, bucket := range buckets {
+		if credential != nil && !credential.AllowsBucket(bucket.Name) {
+			continue
+		}
 		bucketXMLs = append(bucketXMLs, BucketXML{
 			Name:         bucket.Name,
 			CreationDate: bucket.CreatedAt,
@@ -134,6 +194,12 @@ func (h *S3Handler) CreateBucket(c *gin.Context) {
 		h.handleS3Error(c, err, "/"+bucketName)
 		return
 	}
+	if strings.EqualFold(c.GetHeader("X-Amz-Bucket-Object-Lock-Enabled"), "true") {
+		if err := h.enableObjectLock(ctx, bucketName); err != nil {
+			h.handleS3Error(c, err, "/"+bucketName)
+			return
+		}
+	}
 
 	// Record S3 operation metric
 	s3OperationsTotal.WithLabelValues("CreateBucket", bucketName, "success").Inc()
@@ -157,23 +223,68 @@ func (h *S3Handler) DeleteBucket(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// HeadBucket handles S3 head bucket request
+func (h *S3Handler) HeadBucket(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	if _, err := h.container.StorageService.GetBucket(ctx, bucketName); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	c.Header("X-Amz-Bucket-Region", h.region())
+	c.Status(http.StatusOK)
+}
+
+// GetBucketLocation handles S3 get bucket location request (GET /{bucket}?location)
+func (h *S3Handler) GetBucketLocation(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	if _, err := h.container.StorageService.GetBucket(ctx, bucketName); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	// S3 reports buckets in us-east-1 with an empty location constraint
+	region := h.region()
+	if region == defaultRegion {
+		region = ""
+	}
+	c.XML(http.StatusOK, LocationConstraint{Region: region})
+}
+
+// region returns the region buckets are reported in
+func (h *S3Handler) region() string {
+	if region := h.container.Config.Storage.S3Config.Region; region != "" {
+		return region
+	}
+	return defaultRegion
+}
+
 // ListObjects handles S3 list objects request
 func (h *S3Handler) ListObjects(c *gin.Context) {
+	if c.Query("list-type") == "2" {
+		h.ListObjectsV2(c)
+		return
+	}
+
 	ctx := c.Request.Context()
 	bucketName := c.Param("bucket")
 
+	encodingType, err := listEncodingType(c)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
 	// Parse query parameters
 	opts := storage.ListOptions{
 		Prefix:    c.Query("prefix"),
 		Delimiter: c.Query("delimiter"),
 		Marker:    c.Query("marker"),
-		MaxKeys:   1000, // Default
-	}
-
-	if maxKeys := c.Query("max-keys"); maxKeys != "" {
-		if keys, err := strconv.Atoi(maxKeys); err == nil && keys > 0 {
-			opts.MaxKeys = keys
-		}
+		MaxKeys:   listMaxKeys(c),
 	}
 
 	result, err := h.container.StorageService.ListObjects(ctx, bucketName, opts)
@@ -182,36 +293,18 @@ func (h *S3Handler) ListObjects(c *gin.Context) {
 		return
 	}
 
-	// Convert to S3 XML format
-	var objectXMLs []ObjectXML
-	for _, obj := range result.Objects {
-		objectXMLs = append(objectXMLs, ObjectXML{
-			Key:          obj.Key,
-			LastModified: obj.LastModified,
-			ETag:         obj.ETag,
-			Size:         obj.Size,
-			StorageClass: "STANDARD",
-			Owner: Owner{
-				ID:          "8fs-owner",
-				DisplayName: "8fs",
-			},
-		})
-	}
-
-	var commonPrefixes []PrefixXML
-	for _, prefix := range result.CommonPrefixes {
-		commonPrefixes = append(commonPrefixes, PrefixXML{Prefix: prefix})
-	}
-
+	encode := listKeyEncoder(encodingType)
 	response := ListBucketResult{
 		Name:           bucketName,
-		Prefix:         opts.Prefix,
-		Marker:         opts.Marker,
+		Prefix:         encode(opts.Prefix),
+		Marker:         encode(opts.Marker),
+		Delimiter:      encode(opts.Delimiter),
+		EncodingType:   encodingType,
 		MaxKeys:        opts.MaxKeys,
 		IsTruncated:    result.IsTruncated,
-		NextMarker:     result.NextMarker,
-		Contents:       objectXMLs,
-		CommonPrefixes: commonPrefixes,
+		NextMarker:     encode(result.NextMarker),
+		Contents:       listContents(result, encode, true),
+		CommonPrefixes: listCommonPrefixes(result, encode),
 	}
 
 	c.XML(http.StatusOK, response)
@@ -219,32 +312,59 @@ func (h *S3Handler) ListObjects(c *gin.Context) {
 
 // PutObject handles S3 put object request
 func (h *S3Handler) PutObject(c *gin.Context) {
+	if _, ok := c.Request.Header["X-Amz-Copy-Source"]; ok {
+		h.CopyObject(c)
+		return
+	}
+
 	ctx := c.Request.Context()
 	bucketName := c.Param("bucket")
 	objectKey := strings.TrimPrefix(c.Param("key"), "/")
 
-	data, err := c.GetRawData()
-	if err != nil {
-		h.handleS3Error(c, errors.Wrap(errors.ErrCodeInvalidRequest, "Failed to read request body", err), "/"+bucketName+"/"+objectKey)
-		return
-	}
-
 	contentType := c.GetHeader("Content-Type")
 	if contentType == "" {
 		contentType = "binary/octet-stream"
 	}
 
-	// Extract metadata from headers
-	metadata := make(map[string]string)
-	for key, values := range c.Request.Header {
-		if len(key) > 11 && strings.ToLower(key[:11]) == "x-amz-meta-" {
-			if len(values) > 0 {
-				metadata[key[11:]] = values[0]
-			}
-		}
+	conditions, err := writeConditions(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+	tags, err := amzTagging(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+	sse, err := serverSideEncryption(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+	contentMD5, checksums, err := checksumHeaders(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+	retention, legalHold, err := objectLockHeaders(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+
+	opts := storage.PutObjectOptions{
+		ContentType: contentType,
+		Metadata:    amzMetadata(c.Request.Header),
+		Tags:        tags,
+		Conditions:  conditions,
+		Encryption:  sse,
+		ContentMD5:  contentMD5,
+		Checksums:   checksums,
+		Retention:   retention,
+		LegalHold:   legalHold,
 	}
 
-	object, err := h.container.StorageService.PutObject(ctx, bucketName, objectKey, data, contentType, metadata)
+	object, err := h.container.StorageService.PutObject(ctx, bucketName, objectKey, requestBody(c.Request), opts)
 	if err != nil {
 		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
 		s3OperationsTotal.WithLabelValues("PutObject", bucketName, "error").Inc()
@@ -252,91 +372,178 @@ func (h *S3Handler) PutObject(c *gin.Context) {
 	}
 
 	// Process content for vector embeddings if AI service is available and content is text
-	if h.container.AIService != nil && h.container.AIService.IsTextContent(contentType) {
-		// Check if indexing is enabled for this bucket
-		bucket, err := h.container.StorageService.GetBucket(ctx, bucketName)
+	h.indexObject(ctx, object)
+	notifyObjectEvent(c, h.container.NotificationService, notifications.ObjectEvent{
+		Name: notifications.EventObjectCreatedPut, Bucket: bucketName, Key: objectKey,
+		Size: object.Size, ETag: object.ETag, VersionID: object.VersionID,
+	})
+
+	s3OperationsTotal.WithLabelValues("PutObject", bucketName, "success").Inc()
+
+	setVersionHeaders(c, object.VersionID, false)
+	setEncryptionHeaders(c, object.Encryption)
+	setChecksumHeaders(c, object.Checksums)
+	c.Header("ETag", object.ETag)
+	c.Status(http.StatusOK)
+}
+
+// maxIndexedObjectSize caps how much of an object is read into memory for
+// vector indexing. Larger text objects are stored but not indexed.
+const maxIndexedObjectSize = 10 * 1024 * 1024
+
+// indexObject submits text content for vector indexing when the bucket has
+// indexing enabled. The stored object is re-read in the background so the
+// upload itself never has to be buffered.
+func (h *S3Handler) indexObject(ctx context.Context, object *storage.Object) {
+	if h.container.AIService == nil || !h.container.AIService.IsTextContent(object.ContentType) {
+		return
+	}
+	// Content encrypted with a customer key is only readable with that key,
+	// and must not end up in the vector store in the clear
+	if object.Encryption.IsCustomerKey() {
+		return
+	}
+	bucketName, objectKey := object.Bucket, object.Key
+
+	// Check if indexing is enabled for this bucket
+	bucket, err := h.container.StorageService.GetBucket(ctx, bucketName)
+	if err != nil {
+		h.container.Logger.Warn("Failed to get bucket for indexing check", "bucket", bucketName, "error", err)
+		return
+	}
+	indexingEnabled := bucket.Metadata["indexing-enabled"]
+	if indexingEnabled != "true" && indexingEnabled != "" { // Default to true if not set
+		h.container.Logger.Debug("Skipping AI processing - indexing disabled for bucket", "bucket", bucketName)
+		return
+	}
+
+	if object.Size == 0 {
+		return
+	}
+	if object.Size > maxIndexedObjectSize {
+		h.container.Logger.Warn("Skipping AI processing - object too large to index", "bucket", bucketName, "key", objectKey, "size", object.Size)
+		return
+	}
+	objectID := fmt.Sprintf("%s/%s", bucketName, objectKey)
+
+	// Add S3 metadata to AI metadata
+	aiMetadata := make(map[string]interface{})
+	aiMetadata["bucket"] = bucketName
+	aiMetadata["key"] = objectKey
+	aiMetadata["content_type"] = object.ContentType
+	aiMetadata["size"] = object.Size
+	for k, v := range object.Metadata {
+		aiMetadata["s3_"+k] = v
+	}
+	for k, v := range object.Tags {
+		aiMetadata["tag_"+k] = v
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		text, err := h.readObjectText(ctx, bucketName, objectKey)
 		if err != nil {
-			h.container.Logger.Warn("Failed to get bucket for indexing check", "bucket", bucketName, "error", err)
-		} else {
-			indexingEnabled := bucket.Metadata["indexing-enabled"]
-			if indexingEnabled == "true" || indexingEnabled == "" { // Default to true if not set
-				text := string(data)
-				if text != "" {
-					objectID := fmt.Sprintf("%s/%s", bucketName, objectKey)
-
-					// Add S3 metadata to AI metadata
-					aiMetadata := make(map[string]interface{})
-					aiMetadata["bucket"] = bucketName
-					aiMetadata["key"] = objectKey
-					aiMetadata["content_type"] = contentType
-					aiMetadata["size"] = len(data)
-					for k, v := range metadata {
-						aiMetadata["s3_"+k] = v
-					}
-
-					// Submit for async indexing instead of direct processing
-					if h.container.IndexingService != nil {
-						go func() {
-							ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-							defer cancel()
-							job, err := h.container.IndexingService.SubmitJob(ctx, objectID, text, aiMetadata)
-							if err != nil {
-								h.container.Logger.Warn("Failed to submit document for indexing", "bucket", bucketName, "key", objectKey, "error", err)
-							} else {
-								h.container.Logger.Info("Document submitted for async indexing", "bucket", bucketName, "key", objectKey, "job_id", job.ID)
-							}
-						}()
-					} else {
-						// Fallback to direct AI processing if indexing service is not available
-						go func() {
-							ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-							defer cancel()
-							if err := h.container.AIService.ProcessAndStoreDocument(ctx, objectID, text, aiMetadata); err != nil {
-								h.container.Logger.Warn("Failed to process document for AI", "bucket", bucketName, "key", objectKey, "error", err)
-							} else {
-								h.container.Logger.Info("Document processed for AI", "bucket", bucketName, "key", objectKey)
-							}
-						}()
-					}
-				}
+			h.container.Logger.Warn("Failed to read object for indexing", "bucket", bucketName, "key", objectKey, "error", err)
+			return
+		}
+		if text == "" {
+			return
+		}
+
+		// Submit for async indexing instead of direct processing
+		if h.container.IndexingService != nil {
+			job, err := h.container.IndexingService.SubmitJob(ctx, objectID, text, aiMetadata)
+			if err != nil {
+				h.container.Logger.Warn("Failed to submit document for indexing", "bucket", bucketName, "key", objectKey, "error", err)
 			} else {
-				h.container.Logger.Debug("Skipping AI processing - indexing disabled for bucket", "bucket", bucketName)
+				h.container.Logger.Info("Document submitted for async indexing", "bucket", bucketName, "key", objectKey, "job_id", job.ID)
 			}
+			return
 		}
-	}
 
-	s3OperationsTotal.WithLabelValues("PutObject", bucketName, "success").Inc()
+		// Fallback to direct AI processing if indexing service is not available
+		if err := h.container.AIService.ProcessAndStoreDocument(ctx, objectID, text, aiMetadata); err != nil {
+			h.container.Logger.Warn("Failed to process document for AI", "bucket", bucketName, "key", objectKey, "error", err)
+		} else {
+			h.container.Logger.Info("Document processed for AI", "bucket", bucketName, "key", objectKey)
+		}
+	}()
+}
 
-	c.Header("ETag", object.ETag)
-	c.Status(http.StatusOK)
+// readObjectText reads up to maxIndexedObjectSize bytes of a stored object
+func (h *S3Handler) readObjectText(ctx context.Context, bucketName, objectKey string) (string, error) {
+	object, err := h.container.StorageService.GetObject(ctx, bucketName, objectKey)
+	if err != nil {
+		return "", err
+	}
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(object.Body, maxIndexedObjectSize))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
 }
 
-// GetObject handles S3 get object request
+// GetObject handles S3 get object request, including ranged and part reads
 func (h *S3Handler) GetObject(c *gin.Context) {
 	ctx := c.Request.Context()
 	bucketName := c.Param("bucket")
 	objectKey := strings.TrimPrefix(c.Param("key"), "/")
 
-	object, err := h.container.StorageService.GetObject(ctx, bucketName, objectKey)
+	sse, err := customerKey(c.Request.Header, sseCustomerPrefix)
 	if err != nil {
 		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
-		s3OperationsTotal.WithLabelValues("GetObject", bucketName, "error").Inc()
 		return
 	}
 
-	s3OperationsTotal.WithLabelValues("GetObject", bucketName, "success").Inc()
+	object, err := h.container.StorageService.GetObjectVersion(ctx, bucketName, objectKey, c.Query("versionId"), sse)
+	if err != nil {
+		setDeleteMarkerError(c, err)
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		s3OperationsTotal.WithLabelValues("GetObject", bucketName, "error").Inc()
+		return
+	}
+	defer object.Body.Close()
 
-	c.Header("Content-Type", object.ContentType)
-	c.Header("Content-Length", strconv.FormatInt(object.Size, 10))
+	setVersionHeaders(c, object.VersionID, false)
+	setEncryptionHeaders(c, object.Encryption)
+	setObjectLockHeaders(c, object.Retention, object.LegalHold)
 	c.Header("ETag", object.ETag)
 	c.Header("Last-Modified", object.LastModified.Format(http.TimeFormat))
 
+	if err := readConditions(c.Request.Header).CheckRead(object.ETag, object.LastModified); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+
+	byteRange, err := objectRange(c, object.Size, object.PartSizes)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		s3OperationsTotal.WithLabelValues("GetObject", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("GetObject", bucketName, "success").Inc()
+
+	// Checksums cover the whole object, so ranged reads do not return them
+	if byteRange == nil && checksumModeEnabled(c) {
+		setChecksumHeaders(c, object.Checksums)
+	}
+
 	// Set metadata headers
 	for key, value := range object.Metadata {
 		c.Header("X-Amz-Meta-"+key, value)
 	}
+	if len(object.Tags) > 0 {
+		c.Header("x-amz-tagging-count", strconv.Itoa(len(object.Tags)))
+	}
 
-	c.Data(http.StatusOK, object.ContentType, object.Data)
+	if err := serveObject(c, object, byteRange); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+	}
 }
 
 // HeadObject handles S3 head object request
@@ -345,23 +552,51 @@ func (h *S3Handler) HeadObject(c *gin.Context) {
 	bucketName := c.Param("bucket")
 	objectKey := strings.TrimPrefix(c.Param("key"), "/")
 
-	objectInfo, err := h.container.StorageService.GetObjectInfo(ctx, bucketName, objectKey)
+	sse, err := customerKey(c.Request.Header, sseCustomerPrefix)
 	if err != nil {
 		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
 		return
 	}
 
-	c.Header("Content-Type", objectInfo.ContentType)
-	c.Header("Content-Length", strconv.FormatInt(objectInfo.Size, 10))
+	objectInfo, err := h.container.StorageService.GetObjectVersionInfo(ctx, bucketName, objectKey, c.Query("versionId"))
+	if err != nil {
+		setDeleteMarkerError(c, err)
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+	if err := objectInfo.Encryption.CheckKey(sse); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+
+	setVersionHeaders(c, objectInfo.VersionID, false)
+	setEncryptionHeaders(c, objectInfo.Encryption)
+	setObjectLockHeaders(c, objectInfo.Retention, objectInfo.LegalHold)
 	c.Header("ETag", objectInfo.ETag)
 	c.Header("Last-Modified", objectInfo.LastModified.Format(http.TimeFormat))
 
+	if err := readConditions(c.Request.Header).CheckRead(objectInfo.ETag, objectInfo.LastModified); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+
+	byteRange, err := objectRange(c, objectInfo.Size, objectInfo.PartSizes)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+
+	c.Header("Content-Type", objectInfo.ContentType)
+	if byteRange == nil && checksumModeEnabled(c) {
+		setChecksumHeaders(c, objectInfo.Checksums)
+	}
+
 	// Set metadata headers
 	for key, value := range objectInfo.Metadata {
 		c.Header("X-Amz-Meta-"+key, value)
 	}
 
-	c.Status(http.StatusOK)
+	c.Status(writeRangeHeaders(c, byteRange, objectInfo.Size, objectInfo.PartSizes))
 }
 
 // DeleteObject handles S3 delete object request
@@ -370,26 +605,22 @@ func (h *S3Handler) DeleteObject(c *gin.Context) {
 	bucketName := c.Param("bucket")
 	objectKey := strings.TrimPrefix(c.Param("key"), "/")
 
-	err := h.container.StorageService.DeleteObject(ctx, bucketName, objectKey)
+	result, err := h.container.StorageService.DeleteObjectVersion(ctx, bucketName, objectKey, c.Query("versionId"), bypassGovernance(c))
 	if err != nil {
 		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
 		s3OperationsTotal.WithLabelValues("DeleteObject", bucketName, "error").Inc()
 		return
 	}
 
-	// Remove vector embeddings if AI service is available
-	if h.container.AIService != nil {
-		objectID := fmt.Sprintf("%s/%s", bucketName, objectKey)
-		go func() {
-			if deleteErr := h.deleteObjectVectors(objectID); deleteErr != nil {
-				h.container.Logger.Warn("Failed to delete vectors for object", "object_id", objectID, "error", deleteErr)
-			} else {
-				h.container.Logger.Info("Vectors deleted for object", "object_id", objectID)
-			}
-		}()
-	}
+	// Keep vector embeddings in line with the current version
+	h.syncObjectVectors(bucketName, objectKey)
+	notifyObjectEvent(c, h.container.NotificationService, notifications.ObjectEvent{
+		Name: removedEvent(c.Query("versionId"), result.DeleteMarker), Bucket: bucketName, Key: objectKey,
+		VersionID: result.VersionID,
+	})
 
 	s3OperationsTotal.WithLabelValues("DeleteObject", bucketName, "success").Inc()
+	setVersionHeaders(c, result.VersionID, result.DeleteMarker)
 	c.Status(http.StatusNoContent)
 }
 
@@ -398,26 +629,22 @@ func (h *S3Handler) DeleteObjects(c *gin.Context) {
 	ctx := c.Request.Context()
 	bucketName := c.Param("bucket")
 
-	// Check if this is a delete objects request
-	_, hasDelete := c.GetQuery("delete")
-	if !hasDelete {
-		// Not a delete objects request, handle as other bucket POST operations
-		c.JSON(http.StatusNotImplemented, gin.H{
-			"error": "Only delete objects operation is supported for bucket POST",
-		})
-		return
-	}
-
 	// Parse the XML request body
 	type DeleteRequest struct {
 		Objects []struct {
-			Key string `xml:"Key"`
+			Key       string `xml:"Key"`
+			VersionId string `xml:"VersionId"`
 		} `xml:"Object"`
 		Quiet bool `xml:"Quiet"`
 	}
 
 	var deleteReq DeleteRequest
-	if err := c.ShouldBindXML(&deleteReq); err != nil {
+	if err := decodeXMLBody(c.Request, &deleteReq); err != nil {
+		var appErr *errors.AppError
+		if errors.As(err, &appErr) && appErr.Code != errors.ErrCodeMalformedXML {
+			h.handleS3Error(c, err, "/"+bucketName)
+			return
+		}
 		c.XML(http.StatusBadRequest, gin.H{
 			"error": "Invalid delete request XML",
 		})
@@ -425,9 +652,12 @@ func (h *S3Handler) DeleteObjects(c *gin.Context) {
 	}
 
 	type DeleteResult struct {
-		Key     string `xml:"Key,omitempty"`
-		Code    string `xml:"Code,omitempty"`
-		Message string `xml:"Message,omitempty"`
+		Key                   string `xml:"Key,omitempty"`
+		VersionId             string `xml:"VersionId,omitempty"`
+		DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+		DeleteMarkerVersionId string `xml:"DeleteMarkerVersionId,omitempty"`
+		Code                  string `xml:"Code,omitempty"`
+		Message               string `xml:"Message,omitempty"`
 	}
 
 	type DeleteResponse struct {
@@ -437,41 +667,49 @@ func (h *S3Handler) DeleteObjects(c *gin.Context) {
 	}
 
 	response := DeleteResponse{}
+	policies := NewPolicyHandler(h.container)
+	bypass := bypassGovernance(c)
 
-	// Delete each object
+	// Delete each object the bucket policy allows the caller to delete
 	for _, obj := range deleteReq.Objects {
 		objectKey := obj.Key
-		err := h.container.StorageService.DeleteObject(ctx, bucketName, objectKey)
+		var result *storage.DeleteResult
+		err := policies.authorize(c, bucketName, objectKey, versionedAction("s3:DeleteObject", obj.VersionId), nil)
+		if err == nil {
+			result, err = h.container.StorageService.DeleteObjectVersion(ctx, bucketName, objectKey, obj.VersionId, bypass)
+		}
 
 		if err != nil {
 			s3OperationsTotal.WithLabelValues("DeleteObjects", bucketName, "error").Inc()
 
 			if !deleteReq.Quiet {
+				code, message := "InternalError", err.Error()
+				var appErr *errors.AppError
+				if errors.As(err, &appErr) && appErr.Code == errors.ErrCodeAccessDenied {
+					code, message = s3ErrorCode(appErr.Code), appErr.Message
+				}
 				response.Errors = append(response.Errors, DeleteResult{
 					Key:     objectKey,
-					Code:    "InternalError",
-					Message: err.Error(),
+					Code:    code,
+					Message: message,
 				})
 			}
 		} else {
 			s3OperationsTotal.WithLabelValues("DeleteObjects", bucketName, "success").Inc()
 
-			// Remove vector embeddings if AI service is available
-			if h.container.AIService != nil {
-				objectID := fmt.Sprintf("%s/%s", bucketName, objectKey)
-				go func(id string) {
-					if deleteErr := h.deleteObjectVectors(id); deleteErr != nil {
-						h.container.Logger.Warn("Failed to delete vectors for object", "object_id", id, "error", deleteErr)
-					} else {
-						h.container.Logger.Info("Vectors deleted for object", "object_id", id)
-					}
-				}(objectID)
-			}
+			// Keep vector embeddings in line with the current version
+			h.syncObjectVectors(bucketName, objectKey)
+			notifyObjectEvent(c, h.container.NotificationService, notifications.ObjectEvent{
+				Name: removedEvent(obj.VersionId, result.DeleteMarker), Bucket: bucketName, Key: objectKey,
+				VersionID: result.VersionID,
+			})
 
 			if !deleteReq.Quiet {
-				response.Deleted = append(response.Deleted, DeleteResult{
-					Key: objectKey,
-				})
+				deleted := DeleteResult{Key: objectKey, VersionId: obj.VersionId, DeleteMarker: result.DeleteMarker}
+				if obj.VersionId == "" && result.DeleteMarker {
+					deleted.DeleteMarkerVersionId = result.VersionID
+				}
+				response.Deleted = append(response.Deleted, deleted)
 			}
 		}
 	}
@@ -479,6 +717,166 @@ func (h *S3Handler) DeleteObjects(c *gin.Context) {
 	c.XML(http.StatusOK, response)
 }
 
+// requestBody returns the body of r, which is nil for requests built without
+// one. aws-chunked bodies are decoded, unless authentication already set up
+// their decoding to verify the chunk signatures.
+func requestBody(r *http.Request) io.Reader {
+	if r.Body == nil {
+		return http.NoBody
+	}
+	if _, decoded := r.Body.(*chunkedBody); !decoded && isChunkedPayload(r) {
+		r.Body = newChunkedBody(r, nil)
+	}
+	return r.Body
+}
+
+// maxXMLBodySize bounds the XML documents read from request bodies. The
+// largest is a CompleteMultipartUpload listing 10,000 parts with checksums.
+const maxXMLBodySize = 4 << 20
+
+// readRequestBody reads up to limit bytes of the body of r. The payload hash
+// and chunk signatures are only checked once the body has been read to its
+// end, so a body is read in full before any of it is used, and the error of
+// a failed check is returned as is.
+func readRequestBody(r *http.Request, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(requestBody(r), limit))
+	if err != nil {
+		var appErr *errors.AppError
+		if errors.As(err, &appErr) {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(errors.ErrCodeInvalidRequest, "Failed to read request body", err)
+	}
+	return body, nil
+}
+
+// decodeXMLBody reads the XML document in the body of r into v. A body that
+// fails verification returns the error of the check; one that is too large
+// or does not decode returns ErrCodeMalformedXML.
+func decodeXMLBody(r *http.Request, v interface{}) error {
+	body, err := readRequestBody(r, maxXMLBodySize+1)
+	if err != nil {
+		return err
+	}
+	if len(body) > maxXMLBodySize {
+		return errors.New(errors.ErrCodeMalformedXML, "The XML you provided was larger than the maximum allowed size")
+	}
+	if err := xml.Unmarshal(body, v); err != nil {
+		return errors.Wrap(errors.ErrCodeMalformedXML, "The XML you provided was not well-formed or did not validate against our published schema", err)
+	}
+	return nil
+}
+
+// readConditions reads the conditional headers of a GET or HEAD
+func readConditions(header http.Header) *storage.Conditions {
+	return conditionHeaders(header, "")
+}
+
+// conditionHeaders reads the If-* headers carrying prefix. Dates that do not
+// parse are ignored, as HTTP requires.
+func conditionHeaders(header http.Header, prefix string) *storage.Conditions {
+	conditions := &storage.Conditions{
+		IfMatch:     header.Get(prefix + "If-Match"),
+		IfNoneMatch: header.Get(prefix + "If-None-Match"),
+	}
+	if t, err := http.ParseTime(header.Get(prefix + "If-Modified-Since")); err == nil {
+		conditions.IfModifiedSince = t
+	}
+	if t, err := http.ParseTime(header.Get(prefix + "If-Unmodified-Since")); err == nil {
+		conditions.IfUnmodifiedSince = t
+	}
+	return conditions
+}
+
+// writeConditions reads the conditional headers of a write. Like S3, only
+// If-Match and If-None-Match: * are supported.
+func writeConditions(header http.Header) (*storage.Conditions, error) {
+	conditions := &storage.Conditions{
+		IfMatch:     header.Get("If-Match"),
+		IfNoneMatch: header.Get("If-None-Match"),
+	}
+	if conditions.IfNoneMatch != "" && conditions.IfNoneMatch != "*" {
+		return nil, errors.New(errors.ErrCodeNotImplemented, "If-None-Match only supports the * wildcard on writes").
+			WithContext("header", "If-None-Match")
+	}
+	return conditions, nil
+}
+
+// objectRange resolves the Range header or partNumber query parameter of an
+// object read. A nil range means the whole object is returned.
+func objectRange(c *gin.Context, size int64, partSizes []int64) (*storage.ByteRange, error) {
+	partNumber := c.Query("partNumber")
+	rangeHeader := c.GetHeader("Range")
+	if partNumber == "" {
+		return storage.ParseRange(rangeHeader, size)
+	}
+	if rangeHeader != "" {
+		return nil, errors.New(errors.ErrCodeInvalidRequest, "Cannot specify both Range header and partNumber query parameter")
+	}
+
+	n, err := strconv.Atoi(partNumber)
+	if err != nil {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Part number must be an integer between 1 and 10000, inclusive")
+	}
+	return storage.PartRange(partSizes, size, n)
+}
+
+// writeRangeHeaders sets Accept-Ranges, Content-Length and, for partial
+// responses, Content-Range. It returns the status code to respond with.
+func writeRangeHeaders(c *gin.Context, byteRange *storage.ByteRange, size int64, partSizes []int64) int {
+	c.Header("Accept-Ranges", "bytes")
+	if c.Query("partNumber") != "" && len(partSizes) > 0 {
+		c.Header("x-amz-mp-parts-count", strconv.Itoa(len(partSizes)))
+	}
+	if byteRange == nil {
+		c.Header("Content-Length", strconv.FormatInt(size, 10))
+		return http.StatusOK
+	}
+	c.Header("Content-Range", byteRange.ContentRange(size))
+	c.Header("Content-Length", strconv.FormatInt(byteRange.Length(), 10))
+	return http.StatusPartialContent
+}
+
+// serveObject streams the body of object, or only byteRange of it when set
+func serveObject(c *gin.Context, object *storage.Object, byteRange *storage.ByteRange) error {
+	status := writeRangeHeaders(c, byteRange, object.Size, object.PartSizes)
+	if byteRange == nil {
+		c.DataFromReader(status, object.Size, object.ContentType, object.Body, nil)
+		return nil
+	}
+
+	if _, err := object.Body.Seek(byteRange.Start, io.SeekStart); err != nil {
+		return errors.Wrap(errors.ErrCodeInternalError, "Failed to read object data", err)
+	}
+	c.DataFromReader(status, byteRange.Length(), object.ContentType, io.LimitReader(object.Body, byteRange.Length()), nil)
+	return nil
+}
+
+// amzMetadata extracts user metadata from x-amz-meta-* headers
+func amzMetadata(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for key, values := range header {
+		if len(key) > 11 && strings.ToLower(key[:11]) == "x-amz-meta-" {
+			if len(values) > 0 {
+				metadata[key[11:]] = values[0]
+			}
+		}
+	}
+	return metadata
+}
+
+// MethodNotAllowed rejects requests whose method has no S3 operation on the
+// requested resource
+func (h *S3Handler) MethodNotAllowed(c *gin.Context) {
+	h.handleS3Error(c, errors.ErrMethodNotAllowed, c.Request.URL.Path)
+}
+
+// NotImplemented rejects requests for subresources and operations 8fs does
+// not support
+func (h *S3Handler) NotImplemented(c *gin.Context) {
+	h.handleS3Error(c, errors.New(errors.ErrCodeNotImplemented, "A header or query you provided implies functionality that is not implemented"), c.Request.URL.Path)
+}
+
 // handleS3Error converts domain errors to S3-compatible XML error responses
 func (h *S3Handler) handleS3Error(c *gin.Context, err error, resource string) {
 	var appErr *errors.AppError
@@ -494,7 +892,7 @@ func (h *S3Handler) handleS3Error(c *gin.Context, err error, resource string) {
 	}
 
 	errorResponse := ErrorResponse{
-		Code:      string(appErr.Code),
+		Code:      s3ErrorCode(appErr.Code),
 		Message:   appErr.Message,
 		Resource:  resource,
 		RequestID: requestID,
@@ -503,6 +901,47 @@ func (h *S3Handler) handleS3Error(c *gin.Context, err error, resource string) {
 	c.XML(appErr.HTTPStatus, errorResponse)
 }
 
+// syncObjectVectors makes the vector index follow the current version of a
+// key once its versions changed: embeddings of the version that went away are
+// dropped and the version that became current, if any, is indexed instead.
+func (h *S3Handler) syncObjectVectors(bucketName, objectKey string) {
+	if h.container.AIService == nil {
+		return
+	}
+
+	objectID := fmt.Sprintf("%s/%s", bucketName, objectKey)
+	go func() {
+		if err := h.deleteObjectVectors(objectID); err != nil {
+			h.container.Logger.Warn("Failed to delete vectors for object", "object_id", objectID, "error", err)
+		} else {
+			h.container.Logger.Info("Vectors deleted for object", "object_id", objectID)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		info, err := h.container.StorageService.GetObjectInfo(ctx, bucketName, objectKey)
+		if err != nil {
+			return // no current version left to index
+		}
+		h.indexObject(ctx, objectFromInfo(bucketName, objectKey, info))
+	}()
+}
+
+// objectFromInfo describes a stored object for indexing without opening it
+func objectFromInfo(bucketName, objectKey string, info *storage.ObjectInfo) *storage.Object {
+	return &storage.Object{
+		Key:          objectKey,
+		Bucket:       bucketName,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+		Metadata:     info.Metadata,
+		VersionID:    info.VersionID,
+		Tags:         info.Tags,
+	}
+}
+
 // deleteObjectVectors removes vector embeddings for a deleted object
 func (h *S3Handler) deleteObjectVectors(objectID string) error {
 	if h.container.AIService == nil {
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/8fs-io/core/internal/domain/policy"
+	"github.com/gin-gonic/gin"
+)
+
+// XML structures for the S3 ACL API
+type AccessControlPolicy struct {
+	XMLName           xml.Name          `xml:"AccessControlPolicy"`
+	Owner             Owner             `xml:"Owner"`
+	AccessControlList AccessControlList `xml:"AccessControlList"`
+}
+
+type AccessControlList struct {
+	Grants []Grant `xml:"Grant"`
+}
+
+type Grant struct {
+	Grantee    Grantee `xml:"Grantee"`
+	Permission string  `xml:"Permission"`
+}
+
+type Grantee struct {
+	XMLNSXSI    string `xml:"xmlns:xsi,attr"`
+	Type        string `xml:"xsi:type,attr"`
+	ID          string `xml:"ID,omitempty"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+	URI         string `xml:"URI,omitempty"`
+}
+
+// Canned ACLs reported for buckets and objects
+const (
+	aclPrivate         = "private"
+	aclPublicRead      = "public-read"
+	aclPublicReadWrite = "public-read-write"
+)
+
+// allUsersGroup is the grantee of the permissions anonymous callers have
+const allUsersGroup = "http://acs.amazonaws.com/groups/global/AllUsers"
+
+// GetBucketAcl handles S3 get bucket ACL request (GET /{bucket}?acl).
+// 8fs grants access with keys and bucket policies rather than ACLs, so the
+// ACL is the canned ACL matching what the bucket policy lets anyone do.
+func (h *S3Handler) GetBucketAcl(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	if _, err := h.container.StorageService.GetBucket(ctx, bucketName); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	acl := aclPrivate
+	read, err := h.anonymousAllowed(ctx, bucketName, policy.BucketResource(bucketName), "s3:ListBucket")
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+	if read {
+		acl = aclPublicRead
+		write, err := h.anonymousAllowed(ctx, bucketName, policy.ObjectResource(bucketName, "*"), "s3:PutObject")
+		if err != nil {
+			h.handleS3Error(c, err, "/"+bucketName)
+			return
+		}
+		if write {
+			acl = aclPublicReadWrite
+		}
+	}
+
+	c.XML(http.StatusOK, cannedACL(acl))
+}
+
+// GetObjectAcl handles S3 get object ACL request (GET /{bucket}/{key}?acl)
+func (h *S3Handler) GetObjectAcl(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+	resource := "/" + bucketName + "/" + objectKey
+
+	objectInfo, err := h.container.StorageService.GetObjectVersionInfo(ctx, bucketName, objectKey, c.Query("versionId"))
+	if err != nil {
+		setDeleteMarkerError(c, err)
+		h.handleS3Error(c, err, resource)
+		return
+	}
+
+	acl := aclPrivate
+	read, err := h.anonymousAllowed(ctx, bucketName, policy.ObjectResource(bucketName, objectKey), "s3:GetObject")
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	if read {
+		acl = aclPublicRead
+	}
+
+	setVersionHeaders(c, objectInfo.VersionID, false)
+	c.XML(http.StatusOK, cannedACL(acl))
+}
+
+// anonymousAllowed reports whether the bucket policy allows anyone to perform
+// an action on a resource
+func (h *S3Handler) anonymousAllowed(ctx context.Context, bucket, resource, action string) (bool, error) {
+	bucketPolicy, err := h.container.StorageService.BucketPolicy(ctx, bucket)
+	if err != nil || bucketPolicy == nil {
+		return false, err
+	}
+	decision := bucketPolicy.Evaluate(policy.Request{Action: action, Resource: resource})
+	return decision == policy.Allowed, nil
+}
+
+// cannedACL returns the grants of a canned ACL, the owner always having full
+// control
+func cannedACL(acl string) AccessControlPolicy {
+	grants := []Grant{{
+		Grantee:    Grantee{Type: "CanonicalUser", ID: defaultOwner.ID, DisplayName: defaultOwner.DisplayName},
+		Permission: "FULL_CONTROL",
+	}}
+	switch acl {
+	case aclPublicReadWrite:
+		grants = append(grants,
+			Grant{Grantee: Grantee{Type: "Group", URI: allUsersGroup}, Permission: "READ"},
+			Grant{Grantee: Grantee{Type: "Group", URI: allUsersGroup}, Permission: "WRITE"})
+	case aclPublicRead:
+		grants = append(grants, Grant{Grantee: Grantee{Type: "Group", URI: allUsersGroup}, Permission: "READ"})
+	}
+	for i := range grants {
+		grants[i].Grantee.XMLNSXSI = "http://www.w3.org/2001/XMLSchema-instance"
+	}
+	return AccessControlPolicy{Owner: defaultOwner, AccessControlList: AccessControlList{Grants: grants}}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// checksumHeaderPrefix prefixes the x-amz-checksum-* header of each algorithm
+const checksumHeaderPrefix = "X-Amz-Checksum-"
+
+// checksumHeaders reads the digests an upload must match from Content-MD5 and
+// the x-amz-checksum-* headers. An algorithm named by x-amz-trailer or
+// x-amz-sdk-checksum-algorithm without a digest is computed and stored.
+func checksumHeaders(header http.Header) (string, storage.Checksums, error) {
+	var checksums storage.Checksums
+	for _, algorithm := range storage.ChecksumAlgorithms {
+		if value := header.Get(checksumHeaderPrefix + algorithm); value != "" {
+			if checksums == nil {
+				checksums = storage.Checksums{}
+			}
+			checksums[algorithm] = value
+		}
+	}
+	if len(checksums) > 1 {
+		return "", nil, errors.New(errors.ErrCodeInvalidRequest, "Expecting a single x-amz-checksum- header. Multiple checksum Types are not allowed.")
+	}
+
+	// A checksum sent in the trailer of an aws-chunked body is checked as
+	// the body is decoded, and only needs storing here
+	trailer := strings.ToUpper(strings.TrimSpace(header.Get("X-Amz-Trailer")))
+	if algorithm, ok := strings.CutPrefix(trailer, strings.ToUpper(checksumHeaderPrefix)); ok && len(checksums) == 0 {
+		checksums = storage.Checksums{algorithm: ""}
+	}
+
+	if algorithm := strings.ToUpper(header.Get("X-Amz-Sdk-Checksum-Algorithm")); algorithm != "" {
+		if _, ok := checksums[algorithm]; !ok {
+			if len(checksums) > 0 {
+				return "", nil, errors.New(errors.ErrCodeInvalidRequest, "Value for x-amz-sdk-checksum-algorithm header is invalid.")
+			}
+			checksums = storage.Checksums{algorithm: ""}
+		}
+	}
+
+	return header.Get("Content-MD5"), checksums, nil
+}
+
+// setChecksumHeaders returns the stored checksums of an object
+func setChecksumHeaders(c *gin.Context, checksums storage.Checksums) {
+	for _, algorithm := range storage.ChecksumAlgorithms {
+		if value := checksums[algorithm]; value != "" {
+			c.Header(checksumHeaderPrefix+algorithm, value)
+		}
+	}
+}
+
+// checksumModeEnabled reports whether a GET or HEAD asks for the checksums of
+// the object
+func checksumModeEnabled(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader("X-Amz-Checksum-Mode"), "ENABLED")
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/base64"
+	stderrors "errors"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/sigv4"
+)
+
+// chunkedBody decodes an aws-chunked request body. Once the last chunk has
+// been read it checks the decoded length against x-amz-decoded-content-length
+// and the data against the trailing checksum announced by x-amz-trailer.
+type chunkedBody struct {
+	body          io.ReadCloser
+	reader        *sigv4.ChunkedReader
+	decodedLength int64 // -1 when the header was not sent
+	read          int64
+	algorithm     string // of the trailing checksum, if any
+	hash          hash.Hash
+}
+
+// isChunkedPayload reports whether the body of r uses aws-chunked encoding
+func isChunkedPayload(r *http.Request) bool {
+	return sigv4.IsStreamingPayload(r.Header.Get("X-Amz-Content-Sha256")) ||
+		strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked")
+}
+
+// newChunkedBody decodes the body of r, verifying the chunk signatures with
+// signer unless it is nil
+func newChunkedBody(r *http.Request, signer *sigv4.ChunkSigner) *chunkedBody {
+	body := &chunkedBody{
+		body:          r.Body,
+		reader:        sigv4.NewChunkedReader(r.Body, signer),
+		decodedLength: -1,
+	}
+	if value := r.Header.Get("X-Amz-Decoded-Content-Length"); value != "" {
+		if length, err := strconv.ParseInt(value, 10, 64); err == nil {
+			body.decodedLength = length
+		}
+	}
+
+	trailer := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Amz-Trailer")))
+	if algorithm, ok := strings.CutPrefix(trailer, strings.ToLower(checksumHeaderPrefix)); ok {
+		algorithm = strings.ToUpper(algorithm)
+		if h := storage.NewChecksumHash(algorithm); h != nil {
+			body.algorithm, body.hash = algorithm, h
+		}
+	}
+	return body
+}
+
+func (b *chunkedBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if b.hash != nil {
+		b.hash.Write(p[:n])
+	}
+
+	switch {
+	case err == io.EOF:
+		if verifyErr := b.verify(); verifyErr != nil {
+			return n, verifyErr
+		}
+	case stderrors.Is(err, sigv4.ErrChunkSignature):
+		return n, errors.ErrInvalidSignature
+	case stderrors.Is(err, sigv4.ErrMalformedChunk):
+		return n, errors.New(errors.ErrCodeInvalidRequest, "The request body is not valid aws-chunked encoding").WithCause(err)
+	case err == io.ErrUnexpectedEOF:
+		return n, errors.New(errors.ErrCodeIncompleteBody, "The request body terminated unexpectedly")
+	}
+	return n, err
+}
+
+// verify checks the decoded body once it has been read in full
+func (b *chunkedBody) verify() error {
+	if b.decodedLength >= 0 && b.read != b.decodedLength {
+		return errors.New(errors.ErrCodeIncompleteBody, "You did not provide the number of bytes specified by the x-amz-decoded-content-length HTTP header")
+	}
+	if b.hash == nil {
+		return nil
+	}
+
+	value := b.reader.Trailer().Get(checksumHeaderPrefix + b.algorithm)
+	if value == "" {
+		return errors.New(errors.ErrCodeInvalidRequest, "The x-amz-trailer header names a checksum the request did not send")
+	}
+	if base64.StdEncoding.EncodeToString(b.hash.Sum(nil)) != value {
+		return errors.New(errors.ErrCodeBadDigest, "The "+b.algorithm+" you specified did not match the calculated checksum.")
+	}
+	return nil
+}
+
+func (b *chunkedBody) Close() error {
+	return b.body.Close()
+}
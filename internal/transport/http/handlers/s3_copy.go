@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/notifications"
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// XML structures for the S3 copy API
+type CopyObjectResult struct {
+	XMLName      xml.Name  `xml:"CopyObjectResult"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+}
+
+type CopyPartResult struct {
+	XMLName      xml.Name  `xml:"CopyPartResult"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+}
+
+// copySourcePrefix prefixes the conditional headers that apply to a copy source
+const copySourcePrefix = "X-Amz-Copy-Source-"
+
+// CopyObject handles S3 copy object request (PUT /{bucket}/{key} with x-amz-copy-source)
+func (h *S3Handler) CopyObject(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+	resource := "/" + bucketName + "/" + objectKey
+
+	srcBucket, srcKey, srcVersion, err := parseCopySource(c.GetHeader("X-Amz-Copy-Source"))
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	conditions, err := writeConditions(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	tags, err := amzTagging(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	sse, err := serverSideEncryption(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	sourceSSE, err := customerKey(c.Request.Header, sseCopySourceCustomerKey)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+
+	opts := storage.CopyObjectOptions{
+		SourceVersionID:   srcVersion,
+		MetadataDirective: strings.ToUpper(c.GetHeader("X-Amz-Metadata-Directive")),
+		ContentType:       c.GetHeader("Content-Type"),
+		Metadata:          amzMetadata(c.Request.Header),
+		TaggingDirective:  strings.ToUpper(c.GetHeader("X-Amz-Tagging-Directive")),
+		Tags:              tags,
+		SourceConditions:  conditionHeaders(c.Request.Header, copySourcePrefix),
+		Conditions:        conditions,
+		SourceEncryption:  sourceSSE,
+		Encryption:        sse,
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "binary/octet-stream"
+	}
+
+	object, err := h.container.StorageService.CopyObject(ctx, srcBucket, srcKey, bucketName, objectKey, opts)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		s3OperationsTotal.WithLabelValues("CopyObject", bucketName, "error").Inc()
+		return
+	}
+
+	// Index the copy the same way a regular PUT would be
+	h.indexObject(ctx, object)
+	notifyObjectEvent(c, h.container.NotificationService, notifications.ObjectEvent{
+		Name: notifications.EventObjectCreatedCopy, Bucket: bucketName, Key: objectKey,
+		Size: object.Size, ETag: object.ETag, VersionID: object.VersionID,
+	})
+
+	s3OperationsTotal.WithLabelValues("CopyObject", bucketName, "success").Inc()
+
+	setVersionHeaders(c, object.VersionID, false)
+	setEncryptionHeaders(c, object.Encryption)
+	if srcVersion != "" {
+		c.Header("x-amz-copy-source-version-id", srcVersion)
+	}
+	c.XML(http.StatusOK, CopyObjectResult{
+		LastModified: object.LastModified,
+		ETag:         object.ETag,
+	})
+}
+
+// UploadPartCopy handles S3 upload part copy request (PUT /{bucket}/{key}?partNumber=&uploadId= with x-amz-copy-source)
+func (h *S3Handler) UploadPartCopy(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+	resource := "/" + bucketName + "/" + objectKey
+
+	srcBucket, srcKey, srcVersion, err := parseCopySource(c.GetHeader("X-Amz-Copy-Source"))
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	partNumber, err := strconv.Atoi(c.Query("partNumber"))
+	if err != nil {
+		h.handleS3Error(c, errors.New(errors.ErrCodeInvalidParameter, "Part number must be an integer between 1 and 10000, inclusive"), resource)
+		return
+	}
+
+	sse, err := customerKey(c.Request.Header, sseCustomerPrefix)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	sourceSSE, err := customerKey(c.Request.Header, sseCopySourceCustomerKey)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+
+	opts := storage.CopyPartOptions{
+		SourceVersionID:  srcVersion,
+		SourceRange:      c.GetHeader("X-Amz-Copy-Source-Range"),
+		SourceConditions: conditionHeaders(c.Request.Header, copySourcePrefix),
+		SourceEncryption: sourceSSE,
+		Encryption:       sse,
+	}
+
+	part, err := h.container.StorageService.UploadPartCopy(ctx, srcBucket, srcKey, bucketName, objectKey, c.Query("uploadId"), partNumber, opts)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		s3OperationsTotal.WithLabelValues("UploadPartCopy", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("UploadPartCopy", bucketName, "success").Inc()
+
+	c.XML(http.StatusOK, CopyPartResult{
+		LastModified: part.LastModified,
+		ETag:         part.ETag,
+	})
+}
+
+// parseCopySource splits an x-amz-copy-source value of the form
+// [/]bucket/key[?versionId=id] into its URL-decoded bucket, key and version
+func parseCopySource(source string) (string, string, string, error) {
+	invalid := errors.New(errors.ErrCodeInvalidParameter, "Copy Source must mention the source bucket and key: sourcebucket/sourcekey").
+		WithContext("copy_source", source)
+
+	source, rawQuery, _ := strings.Cut(source, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", "", "", invalid
+	}
+	decoded, err := url.PathUnescape(strings.TrimPrefix(source, "/"))
+	if err != nil {
+		return "", "", "", invalid
+	}
+	bucket, key, ok := strings.Cut(decoded, "/")
+	if !ok || bucket == "" || key == "" {
+		return "", "", "", invalid
+	}
+	return bucket, key, query.Get("versionId"), nil
+}
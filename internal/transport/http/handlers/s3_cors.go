@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// XML structures for the S3 bucket CORS API
+type CORSConfiguration struct {
+	XMLName xml.Name   `xml:"CORSConfiguration"`
+	Rules   []CORSRule `xml:"CORSRule"`
+}
+
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// PutBucketCors handles S3 put bucket CORS request (PUT /{bucket}?cors)
+func (h *S3Handler) PutBucketCors(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	var request CORSConfiguration
+	if err := decodeXMLBody(c.Request, &request); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	config := &storage.CORSConfiguration{}
+	for _, rule := range request.Rules {
+		config.Rules = append(config.Rules, storage.CORSRule(rule))
+	}
+
+	if err := h.container.StorageService.PutBucketCors(ctx, bucketName, config); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		s3OperationsTotal.WithLabelValues("PutBucketCors", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("PutBucketCors", bucketName, "success").Inc()
+	c.Status(http.StatusOK)
+}
+
+// GetBucketCors handles S3 get bucket CORS request (GET /{bucket}?cors)
+func (h *S3Handler) GetBucketCors(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	config, err := h.container.StorageService.GetBucketCors(ctx, bucketName)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	response := CORSConfiguration{}
+	for _, rule := range config.Rules {
+		response.Rules = append(response.Rules, CORSRule(rule))
+	}
+	c.XML(http.StatusOK, response)
+}
+
+// DeleteBucketCors handles S3 delete bucket CORS request (DELETE /{bucket}?cors)
+func (h *S3Handler) DeleteBucketCors(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	if err := h.container.StorageService.DeleteBucketCors(ctx, bucketName); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		s3OperationsTotal.WithLabelValues("DeleteBucketCors", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("DeleteBucketCors", bucketName, "success").Inc()
+	c.Status(http.StatusNoContent)
+}
+
+// errCORSForbidden is returned for cross-origin requests no rule allows
+var errCORSForbidden = errors.New(errors.ErrCodeCORSForbidden,
+	"CORSResponse: This CORS request is not allowed. This is usually because the evalution of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.")
+
+// CORSMiddleware applies the CORS configuration of the requested bucket. It
+// answers OPTIONS preflight requests itself, ahead of authentication since
+// browsers never sign them, and adds the Access-Control-* headers of the
+// matching rule to the responses of other cross-origin requests.
+func (h *S3Handler) CORSMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodOptions {
+			h.preflight(c)
+			c.Abort()
+			return
+		}
+
+		origin := c.GetHeader("Origin")
+		bucketName := c.Param("bucket")
+		if origin == "" || bucketName == "" {
+			c.Next()
+			return
+		}
+
+		config, err := h.container.StorageService.BucketCors(c.Request.Context(), bucketName)
+		if err != nil {
+			h.container.Logger.Warn("Failed to read bucket CORS configuration", "bucket", bucketName, "error", err)
+		}
+		if config != nil {
+			if rule := config.MatchRule(origin, c.Request.Method, nil); rule != nil {
+				setCORSHeaders(c, rule, origin)
+			}
+		}
+		c.Next()
+	}
+}
+
+// preflight answers an OPTIONS preflight request
+func (h *S3Handler) preflight(c *gin.Context) {
+	bucketName := c.Param("bucket")
+	resource := c.Request.URL.Path
+
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		h.handleS3Error(c, errors.New(errors.ErrCodeInvalidRequest, "Insufficient information. Origin request header needed."), resource)
+		return
+	}
+	method := c.GetHeader("Access-Control-Request-Method")
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodHead:
+	default:
+		h.handleS3Error(c, errors.New(errors.ErrCodeInvalidRequest, "Invalid Access-Control-Request-Method: "+method), resource)
+		return
+	}
+	if bucketName == "" {
+		h.handleS3Error(c, errCORSForbidden, resource)
+		return
+	}
+
+	config, err := h.container.StorageService.BucketCors(c.Request.Context(), bucketName)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+
+	var headers []string
+	for _, header := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+
+	var rule *storage.CORSRule
+	if config != nil {
+		rule = config.MatchRule(origin, method, headers)
+	}
+	if rule == nil {
+		s3OperationsTotal.WithLabelValues("PreflightRequest", bucketName, "error").Inc()
+		h.handleS3Error(c, errCORSForbidden, resource)
+		return
+	}
+
+	setCORSHeaders(c, rule, origin)
+	if len(headers) > 0 {
+		c.Header("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if rule.MaxAgeSeconds > 0 {
+		c.Header("Access-Control-Max-Age", strconv.Itoa(rule.MaxAgeSeconds))
+	}
+	c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+	c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+
+	s3OperationsTotal.WithLabelValues("PreflightRequest", bucketName, "success").Inc()
+	c.Status(http.StatusOK)
+}
+
+// setCORSHeaders adds the headers allowing a cross-origin request by rule.
+// A rule open to any origin allows it without credentials.
+func setCORSHeaders(c *gin.Context, rule *storage.CORSRule, origin string) {
+	allowOrigin := origin
+	for _, allowed := range rule.AllowedOrigins {
+		if allowed == "*" {
+			allowOrigin = "*"
+			break
+		}
+	}
+
+	c.Header("Access-Control-Allow-Origin", allowOrigin)
+	if allowOrigin != "*" {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+	c.Header("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+	if len(rule.ExposeHeaders) > 0 {
+		c.Header("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+	c.Writer.Header().Add("Vary", "Origin")
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// Server-side encryption headers. SSE-C keys are sent under the customer
+// prefix, and under the copy source prefix for the source of a copy.
+const (
+	sseHeader                = "X-Amz-Server-Side-Encryption"
+	sseCustomerPrefix        = "X-Amz-Server-Side-Encryption-Customer-"
+	sseCopySourceCustomerKey = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-"
+)
+
+// serverSideEncryption reads the encryption requested for a new object,
+// either SSE-S3 or SSE-C. It returns nil when the request asks for neither.
+func serverSideEncryption(header http.Header) (*storage.ServerSideEncryption, error) {
+	sse, err := customerKey(header, sseCustomerPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm := header.Get(sseHeader)
+	if algorithm == "" {
+		return sse, nil
+	}
+	if sse != nil {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Server Side Encryption with Customer provided key is incompatible with the encryption method specified")
+	}
+	if algorithm != storage.EncryptionAES256 {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "The encryption method specified is not supported").
+			WithContext("algorithm", algorithm)
+	}
+	return &storage.ServerSideEncryption{}, nil
+}
+
+// customerKey reads an SSE-C key from the headers with the given prefix. It
+// returns nil when none of them are set.
+func customerKey(header http.Header, prefix string) (*storage.ServerSideEncryption, error) {
+	algorithm := header.Get(prefix + "Algorithm")
+	key := header.Get(prefix + "Key")
+	keyMD5 := header.Get(prefix + "Key-MD5")
+	if algorithm == "" && key == "" && keyMD5 == "" {
+		return nil, nil
+	}
+
+	if algorithm != storage.EncryptionAES256 {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Requests specifying Server Side Encryption with Customer provided keys must provide a valid encryption algorithm.").
+			WithContext("algorithm", algorithm)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decoded) != storage.EncryptionKeySize {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "The secret key was invalid for the specified algorithm.")
+	}
+	if keyMD5 == "" {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "Requests specifying Server Side Encryption with Customer provided keys must provide the client calculated MD5 of the secret key.")
+	}
+	if keyMD5 != storage.CustomerKeyMD5(decoded) {
+		return nil, errors.New(errors.ErrCodeInvalidParameter, "The calculated MD5 hash of the key did not match the hash that was provided.")
+	}
+
+	return &storage.ServerSideEncryption{CustomerKey: decoded}, nil
+}
+
+// setEncryptionHeaders reports how an object is encrypted at rest
+func setEncryptionHeaders(c *gin.Context, encryption *storage.Encryption) {
+	switch {
+	case encryption == nil:
+	case encryption.IsCustomerKey():
+		c.Header(sseCustomerPrefix+"Algorithm", encryption.Algorithm)
+		c.Header(sseCustomerPrefix+"Key-MD5", encryption.CustomerKeyMD5)
+	default:
+		c.Header(sseHeader, encryption.Algorithm)
+	}
+}
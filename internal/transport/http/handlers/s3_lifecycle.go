@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// XML structures for the S3 bucket lifecycle API
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+type LifecycleRule struct {
+	ID         string               `xml:"ID,omitempty"`
+	Prefix     *string              `xml:"Prefix,omitempty"` // deprecated rule-level prefix, still sent by older clients
+	Filter     *LifecycleFilter     `xml:"Filter,omitempty"`
+	Status     string               `xml:"Status"`
+	Expiration *LifecycleExpiration `xml:"Expiration,omitempty"`
+}
+
+// LifecycleFilter selects the objects of a rule. Metadata is an 8fs
+// extension matching x-amz-meta-* values the way Tag matches object tags.
+type LifecycleFilter struct {
+	Prefix   string            `xml:"Prefix,omitempty"`
+	Tag      *Tag              `xml:"Tag,omitempty"`
+	Metadata *LifecycleMeta    `xml:"Metadata,omitempty"`
+	And      *LifecycleFilters `xml:"And,omitempty"`
+}
+
+type LifecycleFilters struct {
+	Prefix   string          `xml:"Prefix,omitempty"`
+	Tags     []Tag           `xml:"Tag"`
+	Metadata []LifecycleMeta `xml:"Metadata"`
+}
+
+type LifecycleMeta struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type LifecycleExpiration struct {
+	Days int    `xml:"Days,omitempty"`
+	Date string `xml:"Date,omitempty"`
+}
+
+// PutBucketLifecycleConfiguration handles S3 put bucket lifecycle request (PUT /{bucket}?lifecycle)
+func (h *S3Handler) PutBucketLifecycleConfiguration(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	var request LifecycleConfiguration
+	if err := decodeXMLBody(c.Request, &request); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	config, err := lifecycleFromXML(request)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	if err := h.container.StorageService.PutBucketLifecycle(ctx, bucketName, config); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		s3OperationsTotal.WithLabelValues("PutBucketLifecycleConfiguration", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("PutBucketLifecycleConfiguration", bucketName, "success").Inc()
+	c.Status(http.StatusOK)
+}
+
+// GetBucketLifecycleConfiguration handles S3 get bucket lifecycle request (GET /{bucket}?lifecycle)
+func (h *S3Handler) GetBucketLifecycleConfiguration(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	config, err := h.container.StorageService.GetBucketLifecycle(ctx, bucketName)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	c.XML(http.StatusOK, lifecycleToXML(config))
+}
+
+// DeleteBucketLifecycle handles S3 delete bucket lifecycle request (DELETE /{bucket}?lifecycle)
+func (h *S3Handler) DeleteBucketLifecycle(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	if err := h.container.StorageService.DeleteBucketLifecycle(ctx, bucketName); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		s3OperationsTotal.WithLabelValues("DeleteBucketLifecycle", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("DeleteBucketLifecycle", bucketName, "success").Inc()
+	c.Status(http.StatusNoContent)
+}
+
+// lifecycleFromXML converts a lifecycle configuration request to its domain
+// form, merging the conditions of a filter and its And element
+func lifecycleFromXML(request LifecycleConfiguration) (*storage.LifecycleConfiguration, error) {
+	config := &storage.LifecycleConfiguration{}
+	for _, rule := range request.Rules {
+		if rule.Expiration == nil {
+			return nil, errors.New(errors.ErrCodeInvalidParameter, "At least one action needs to be specified in a rule")
+		}
+
+		domainRule := storage.LifecycleRule{
+			ID:     rule.ID,
+			Status: rule.Status,
+			Expiration: storage.LifecycleExpiration{
+				Days: rule.Expiration.Days,
+			},
+		}
+		if rule.Expiration.Date != "" {
+			date, err := time.Parse(time.RFC3339, rule.Expiration.Date)
+			if err != nil {
+				return nil, errors.New(errors.ErrCodeInvalidParameter, "Invalid date specified in Expiration action").
+					WithContext("date", rule.Expiration.Date)
+			}
+			date = date.UTC()
+			domainRule.Expiration.Date = &date
+		}
+
+		tags := make(map[string]string)
+		metadata := make(map[string]string)
+		if rule.Prefix != nil {
+			domainRule.Filter.Prefix = *rule.Prefix
+		}
+		if filter := rule.Filter; filter != nil {
+			if rule.Prefix != nil {
+				return nil, errors.New(errors.ErrCodeMalformedXML, "A rule cannot have both a Prefix and a Filter")
+			}
+			domainRule.Filter.Prefix = filter.Prefix
+			addTag := func(tag Tag) error {
+				if _, ok := tags[tag.Key]; ok {
+					return errors.New(errors.ErrCodeInvalidTag, "Duplicate Tag Keys are not allowed.")
+				}
+				tags[tag.Key] = tag.Value
+				return nil
+			}
+			if filter.Tag != nil {
+				if err := addTag(*filter.Tag); err != nil {
+					return nil, err
+				}
+			}
+			if filter.Metadata != nil {
+				metadata[filter.Metadata.Key] = filter.Metadata.Value
+			}
+			if and := filter.And; and != nil {
+				if and.Prefix != "" {
+					domainRule.Filter.Prefix = and.Prefix
+				}
+				for _, tag := range and.Tags {
+					if err := addTag(tag); err != nil {
+						return nil, err
+					}
+				}
+				for _, meta := range and.Metadata {
+					metadata[meta.Key] = meta.Value
+				}
+			}
+		}
+		if len(tags) > 0 {
+			domainRule.Filter.Tags = tags
+		}
+		if len(metadata) > 0 {
+			domainRule.Filter.Metadata = metadata
+		}
+
+		config.Rules = append(config.Rules, domainRule)
+	}
+	return config, nil
+}
+
+// lifecycleToXML renders a lifecycle configuration, using an And element
+// whenever a filter has more than one condition
+func lifecycleToXML(config *storage.LifecycleConfiguration) LifecycleConfiguration {
+	response := LifecycleConfiguration{}
+	for _, rule := range config.Rules {
+		xmlRule := LifecycleRule{
+			ID:         rule.ID,
+			Status:     rule.Status,
+			Filter:     &LifecycleFilter{},
+			Expiration: &LifecycleExpiration{Days: rule.Expiration.Days},
+		}
+		if rule.Expiration.Date != nil {
+			xmlRule.Expiration.Date = rule.Expiration.Date.UTC().Format(time.RFC3339)
+		}
+
+		var and LifecycleFilters
+		and.Prefix = rule.Filter.Prefix
+		for _, key := range sortedKeys(rule.Filter.Tags) {
+			and.Tags = append(and.Tags, Tag{Key: key, Value: rule.Filter.Tags[key]})
+		}
+		for _, key := range sortedKeys(rule.Filter.Metadata) {
+			and.Metadata = append(and.Metadata, LifecycleMeta{Key: key, Value: rule.Filter.Metadata[key]})
+		}
+
+		conditions := len(and.Tags) + len(and.Metadata)
+		if and.Prefix != "" {
+			conditions++
+		}
+		switch {
+		case conditions > 1:
+			xmlRule.Filter.And = &and
+		case len(and.Tags) == 1:
+			xmlRule.Filter.Tag = &and.Tags[0]
+		case len(and.Metadata) == 1:
+			xmlRule.Filter.Metadata = &and.Metadata[0]
+		default:
+			xmlRule.Filter.Prefix = and.Prefix
+		}
+
+		response.Rules = append(response.Rules, xmlRule)
+	}
+	return response
+}
+
+// sortedKeys returns the keys of m in ascending order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
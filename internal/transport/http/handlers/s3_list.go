@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// ListBucketResultV2 is the ListObjectsV2 response
+type ListBucketResultV2 struct {
+	XMLName               xml.Name    `xml:"ListBucketResult"`
+	Name                  string      `xml:"Name"`
+	Prefix                string      `xml:"Prefix"`
+	Delimiter             string      `xml:"Delimiter,omitempty"`
+	StartAfter            string      `xml:"StartAfter,omitempty"`
+	ContinuationToken     string      `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string      `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int         `xml:"KeyCount"`
+	MaxKeys               int         `xml:"MaxKeys"`
+	EncodingType          string      `xml:"EncodingType,omitempty"`
+	IsTruncated           bool        `xml:"IsTruncated"`
+	Contents              []ObjectXML `xml:"Contents"`
+	CommonPrefixes        []PrefixXML `xml:"CommonPrefixes,omitempty"`
+}
+
+// ListObjectsV2 handles S3 list objects V2 request (GET /{bucket}?list-type=2)
+func (h *S3Handler) ListObjectsV2(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	resource := "/" + bucketName
+
+	encodingType, err := listEncodingType(c)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+
+	opts := storage.ListOptions{
+		Prefix:    c.Query("prefix"),
+		Delimiter: c.Query("delimiter"),
+		Marker:    c.Query("start-after"),
+		MaxKeys:   listMaxKeys(c),
+	}
+
+	// A continuation token resumes a previous listing and supersedes start-after
+	token, hasToken := c.GetQuery("continuation-token")
+	if hasToken {
+		marker, err := decodeContinuationToken(token)
+		if err != nil {
+			h.handleS3Error(c, err, resource)
+			return
+		}
+		opts.Marker = marker
+	}
+
+	result, err := h.container.StorageService.ListObjects(ctx, bucketName, opts)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		s3OperationsTotal.WithLabelValues("ListObjectsV2", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("ListObjectsV2", bucketName, "success").Inc()
+
+	encode := listKeyEncoder(encodingType)
+	response := ListBucketResultV2{
+		Name:              bucketName,
+		Prefix:            encode(opts.Prefix),
+		Delimiter:         encode(opts.Delimiter),
+		StartAfter:        encode(c.Query("start-after")),
+		ContinuationToken: token,
+		KeyCount:          len(result.Objects) + len(result.CommonPrefixes),
+		MaxKeys:           opts.MaxKeys,
+		EncodingType:      encodingType,
+		IsTruncated:       result.IsTruncated,
+		Contents:          listContents(result, encode, c.Query("fetch-owner") == "true"),
+		CommonPrefixes:    listCommonPrefixes(result, encode),
+	}
+	if result.IsTruncated {
+		response.NextContinuationToken = encodeContinuationToken(result.NextMarker)
+	}
+
+	c.XML(http.StatusOK, response)
+}
+
+// encodeContinuationToken wraps the last listed key or common prefix in an
+// opaque token so that clients do not depend on its contents
+func encodeContinuationToken(marker string) string {
+	return base64.URLEncoding.EncodeToString([]byte(marker))
+}
+
+// decodeContinuationToken recovers the marker from a continuation token
+func decodeContinuationToken(token string) (string, error) {
+	marker, err := base64.URLEncoding.DecodeString(token)
+	if err != nil || len(marker) == 0 {
+		return "", errors.New(errors.ErrCodeInvalidParameter, "The continuation token provided is incorrect").
+			WithContext("continuation-token", token)
+	}
+	return string(marker), nil
+}
+
+// listMaxKeys parses max-keys, falling back to the S3 default of 1000
+func listMaxKeys(c *gin.Context) int {
+	if keys, err := strconv.Atoi(c.Query("max-keys")); err == nil && keys > 0 {
+		return keys
+	}
+	return 1000
+}
+
+// listEncodingType validates encoding-type, the only supported value being url
+func listEncodingType(c *gin.Context) (string, error) {
+	encodingType := c.Query("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		return "", errors.New(errors.ErrCodeInvalidParameter, "Invalid Encoding Method specified in Request").
+			WithContext("encoding-type", encodingType)
+	}
+	return encodingType, nil
+}
+
+// listKeyEncoder returns the function applied to keys and prefixes in a
+// listing response. With encoding-type=url they are URL encoded so that keys
+// containing characters XML cannot carry survive the round trip.
+func listKeyEncoder(encodingType string) func(string) string {
+	if encodingType == "url" {
+		return url.QueryEscape
+	}
+	return func(s string) string { return s }
+}
+
+// listContents converts listed objects to their XML form
+func listContents(result *storage.ListResult, encode func(string) string, withOwner bool) []ObjectXML {
+	var contents []ObjectXML
+	for _, obj := range result.Objects {
+		object := ObjectXML{
+			Key:          encode(obj.Key),
+			LastModified: obj.LastModified,
+			ETag:         obj.ETag,
+			Size:         obj.Size,
+			StorageClass: "STANDARD",
+		}
+		if withOwner {
+			owner := defaultOwner
+			object.Owner = &owner
+		}
+		contents = append(contents, object)
+	}
+	return contents
+}
+
+// listCommonPrefixes converts the common prefixes of a listing to their XML form
+func listCommonPrefixes(result *storage.ListResult, encode func(string) string) []PrefixXML {
+	var prefixes []PrefixXML
+	for _, prefix := range result.CommonPrefixes {
+		prefixes = append(prefixes, PrefixXML{Prefix: encode(prefix)})
+	}
+	return prefixes
+}
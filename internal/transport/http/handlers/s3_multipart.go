@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/notifications"
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// XML structures for the S3 multipart upload API
+type InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type CompleteMultipartUpload struct {
+	XMLName xml.Name           `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPartXML `xml:"Part"`
+}
+
+type CompletedPartXML struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+type ListPartsResult struct {
+	XMLName              xml.Name  `xml:"ListPartsResult"`
+	Bucket               string    `xml:"Bucket"`
+	Key                  string    `xml:"Key"`
+	UploadID             string    `xml:"UploadId"`
+	Initiator            Owner     `xml:"Initiator"`
+	Owner                Owner     `xml:"Owner"`
+	StorageClass         string    `xml:"StorageClass"`
+	PartNumberMarker     int       `xml:"PartNumberMarker"`
+	NextPartNumberMarker int       `xml:"NextPartNumberMarker,omitempty"`
+	MaxParts             int       `xml:"MaxParts"`
+	IsTruncated          bool      `xml:"IsTruncated"`
+	Parts                []PartXML `xml:"Part"`
+}
+
+type PartXML struct {
+	PartNumber   int       `xml:"PartNumber"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	Size         int64     `xml:"Size"`
+}
+
+type ListMultipartUploadsResult struct {
+	XMLName            xml.Name    `xml:"ListMultipartUploadsResult"`
+	Bucket             string      `xml:"Bucket"`
+	KeyMarker          string      `xml:"KeyMarker"`
+	UploadIDMarker     string      `xml:"UploadIdMarker"`
+	NextKeyMarker      string      `xml:"NextKeyMarker,omitempty"`
+	NextUploadIDMarker string      `xml:"NextUploadIdMarker,omitempty"`
+	Prefix             string      `xml:"Prefix,omitempty"`
+	Delimiter          string      `xml:"Delimiter,omitempty"`
+	MaxUploads         int         `xml:"MaxUploads"`
+	IsTruncated        bool        `xml:"IsTruncated"`
+	Uploads            []UploadXML `xml:"Upload"`
+	CommonPrefixes     []PrefixXML `xml:"CommonPrefixes,omitempty"`
+}
+
+type UploadXML struct {
+	Key          string    `xml:"Key"`
+	UploadID     string    `xml:"UploadId"`
+	Initiator    Owner     `xml:"Initiator"`
+	Owner        Owner     `xml:"Owner"`
+	StorageClass string    `xml:"StorageClass"`
+	Initiated    time.Time `xml:"Initiated"`
+}
+
+// defaultOwner is reported as the owner and initiator of every upload
+var defaultOwner = Owner{ID: "8fs-owner", DisplayName: "8fs"}
+
+// CreateMultipartUpload handles S3 initiate multipart upload request (POST /{bucket}/{key}?uploads)
+func (h *S3Handler) CreateMultipartUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+
+	contentType := c.GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = "binary/octet-stream"
+	}
+
+	sse, err := serverSideEncryption(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+
+	upload, err := h.container.StorageService.CreateMultipartUpload(ctx, bucketName, objectKey, contentType, amzMetadata(c.Request.Header), sse)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		s3OperationsTotal.WithLabelValues("CreateMultipartUpload", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("CreateMultipartUpload", bucketName, "success").Inc()
+
+	setEncryptionHeaders(c, upload.Encryption)
+	c.XML(http.StatusOK, InitiateMultipartUploadResult{
+		Bucket:   upload.Bucket,
+		Key:      upload.Key,
+		UploadID: upload.UploadID,
+	})
+}
+
+// UploadPart handles S3 upload part request (PUT /{bucket}/{key}?partNumber=&uploadId=)
+func (h *S3Handler) UploadPart(c *gin.Context) {
+	if _, ok := c.Request.Header["X-Amz-Copy-Source"]; ok {
+		h.UploadPartCopy(c)
+		return
+	}
+
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+	resource := "/" + bucketName + "/" + objectKey
+
+	partNumber, err := strconv.Atoi(c.Query("partNumber"))
+	if err != nil {
+		h.handleS3Error(c, errors.New(errors.ErrCodeInvalidParameter, "Part number must be an integer between 1 and 10000, inclusive"), resource)
+		return
+	}
+
+	sse, err := customerKey(c.Request.Header, sseCustomerPrefix)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	contentMD5, checksums, err := checksumHeaders(c.Request.Header)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+
+	part, err := h.container.StorageService.UploadPart(ctx, bucketName, objectKey, c.Query("uploadId"), partNumber, requestBody(c.Request), storage.UploadPartOptions{
+		Encryption: sse,
+		ContentMD5: contentMD5,
+		Checksums:  checksums,
+	})
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		s3OperationsTotal.WithLabelValues("UploadPart", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("UploadPart", bucketName, "success").Inc()
+
+	setChecksumHeaders(c, part.Checksums)
+	c.Header("ETag", part.ETag)
+	c.Status(http.StatusOK)
+}
+
+// CompleteMultipartUpload handles S3 complete multipart upload request (POST /{bucket}/{key}?uploadId=)
+func (h *S3Handler) CompleteMultipartUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+	resource := "/" + bucketName + "/" + objectKey
+
+	var req CompleteMultipartUpload
+	if err := decodeXMLBody(c.Request, &req); err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+
+	parts := make([]storage.CompletedPart, 0, len(req.Parts))
+	for _, p := range req.Parts {
+		parts = append(parts, storage.CompletedPart{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+
+	object, err := h.container.StorageService.CompleteMultipartUpload(ctx, bucketName, objectKey, c.Query("uploadId"), parts)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		s3OperationsTotal.WithLabelValues("CompleteMultipartUpload", bucketName, "error").Inc()
+		return
+	}
+
+	// Index the assembled object the same way a single PUT would be
+	h.indexObject(ctx, object)
+	notifyObjectEvent(c, h.container.NotificationService, notifications.ObjectEvent{
+		Name: notifications.EventObjectCreatedCompleteMultipartUpload, Bucket: bucketName, Key: objectKey,
+		Size: object.Size, ETag: object.ETag, VersionID: object.VersionID,
+	})
+
+	s3OperationsTotal.WithLabelValues("CompleteMultipartUpload", bucketName, "success").Inc()
+
+	setVersionHeaders(c, object.VersionID, false)
+	setEncryptionHeaders(c, object.Encryption)
+	c.XML(http.StatusOK, CompleteMultipartUploadResult{
+		Location: "/" + bucketName + "/" + objectKey,
+		Bucket:   bucketName,
+		Key:      objectKey,
+		ETag:     object.ETag,
+	})
+}
+
+// AbortMultipartUpload handles S3 abort multipart upload request (DELETE /{bucket}/{key}?uploadId=)
+func (h *S3Handler) AbortMultipartUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+
+	if err := h.container.StorageService.AbortMultipartUpload(ctx, bucketName, objectKey, c.Query("uploadId")); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		s3OperationsTotal.WithLabelValues("AbortMultipartUpload", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("AbortMultipartUpload", bucketName, "success").Inc()
+	c.Status(http.StatusNoContent)
+}
+
+// ListParts handles S3 list parts request (GET /{bucket}/{key}?uploadId=)
+func (h *S3Handler) ListParts(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+
+	opts := storage.ListPartsOptions{
+		MaxParts: 1000, // Default
+	}
+	if marker, err := strconv.Atoi(c.Query("part-number-marker")); err == nil && marker > 0 {
+		opts.PartNumberMarker = marker
+	}
+	if maxParts, err := strconv.Atoi(c.Query("max-parts")); err == nil && maxParts > 0 {
+		opts.MaxParts = maxParts
+	}
+
+	result, err := h.container.StorageService.ListParts(ctx, bucketName, objectKey, c.Query("uploadId"), opts)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+
+	response := ListPartsResult{
+		Bucket:               bucketName,
+		Key:                  objectKey,
+		UploadID:             result.Upload.UploadID,
+		Initiator:            defaultOwner,
+		Owner:                defaultOwner,
+		StorageClass:         "STANDARD",
+		PartNumberMarker:     opts.PartNumberMarker,
+		NextPartNumberMarker: result.NextPartNumberMarker,
+		MaxParts:             opts.MaxParts,
+		IsTruncated:          result.IsTruncated,
+	}
+	for _, part := range result.Parts {
+		response.Parts = append(response.Parts, PartXML{
+			PartNumber:   part.PartNumber,
+			LastModified: part.LastModified,
+			ETag:         part.ETag,
+			Size:         part.Size,
+		})
+	}
+
+	c.XML(http.StatusOK, response)
+}
+
+// ListMultipartUploads handles S3 list multipart uploads request (GET /{bucket}?uploads)
+func (h *S3Handler) ListMultipartUploads(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	opts := storage.ListUploadsOptions{
+		Prefix:         c.Query("prefix"),
+		Delimiter:      c.Query("delimiter"),
+		KeyMarker:      c.Query("key-marker"),
+		UploadIDMarker: c.Query("upload-id-marker"),
+		MaxUploads:     1000, // Default
+	}
+	if maxUploads, err := strconv.Atoi(c.Query("max-uploads")); err == nil && maxUploads > 0 {
+		opts.MaxUploads = maxUploads
+	}
+
+	result, err := h.container.StorageService.ListMultipartUploads(ctx, bucketName, opts)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	response := ListMultipartUploadsResult{
+		Bucket:             bucketName,
+		KeyMarker:          opts.KeyMarker,
+		UploadIDMarker:     opts.UploadIDMarker,
+		NextKeyMarker:      result.NextKeyMarker,
+		NextUploadIDMarker: result.NextUploadIDMarker,
+		Prefix:             opts.Prefix,
+		Delimiter:          opts.Delimiter,
+		MaxUploads:         opts.MaxUploads,
+		IsTruncated:        result.IsTruncated,
+	}
+	for _, upload := range result.Uploads {
+		response.Uploads = append(response.Uploads, UploadXML{
+			Key:          upload.Key,
+			UploadID:     upload.UploadID,
+			Initiator:    defaultOwner,
+			Owner:        defaultOwner,
+			StorageClass: "STANDARD",
+			Initiated:    upload.Initiated,
+		})
+	}
+	for _, prefix := range result.CommonPrefixes {
+		response.CommonPrefixes = append(response.CommonPrefixes, PrefixXML{Prefix: prefix})
+	}
+
+	c.XML(http.StatusOK, response)
+}
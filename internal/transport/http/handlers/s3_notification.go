@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/notifications"
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// XML structures for the S3 bucket notification API. Events are delivered
+// to webhooks, which queue configurations refer to by ARN.
+type NotificationConfiguration struct {
+	XMLName                     xml.Name             `xml:"NotificationConfiguration"`
+	QueueConfigurations         []QueueConfiguration `xml:"QueueConfiguration"`
+	TopicConfigurations         []struct{}           `xml:"TopicConfiguration"`
+	CloudFunctionConfigurations []struct{}           `xml:"CloudFunctionConfiguration"`
+}
+
+type QueueConfiguration struct {
+	ID     string              `xml:"Id,omitempty"`
+	Queue  string              `xml:"Queue"`
+	Events []string            `xml:"Event"`
+	Filter *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+type NotificationFilter struct {
+	S3Key S3KeyFilter `xml:"S3Key"`
+}
+
+type S3KeyFilter struct {
+	FilterRules []FilterRule `xml:"FilterRule"`
+}
+
+type FilterRule struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// PutBucketNotificationConfiguration handles S3 put bucket notification configuration request (PUT /{bucket}?notification)
+func (h *S3Handler) PutBucketNotificationConfiguration(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	var request NotificationConfiguration
+	if err := decodeXMLBody(c.Request, &request); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+	if len(request.TopicConfigurations) > 0 || len(request.CloudFunctionConfigurations) > 0 {
+		h.handleS3Error(c, errors.New(errors.ErrCodeInvalidParameter, "Only queue configurations with webhook destinations are supported"), "/"+bucketName)
+		return
+	}
+
+	config := &notifications.Configuration{Rules: make([]notifications.Rule, 0, len(request.QueueConfigurations))}
+	for _, queue := range request.QueueConfigurations {
+		rule := notifications.Rule{ID: queue.ID, Target: queue.Queue, Events: queue.Events}
+		if queue.Filter != nil {
+			if err := keyFilter(queue.Filter.S3Key.FilterRules, &rule); err != nil {
+				h.handleS3Error(c, err, "/"+bucketName)
+				return
+			}
+		}
+		config.Rules = append(config.Rules, rule)
+	}
+
+	if err := h.container.NotificationService.PutBucketNotification(ctx, bucketName, config); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		s3OperationsTotal.WithLabelValues("PutBucketNotificationConfiguration", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("PutBucketNotificationConfiguration", bucketName, "success").Inc()
+	c.Status(http.StatusOK)
+}
+
+// GetBucketNotificationConfiguration handles S3 get bucket notification configuration request (GET /{bucket}?notification)
+func (h *S3Handler) GetBucketNotificationConfiguration(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	config, err := h.container.NotificationService.GetBucketNotification(ctx, bucketName)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	response := NotificationConfiguration{}
+	for _, rule := range config.Rules {
+		queue := QueueConfiguration{ID: rule.ID, Queue: rule.Target, Events: rule.Events}
+		var filterRules []FilterRule
+		if rule.Prefix != "" {
+			filterRules = append(filterRules, FilterRule{Name: "Prefix", Value: rule.Prefix})
+		}
+		if rule.Suffix != "" {
+			filterRules = append(filterRules, FilterRule{Name: "Suffix", Value: rule.Suffix})
+		}
+		if filterRules != nil {
+			queue.Filter = &NotificationFilter{S3Key: S3KeyFilter{FilterRules: filterRules}}
+		}
+		response.QueueConfigurations = append(response.QueueConfigurations, queue)
+	}
+	c.XML(http.StatusOK, response)
+}
+
+// keyFilter sets the prefix and suffix of a rule from its key filter rules
+func keyFilter(filterRules []FilterRule, rule *notifications.Rule) error {
+	seen := make(map[string]bool, len(filterRules))
+	for _, filterRule := range filterRules {
+		name := strings.ToLower(filterRule.Name)
+		if seen[name] {
+			return errors.New(errors.ErrCodeInvalidParameter, "Cannot specify more than one prefix or suffix rule in a filter").
+				WithContext("name", filterRule.Name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "prefix":
+			rule.Prefix = filterRule.Value
+		case "suffix":
+			rule.Suffix = filterRule.Value
+		default:
+			return errors.New(errors.ErrCodeInvalidParameter, "filter rule name must be either prefix or suffix").
+				WithContext("name", filterRule.Name)
+		}
+	}
+	return nil
+}
+
+// notifyObjectEvent sends the bucket notifications of a change to an object
+// made by a request
+func notifyObjectEvent(c *gin.Context, service notifications.Service, event notifications.ObjectEvent) {
+	if credential := requestCredential(c); credential != nil {
+		event.Principal = credential.AccessKey
+	}
+	event.SourceIP = c.ClientIP()
+	event.RequestID = c.GetHeader("X-Request-ID")
+	if event.RequestID == "" {
+		event.RequestID = c.Writer.Header().Get("X-Request-ID")
+	}
+	event.Time = time.Now()
+	service.Notify(c.Request.Context(), event)
+}
+
+// removedEvent returns the event type of a delete that removed versionID, or
+// created a delete marker if deleteMarker is set and no version was named
+func removedEvent(versionID string, deleteMarker bool) string {
+	if versionID == "" && deleteMarker {
+		return notifications.EventObjectRemovedDeleteMarkerCreated
+	}
+	return notifications.EventObjectRemovedDelete
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/8fs-io/core/internal/domain/policy"
+	"github.com/gin-gonic/gin"
+)
+
+// PutBucketPolicy handles S3 put bucket policy request (PUT /{bucket}?policy)
+func (h *S3Handler) PutBucketPolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	document, err := readRequestBody(c.Request, policy.MaxSize+1)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	if err := h.container.StorageService.PutBucketPolicy(ctx, bucketName, string(document)); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		s3OperationsTotal.WithLabelValues("PutBucketPolicy", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("PutBucketPolicy", bucketName, "success").Inc()
+	c.Status(http.StatusNoContent)
+}
+
+// GetBucketPolicy handles S3 get bucket policy request (GET /{bucket}?policy)
+func (h *S3Handler) GetBucketPolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	document, err := h.container.StorageService.GetBucketPolicy(ctx, bucketName)
+	if err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", []byte(document))
+}
+
+// DeleteBucketPolicy handles S3 delete bucket policy request (DELETE /{bucket}?policy)
+func (h *S3Handler) DeleteBucketPolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+
+	if err := h.container.StorageService.DeleteBucketPolicy(ctx, bucketName); err != nil {
+		h.handleS3Error(c, err, "/"+bucketName)
+		s3OperationsTotal.WithLabelValues("DeleteBucketPolicy", bucketName, "error").Inc()
+		return
+	}
+
+	s3OperationsTotal.WithLabelValues("DeleteBucketPolicy", bucketName, "success").Inc()
+	c.Status(http.StatusNoContent)
+}
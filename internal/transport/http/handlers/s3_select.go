@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/xml"
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/eventstream"
+	"github.com/8fs-io/core/pkg/s3select"
+	"github.com/gin-gonic/gin"
+)
+
+// XML structures for the S3 Select API
+type SelectObjectContentRequest struct {
+	XMLName             xml.Name                  `xml:"SelectObjectContentRequest"`
+	Expression          string                    `xml:"Expression"`
+	ExpressionType      string                    `xml:"ExpressionType"`
+	RequestProgress     *SelectRequestProgress    `xml:"RequestProgress"`
+	InputSerialization  SelectInputSerialization  `xml:"InputSerialization"`
+	OutputSerialization SelectOutputSerialization `xml:"OutputSerialization"`
+	ScanRange           *struct{}                 `xml:"ScanRange"`
+}
+
+type SelectRequestProgress struct {
+	Enabled bool `xml:"Enabled"`
+}
+
+type SelectInputSerialization struct {
+	CompressionType string          `xml:"CompressionType"`
+	CSV             *SelectCSVInput `xml:"CSV"`
+	JSON            *SelectJSON     `xml:"JSON"`
+	Parquet         *struct{}       `xml:"Parquet"`
+}
+
+type SelectCSVInput struct {
+	FileHeaderInfo             string `xml:"FileHeaderInfo"`
+	Comments                   string `xml:"Comments"`
+	QuoteEscapeCharacter       string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter            string `xml:"RecordDelimiter"`
+	FieldDelimiter             string `xml:"FieldDelimiter"`
+	QuoteCharacter             string `xml:"QuoteCharacter"`
+	AllowQuotedRecordDelimiter bool   `xml:"AllowQuotedRecordDelimiter"`
+}
+
+type SelectOutputSerialization struct {
+	CSV  *SelectCSVOutput `xml:"CSV"`
+	JSON *SelectJSON      `xml:"JSON"`
+}
+
+type SelectCSVOutput struct {
+	QuoteFields          string `xml:"QuoteFields"`
+	QuoteEscapeCharacter string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter      string `xml:"RecordDelimiter"`
+	FieldDelimiter       string `xml:"FieldDelimiter"`
+	QuoteCharacter       string `xml:"QuoteCharacter"`
+}
+
+// SelectJSON describes JSON input (Type) and output (RecordDelimiter)
+type SelectJSON struct {
+	Type            string `xml:"Type"`
+	RecordDelimiter string `xml:"RecordDelimiter"`
+}
+
+// SelectStats is the payload of the Stats and Progress events, whose root
+// element is named after the event
+type SelectStats struct {
+	XMLName        xml.Name
+	BytesScanned   int64 `xml:"BytesScanned"`
+	BytesProcessed int64 `xml:"BytesProcessed"`
+	BytesReturned  int64 `xml:"BytesReturned"`
+}
+
+// SelectObjectContent handles S3 select object content request (POST /{bucket}/{key}?select&select-type=2).
+// Matching records are streamed as event stream messages, so errors found
+// while reading the object are sent as an error message after a 200 status.
+func (h *S3Handler) SelectObjectContent(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+	resource := "/" + bucketName + "/" + objectKey
+
+	if c.Query("select-type") != "2" {
+		h.handleS3Error(c, errors.New(errors.ErrCodeInvalidParameter, "The select-type must be 2"), resource)
+		return
+	}
+
+	var request SelectObjectContentRequest
+	if err := decodeXMLBody(c.Request, &request); err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	if request.ExpressionType != "SQL" {
+		h.handleS3Error(c, selectError("InvalidExpressionType", "The ExpressionType must be SQL"), resource)
+		return
+	}
+	if request.InputSerialization.Parquet != nil {
+		h.handleS3Error(c, errors.New(errors.ErrCodeNotImplemented, "Parquet input is not supported"), resource)
+		return
+	}
+	if request.ScanRange != nil {
+		h.handleS3Error(c, errors.New(errors.ErrCodeNotImplemented, "Scan ranges are not supported"), resource)
+		return
+	}
+
+	query, err := s3select.New(selectOptions(&request))
+	if err != nil {
+		h.handleS3Error(c, selectAppError(err), resource)
+		s3OperationsTotal.WithLabelValues("SelectObjectContent", bucketName, "error").Inc()
+		return
+	}
+
+	sse, err := customerKey(c.Request.Header, sseCustomerPrefix)
+	if err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+	object, err := h.container.StorageService.GetObjectVersion(ctx, bucketName, objectKey, "", sse)
+	if err != nil {
+		setDeleteMarkerError(c, err)
+		h.handleS3Error(c, err, resource)
+		s3OperationsTotal.WithLabelValues("SelectObjectContent", bucketName, "error").Inc()
+		return
+	}
+	defer object.Body.Close()
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Status(http.StatusOK)
+	encoder := eventstream.NewEncoder(c.Writer)
+	send := func(m eventstream.Message) error {
+		if err := encoder.Encode(m); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	stats, err := query.Run(object.Body, func(records []byte) error {
+		return send(selectEvent("Records", "application/octet-stream", records))
+	})
+	if err != nil {
+		var selectErr *s3select.Error
+		if !stderrors.As(err, &selectErr) {
+			h.container.Logger.Error("S3 Select failed", "bucket", bucketName, "key", objectKey, "error", err)
+			selectErr = &s3select.Error{Code: "InternalError", Message: "We encountered an internal error. Please try again."}
+		}
+		s3OperationsTotal.WithLabelValues("SelectObjectContent", bucketName, "error").Inc()
+		send(eventstream.Message{Headers: []eventstream.Header{
+			{Name: ":error-code", Value: selectErr.Code},
+			{Name: ":error-message", Value: selectErr.Message},
+			{Name: ":message-type", Value: "error"},
+		}})
+		return
+	}
+
+	payload := SelectStats{BytesScanned: stats.BytesScanned, BytesProcessed: stats.BytesProcessed, BytesReturned: stats.BytesReturned}
+	if request.RequestProgress != nil && request.RequestProgress.Enabled {
+		if err := send(statsEvent("Progress", payload)); err != nil {
+			return
+		}
+	}
+	if err := send(statsEvent("Stats", payload)); err != nil {
+		return
+	}
+	send(eventstream.Message{Headers: []eventstream.Header{
+		{Name: ":event-type", Value: "End"},
+		{Name: ":message-type", Value: "event"},
+	}})
+	s3OperationsTotal.WithLabelValues("SelectObjectContent", bucketName, "success").Inc()
+}
+
+// selectOptions converts a request to the options of a query
+func selectOptions(request *SelectObjectContentRequest) s3select.Options {
+	opts := s3select.Options{
+		Expression: request.Expression,
+		Input:      s3select.InputSerialization{CompressionType: request.InputSerialization.CompressionType},
+	}
+	if in := request.InputSerialization.CSV; in != nil {
+		opts.Input.CSV = &s3select.CSVInput{
+			FileHeaderInfo:       in.FileHeaderInfo,
+			Comments:             in.Comments,
+			QuoteEscapeCharacter: in.QuoteEscapeCharacter,
+			RecordDelimiter:      in.RecordDelimiter,
+			FieldDelimiter:       in.FieldDelimiter,
+			QuoteCharacter:       in.QuoteCharacter,
+		}
+	}
+	if in := request.InputSerialization.JSON; in != nil {
+		opts.Input.JSON = &s3select.JSONInput{Type: in.Type}
+	}
+	if out := request.OutputSerialization.CSV; out != nil {
+		opts.Output.CSV = &s3select.CSVOutput{
+			QuoteFields:          out.QuoteFields,
+			QuoteEscapeCharacter: out.QuoteEscapeCharacter,
+			RecordDelimiter:      out.RecordDelimiter,
+			FieldDelimiter:       out.FieldDelimiter,
+			QuoteCharacter:       out.QuoteCharacter,
+		}
+	}
+	if out := request.OutputSerialization.JSON; out != nil {
+		opts.Output.JSON = &s3select.JSONOutput{RecordDelimiter: out.RecordDelimiter}
+	}
+	return opts
+}
+
+// selectError creates a client error reported with an S3 Select error code
+func selectError(code, message string) *errors.AppError {
+	return &errors.AppError{Code: errors.ErrorCode(code), Message: message, HTTPStatus: http.StatusBadRequest}
+}
+
+// selectAppError converts a query error to an application error
+func selectAppError(err error) error {
+	var selectErr *s3select.Error
+	if stderrors.As(err, &selectErr) {
+		return selectError(selectErr.Code, selectErr.Message)
+	}
+	return err
+}
+
+func selectEvent(eventType, contentType string, payload []byte) eventstream.Message {
+	return eventstream.Message{
+		Headers: []eventstream.Header{
+			{Name: ":event-type", Value: eventType},
+			{Name: ":content-type", Value: contentType},
+			{Name: ":message-type", Value: "event"},
+		},
+		Payload: payload,
+	}
+}
+
+// statsEvent creates a Stats or Progress event
+func statsEvent(eventType string, stats SelectStats) eventstream.Message {
+	stats.XMLName = xml.Name{Local: eventType}
+	payload, _ := xml.Marshal(stats)
+	return selectEvent(eventType, "text/xml", payload)
+}
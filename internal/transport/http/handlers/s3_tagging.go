@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/8fs-io/core/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// XML structures for the S3 object tagging API
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  TagSet   `xml:"TagSet"`
+}
+
+type TagSet struct {
+	Tags []Tag `xml:"Tag"`
+}
+
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// PutObjectTagging handles S3 put object tagging request (PUT /{bucket}/{key}?tagging)
+func (h *S3Handler) PutObjectTagging(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+	resource := "/" + bucketName + "/" + objectKey
+
+	var tagging Tagging
+	if err := decodeXMLBody(c.Request, &tagging); err != nil {
+		h.handleS3Error(c, err, resource)
+		return
+	}
+
+	tags := make(map[string]string, len(tagging.TagSet.Tags))
+	for _, tag := range tagging.TagSet.Tags {
+		if _, ok := tags[tag.Key]; ok {
+			h.handleS3Error(c, errors.New(errors.ErrCodeInvalidTag, "Cannot provide multiple Tags with the same key"), resource)
+			return
+		}
+		tags[tag.Key] = tag.Value
+	}
+
+	info, err := h.container.StorageService.PutObjectTagging(ctx, bucketName, objectKey, c.Query("versionId"), tags)
+	if err != nil {
+		setDeleteMarkerError(c, err)
+		h.handleS3Error(c, err, resource)
+		s3OperationsTotal.WithLabelValues("PutObjectTagging", bucketName, "error").Inc()
+		return
+	}
+
+	h.reindexObject(ctx, bucketName, objectKey, info.VersionID)
+
+	s3OperationsTotal.WithLabelValues("PutObjectTagging", bucketName, "success").Inc()
+	setVersionHeaders(c, info.VersionID, false)
+	c.Status(http.StatusOK)
+}
+
+// GetObjectTagging handles S3 get object tagging request (GET /{bucket}/{key}?tagging)
+func (h *S3Handler) GetObjectTagging(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+
+	info, err := h.container.StorageService.GetObjectVersionInfo(ctx, bucketName, objectKey, c.Query("versionId"))
+	if err != nil {
+		setDeleteMarkerError(c, err)
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		return
+	}
+
+	response := Tagging{}
+	for _, key := range sortedKeys(info.Tags) {
+		response.TagSet.Tags = append(response.TagSet.Tags, Tag{Key: key, Value: info.Tags[key]})
+	}
+
+	setVersionHeaders(c, info.VersionID, false)
+	c.XML(http.StatusOK, response)
+}
+
+// DeleteObjectTagging handles S3 delete object tagging request (DELETE /{bucket}/{key}?tagging)
+func (h *S3Handler) DeleteObjectTagging(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucketName := c.Param("bucket")
+	objectKey := strings.TrimPrefix(c.Param("key"), "/")
+
+	info, err := h.container.StorageService.DeleteObjectTagging(ctx, bucketName, objectKey, c.Query("versionId"))
+	if err != nil {
+		setDeleteMarkerError(c, err)
+		h.handleS3Error(c, err, "/"+bucketName+"/"+objectKey)
+		s3OperationsTotal.WithLabelValues("DeleteObjectTagging", bucketName, "error").Inc()
+		return
+	}
+
+	h.reindexObject(ctx, bucketName, objectKey, info.VersionID)
+
+	s3OperationsTotal.WithLabelValues("DeleteObjectTagging", bucketName, "success").Inc()
+	setVersionHeaders(c, info.VersionID, false)
+	c.Status(http.StatusNoContent)
+}
+
+// reindexObject refreshes the vector metadata of an object after its tags
+// changed, provided the version that changed is the current one
+func (h *S3Handler) reindexObject(ctx context.Context, bucketName, objectKey, versionID string) {
+	if h.container.AIService == nil {
+		return
+	}
+
+	info, err := h.container.StorageService.GetObjectInfo(ctx, bucketName, objectKey)
+	if err != nil || info.VersionID != versionID {
+		return
+	}
+	h.indexObject(ctx, objectFromInfo(bucketName, objectKey, info))
+}
+
+// amzTagging parses the URL-encoded tag set of an x-amz-tagging header
+func amzTagging(header http.Header) (map[string]string, error) {
+	value := header.Get("X-Amz-Tagging")
+	if value == "" {
+		return nil, nil
+	}
+
+	invalid := errors.New(errors.ErrCodeInvalidTag, "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+	query, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, invalid
+	}
+
+	tags := make(map[string]string, len(query))
+	for key, values := range query {
+		if len(values) > 1 {
+			return nil, invalid
+		}
+		tags[key] = values[0]
+	}
+	return tags, nil
+}
@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/8fs-io/core/internal/container"
+	"github.com/8fs-io/core/internal/domain/notifications"
 	"github.com/8fs-io/core/internal/domain/storage"
 	"github.com/8fs-io/core/pkg/errors"
+	"github.com/8fs-io/core/pkg/sigv4"
 	"github.com/gin-gonic/gin"
 )
 
@@ -92,12 +97,6 @@ func (h *StorageHandler) PutObject(c *gin.Context) {
 	bucketName := c.Param("bucket")
 	objectKey := c.Param("key")[1:] // Remove leading slash
 
-	data, err := c.GetRawData()
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
-		return
-	}
-
 	contentType := c.GetHeader("Content-Type")
 	if contentType == "" {
 		contentType = "application/octet-stream"
@@ -113,11 +112,27 @@ func (h *StorageHandler) PutObject(c *gin.Context) {
 		}
 	}
 
-	object, err := h.container.StorageService.PutObject(ctx, bucketName, objectKey, data, contentType, metadata)
+	conditions, err := writeConditions(c.Request.Header)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	opts := storage.PutObjectOptions{
+		ContentType: contentType,
+		Metadata:    metadata,
+		Conditions:  conditions,
+	}
+
+	object, err := h.container.StorageService.PutObject(ctx, bucketName, objectKey, requestBody(c.Request), opts)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
+	notifyObjectEvent(c, h.container.NotificationService, notifications.ObjectEvent{
+		Name: notifications.EventObjectCreatedPut, Bucket: bucketName, Key: objectKey,
+		Size: object.Size, ETag: object.ETag, VersionID: object.VersionID,
+	})
 
 	c.Header("ETag", object.ETag)
 	c.JSON(http.StatusOK, gin.H{
@@ -128,7 +143,7 @@ func (h *StorageHandler) PutObject(c *gin.Context) {
 	})
 }
 
-// GetObject retrieves an object
+// GetObject retrieves an object, honoring Range headers and partNumber reads
 func (h *StorageHandler) GetObject(c *gin.Context) {
 	ctx := c.Request.Context()
 	bucketName := c.Param("bucket")
@@ -139,18 +154,30 @@ func (h *StorageHandler) GetObject(c *gin.Context) {
 		h.handleError(c, err)
 		return
 	}
+	defer object.Body.Close()
 
-	c.Header("Content-Type", object.ContentType)
-	c.Header("Content-Length", strconv.FormatInt(object.Size, 10))
 	c.Header("ETag", object.ETag)
 	c.Header("Last-Modified", object.LastModified.Format(http.TimeFormat))
 
+	if err := readConditions(c.Request.Header).CheckRead(object.ETag, object.LastModified); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	byteRange, err := objectRange(c, object.Size, object.PartSizes)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
 	// Set metadata headers
 	for key, value := range object.Metadata {
 		c.Header("X-Amz-Meta-"+key, value)
 	}
 
-	c.Data(http.StatusOK, object.ContentType, object.Data)
+	if err := serveObject(c, object, byteRange); err != nil {
+		h.handleError(c, err)
+	}
 }
 
 // HeadObject retrieves object metadata only
@@ -165,17 +192,28 @@ func (h *StorageHandler) HeadObject(c *gin.Context) {
 		return
 	}
 
-	c.Header("Content-Type", objectInfo.ContentType)
-	c.Header("Content-Length", strconv.FormatInt(objectInfo.Size, 10))
 	c.Header("ETag", objectInfo.ETag)
 	c.Header("Last-Modified", objectInfo.LastModified.Format(http.TimeFormat))
 
+	if err := readConditions(c.Request.Header).CheckRead(objectInfo.ETag, objectInfo.LastModified); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	byteRange, err := objectRange(c, objectInfo.Size, objectInfo.PartSizes)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", objectInfo.ContentType)
+
 	// Set metadata headers
 	for key, value := range objectInfo.Metadata {
 		c.Header("X-Amz-Meta-"+key, value)
 	}
 
-	c.Status(http.StatusOK)
+	c.Status(writeRangeHeaders(c, byteRange, objectInfo.Size, objectInfo.PartSizes))
 }
 
 // DeleteObject deletes an object
@@ -189,6 +227,9 @@ func (h *StorageHandler) DeleteObject(c *gin.Context) {
 		h.handleError(c, err)
 		return
 	}
+	notifyObjectEvent(c, h.container.NotificationService, notifications.ObjectEvent{
+		Name: notifications.EventObjectRemovedDelete, Bucket: bucketName, Key: objectKey,
+	})
 
 	c.JSON(http.StatusNoContent, nil)
 }
@@ -220,6 +261,180 @@ func (h *StorageHandler) ListObjects(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// maxChangeWait caps how long a change feed request waits for a change,
+// keeping long polls within the server's write timeout
+const maxChangeWait = 20 * time.Second
+
+// ListChanges returns the changes to objects after a cursor, in the order
+// they were made. With wait set, a request that finds no changes is held
+// open for up to that many seconds until one arrives.
+func (h *StorageHandler) ListChanges(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// The feed has no bucket route parameter, so the bucket policy is not
+	// applied by the storage middleware and is evaluated here instead
+	opts := storage.ChangeFeedOptions{Bucket: c.Query("bucket")}
+	if err := authorizeBucketScope(c, opts.Bucket, "s3:ListBucket"); err != nil {
+		h.handleError(c, err)
+		return
+	}
+	policies := NewPolicyHandler(h.container)
+	if opts.Bucket != "" {
+		if err := policies.authorize(c, opts.Bucket, "", "s3:ListBucket", nil); err != nil {
+			h.handleError(c, err)
+			return
+		}
+	}
+
+	if since := c.Query("since"); since != "" {
+		cursor, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || cursor < 0 {
+			h.handleError(c, errors.New(errors.ErrCodeInvalidParameter, "since must be a cursor returned by an earlier request").
+				WithContext("since", since))
+			return
+		}
+		opts.Since = cursor
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			h.handleError(c, errors.New(errors.ErrCodeInvalidParameter, "limit must be a positive number").
+				WithContext("limit", limit))
+			return
+		}
+		opts.Limit = n
+	}
+	if wait := c.Query("wait"); wait != "" {
+		seconds, err := strconv.Atoi(wait)
+		if err != nil || seconds < 0 {
+			h.handleError(c, errors.New(errors.ErrCodeInvalidParameter, "wait must be a number of seconds").
+				WithContext("wait", wait))
+			return
+		}
+		opts.Wait = time.Duration(seconds) * time.Second
+		if opts.Wait > maxChangeWait {
+			opts.Wait = maxChangeWait
+		}
+	}
+
+	// Across all buckets, the changes to buckets whose policy denies the
+	// caller are left out; the cursor still moves past them
+	if opts.Bucket == "" {
+		opts.Allow = func(bucket string) (bool, error) {
+			err := policies.authorize(c, bucket, "", "s3:ListBucket", nil)
+			var appErr *errors.AppError
+			if err != nil && (!errors.As(err, &appErr) || appErr.Code != errors.ErrCodeAccessDenied) {
+				return false, err
+			}
+			return err == nil, nil
+		}
+	}
+
+	feed, err := h.container.StorageService.ListChanges(ctx, opts)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changes":  feed.Changes,
+		"count":    len(feed.Changes),
+		"cursor":   strconv.FormatInt(feed.Cursor, 10),
+		"has_more": feed.HasMore,
+	})
+}
+
+// defaultPresignExpiry is used when a presign request does not set expires_in
+const defaultPresignExpiry = time.Hour
+
+// PresignURL mints a presigned S3 URL for an object
+func (h *StorageHandler) PresignURL(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req struct {
+		Bucket    string `json:"bucket" binding:"required"`
+		Key       string `json:"key" binding:"required"`
+		Method    string `json:"method"`
+		ExpiresIn int64  `json:"expires_in,omitempty"` // seconds
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPut && method != http.MethodHead {
+		h.handleError(c, errors.New(errors.ErrCodeInvalidParameter, "method must be GET, PUT or HEAD").
+			WithContext("method", req.Method))
+		return
+	}
+
+	expires := defaultPresignExpiry
+	if req.ExpiresIn != 0 {
+		expires = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expires <= 0 || expires > sigv4.MaxPresignExpiry {
+		h.handleError(c, errors.New(errors.ErrCodeInvalidParameter, "expires_in must be between 1 and 604800 seconds").
+			WithContext("expires_in", req.ExpiresIn))
+		return
+	}
+
+	if err := h.container.Validator.ValidateObjectKey(req.Key); err != nil {
+		h.handleError(c, err)
+		return
+	}
+	if _, err := h.container.StorageService.GetBucket(ctx, req.Bucket); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// The URL is signed with the caller's own access key, so the caller must
+	// be authenticated and allowed the presigned operation
+	credential := requestCredential(c)
+	if credential == nil {
+		h.handleError(c, errors.New(errors.ErrCodeAuthenticationRequired, "Presigning requires an authenticated caller"))
+		return
+	}
+	action := "s3:GetObject"
+	if method == http.MethodPut {
+		action = "s3:PutObject"
+	}
+	if err := NewPolicyHandler(h.container).authorize(c, req.Bucket, req.Key, action, nil); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// Token credentials carry no secret, so the key is looked up again; it
+	// may have been disabled since the request was authenticated
+	key, appErr := NewAuthHandler(h.container).validateCredential(ctx, credential.AccessKey)
+	if appErr != nil {
+		h.handleError(c, appErr)
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	region := h.container.Config.Storage.S3Config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	now := time.Now()
+	target := &url.URL{Scheme: scheme, Host: c.Request.Host, Path: "/" + req.Bucket + "/" + req.Key}
+	signed := sigv4.Presign(method, target, key.AccessKey, key.SecretKey, region, now, expires)
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        signed.String(),
+		"method":     method,
+		"expires_at": now.Add(expires).UTC(),
+	})
+}
+
 // handleError converts domain errors to appropriate HTTP responses
 func (h *StorageHandler) handleError(c *gin.Context, err error) {
 	var appErr *errors.AppError
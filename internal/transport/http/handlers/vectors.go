@@ -44,14 +44,16 @@ type StoreEmbeddingRequest struct {
 
 // SearchEmbeddingsRequest represents the request payload for searching embeddings
 type SearchEmbeddingsRequest struct {
-	Query []float64 `json:"query" binding:"required"`
-	TopK  int       `json:"top_k,omitempty"`
+	Query  []float64         `json:"query" binding:"required"`
+	TopK   int               `json:"top_k,omitempty"`
+	Filter map[string]string `json:"filter,omitempty"` // exact match on metadata, e.g. {"tag_dataset": "train"}
 }
 
 // SearchTextRequest represents the request payload for text-based searching
 type SearchTextRequest struct {
-	Query string `json:"query" binding:"required"`
-	TopK  int    `json:"top_k,omitempty"`
+	Query  string            `json:"query" binding:"required"`
+	TopK   int               `json:"top_k,omitempty"`
+	Filter map[string]string `json:"filter,omitempty"` // exact match on metadata, e.g. {"tag_dataset": "train"}
 }
 
 // StoreEmbedding handles POST /vectors/embeddings
@@ -79,6 +81,14 @@ func (h *VectorHandler) StoreEmbedding(c *gin.Context) {
 		return
 	}
 
+	// Embeddings of bucket-scoped callers must belong to one of their buckets
+	bucket, _ := req.Metadata["bucket"].(string)
+	if err := authorizeBucketScope(c, bucket, "s3:PutObject"); err != nil {
+		status = STATUS_ERROR
+		NewStorageHandler(h.container).handleError(c, err)
+		return
+	}
+
 	// Validate vector dimensions
 	vm := vectors.NewVectorMath()
 	if err := vm.ValidateDimensions(req.Embedding); err != nil {
@@ -156,6 +166,12 @@ func (h *VectorHandler) SearchEmbeddings(c *gin.Context) {
 		return
 	}
 
+	if err := authorizeBucketScope(c, req.Filter["bucket"], "s3:GetObject"); err != nil {
+		status = STATUS_ERROR
+		NewStorageHandler(h.container).handleError(c, err)
+		return
+	}
+
 	// Set default top_k if not provided
 	if req.TopK <= 0 {
 		req.TopK = 10
@@ -173,10 +189,10 @@ func (h *VectorHandler) SearchEmbeddings(c *gin.Context) {
 	}
 
 	// Perform the search
-	results, err := h.storage.Search(req.Query, req.TopK)
+	results, err := h.storage.SearchWithFilter(req.Query, req.TopK, req.Filter)
 	if err != nil {
 		status = STATUS_ERROR
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(searchErrorStatus(err), gin.H{
 			"error":   "Search failed",
 			"details": err.Error(),
 		})
@@ -210,6 +226,12 @@ func (h *VectorHandler) SearchText(c *gin.Context) {
 		return
 	}
 
+	if err := authorizeBucketScope(c, req.Filter["bucket"], "s3:GetObject"); err != nil {
+		status = STATUS_ERROR
+		NewStorageHandler(h.container).handleError(c, err)
+		return
+	}
+
 	// Set default top_k if not provided
 	if req.TopK <= 0 {
 		req.TopK = 10
@@ -237,10 +259,10 @@ func (h *VectorHandler) SearchText(c *gin.Context) {
 	}
 
 	// Perform the search with generated embedding
-	results, err := h.storage.Search(queryEmbedding, req.TopK)
+	results, err := h.storage.SearchWithFilter(queryEmbedding, req.TopK, req.Filter)
 	if err != nil {
 		status = STATUS_ERROR
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(searchErrorStatus(err), gin.H{
 			"error":   "Search failed",
 			"details": err.Error(),
 		})
@@ -256,6 +278,15 @@ func (h *VectorHandler) SearchText(c *gin.Context) {
 	})
 }
 
+// searchErrorStatus maps a failed search to the client's fault when the
+// metadata filter was invalid
+func searchErrorStatus(err error) int {
+	if errors.Is(err, vectors.ErrInvalidFilter) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 // GetEmbedding handles GET /vectors/embeddings/:id
 func (h *VectorHandler) GetEmbedding(c *gin.Context) {
 	id := c.Param("id")
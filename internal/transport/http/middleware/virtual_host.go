@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VirtualHost creates a middleware for virtual-hosted-style S3 requests. When
+// the Host header is <bucket>.<domain> the bucket comes from the host and the
+// whole path is the object key, so the bucket and key parameters are set
+// accordingly. The request URL is left alone, as it is what the client signed.
+func VirtualHost(domain string) gin.HandlerFunc {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return func(c *gin.Context) {
+		bucket := hostBucket(c.Request.Host, suffix)
+		if bucket == "" {
+			c.Next()
+			return
+		}
+
+		key := ""
+		if path := c.Request.URL.Path; path != "/" {
+			key = path
+		}
+		c.Params = gin.Params{
+			{Key: "bucket", Value: bucket},
+			{Key: "key", Value: key},
+		}
+		c.Next()
+	}
+}
+
+// hostBucket returns the bucket named by host, or an empty string for hosts
+// that are not a direct subdomain of the domain with the given suffix. A
+// deeper subdomain is not taken for a dotted bucket name, so that such hosts
+// fall back to path-style requests.
+func hostBucket(host, suffix string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+	bucket := strings.TrimSuffix(host, suffix)
+	if strings.Contains(bucket, ".") {
+		return ""
+	}
+	return bucket
+}
@@ -1,8 +1,12 @@
 package router
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/8fs-io/core/internal/container"
 	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/8fs-io/core/internal/transport/http/middleware"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,8 +22,19 @@ func SetupRoutes(r *gin.Engine, c *container.Container) {
 	// API version 1
 	v1 := r.Group("/api/v1")
 	{
+		// With the JWT driver the REST and AI endpoints require a bearer
+		// token, issued in exchange for an access key pair
+		var apiAuth []gin.HandlerFunc
+		if c.Config.Auth.Enabled && c.Config.Auth.Driver == "jwt" {
+			authHandler := handlers.NewAuthHandler(c)
+			v1.POST("/auth/token", authHandler.IssueToken)
+			apiAuth = append(apiAuth, authHandler.BearerMiddleware())
+		}
+
 		// Storage endpoints
 		storage := v1.Group("/storage")
+		storage.Use(apiAuth...)
+		storage.Use(handlers.NewPolicyHandler(c).StorageMiddleware())
 		{
 			storageHandler := handlers.NewStorageHandler(c)
 			storage.GET("/buckets", storageHandler.ListBuckets)
@@ -32,11 +47,34 @@ func SetupRoutes(r *gin.Engine, c *container.Container) {
 			storage.HEAD("/buckets/:bucket/objects/*key", storageHandler.HeadObject)
 			storage.DELETE("/buckets/:bucket/objects/*key", storageHandler.DeleteObject)
 			storage.GET("/buckets/:bucket/objects", storageHandler.ListObjects)
+			// Presigned URLs are signed with the caller's key, so the
+			// caller must authenticate even without the JWT driver
+			var presignAuth []gin.HandlerFunc
+			if c.Config.Auth.Enabled && len(apiAuth) == 0 {
+				presignAuth = append(presignAuth, handlers.NewAuthHandler(c).AWSSignatureMiddleware())
+			}
+			storage.POST("/presign", append(presignAuth, storageHandler.PresignURL)...)
+			storage.GET("/changes", storageHandler.ListChanges)
+		}
+
+		// Access key management, signed with an admin access key
+		admin := v1.Group("/admin")
+		{
+			adminHandler := handlers.NewAdminHandler(c)
+			admin.Use(handlers.NewAuthHandler(c).AWSSignatureMiddleware(), adminHandler.RequireAdmin())
+			admin.POST("/keys", adminHandler.CreateKey)
+			admin.GET("/keys", adminHandler.ListKeys)
+			admin.GET("/keys/:accessKey", adminHandler.GetKey)
+			admin.DELETE("/keys/:accessKey", adminHandler.DeleteKey)
+			admin.POST("/keys/:accessKey/rotate", adminHandler.RotateKey)
+			admin.POST("/keys/:accessKey/disable", adminHandler.DisableKey)
+			admin.POST("/keys/:accessKey/enable", adminHandler.EnableKey)
 		}
 
 		// Vector endpoints (experimental)
 		if c.Config.Vector.Enabled && c.VectorStorage != nil {
 			vectorGroup := v1.Group("/vectors")
+			vectorGroup.Use(apiAuth...)
 			vectorHandler := handlers.NewVectorHandler(c, c.VectorStorage)
 			vectorGroup.POST("/embeddings", vectorHandler.StoreEmbedding)
 			vectorGroup.POST("/search", vectorHandler.SearchEmbeddings)
@@ -49,6 +87,7 @@ func SetupRoutes(r *gin.Engine, c *container.Container) {
 		// Async indexing endpoints
 		if c.Config.Indexing.Enabled && c.Config.Indexing.StatusEnabled && c.IndexingService != nil {
 			indexingGroup := v1.Group("/indexing")
+			indexingGroup.Use(apiAuth...)
 			indexingHandler := handlers.NewIndexingHandler(c)
 			indexingGroup.GET("/jobs/:jobId", indexingHandler.GetJobStatus)
 			indexingGroup.GET("/jobs", indexingHandler.GetJobsByObject)
@@ -59,6 +98,7 @@ func SetupRoutes(r *gin.Engine, c *container.Container) {
 		// RAG endpoints
 		if c.RAGService != nil {
 			chatGroup := v1.Group("/chat")
+			chatGroup.Use(apiAuth...)
 			ragHandler := handlers.NewRAGHandler(c, c.RAGService)
 			chatGroup.POST("/completions", ragHandler.ChatCompletions)          // OpenAI compatible
 			chatGroup.POST("/search/context", ragHandler.SearchContext)         // Context retrieval
@@ -68,30 +108,154 @@ func SetupRoutes(r *gin.Engine, c *container.Container) {
 	}
 
 	// S3-compatible endpoints (with optional auth)
-	if c.Config.Auth.Enabled && c.Config.Auth.Driver == "signature" {
-		// Apply AWS signature middleware to S3 endpoints
-		s3Group := r.Group("")
+	s3Group := r.Group("")
+	if c.Config.Server.Domain != "" {
+		// Virtual-hosted-style requests name the bucket in the host
+		s3Group.Use(middleware.VirtualHost(c.Config.Server.Domain))
+	}
+	// Bucket CORS rules apply ahead of authentication, as browsers send
+	// preflight requests unsigned
+	s3Group.Use(handlers.NewS3Handler(c).CORSMiddleware())
+	if c.Config.Auth.Enabled && (c.Config.Auth.Driver == "signature" || c.Config.Auth.Driver == "jwt") {
+		// Apply AWS signature middleware to S3 endpoints; S3 clients sign
+		// their requests with either driver
 		s3Group.Use(handlers.NewAuthHandler(c).AWSSignatureMiddleware())
-		setupS3Routes(s3Group, c)
-	} else {
-		setupS3Routes(r, c)
 	}
+	// Bucket policies apply once the caller is known
+	s3Group.Use(handlers.NewPolicyHandler(c).S3Middleware())
+	setupS3Routes(s3Group, c)
 }
 
 // setupS3Routes configures S3-compatible routes
 func setupS3Routes(r gin.IRoutes, c *container.Container) {
 	s3Handler := handlers.NewS3Handler(c)
 
-	// Bucket operations
-	r.GET("/", s3Handler.ListBuckets)
-	r.PUT("/:bucket", s3Handler.CreateBucket)
-	r.DELETE("/:bucket", s3Handler.DeleteBucket)
-	r.GET("/:bucket", s3Handler.ListObjects)
-	r.POST("/:bucket", s3Handler.DeleteObjects) // S3 delete objects API
-
-	// Object operations
-	r.PUT("/:bucket/*key", s3Handler.PutObject)
-	r.GET("/:bucket/*key", s3Handler.GetObject)
-	r.HEAD("/:bucket/*key", s3Handler.HeadObject)
-	r.DELETE("/:bucket/*key", s3Handler.DeleteObject)
+	routes := []struct {
+		method string
+		ops    s3Operations
+	}{
+		{http.MethodGet, s3Operations{
+			service: s3Handler.ListBuckets, bucket: s3Handler.ListObjects, object: s3Handler.GetObject,
+			subresources: map[string]s3Operations{
+				"acl":          {bucket: s3Handler.GetBucketAcl, object: s3Handler.GetObjectAcl},
+				"cors":         {bucket: s3Handler.GetBucketCors},
+				"legal-hold":   {object: s3Handler.GetObjectLegalHold},
+				"lifecycle":    {bucket: s3Handler.GetBucketLifecycleConfiguration},
+				"location":     {bucket: s3Handler.GetBucketLocation},
+				"notification": {bucket: s3Handler.GetBucketNotificationConfiguration},
+				"object-lock":  {bucket: s3Handler.GetObjectLockConfiguration},
+				"policy":       {bucket: s3Handler.GetBucketPolicy},
+				"retention":    {object: s3Handler.GetObjectRetention},
+				"tagging":      {object: s3Handler.GetObjectTagging},
+				"uploadId":     {object: s3Handler.ListParts},
+				"uploads":      {bucket: s3Handler.ListMultipartUploads},
+				"versioning":   {bucket: s3Handler.GetBucketVersioning},
+				"versions":     {bucket: s3Handler.ListObjectVersions},
+			},
+		}},
+		{http.MethodPut, s3Operations{
+			bucket: s3Handler.CreateBucket, object: s3Handler.PutObject,
+			subresources: map[string]s3Operations{
+				"cors":         {bucket: s3Handler.PutBucketCors},
+				"legal-hold":   {object: s3Handler.PutObjectLegalHold},
+				"lifecycle":    {bucket: s3Handler.PutBucketLifecycleConfiguration},
+				"notification": {bucket: s3Handler.PutBucketNotificationConfiguration},
+				"object-lock":  {bucket: s3Handler.PutObjectLockConfiguration},
+				"policy":       {bucket: s3Handler.PutBucketPolicy},
+				"retention":    {object: s3Handler.PutObjectRetention},
+				"tagging":      {object: s3Handler.PutObjectTagging},
+				"uploadId":     {object: s3Handler.UploadPart},
+				"versioning":   {bucket: s3Handler.PutBucketVersioning},
+			},
+		}},
+		{http.MethodDelete, s3Operations{
+			bucket: s3Handler.DeleteBucket, object: s3Handler.DeleteObject,
+			subresources: map[string]s3Operations{
+				"cors":      {bucket: s3Handler.DeleteBucketCors},
+				"lifecycle": {bucket: s3Handler.DeleteBucketLifecycle},
+				"policy":    {bucket: s3Handler.DeleteBucketPolicy},
+				"tagging":   {object: s3Handler.DeleteObjectTagging},
+				"uploadId":  {object: s3Handler.AbortMultipartUpload},
+			},
+		}},
+		{http.MethodPost, s3Operations{
+			// Browser-based uploads and other POST operations are not supported
+			bucket: s3Handler.NotImplemented, object: s3Handler.NotImplemented,
+			subresources: map[string]s3Operations{
+				"delete":   {bucket: s3Handler.DeleteObjects},
+				"select":   {object: s3Handler.SelectObjectContent},
+				"uploadId": {object: s3Handler.CompleteMultipartUpload},
+				"uploads":  {object: s3Handler.CreateMultipartUpload},
+			},
+		}},
+		{http.MethodHead, s3Operations{bucket: s3Handler.HeadBucket, object: s3Handler.HeadObject}},
+		{http.MethodOptions, s3Operations{}}, // Preflight requests are answered by the CORS middleware
+	}
+	for _, route := range routes {
+		handler := route.ops.handler(s3Handler.MethodNotAllowed, s3Handler.NotImplemented)
+		for _, path := range []string{"/", "/:bucket", "/:bucket/*key"} {
+			r.Handle(route.method, path, handler)
+		}
+	}
+}
+
+// s3Subresources lists the query parameters that address a subresource of a
+// bucket or object, such as ?acl, rather than the bucket or object itself
+var s3Subresources = []string{
+	"accelerate", "acl", "analytics", "attributes", "cors", "delete", "encryption",
+	"intelligent-tiering", "inventory", "legal-hold", "lifecycle", "location", "logging",
+	"metrics", "notification", "object-lock", "ownershipControls", "policy", "policyStatus",
+	"publicAccessBlock", "replication", "requestPayment", "restore", "retention", "select",
+	"tagging", "torrent", "uploadId", "uploads", "versioning", "versions", "website",
+}
+
+// s3Operations holds the handlers of one HTTP method on the service, on a
+// bucket and on an object
+type s3Operations struct {
+	service gin.HandlerFunc
+	bucket  gin.HandlerFunc
+	object  gin.HandlerFunc
+	// subresources holds the operations on the subresources of buckets and
+	// objects, keyed by the query parameter that names them
+	subresources map[string]s3Operations
+}
+
+// handler dispatches on the bucket and key parameters rather than on the
+// matched route, since virtual-hosted requests take the bucket from the host
+// and the key from the whole path. Requests for a subresource go to its
+// operation, or to notImplemented if it has none, so that they never fall
+// through to the bucket or object itself. Other missing operations are
+// handled by fallback.
+func (o s3Operations) handler(fallback, notImplemented gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ops, missing := o, fallback
+		if c.Param("bucket") != "" {
+			if subresource := requestedSubresource(c); subresource != "" {
+				ops, missing = o.subresources[subresource], notImplemented
+			}
+		}
+
+		handler := ops.object
+		switch {
+		case c.Param("bucket") == "":
+			handler = ops.service
+		case strings.TrimPrefix(c.Param("key"), "/") == "":
+			handler = ops.bucket
+		}
+		if handler == nil {
+			handler = missing
+		}
+		handler(c)
+	}
+}
+
+// requestedSubresource returns the subresource a request addresses, if any
+func requestedSubresource(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	for _, subresource := range s3Subresources {
+		if _, ok := query[subresource]; ok {
+			return subresource
+		}
+	}
+	return ""
 }
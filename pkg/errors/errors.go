@@ -17,6 +17,28 @@ const (
 	ErrCodeInvalidBucketName    ErrorCode = "INVALID_BUCKET_NAME"
 	ErrCodeInvalidObjectName    ErrorCode = "INVALID_OBJECT_NAME"
 	ErrCodeStorageQuotaExceeded ErrorCode = "STORAGE_QUOTA_EXCEEDED"
+	ErrCodeNoSuchUpload         ErrorCode = "NO_SUCH_UPLOAD"
+	ErrCodeInvalidPart          ErrorCode = "INVALID_PART"
+	ErrCodeInvalidPartOrder     ErrorCode = "INVALID_PART_ORDER"
+	ErrCodeEntityTooSmall       ErrorCode = "ENTITY_TOO_SMALL"
+	ErrCodeInvalidRange         ErrorCode = "INVALID_RANGE"
+	ErrCodeInvalidPartNumber    ErrorCode = "INVALID_PART_NUMBER"
+	ErrCodeNotModified          ErrorCode = "NOT_MODIFIED"
+	ErrCodePreconditionFailed   ErrorCode = "PRECONDITION_FAILED"
+	ErrCodeNoSuchVersion        ErrorCode = "NO_SUCH_VERSION"
+	ErrCodeMethodNotAllowed     ErrorCode = "METHOD_NOT_ALLOWED"
+	ErrCodeInvalidTag           ErrorCode = "INVALID_TAG"
+	ErrCodeNoSuchLifecycle      ErrorCode = "NO_SUCH_LIFECYCLE_CONFIGURATION"
+	ErrCodeNoSuchBucketPolicy   ErrorCode = "NO_SUCH_BUCKET_POLICY"
+	ErrCodeMalformedPolicy      ErrorCode = "MALFORMED_POLICY"
+	ErrCodeNoSuchCORS           ErrorCode = "NO_SUCH_CORS_CONFIGURATION"
+	ErrCodeCORSForbidden        ErrorCode = "CORS_FORBIDDEN"
+	ErrCodeBadDigest            ErrorCode = "BAD_DIGEST"
+	ErrCodeInvalidDigest        ErrorCode = "INVALID_DIGEST"
+	ErrCodeNoSuchObjectLock     ErrorCode = "NO_SUCH_OBJECT_LOCK_CONFIGURATION"
+	ErrCodeNoSuchRetention      ErrorCode = "NO_SUCH_OBJECT_RETENTION"
+	ErrCodeInvalidBucketState   ErrorCode = "INVALID_BUCKET_STATE"
+	ErrCodeCursorExpired        ErrorCode = "CURSOR_EXPIRED"
 
 	// Authentication errors
 	ErrCodeAuthenticationRequired ErrorCode = "AUTHENTICATION_REQUIRED"
@@ -24,6 +46,11 @@ const (
 	ErrCodeInvalidSignature       ErrorCode = "INVALID_SIGNATURE"
 	ErrCodeAccessDenied           ErrorCode = "ACCESS_DENIED"
 	ErrCodeTokenExpired           ErrorCode = "TOKEN_EXPIRED"
+	ErrCodeRequestTimeTooSkewed   ErrorCode = "REQUEST_TIME_TOO_SKEWED"
+	ErrCodeMalformedAuth          ErrorCode = "AUTHORIZATION_HEADER_MALFORMED"
+	ErrCodeContentSHA256Mismatch  ErrorCode = "CONTENT_SHA256_MISMATCH"
+	ErrCodeAccessKeyNotFound      ErrorCode = "ACCESS_KEY_NOT_FOUND"
+	ErrCodeAccessKeyExists        ErrorCode = "ACCESS_KEY_ALREADY_EXISTS"
 
 	// Request errors
 	ErrCodeInvalidRequest   ErrorCode = "INVALID_REQUEST"
@@ -31,6 +58,7 @@ const (
 	ErrCodeMissingHeaders   ErrorCode = "MISSING_REQUIRED_HEADERS"
 	ErrCodeInvalidParameter ErrorCode = "INVALID_PARAMETER"
 	ErrCodeRequestTooLarge  ErrorCode = "REQUEST_TOO_LARGE"
+	ErrCodeIncompleteBody   ErrorCode = "INCOMPLETE_BODY"
 
 	// System errors
 	ErrCodeInternalError      ErrorCode = "INTERNAL_ERROR"
@@ -129,19 +157,35 @@ func As(err error, target interface{}) bool {
 // getHTTPStatus maps error codes to HTTP status codes
 func getHTTPStatus(code ErrorCode) int {
 	switch code {
-	case ErrCodeBucketExists:
+	case ErrCodeBucketExists, ErrCodeAccessKeyExists:
 		return http.StatusConflict
-	case ErrCodeBucketNotFound, ErrCodeObjectNotFound:
+	case ErrCodeBucketNotFound, ErrCodeObjectNotFound, ErrCodeNoSuchUpload, ErrCodeNoSuchVersion, ErrCodeNoSuchLifecycle, ErrCodeNoSuchBucketPolicy, ErrCodeNoSuchCORS, ErrCodeAccessKeyNotFound:
 		return http.StatusNotFound
-	case ErrCodeBucketNotEmpty:
+	case ErrCodeNoSuchObjectLock, ErrCodeNoSuchRetention:
+		return http.StatusNotFound
+	case ErrCodeBucketNotEmpty, ErrCodeInvalidBucketState:
 		return http.StatusConflict
-	case ErrCodeInvalidBucketName, ErrCodeInvalidObjectName, ErrCodeInvalidRequest, ErrCodeMalformedXML, ErrCodeMissingHeaders, ErrCodeInvalidParameter:
+	case ErrCodeInvalidBucketName, ErrCodeInvalidObjectName, ErrCodeInvalidRequest, ErrCodeMalformedXML, ErrCodeMissingHeaders, ErrCodeInvalidParameter, ErrCodeIncompleteBody:
+		return http.StatusBadRequest
+	case ErrCodeMalformedAuth, ErrCodeContentSHA256Mismatch, ErrCodeBadDigest, ErrCodeInvalidDigest:
+		return http.StatusBadRequest
+	case ErrCodeInvalidPart, ErrCodeInvalidPartOrder, ErrCodeEntityTooSmall, ErrCodeInvalidTag, ErrCodeMalformedPolicy:
 		return http.StatusBadRequest
+	case ErrCodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case ErrCodeCursorExpired:
+		return http.StatusGone
+	case ErrCodeNotModified:
+		return http.StatusNotModified
+	case ErrCodePreconditionFailed:
+		return http.StatusPreconditionFailed
+	case ErrCodeInvalidRange, ErrCodeInvalidPartNumber:
+		return http.StatusRequestedRangeNotSatisfiable
 	case ErrCodeAuthenticationRequired:
 		return http.StatusUnauthorized
 	case ErrCodeInvalidCredentials, ErrCodeInvalidSignature, ErrCodeTokenExpired:
 		return http.StatusUnauthorized
-	case ErrCodeAccessDenied:
+	case ErrCodeAccessDenied, ErrCodeRequestTimeTooSkewed, ErrCodeCORSForbidden:
 		return http.StatusForbidden
 	case ErrCodeRequestTooLarge:
 		return http.StatusRequestEntityTooLarge
@@ -170,6 +214,30 @@ var (
 	ErrInvalidCredentials = New(ErrCodeInvalidCredentials, "The AWS access key ID you provided does not exist in our records")
 	ErrInvalidSignature   = New(ErrCodeInvalidSignature, "The request signature we calculated does not match the signature you provided")
 	ErrAccessDenied       = New(ErrCodeAccessDenied, "Access denied")
+	ErrTokenExpired       = New(ErrCodeTokenExpired, "The provided token has expired")
+	ErrRequestTimeSkewed  = New(ErrCodeRequestTimeTooSkewed, "The difference between the request time and the server's time is too large")
+	ErrMalformedAuth      = New(ErrCodeMalformedAuth, "The authorization header is malformed")
+	ErrContentSHA256      = New(ErrCodeContentSHA256Mismatch, "The provided 'x-amz-content-sha256' header does not match what was computed")
+	ErrNoSuchUpload       = New(ErrCodeNoSuchUpload, "The specified multipart upload does not exist")
+	ErrInvalidPart        = New(ErrCodeInvalidPart, "One or more of the specified parts could not be found or the entity tag did not match")
+	ErrInvalidPartOrder   = New(ErrCodeInvalidPartOrder, "The list of parts was not in ascending order")
+	ErrEntityTooSmall     = New(ErrCodeEntityTooSmall, "Your proposed upload is smaller than the minimum allowed object size")
+	ErrInvalidRange       = New(ErrCodeInvalidRange, "The requested range is not satisfiable")
+	ErrInvalidPartNumber  = New(ErrCodeInvalidPartNumber, "The requested partnumber is not satisfiable")
+	ErrNotModified        = New(ErrCodeNotModified, "Not Modified")
+	ErrPreconditionFailed = New(ErrCodePreconditionFailed, "At least one of the pre-conditions you specified did not hold")
+	ErrNoSuchVersion      = New(ErrCodeNoSuchVersion, "The specified version does not exist")
+	ErrMethodNotAllowed   = New(ErrCodeMethodNotAllowed, "The specified method is not allowed against this resource")
+	ErrNoSuchLifecycle    = New(ErrCodeNoSuchLifecycle, "The lifecycle configuration does not exist")
+	ErrNoSuchBucketPolicy = New(ErrCodeNoSuchBucketPolicy, "The bucket policy does not exist")
+	ErrNoSuchCORS         = New(ErrCodeNoSuchCORS, "The CORS configuration does not exist")
+	ErrBadDigest          = New(ErrCodeBadDigest, "The Content-MD5 you specified did not match what we received.")
+	ErrNoSuchObjectLock   = New(ErrCodeNoSuchObjectLock, "Object Lock configuration does not exist for this bucket")
+	ErrNoSuchRetention    = New(ErrCodeNoSuchRetention, "The specified object does not have a ObjectLock configuration")
+	ErrObjectLocked       = New(ErrCodeAccessDenied, "Access Denied because object protected by object lock.")
+	ErrAccessKeyNotFound  = New(ErrCodeAccessKeyNotFound, "The specified access key does not exist")
+	ErrAccessKeyExists    = New(ErrCodeAccessKeyExists, "The specified access key already exists")
 	ErrInternalError      = New(ErrCodeInternalError, "We encountered an internal error. Please try again")
 	ErrNotImplemented     = New(ErrCodeNotImplemented, "A header you provided implies functionality that is not implemented")
+	ErrCursorExpired      = New(ErrCodeCursorExpired, "The changes after the specified cursor are no longer retained")
 )
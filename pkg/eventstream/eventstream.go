@@ -0,0 +1,167 @@
+// Package eventstream implements the binary framing of AWS event streams, in
+// which responses such as those of S3 Select are sent as a series of
+// messages. Only string header values are supported.
+package eventstream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+const (
+	// preludeLen is the size of the total and headers lengths and the CRC
+	// that covers them
+	preludeLen = 12
+	// crcLen is the size of the CRC that ends every message
+	crcLen = 4
+
+	// headerTypeString is the type of string header values
+	headerTypeString = 7
+
+	// MaxMessageSize bounds the messages a Decoder accepts
+	MaxMessageSize = 16 * 1024 * 1024
+)
+
+// ErrChecksum is returned when the CRC of a message does not match its content
+var ErrChecksum = errors.New("event stream message checksum mismatch")
+
+// Header is a named value sent ahead of the payload of a message
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Message is a single frame of an event stream
+type Message struct {
+	Headers []Header
+	Payload []byte
+}
+
+// Header returns the value of the named header, or an empty string
+func (m *Message) Header(name string) string {
+	for _, header := range m.Headers {
+		if header.Name == name {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// Encoder writes messages to a stream
+type Encoder struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+// NewEncoder creates an encoder writing to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes a message as a single frame
+func (e *Encoder) Encode(m Message) error {
+	var headers bytes.Buffer
+	for _, header := range m.Headers {
+		if len(header.Name) == 0 || len(header.Name) > math.MaxUint8 {
+			return fmt.Errorf("invalid event stream header name %q", header.Name)
+		}
+		if len(header.Value) > math.MaxUint16 {
+			return fmt.Errorf("event stream header %s is too long", header.Name)
+		}
+		headers.WriteByte(byte(len(header.Name)))
+		headers.WriteString(header.Name)
+		headers.WriteByte(headerTypeString)
+		binary.Write(&headers, binary.BigEndian, uint16(len(header.Value)))
+		headers.WriteString(header.Value)
+	}
+
+	total := preludeLen + headers.Len() + len(m.Payload) + crcLen
+	if total > MaxMessageSize {
+		return fmt.Errorf("event stream message of %d bytes is too large", total)
+	}
+
+	e.buf.Reset()
+	binary.Write(&e.buf, binary.BigEndian, uint32(total))
+	binary.Write(&e.buf, binary.BigEndian, uint32(headers.Len()))
+	binary.Write(&e.buf, binary.BigEndian, crc32.ChecksumIEEE(e.buf.Bytes()))
+	e.buf.Write(headers.Bytes())
+	e.buf.Write(m.Payload)
+	binary.Write(&e.buf, binary.BigEndian, crc32.ChecksumIEEE(e.buf.Bytes()))
+
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
+
+// Decoder reads messages from a stream
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder creates a decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads the next message, returning io.EOF at the end of the stream
+func (d *Decoder) Decode() (*Message, error) {
+	prelude := make([]byte, preludeLen)
+	if _, err := io.ReadFull(d.r, prelude); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated event stream message: %w", err)
+		}
+		return nil, err
+	}
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, ErrChecksum
+	}
+	if total > MaxMessageSize || uint64(total) < uint64(preludeLen)+uint64(headersLen)+crcLen {
+		return nil, fmt.Errorf("invalid event stream message length %d", total)
+	}
+
+	frame := make([]byte, total)
+	copy(frame, prelude)
+	if _, err := io.ReadFull(d.r, frame[preludeLen:]); err != nil {
+		return nil, fmt.Errorf("truncated event stream message: %w", err)
+	}
+	body := frame[:total-crcLen]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(frame[total-crcLen:]) {
+		return nil, ErrChecksum
+	}
+
+	headers, err := decodeHeaders(body[preludeLen : preludeLen+headersLen])
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Headers: headers, Payload: body[preludeLen+headersLen:]}, nil
+}
+
+// decodeHeaders parses the header block of a message
+func decodeHeaders(data []byte) ([]Header, error) {
+	var headers []Header
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+1+2 {
+			return nil, fmt.Errorf("truncated event stream header")
+		}
+		name := string(data[1 : 1+nameLen])
+		data = data[1+nameLen:]
+		if data[0] != headerTypeString {
+			return nil, fmt.Errorf("unsupported type %d of event stream header %s", data[0], name)
+		}
+		valueLen := int(binary.BigEndian.Uint16(data[1:3]))
+		data = data[3:]
+		if len(data) < valueLen {
+			return nil, fmt.Errorf("truncated event stream header %s", name)
+		}
+		headers = append(headers, Header{Name: name, Value: string(data[:valueLen])})
+		data = data[valueLen:]
+	}
+	return headers, nil
+}
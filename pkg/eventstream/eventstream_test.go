@@ -0,0 +1,90 @@
+package eventstream
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestEncoder_EmptyMessage(t *testing.T) {
+	// The empty message of the AWS event stream test suite
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(Message{}); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if got, want := hex.EncodeToString(buf.Bytes()), "000000100000000005c248eb7d98c8ff"; got != want {
+		t.Errorf("Encode() = %s, want %s", got, want)
+	}
+}
+
+func TestEncoder_RoundTrip(t *testing.T) {
+	messages := []Message{
+		{
+			Headers: []Header{{":event-type", "Records"}, {":content-type", "application/octet-stream"}, {":message-type", "event"}},
+			Payload: []byte("a,b\n1,2\n"),
+		},
+		{
+			Headers: []Header{{":event-type", "End"}, {":message-type", "event"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	encoder := NewEncoder(&buf)
+	for _, m := range messages {
+		if err := encoder.Encode(m); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+
+	decoder := NewDecoder(&buf)
+	for i, want := range messages {
+		got, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("Decode() message %d error = %v", i, err)
+		}
+		if len(got.Headers) != len(want.Headers) {
+			t.Fatalf("message %d: headers = %v, want %v", i, got.Headers, want.Headers)
+		}
+		for j := range want.Headers {
+			if got.Headers[j] != want.Headers[j] {
+				t.Errorf("message %d: header %d = %v, want %v", i, j, got.Headers[j], want.Headers[j])
+			}
+		}
+		if got.Header(":event-type") != want.Headers[0].Value {
+			t.Errorf("message %d: Header(:event-type) = %q, want %q", i, got.Header(":event-type"), want.Headers[0].Value)
+		}
+		if !bytes.Equal(got.Payload, want.Payload) {
+			t.Errorf("message %d: payload = %q, want %q", i, got.Payload, want.Payload)
+		}
+	}
+	if _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("Decode() at end of stream error = %v, want io.EOF", err)
+	}
+}
+
+func TestDecoder_Corruption(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(Message{Headers: []Header{{":event-type", "Stats"}}, Payload: []byte("<Stats/>")}); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	frame := buf.Bytes()
+
+	tests := map[string]func([]byte) []byte{
+		"prelude":   func(b []byte) []byte { b[1] ^= 0xff; return b },
+		"payload":   func(b []byte) []byte { b[len(b)-6] ^= 0xff; return b },
+		"truncated": func(b []byte) []byte { return b[:len(b)-2] },
+	}
+	for name, corrupt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewDecoder(bytes.NewReader(corrupt(append([]byte(nil), frame...)))).Decode()
+			if err == nil {
+				t.Fatal("Decode() error = nil, want an error")
+			}
+			if name != "truncated" && !errors.Is(err, ErrChecksum) {
+				t.Errorf("Decode() error = %v, want ErrChecksum", err)
+			}
+		})
+	}
+}
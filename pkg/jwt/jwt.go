@@ -0,0 +1,132 @@
+// Package jwt implements the subset of JSON Web Tokens (RFC 7519) used for
+// bearer authentication: compact tokens signed with HMAC-SHA256.
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// Algorithm is the only signing algorithm supported
+const Algorithm = "HS256"
+
+var (
+	// ErrMalformed is returned for tokens that cannot be decoded
+	ErrMalformed = errors.New("jwt: malformed token")
+	// ErrAlgorithm is returned for tokens not signed with HS256
+	ErrAlgorithm = errors.New("jwt: unsupported signing algorithm")
+	// ErrSignature is returned when the signature does not match
+	ErrSignature = errors.New("jwt: invalid signature")
+	// ErrExpired is returned for tokens past their expiry time
+	ErrExpired = errors.New("jwt: token has expired")
+	// ErrNotValidYet is returned for tokens issued in the future
+	ErrNotValidYet = errors.New("jwt: token is not valid yet")
+)
+
+// MaxClockSkew is the leeway allowed when checking the issue time of a token
+const MaxClockSkew = time.Minute
+
+// Claims are the registered claims of a token together with the scope of
+// the access key it was issued for
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub"`
+	ID        string   `json:"jti,omitempty"`
+	IssuedAt  float64  `json:"iat"` // a NumericDate with microseconds, see NumericDate
+	ExpiresAt int64    `json:"exp"`
+	Role      string   `json:"role,omitempty"`
+	Buckets   []string `json:"buckets,omitempty"`
+	Actions   []string `json:"actions,omitempty"`
+}
+
+// NumericDate returns t as seconds since the epoch with microsecond
+// precision, so that tokens issued within the same second can be ordered
+func NumericDate(t time.Time) float64 {
+	return float64(t.UnixMicro()) / 1e6
+}
+
+// IssuedTime returns the iat claim as a time
+func (c *Claims) IssuedTime() time.Time {
+	return time.UnixMicro(int64(math.Round(c.IssuedAt * 1e6)))
+}
+
+// header is the JOSE header of a token
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// Sign encodes claims as a token signed with secret
+func Sign(claims *Claims, secret []byte) (string, error) {
+	headerJSON, err := json.Marshal(header{Algorithm: Algorithm, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encoding.EncodeToString(headerJSON) + "." + encoding.EncodeToString(claimsJSON)
+	return signingInput + "." + encoding.EncodeToString(signature(signingInput, secret)), nil
+}
+
+// Parse verifies the signature and validity window of token and returns its claims
+func Parse(token string, secret []byte, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	headerJSON, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return nil, ErrMalformed
+	}
+	if h.Algorithm != Algorithm {
+		return nil, ErrAlgorithm
+	}
+
+	sig, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !hmac.Equal(sig, signature(parts[0]+"."+parts[1], secret)) {
+		return nil, ErrSignature
+	}
+
+	claimsJSON, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var claims Claims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, ErrMalformed
+	}
+
+	if claims.ExpiresAt == 0 || !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrExpired
+	}
+	if claims.IssuedTime().After(now.Add(MaxClockSkew)) {
+		return nil, ErrNotValidYet
+	}
+	return &claims, nil
+}
+
+// signature computes the HMAC-SHA256 of the signing input
+func signature(signingInput string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,671 @@
+package s3select
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Values are nil for NULL and MISSING, bool, int64, float64, string, *object
+// for JSON objects and []interface{} for JSON arrays.
+
+// expr is an expression evaluated against a record
+type expr interface {
+	eval(r record) (interface{}, error)
+}
+
+// pathElement is a field name, or an array index if index is not negative
+type pathElement struct {
+	name   string
+	quoted bool // quoted names are matched case-sensitively
+	index  int
+}
+
+type literalExpr struct {
+	value interface{}
+}
+
+func (e *literalExpr) eval(record) (interface{}, error) {
+	return e.value, nil
+}
+
+// columnExpr refers to a value of the record, or the record itself if its
+// path is empty
+type columnExpr struct {
+	path []pathElement
+}
+
+func (e *columnExpr) eval(r record) (interface{}, error) {
+	return r.get(e.path), nil
+}
+
+type logicalExpr struct {
+	op          string
+	left, right expr
+}
+
+// eval applies three-valued logic, in which NULL stands for unknown
+func (e *logicalExpr) eval(r record) (interface{}, error) {
+	left, err := e.left.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	l, lok := left.(bool)
+	if lok && (e.op == "AND" && !l || e.op == "OR" && l) {
+		return l, nil
+	}
+	right, err := e.right.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	rv, rok := right.(bool)
+	if rok && (e.op == "AND" && !rv || e.op == "OR" && rv) {
+		return rv, nil
+	}
+	if !lok || !rok {
+		return nil, nil
+	}
+	return l, nil
+}
+
+type notExpr struct {
+	operand expr
+}
+
+func (e *notExpr) eval(r record) (interface{}, error) {
+	v, err := e.operand.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := v.(bool); ok {
+		return !b, nil
+	}
+	return nil, nil
+}
+
+type compareExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *compareExpr) eval(r record) (interface{}, error) {
+	left, err := e.left.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	cmp, ok := compareValues(left, right)
+	if !ok {
+		// Values of different types are never equal and cannot be ordered
+		switch e.op {
+		case "=":
+			return false, nil
+		case "!=", "<>":
+			return true, nil
+		}
+		return nil, nil
+	}
+	switch e.op {
+	case "=":
+		return cmp == 0, nil
+	case "!=", "<>":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type isNullExpr struct {
+	operand expr
+	negate  bool
+}
+
+func (e *isNullExpr) eval(r record) (interface{}, error) {
+	v, err := e.operand.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != e.negate, nil
+}
+
+type likeExpr struct {
+	operand, pattern, escape expr
+	negate                   bool
+
+	// compiled caches the last pattern, which is usually a literal
+	compiledFrom string
+	compiled     *regexp.Regexp
+}
+
+func (e *likeExpr) eval(r record) (interface{}, error) {
+	v, err := e.operand.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := e.pattern.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	var escape interface{}
+	if e.escape != nil {
+		if escape, err = e.escape.eval(r); err != nil {
+			return nil, err
+		}
+	}
+	if v == nil || pattern == nil || e.escape != nil && escape == nil {
+		return nil, nil
+	}
+
+	key := toString(pattern)
+	var escapeRune rune = -1
+	if escape != nil {
+		s := toString(escape)
+		if utf8.RuneCountInString(s) != 1 {
+			return nil, &Error{Code: "EvaluatorInvalidArguments", Message: "The LIKE escape must be a single character"}
+		}
+		escapeRune, _ = utf8.DecodeRuneInString(s)
+		key = s + key
+	}
+	if e.compiled == nil || e.compiledFrom != key {
+		if e.compiled, err = likePattern(toString(pattern), escapeRune); err != nil {
+			return nil, err
+		}
+		e.compiledFrom = key
+	}
+	return e.compiled.MatchString(toString(v)) != e.negate, nil
+}
+
+// likePattern translates a LIKE pattern, in which % matches any run of
+// characters and _ a single character, to a regular expression
+func likePattern(pattern string, escape rune) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case c == escape:
+			escaped = true
+		case c == '%':
+			b.WriteString(".*")
+		case c == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if escaped {
+		return nil, &Error{Code: "EvaluatorInvalidArguments", Message: "The LIKE pattern ends with its escape character"}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+type betweenExpr struct {
+	operand, low, high expr
+	negate             bool
+}
+
+func (e *betweenExpr) eval(r record) (interface{}, error) {
+	v, err := e.operand.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	low, err := e.low.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	high, err := e.high.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil || low == nil || high == nil {
+		return nil, nil
+	}
+	lowCmp, lok := compareValues(v, low)
+	highCmp, hok := compareValues(v, high)
+	if !lok || !hok {
+		return e.negate, nil
+	}
+	return (lowCmp >= 0 && highCmp <= 0) != e.negate, nil
+}
+
+type inExpr struct {
+	operand expr
+	list    []expr
+	negate  bool
+}
+
+func (e *inExpr) eval(r record) (interface{}, error) {
+	v, err := e.operand.eval(r)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	for _, item := range e.list {
+		candidate, err := item.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			continue
+		}
+		if cmp, ok := compareValues(v, candidate); ok && cmp == 0 {
+			return !e.negate, nil
+		}
+	}
+	return e.negate, nil
+}
+
+type arithmeticExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *arithmeticExpr) eval(r record) (interface{}, error) {
+	left, err := e.left.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	if e.op == "||" {
+		return toString(left) + toString(right), nil
+	}
+
+	l, err := toNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := toNumber(right)
+	if err != nil {
+		return nil, err
+	}
+
+	li, lint := l.(int64)
+	ri, rint := rv.(int64)
+	if lint && rint {
+		if (e.op == "/" || e.op == "%") && ri == 0 {
+			return nil, &Error{Code: "DivisionByZero", Message: "Division by zero"}
+		}
+		n, ok := intArithmetic(e.op, li, ri)
+		if !ok {
+			return nil, errIntegerOverflow
+		}
+		return n, nil
+	}
+
+	lf, rf := toFloat(l), toFloat(rv)
+	switch e.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	}
+	if rf == 0 {
+		return nil, &Error{Code: "DivisionByZero", Message: "Division by zero"}
+	}
+	if e.op == "/" {
+		return lf / rf, nil
+	}
+	return math.Mod(lf, rf), nil
+}
+
+// errIntegerOverflow is returned when integer arithmetic leaves the int64 range
+var errIntegerOverflow = &Error{Code: "IntegerOverflow", Message: "Int overflow or underflow"}
+
+// intArithmetic applies an arithmetic operator to two integers, reporting
+// false if the result overflows. The divisor of / and % must not be zero.
+func intArithmetic(op string, a, b int64) (int64, bool) {
+	switch op {
+	case "+":
+		n := a + b
+		return n, (n > a) == (b > 0)
+	case "-":
+		n := a - b
+		return n, (n < a) == (b > 0)
+	case "*":
+		if a == 0 || b == 0 {
+			return 0, true
+		}
+		n := a * b
+		return n, n/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64)
+	case "/":
+		return a / b, !(a == math.MinInt64 && b == -1)
+	default:
+		return a % b, true
+	}
+}
+
+type castExpr struct {
+	operand expr
+	to      string
+}
+
+func (e *castExpr) eval(r record) (interface{}, error) {
+	v, err := e.operand.eval(r)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	failed := &Error{Code: "CastFailed", Message: fmt.Sprintf("Cannot cast %s to %s", toString(v), e.to)}
+	switch e.to {
+	case "INT":
+		switch n := v.(type) {
+		case bool:
+			if n {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case string:
+			if i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64); err == nil {
+				return i, nil
+			}
+		}
+		n, err := toNumber(v)
+		if err != nil {
+			return nil, failed
+		}
+		if f, ok := n.(float64); ok {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, failed
+			}
+			return int64(f), nil
+		}
+		return n, nil
+	case "FLOAT":
+		n, err := toNumber(v)
+		if err != nil {
+			return nil, failed
+		}
+		return toFloat(n), nil
+	case "BOOL":
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case int64:
+			return b != 0, nil
+		case float64:
+			return b != 0, nil
+		case string:
+			if parsed, err := strconv.ParseBool(strings.TrimSpace(b)); err == nil {
+				return parsed, nil
+			}
+		}
+		return nil, failed
+	default:
+		return toString(v), nil
+	}
+}
+
+type callExpr struct {
+	function string
+	args     []expr
+}
+
+func (e *callExpr) eval(r record) (interface{}, error) {
+	if e.function == "COALESCE" {
+		for _, arg := range e.args {
+			v, err := arg.eval(r)
+			if err != nil || v != nil {
+				return v, err
+			}
+		}
+		return nil, nil
+	}
+
+	v, err := e.args[0].eval(r)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	s := toString(v)
+	switch e.function {
+	case "LOWER":
+		return strings.ToLower(s), nil
+	case "UPPER":
+		return strings.ToUpper(s), nil
+	case "TRIM":
+		return strings.TrimSpace(s), nil
+	default: // CHAR_LENGTH, CHARACTER_LENGTH
+		return int64(utf8.RuneCountInString(s)), nil
+	}
+}
+
+// aggregateExpr accumulates a value over every record that matched, and
+// evaluates to the result once all were seen
+type aggregateExpr struct {
+	function string
+	operand  expr
+	star     bool
+
+	count  int64
+	sum    interface{} // int64 until a float is added
+	result interface{} // MIN and MAX so far
+}
+
+// add accumulates the value of a record
+func (e *aggregateExpr) add(r record) error {
+	if e.star {
+		e.count++
+		return nil
+	}
+	v, err := e.operand.eval(r)
+	if err != nil || v == nil {
+		return err
+	}
+	e.count++
+
+	switch e.function {
+	case "SUM", "AVG":
+		n, err := toNumber(v)
+		if err != nil {
+			return err
+		}
+		switch sum := e.sum.(type) {
+		case nil:
+			e.sum = n
+		case int64:
+			if i, ok := n.(int64); ok {
+				if e.sum, ok = intArithmetic("+", sum, i); !ok {
+					return errIntegerOverflow
+				}
+			} else {
+				e.sum = float64(sum) + toFloat(n)
+			}
+		case float64:
+			e.sum = sum + toFloat(n)
+		}
+	case "MIN", "MAX":
+		// Numeric strings, such as CSV fields, are compared as numbers
+		if s, ok := v.(string); ok {
+			if n, err := toNumber(s); err == nil {
+				v = n
+			}
+		}
+		if e.result == nil {
+			e.result = v
+			return nil
+		}
+		cmp, ok := compareValues(v, e.result)
+		if !ok {
+			return &Error{Code: "EvaluatorInvalidArguments", Message: fmt.Sprintf("Cannot compare %s and %s in %s", toString(v), toString(e.result), e.function)}
+		}
+		if e.function == "MIN" && cmp < 0 || e.function == "MAX" && cmp > 0 {
+			e.result = v
+		}
+	}
+	return nil
+}
+
+func (e *aggregateExpr) eval(record) (interface{}, error) {
+	switch e.function {
+	case "COUNT":
+		return e.count, nil
+	case "SUM":
+		return e.sum, nil
+	case "AVG":
+		if e.count == 0 {
+			return nil, nil
+		}
+		return toFloat(e.sum) / float64(e.count), nil
+	default:
+		return e.result, nil
+	}
+}
+
+// compareValues orders two non-NULL values. Strings compared with numbers or
+// booleans are parsed first, as CSV fields are always strings. It returns
+// false if the values cannot be compared.
+func compareValues(a, b interface{}) (int, bool) {
+	if s, ok := a.(string); ok {
+		if t, ok := b.(string); ok {
+			return strings.Compare(s, t), true
+		}
+		parsed, ok := parseAs(s, b)
+		if !ok {
+			return 0, false
+		}
+		a = parsed
+	} else if s, ok := b.(string); ok {
+		parsed, ok := parseAs(s, a)
+		if !ok {
+			return 0, false
+		}
+		b = parsed
+	}
+
+	switch x := a.(type) {
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x == y:
+			return 0, true
+		case !x:
+			return -1, true
+		default:
+			return 1, true
+		}
+	case int64:
+		if y, ok := b.(int64); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	if !isNumber(a) || !isNumber(b) {
+		return 0, false
+	}
+	x, y := toFloat(a), toFloat(b)
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+// parseAs parses s as a value of the type of like
+func parseAs(s string, like interface{}) (interface{}, bool) {
+	switch like.(type) {
+	case bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		return b, err == nil
+	case int64, float64:
+		n, err := toNumber(s)
+		return n, err == nil
+	}
+	return nil, false
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+// toNumber converts a value to int64 or float64
+func toNumber(v interface{}) (interface{}, error) {
+	switch n := v.(type) {
+	case int64, float64:
+		return n, nil
+	case string:
+		s := strings.TrimSpace(n)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	}
+	return nil, &Error{Code: "EvaluatorInvalidArguments", Message: fmt.Sprintf("%q is not a number", toString(v))}
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// toString formats a value as text
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case bool:
+		return strconv.FormatBool(s)
+	case int64:
+		return strconv.FormatInt(s, 10)
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	}
+	var b strings.Builder
+	appendJSON(&b, v)
+	return b.String()
+}
+
+// truthy reports whether a WHERE condition holds
+func truthy(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
@@ -0,0 +1,532 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// record is a row of the input
+type record interface {
+	// get returns the value at a path, or the whole record for an empty path
+	get(path []pathElement) interface{}
+	// values returns the values of the record in order, for SELECT *
+	values() []interface{}
+}
+
+// recordReader reads the records of the input in order
+type recordReader interface {
+	// next returns the next record, or io.EOF at the end of the input
+	next() (record, error)
+}
+
+// object is a JSON object that keeps the order of its keys
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: map[string]interface{}{}}
+}
+
+func (o *object) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// lookup finds a key, ignoring case unless the name was quoted
+func (o *object) lookup(element pathElement) (interface{}, bool) {
+	if v, ok := o.values[element.name]; ok {
+		return v, true
+	}
+	if element.quoted {
+		return nil, false
+	}
+	for _, key := range o.keys {
+		if strings.EqualFold(key, element.name) {
+			return o.values[key], true
+		}
+	}
+	return nil, false
+}
+
+// walk follows a path into a JSON value
+func walk(v interface{}, path []pathElement) interface{} {
+	for _, element := range path {
+		switch container := v.(type) {
+		case *object:
+			if element.index >= 0 {
+				return nil
+			}
+			v, _ = container.lookup(element)
+		case []interface{}:
+			if element.index < 0 || element.index >= len(container) {
+				return nil
+			}
+			v = container[element.index]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// csvRecord is a row of a CSV object
+type csvRecord struct {
+	fields []string
+	header *csvHeader
+}
+
+// csvHeader maps the column names of the first row to positions
+type csvHeader struct {
+	names   []string
+	exact   map[string]int
+	folded  map[string]int
+	enabled bool
+}
+
+func (r *csvRecord) get(path []pathElement) interface{} {
+	if len(path) == 0 {
+		row := newObject()
+		for i, field := range r.fields {
+			row.set(r.header.name(i), field)
+		}
+		return row
+	}
+	if len(path) > 1 || path[0].index >= 0 {
+		return nil
+	}
+
+	name := path[0].name
+	if !path[0].quoted && len(name) > 1 && name[0] == '_' {
+		if n, err := strconv.Atoi(name[1:]); err == nil {
+			if n > len(r.fields) {
+				return nil
+			}
+			return r.fields[n-1]
+		}
+	}
+	if !r.header.enabled {
+		return nil
+	}
+	i, ok := r.header.exact[name]
+	if !ok && !path[0].quoted {
+		i, ok = r.header.folded[strings.ToLower(name)]
+	}
+	if !ok || i >= len(r.fields) {
+		return nil
+	}
+	return r.fields[i]
+}
+
+func (r *csvRecord) values() []interface{} {
+	values := make([]interface{}, len(r.fields))
+	for i, field := range r.fields {
+		values[i] = field
+	}
+	return values
+}
+
+// name returns the name of a column in JSON output
+func (h *csvHeader) name(i int) string {
+	if h.enabled && i < len(h.names) {
+		return h.names[i]
+	}
+	return "_" + strconv.Itoa(i+1)
+}
+
+type csvReader struct {
+	reader     *csv.Reader
+	header     *csvHeader
+	headerInfo string
+	started    bool
+}
+
+func newCSVReader(r io.Reader, opts *CSVInput) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	delimiter, err := singleRune("FieldDelimiter", opts.FieldDelimiter, ',')
+	if err != nil {
+		return nil, err
+	}
+	reader.Comma = delimiter
+	if opts.Comments != "" {
+		if reader.Comment, err = singleRune("Comments", opts.Comments, 0); err != nil {
+			return nil, err
+		}
+	}
+	if opts.QuoteCharacter != "" && opts.QuoteCharacter != `"` {
+		return nil, &Error{Code: "InvalidRequestParameter", Message: "Only \" is supported as the CSV QuoteCharacter"}
+	}
+	if opts.QuoteEscapeCharacter != "" && opts.QuoteEscapeCharacter != `"` {
+		return nil, &Error{Code: "InvalidRequestParameter", Message: "Only \" is supported as the CSV QuoteEscapeCharacter"}
+	}
+	if opts.RecordDelimiter != "" && opts.RecordDelimiter != "\n" && opts.RecordDelimiter != "\r\n" {
+		return nil, &Error{Code: "InvalidRequestParameter", Message: "Only \\n and \\r\\n are supported as the CSV RecordDelimiter"}
+	}
+
+	headerInfo := strings.ToUpper(opts.FileHeaderInfo)
+	switch headerInfo {
+	case "":
+		headerInfo = "NONE"
+	case "NONE", "IGNORE", "USE":
+	default:
+		return nil, &Error{Code: "InvalidFileHeaderInfo", Message: fmt.Sprintf("The FileHeaderInfo %s is invalid", opts.FileHeaderInfo)}
+	}
+	if reader.Comma == reader.Comment || strings.ContainsRune("\"\r\n", reader.Comma) {
+		return nil, &Error{Code: "InvalidRequestParameter", Message: "The CSV FieldDelimiter conflicts with the quote or comment character"}
+	}
+	return &csvReader{reader: reader, header: &csvHeader{}, headerInfo: headerInfo}, nil
+}
+
+func (r *csvReader) next() (record, error) {
+	if !r.started {
+		r.started = true
+		if r.headerInfo != "NONE" {
+			names, err := r.read()
+			if err != nil {
+				return nil, err
+			}
+			if r.headerInfo == "USE" {
+				r.header = &csvHeader{names: names, exact: map[string]int{}, folded: map[string]int{}, enabled: true}
+				for i, name := range names {
+					if _, ok := r.header.exact[name]; !ok {
+						r.header.exact[name] = i
+					}
+					if _, ok := r.header.folded[strings.ToLower(name)]; !ok {
+						r.header.folded[strings.ToLower(name)] = i
+					}
+				}
+			}
+		}
+	}
+
+	fields, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	return &csvRecord{fields: fields, header: r.header}, nil
+}
+
+func (r *csvReader) read() ([]string, error) {
+	fields, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &Error{Code: "CSVParsingError", Message: parseErr.Error()}
+		}
+		return nil, err
+	}
+	return fields, nil
+}
+
+// jsonRecord is a value of a JSON object
+type jsonRecord struct {
+	value interface{}
+}
+
+func (r *jsonRecord) get(path []pathElement) interface{} {
+	return walk(r.value, path)
+}
+
+func (r *jsonRecord) values() []interface{} {
+	switch v := r.value.(type) {
+	case *object:
+		values := make([]interface{}, len(v.keys))
+		for i, key := range v.keys {
+			values[i] = v.values[key]
+		}
+		return values
+	case []interface{}:
+		return v
+	}
+	return []interface{}{r.value}
+}
+
+type jsonReader struct {
+	decoder  *json.Decoder
+	path     []pathElement
+	all      bool
+	elements []interface{} // remaining elements of the current array
+}
+
+func newJSONReader(r io.Reader, opts *JSONInput, q *query) (*jsonReader, error) {
+	switch strings.ToUpper(opts.Type) {
+	case "DOCUMENT", "LINES":
+	default:
+		return nil, &Error{Code: "InvalidJsonType", Message: fmt.Sprintf("The JSON Type %s is invalid", opts.Type)}
+	}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return &jsonReader{decoder: decoder, path: q.fromPath, all: q.fromAll}, nil
+}
+
+// next returns the values the FROM path leads to in each document. Lines
+// and documents are both read as a sequence of JSON values.
+func (r *jsonReader) next() (record, error) {
+	for {
+		if len(r.elements) > 0 {
+			v := r.elements[0]
+			r.elements = r.elements[1:]
+			return &jsonRecord{value: v}, nil
+		}
+
+		document, err := decodeJSON(r.decoder)
+		if err != nil {
+			return nil, err
+		}
+		v := walk(document, r.path)
+		if !r.all {
+			return &jsonRecord{value: v}, nil
+		}
+		if elements, ok := v.([]interface{}); ok {
+			r.elements = elements
+		}
+	}
+}
+
+// decodeJSON reads a value, keeping the order of object keys
+func decodeJSON(decoder *json.Decoder) (interface{}, error) {
+	t, err := decoder.Token()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &Error{Code: "JSONParsingError", Message: err.Error()}
+	}
+
+	switch v := t.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			o := newObject()
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, &Error{Code: "JSONParsingError", Message: err.Error()}
+				}
+				value, err := decodeJSON(decoder)
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				o.set(key.(string), value)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, &Error{Code: "JSONParsingError", Message: err.Error()}
+			}
+			return o, nil
+		case '[':
+			array := []interface{}{}
+			for decoder.More() {
+				value, err := decodeJSON(decoder)
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				array = append(array, value)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, &Error{Code: "JSONParsingError", Message: err.Error()}
+			}
+			return array, nil
+		}
+		return nil, &Error{Code: "JSONParsingError", Message: fmt.Sprintf("unexpected %v", v)}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, &Error{Code: "JSONParsingError", Message: err.Error()}
+		}
+		return f, nil
+	default:
+		return v, nil
+	}
+}
+
+// unexpectedEOF reports the end of the input within a value as a parse error
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return &Error{Code: "JSONParsingError", Message: "unexpected end of JSON input"}
+	}
+	return err
+}
+
+// recordWriter formats output records
+type recordWriter interface {
+	write(buf *bytes.Buffer, names []string, values []interface{})
+}
+
+type csvWriter struct {
+	delimiter       string
+	recordDelimiter string
+	quote           string
+	quoteEscape     string
+	always          bool
+}
+
+func newCSVWriter(opts *CSVOutput) (*csvWriter, error) {
+	w := &csvWriter{
+		delimiter:       valueOr(opts.FieldDelimiter, ","),
+		recordDelimiter: valueOr(opts.RecordDelimiter, "\n"),
+		quote:           valueOr(opts.QuoteCharacter, `"`),
+		quoteEscape:     valueOr(opts.QuoteEscapeCharacter, `"`),
+	}
+	switch strings.ToUpper(opts.QuoteFields) {
+	case "", "ASNEEDED":
+	case "ALWAYS":
+		w.always = true
+	default:
+		return nil, &Error{Code: "InvalidQuoteFields", Message: fmt.Sprintf("The QuoteFields %s is invalid", opts.QuoteFields)}
+	}
+	return w, nil
+}
+
+func (w *csvWriter) write(buf *bytes.Buffer, _ []string, values []interface{}) {
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteString(w.delimiter)
+		}
+		field := toString(v)
+		if w.always || strings.Contains(field, w.delimiter) || strings.Contains(field, w.quote) ||
+			strings.ContainsAny(field, "\r\n") || strings.Contains(field, w.recordDelimiter) {
+			buf.WriteString(w.quote)
+			buf.WriteString(strings.ReplaceAll(field, w.quote, w.quoteEscape+w.quote))
+			buf.WriteString(w.quote)
+		} else {
+			buf.WriteString(field)
+		}
+	}
+	buf.WriteString(w.recordDelimiter)
+}
+
+type jsonWriter struct {
+	recordDelimiter string
+}
+
+// write leaves out fields without a value, as MISSING and NULL are the same
+// to the evaluator
+func (w *jsonWriter) write(buf *bytes.Buffer, names []string, values []interface{}) {
+	buf.WriteByte('{')
+	first := true
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		appendJSONString(buf, names[i])
+		buf.WriteByte(':')
+		appendJSON(buf, v)
+	}
+	buf.WriteByte('}')
+	buf.WriteString(w.recordDelimiter)
+}
+
+// textWriter is implemented by bytes.Buffer and strings.Builder
+type textWriter interface {
+	io.ByteWriter
+	io.StringWriter
+}
+
+// appendJSON writes a value as JSON, without escaping HTML characters
+func appendJSON(w textWriter, v interface{}) {
+	switch value := v.(type) {
+	case nil:
+		w.WriteString("null")
+	case bool:
+		w.WriteString(strconv.FormatBool(value))
+	case int64:
+		w.WriteString(strconv.FormatInt(value, 10))
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			w.WriteString("null")
+		} else {
+			w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+		}
+	case string:
+		appendJSONString(w, value)
+	case *object:
+		w.WriteByte('{')
+		for i, key := range value.keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			appendJSONString(w, key)
+			w.WriteByte(':')
+			appendJSON(w, value.values[key])
+		}
+		w.WriteByte('}')
+	case []interface{}:
+		w.WriteByte('[')
+		for i, element := range value {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			appendJSON(w, element)
+		}
+		w.WriteByte(']')
+	}
+}
+
+func appendJSONString(w textWriter, s string) {
+	const hex = "0123456789abcdef"
+	w.WriteByte('"')
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case c == '"' || c == '\\':
+			w.WriteByte('\\')
+			w.WriteByte(byte(c))
+		case c == '\n':
+			w.WriteString(`\n`)
+		case c == '\r':
+			w.WriteString(`\r`)
+		case c == '\t':
+			w.WriteString(`\t`)
+		case c < 0x20:
+			w.WriteString(`\u00`)
+			w.WriteByte(hex[c>>4])
+			w.WriteByte(hex[c&0xf])
+		default:
+			// Invalid UTF-8 was decoded as the replacement character
+			w.WriteString(string(c))
+		}
+	}
+	w.WriteByte('"')
+}
+
+func singleRune(name, value string, fallback rune) (rune, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, &Error{Code: "InvalidRequestParameter", Message: fmt.Sprintf("The %s must be a single character", name)}
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, nil
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,280 @@
+// Package s3select runs S3 Select queries, a subset of SQL, over CSV and JSON
+// objects.
+package s3select
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// chunkSize is the size of output at which records are emitted
+const chunkSize = 64 * 1024
+
+// Error is a failure of a query, with the S3 error code it is reported as
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Options describes a query and the format of its input and output. Exactly
+// one input and one output format must be set.
+type Options struct {
+	Expression string
+	Input      InputSerialization
+	Output     OutputSerialization
+}
+
+// InputSerialization describes the format of the object
+type InputSerialization struct {
+	CompressionType string // NONE, GZIP or BZIP2
+	CSV             *CSVInput
+	JSON            *JSONInput
+}
+
+// CSVInput describes CSV objects
+type CSVInput struct {
+	FileHeaderInfo       string // NONE, IGNORE or USE
+	Comments             string
+	QuoteEscapeCharacter string
+	RecordDelimiter      string
+	FieldDelimiter       string
+	QuoteCharacter       string
+}
+
+// JSONInput describes JSON objects
+type JSONInput struct {
+	Type string // DOCUMENT or LINES
+}
+
+// OutputSerialization describes the format of the records returned
+type OutputSerialization struct {
+	CSV  *CSVOutput
+	JSON *JSONOutput
+}
+
+// CSVOutput describes CSV output
+type CSVOutput struct {
+	QuoteFields          string // ALWAYS or ASNEEDED
+	QuoteEscapeCharacter string
+	RecordDelimiter      string
+	FieldDelimiter       string
+	QuoteCharacter       string
+}
+
+// JSONOutput describes JSON output
+type JSONOutput struct {
+	RecordDelimiter string
+}
+
+// Stats counts the bytes read and returned by a query
+type Stats struct {
+	BytesScanned   int64 // bytes of the object, as stored
+	BytesProcessed int64 // bytes after decompression
+	BytesReturned  int64
+}
+
+// Select is a validated query ready to run
+type Select struct {
+	opts   Options
+	query  *query
+	writer recordWriter
+}
+
+// New parses the expression and checks the options, so that requests can be
+// rejected before any output is sent
+func New(opts Options) (*Select, error) {
+	if (opts.Input.CSV == nil) == (opts.Input.JSON == nil) {
+		return nil, &Error{Code: "InvalidRequestParameter", Message: "The InputSerialization must specify exactly one of CSV and JSON"}
+	}
+	if (opts.Output.CSV == nil) == (opts.Output.JSON == nil) {
+		return nil, &Error{Code: "InvalidRequestParameter", Message: "The OutputSerialization must specify exactly one of CSV and JSON"}
+	}
+	switch strings.ToUpper(opts.Input.CompressionType) {
+	case "", "NONE", "GZIP", "BZIP2":
+	default:
+		return nil, &Error{Code: "InvalidCompressionFormat", Message: fmt.Sprintf("The CompressionType %s is invalid", opts.Input.CompressionType)}
+	}
+
+	q, err := parse(opts.Expression)
+	if err != nil {
+		return nil, err
+	}
+	s := &Select{opts: opts, query: q}
+
+	// Check the input options now rather than once the object is read
+	if opts.Input.CSV != nil {
+		if _, err := newCSVReader(strings.NewReader(""), opts.Input.CSV); err != nil {
+			return nil, err
+		}
+		if len(q.fromPath) > 0 || q.fromAll {
+			return nil, &Error{Code: "UnsupportedSyntax", Message: "CSV objects do not support paths in the FROM clause"}
+		}
+	} else if _, err := newJSONReader(strings.NewReader(""), opts.Input.JSON, q); err != nil {
+		return nil, err
+	}
+
+	if opts.Output.CSV != nil {
+		if s.writer, err = newCSVWriter(opts.Output.CSV); err != nil {
+			return nil, err
+		}
+	} else {
+		s.writer = &jsonWriter{recordDelimiter: valueOr(opts.Output.JSON.RecordDelimiter, "\n")}
+	}
+	return s, nil
+}
+
+// Run reads the object from r and passes the output to emit in chunks. The
+// stats are returned even if the query failed part way.
+func (s *Select) Run(r io.Reader, emit func([]byte) error) (*Stats, error) {
+	stats := &Stats{}
+	scanned := &countingReader{r: r, n: &stats.BytesScanned}
+
+	var input io.Reader = scanned
+	switch strings.ToUpper(s.opts.Input.CompressionType) {
+	case "GZIP":
+		gz, err := gzip.NewReader(scanned)
+		if err != nil {
+			return stats, &Error{Code: "InvalidCompressionFormat", Message: "The object is not GZIP compressed: " + err.Error()}
+		}
+		defer gz.Close()
+		input = gz
+	case "BZIP2":
+		input = bzip2.NewReader(scanned)
+	}
+	input = &countingReader{r: input, n: &stats.BytesProcessed}
+
+	var records recordReader
+	var err error
+	if s.opts.Input.CSV != nil {
+		records, err = newCSVReader(input, s.opts.Input.CSV)
+	} else {
+		records, err = newJSONReader(input, s.opts.Input.JSON, s.query)
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	var buf bytes.Buffer
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		stats.BytesReturned += int64(buf.Len())
+		err := emit(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+
+	q := s.query
+	var returned int64
+	for q.limit < 0 || returned < q.limit || len(q.aggregates) > 0 {
+		rec, err := records.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, s.readError(err)
+		}
+
+		if q.where != nil {
+			matched, err := q.where.eval(rec)
+			if err != nil {
+				return stats, err
+			}
+			if !truthy(matched) {
+				continue
+			}
+		}
+
+		if len(q.aggregates) > 0 {
+			for _, aggregate := range q.aggregates {
+				if err := aggregate.add(rec); err != nil {
+					return stats, err
+				}
+			}
+			continue
+		}
+
+		if err := s.project(&buf, rec); err != nil {
+			return stats, err
+		}
+		returned++
+		if buf.Len() >= chunkSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	// Aggregates produce a single row once every record was seen
+	if len(q.aggregates) > 0 && q.limit != 0 {
+		if err := s.project(&buf, &jsonRecord{}); err != nil {
+			return stats, err
+		}
+	}
+	return stats, flush()
+}
+
+// project writes the output of a record
+func (s *Select) project(buf *bytes.Buffer, rec record) error {
+	if s.query.star {
+		if s.opts.Output.CSV != nil {
+			s.writer.write(buf, nil, rec.values())
+			return nil
+		}
+		whole, ok := rec.get(nil).(*object)
+		if !ok {
+			s.writer.write(buf, []string{"_1"}, []interface{}{rec.get(nil)})
+			return nil
+		}
+		values := make([]interface{}, len(whole.keys))
+		for i, key := range whole.keys {
+			values[i] = whole.values[key]
+		}
+		s.writer.write(buf, whole.keys, values)
+		return nil
+	}
+
+	names := make([]string, len(s.query.projections))
+	values := make([]interface{}, len(s.query.projections))
+	for i, item := range s.query.projections {
+		v, err := item.expr.eval(rec)
+		if err != nil {
+			return err
+		}
+		names[i], values[i] = item.name, v
+	}
+	s.writer.write(buf, names, values)
+	return nil
+}
+
+// readError reports failures to decompress the object as such
+func (s *Select) readError(err error) error {
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	if compression := strings.ToUpper(s.opts.Input.CompressionType); compression == "GZIP" || compression == "BZIP2" {
+		return &Error{Code: "InvalidCompressionFormat", Message: fmt.Sprintf("The object could not be decompressed as %s: %v", compression, err)}
+	}
+	return err
+}
+
+// countingReader adds the number of bytes read to n
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
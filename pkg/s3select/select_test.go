@@ -0,0 +1,211 @@
+package s3select
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const people = `name,age,city
+Alice,30,Paris
+Bob,25,"New York, NY"
+Carol,41,Berlin
+Dave,,Paris
+`
+
+const orders = `{"id":1,"customer":{"name":"Alice"},"total":12.5,"items":["a","b"]}
+{"id":2,"customer":{"name":"Bob"},"total":20,"items":[]}
+{"id":3,"customer":{"name":"alice <a@b>"},"total":7.25}
+`
+
+func run(t *testing.T, opts Options, input string) (string, *Stats) {
+	t.Helper()
+	s, err := New(opts)
+	if err != nil {
+		t.Fatalf("New(%q) error = %v", opts.Expression, err)
+	}
+	var out bytes.Buffer
+	stats, err := s.Run(strings.NewReader(input), func(b []byte) error {
+		out.Write(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run(%q) error = %v", opts.Expression, err)
+	}
+	return out.String(), stats
+}
+
+func csvOptions(expression, headerInfo string) Options {
+	return Options{
+		Expression: expression,
+		Input:      InputSerialization{CSV: &CSVInput{FileHeaderInfo: headerInfo}},
+		Output:     OutputSerialization{CSV: &CSVOutput{}},
+	}
+}
+
+func jsonOptions(expression string) Options {
+	return Options{
+		Expression: expression,
+		Input:      InputSerialization{JSON: &JSONInput{Type: "LINES"}},
+		Output:     OutputSerialization{JSON: &JSONOutput{}},
+	}
+}
+
+func TestSelect_CSV(t *testing.T) {
+	tests := []struct {
+		expression string
+		headerInfo string
+		want       string
+	}{
+		{"SELECT * FROM S3Object", "USE", "Alice,30,Paris\nBob,25,\"New York, NY\"\nCarol,41,Berlin\nDave,,Paris\n"},
+		{"SELECT * FROM S3Object LIMIT 1", "NONE", "name,age,city\n"},
+		{"SELECT s.name FROM S3Object s WHERE s.city = 'Paris'", "USE", "Alice\nDave\n"},
+		{"SELECT name, age FROM S3Object WHERE age <> '' AND CAST(age AS INT) > 26", "USE", "Alice,30\nCarol,41\n"},
+		{"SELECT _1 FROM S3Object WHERE _2 >= 30 AND _3 <> 'Berlin'", "IGNORE", "Alice\n"},
+		{"SELECT NAME FROM S3Object WHERE city LIKE 'New%' OR name LIKE '_aro_'", "USE", "Bob\nCarol\n"},
+		{`SELECT "name" FROM S3Object WHERE age IS NULL OR age = ''`, "USE", "Dave\n"},
+		{`SELECT "Name" FROM S3Object LIMIT 1`, "USE", "\n"},
+		{"SELECT UPPER(name) || '!' FROM S3Object WHERE age BETWEEN 25 AND 30", "USE", "ALICE!\nBOB!\n"},
+		{"SELECT name FROM S3Object WHERE city IN ('Berlin', 'Rome') OR NOT age < 41", "USE", "Carol\n"},
+		{"SELECT COUNT(*), SUM(age), MIN(age), MAX(name), AVG(CAST(age AS INT)) FROM S3Object WHERE age != ''", "USE", "3,96,25,Carol,32\n"},
+		{"SELECT COUNT(age) FROM S3Object s WHERE s.city = 'Paris'", "USE", "2\n"},
+		{"SELECT name FROM S3Object LIMIT 2", "USE", "Alice\nBob\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, _ := run(t, csvOptions(tt.expression, tt.headerInfo), people)
+			if got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect_JSON(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"SELECT * FROM S3Object[*] s WHERE s.id = 2", `{"id":2,"customer":{"name":"Bob"},"total":20,"items":[]}` + "\n"},
+		{"SELECT s.id, s.customer.name AS who FROM S3Object s WHERE s.total < 15", `{"id":1,"who":"Alice"}` + "\n" + `{"id":3,"who":"alice <a@b>"}` + "\n"},
+		{"SELECT s.items[1] FROM S3Object s", `{"_1":"b"}` + "\n{}\n{}\n"},
+		{"SELECT s.id FROM S3Object s WHERE LOWER(s.customer.name) LIKE 'alice%'", `{"id":1}` + "\n" + `{"id":3}` + "\n"},
+		{"SELECT SUM(s.total), COUNT(*) FROM S3Object s WHERE s.items IS NOT MISSING", `{"_1":32.5,"_2":2}` + "\n"},
+		{"SELECT s.id * 10 + 1 AS n FROM S3Object s LIMIT 1", `{"n":11}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, _ := run(t, jsonOptions(tt.expression), orders)
+			if got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect_JSONDocumentPath(t *testing.T) {
+	opts := jsonOptions("SELECT e.name FROM S3Object[*].employees[*] e WHERE e.age > 30")
+	opts.Input.JSON.Type = "DOCUMENT"
+	opts.Output = OutputSerialization{CSV: &CSVOutput{QuoteFields: "ALWAYS"}}
+	got, _ := run(t, opts, `{"employees":[{"name":"Ann","age":35},{"name":"Ben","age":28},{"name":"Cy \"C\"","age":50}]}`)
+	if want := "\"Ann\"\n\"Cy \"\"C\"\"\"\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestSelect_Stats(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(people))
+	gz.Close()
+
+	opts := csvOptions("SELECT name FROM S3Object WHERE city = 'Paris'", "USE")
+	opts.Input.CompressionType = "GZIP"
+	got, stats := run(t, opts, compressed.String())
+	if got != "Alice\nDave\n" {
+		t.Errorf("output = %q", got)
+	}
+	if stats.BytesScanned != int64(compressed.Len()) || stats.BytesProcessed != int64(len(people)) || stats.BytesReturned != int64(len(got)) {
+		t.Errorf("stats = %+v, want %d scanned, %d processed, %d returned", stats, compressed.Len(), len(people), len(got))
+	}
+}
+
+func TestSelect_Chunks(t *testing.T) {
+	input := strings.Repeat("0123456789abcdef\n", 10000)
+	s, err := New(csvOptions("SELECT * FROM S3Object", "NONE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks, total int
+	if _, err := s.Run(strings.NewReader(input), func(b []byte) error {
+		chunks++
+		total += len(b)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if chunks < 2 || total != len(input) {
+		t.Errorf("emitted %d bytes in %d chunks, want %d bytes in several", total, chunks, len(input))
+	}
+}
+
+func TestSelect_Errors(t *testing.T) {
+	tests := []struct {
+		opts Options
+		code string
+	}{
+		{csvOptions("SELEKT * FROM S3Object", "USE"), "ParseUnexpectedToken"},
+		{csvOptions("SELECT * FROM S3Object WHERE", "USE"), "ParseUnexpectedToken"},
+		{csvOptions("SELECT * FROM S3Object WHERE name = 'unterminated", "USE"), "ParseUnexpectedToken"},
+		{csvOptions("SELECT name, COUNT(*) FROM S3Object", "USE"), "UnsupportedSyntax"},
+		{csvOptions("SELECT * FROM S3Object WHERE COUNT(*) > 1", "USE"), "UnsupportedSyntax"},
+		{csvOptions("SELECT SUBSTRING(name, 1) FROM S3Object", "USE"), "UnsupportedFunction"},
+		{csvOptions("SELECT _0 FROM S3Object", "NONE"), "InvalidColumnIndex"},
+		{csvOptions("SELECT * FROM S3Object", "FIRST"), "InvalidFileHeaderInfo"},
+		{csvOptions("SELECT * FROM S3Object s.a", "USE"), "ParseUnexpectedToken"},
+		{csvOptions("SELECT * FROM S3Object[*].a", "USE"), "UnsupportedSyntax"},
+		{Options{Expression: "SELECT * FROM S3Object", Input: InputSerialization{JSON: &JSONInput{Type: "XML"}}, Output: OutputSerialization{JSON: &JSONOutput{}}}, "InvalidJsonType"},
+		{Options{Expression: "SELECT * FROM S3Object", Input: InputSerialization{CSV: &CSVInput{}}}, "InvalidRequestParameter"},
+		{Options{Expression: "SELECT * FROM S3Object", Input: InputSerialization{CSV: &CSVInput{}, CompressionType: "ZSTD"}, Output: OutputSerialization{CSV: &CSVOutput{}}}, "InvalidCompressionFormat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.opts.Expression, func(t *testing.T) {
+			_, err := New(tt.opts)
+			var selectErr *Error
+			if !errors.As(err, &selectErr) || selectErr.Code != tt.code {
+				t.Errorf("New() error = %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestSelect_RuntimeErrors(t *testing.T) {
+	tests := []struct {
+		opts  Options
+		input string
+		code  string
+	}{
+		{csvOptions("SELECT CAST(name AS INT) FROM S3Object", "USE"), people, "CastFailed"},
+		{csvOptions("SELECT CAST(age AS INT) / 0 FROM S3Object", "USE"), people, "DivisionByZero"},
+		{csvOptions("SELECT CAST(age AS INT) * 9223372036854775807 FROM S3Object", "USE"), people, "IntegerOverflow"},
+		{csvOptions("SELECT -9223372036854775807 - CAST(age AS INT) FROM S3Object", "USE"), people, "IntegerOverflow"},
+		{jsonOptions("SELECT SUM(s.a) FROM S3Object s"), `{"a":9223372036854775807}` + "\n" + `{"a":1}`, "IntegerOverflow"},
+		{csvOptions("SELECT * FROM S3Object", "NONE"), "a,\"b\nc", "CSVParsingError"},
+		{jsonOptions("SELECT * FROM S3Object"), `{"a":1}` + "\n" + `{"a":`, "JSONParsingError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.opts.Expression, func(t *testing.T) {
+			s, err := New(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.Run(strings.NewReader(tt.input), func([]byte) error { return nil })
+			var selectErr *Error
+			if !errors.As(err, &selectErr) || selectErr.Code != tt.code {
+				t.Errorf("Run() error = %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,714 @@
+package s3select
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Token kinds of the SQL lexer
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// reserved lists the keywords that cannot be used as bare aliases
+var reserved = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "ESCAPE": true,
+	"IS": true, "NULL": true, "MISSING": true, "TRUE": true, "FALSE": true,
+	"BETWEEN": true, "IN": true, "CAST": true,
+}
+
+// lex splits an expression into tokens
+func lex(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(input) && (input[i] == '_' || unicode.IsLetter(rune(input[i])) || unicode.IsDigit(rune(input[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: input[start:i], pos: start})
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(input) && input[i+1] >= '0' && input[i+1] <= '9':
+			start := i
+			for i < len(input) && (input[i] >= '0' && input[i] <= '9' || input[i] == '.') {
+				i++
+			}
+			if i < len(input) && (input[i] == 'e' || input[i] == 'E') {
+				i++
+				if i < len(input) && (input[i] == '+' || input[i] == '-') {
+					i++
+				}
+				for i < len(input) && input[i] >= '0' && input[i] <= '9' {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: input[start:i], pos: start})
+		case c == '\'' || c == '"':
+			// Quotes are escaped by doubling them
+			start := i
+			var text strings.Builder
+			for i++; ; i++ {
+				if i >= len(input) {
+					return nil, parseError(start, "unterminated quoted string")
+				}
+				if input[i] == c {
+					if i+1 < len(input) && input[i+1] == c {
+						text.WriteByte(c)
+						i++
+						continue
+					}
+					i++
+					break
+				}
+				text.WriteByte(input[i])
+			}
+			kind := tokenString
+			if c == '"' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: text.String(), pos: start})
+		default:
+			start := i
+			symbol := string(c)
+			if i+1 < len(input) {
+				switch two := input[i : i+2]; two {
+				case "<=", ">=", "<>", "!=", "||":
+					symbol = two
+				}
+			}
+			if !strings.Contains("=<>!|+-*/%(),.[]", symbol[:1]) || symbol == "!" || symbol == "|" {
+				return nil, parseError(start, fmt.Sprintf("unexpected character %q", c))
+			}
+			i += len(symbol)
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol, pos: start})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+func parseError(pos int, message string) error {
+	return &Error{Code: "ParseUnexpectedToken", Message: fmt.Sprintf("%s at position %d", message, pos+1)}
+}
+
+// query is a parsed SELECT statement
+type query struct {
+	star        bool
+	projections []projection
+	alias       string
+	fromPath    []pathElement // path within each JSON document whose elements are the records
+	fromAll     bool          // the path ends with [*], so array elements are the records
+	where       expr
+	limit       int64 // -1 if there is no limit
+	aggregates  []*aggregateExpr
+	columns     []*columnExpr
+}
+
+// projection is an expression of the SELECT list and its output name
+type projection struct {
+	expr expr
+	name string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	query  *query
+
+	// aggregateDepth is non-zero while parsing the arguments of an aggregate
+	aggregateDepth int
+	// inSelect is set while parsing the SELECT list
+	inSelect bool
+	// bareColumn is set when the SELECT list refers to a column outside of
+	// an aggregate
+	bareColumn bool
+}
+
+// parse parses a SELECT statement of the supported subset of SQL
+func parse(expression string) (*query, error) {
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, query: &query{limit: -1}}
+	if err := p.parseQuery(); err != nil {
+		return nil, err
+	}
+	return p.query, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isKeyword reports whether the next token is the given keyword
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+// acceptKeyword consumes the next token if it is the given keyword
+func (p *parser) acceptKeyword(keyword string) bool {
+	if p.isKeyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// acceptSymbol consumes the next token if it is the given symbol
+func (p *parser) acceptSymbol(symbol string) bool {
+	if t := p.peek(); t.kind == tokenSymbol && t.text == symbol {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.acceptKeyword(keyword) {
+		return p.unexpected("expected " + keyword)
+	}
+	return nil
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return p.unexpected("expected " + symbol)
+	}
+	return nil
+}
+
+// unexpected returns the error for the next token
+func (p *parser) unexpected(message string) error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return parseError(t.pos, message+", found end of expression")
+	}
+	return parseError(t.pos, fmt.Sprintf("%s, found %q", message, t.text))
+}
+
+func (p *parser) parseQuery() error {
+	q := p.query
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return err
+	}
+
+	if p.acceptSymbol("*") {
+		q.star = true
+	} else {
+		p.inSelect = true
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return err
+			}
+			item := projection{expr: e}
+			if p.acceptKeyword("AS") {
+				t := p.next()
+				if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+					p.pos--
+					return p.unexpected("expected an alias")
+				}
+				item.name = t.text
+			} else if t := p.peek(); t.kind == tokenQuotedIdent || t.kind == tokenIdent && !reserved[strings.ToUpper(t.text)] {
+				item.name = p.next().text
+			}
+			q.projections = append(q.projections, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		p.inSelect = false
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return err
+	}
+	if err := p.parseFrom(); err != nil {
+		return err
+	}
+
+	if p.acceptKeyword("WHERE") {
+		aggregates := len(q.aggregates)
+		where, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		if len(q.aggregates) > aggregates {
+			return &Error{Code: "UnsupportedSyntax", Message: "Aggregate functions are not allowed in the WHERE clause"}
+		}
+		q.where = where
+	}
+
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+		limit, err := strconv.ParseInt(t.text, 10, 64)
+		if t.kind != tokenNumber || err != nil || limit < 0 {
+			p.pos--
+			return p.unexpected("expected a non-negative integer limit")
+		}
+		q.limit = limit
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return p.unexpected("expected end of expression")
+	}
+	if len(q.aggregates) > 0 && p.bareColumn {
+		return &Error{Code: "UnsupportedSyntax", Message: "Columns must be used within aggregate functions when the SELECT list has aggregates"}
+	}
+
+	// Names of the form alias.column refer to the record the alias names
+	for _, column := range q.columns {
+		if len(column.path) > 0 && column.path[0].index < 0 && !column.path[0].quoted &&
+			(q.alias != "" && strings.EqualFold(column.path[0].name, q.alias) || strings.EqualFold(column.path[0].name, "S3Object")) {
+			column.path = column.path[1:]
+		}
+	}
+	for i := range q.projections {
+		if q.projections[i].name != "" {
+			continue
+		}
+		if column, ok := q.projections[i].expr.(*columnExpr); ok && len(column.path) > 0 && column.path[len(column.path)-1].index < 0 {
+			q.projections[i].name = column.path[len(column.path)-1].name
+		} else {
+			q.projections[i].name = "_" + strconv.Itoa(i+1)
+		}
+	}
+	return nil
+}
+
+// parseFrom parses S3Object with an optional path into JSON documents and
+// an optional alias
+func (p *parser) parseFrom() error {
+	t := p.next()
+	if t.kind != tokenIdent || !strings.EqualFold(t.text, "S3Object") {
+		p.pos--
+		return p.unexpected("expected S3Object")
+	}
+	// A wildcard right after S3Object stands for the documents themselves,
+	// and one ending the path for the elements of the array it leads to
+	leading := true
+path:
+	for {
+		switch {
+		case p.acceptSymbol("["):
+			if p.query.fromAll {
+				return &Error{Code: "UnsupportedSyntax", Message: "Only a single wildcard ending the FROM path is supported"}
+			}
+			if p.acceptSymbol("*") {
+				p.query.fromAll = !leading
+			} else {
+				index, err := p.parseIndex()
+				if err != nil {
+					return err
+				}
+				p.query.fromPath = append(p.query.fromPath, pathElement{index: index})
+			}
+			if err := p.expectSymbol("]"); err != nil {
+				return err
+			}
+		case p.acceptSymbol("."):
+			if p.query.fromAll {
+				return &Error{Code: "UnsupportedSyntax", Message: "Only a single wildcard ending the FROM path is supported"}
+			}
+			t := p.next()
+			if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+				p.pos--
+				return p.unexpected("expected a path element")
+			}
+			p.query.fromPath = append(p.query.fromPath, pathElement{name: t.text, quoted: t.kind == tokenQuotedIdent, index: -1})
+		default:
+			break path
+		}
+		leading = false
+	}
+
+	if p.acceptKeyword("AS") {
+		t := p.next()
+		if t.kind != tokenIdent {
+			p.pos--
+			return p.unexpected("expected an alias")
+		}
+		p.query.alias = t.text
+	} else if t := p.peek(); t.kind == tokenIdent && !reserved[strings.ToUpper(t.text)] {
+		p.query.alias = p.next().text
+	}
+	return nil
+}
+
+func (p *parser) parseIndex() (int, error) {
+	t := p.next()
+	index, err := strconv.Atoi(t.text)
+	if t.kind != tokenNumber || err != nil || index < 0 {
+		p.pos--
+		return 0, p.unexpected("expected an array index")
+	}
+	return index, nil
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{operand: operand}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind == tokenSymbol {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &compareExpr{op: t.text, left: left, right: right}, nil
+		}
+	}
+
+	if p.acceptKeyword("IS") {
+		negate := p.acceptKeyword("NOT")
+		if !p.acceptKeyword("NULL") && !p.acceptKeyword("MISSING") {
+			return nil, p.unexpected("expected NULL or MISSING")
+		}
+		return &isNullExpr{operand: left, negate: negate}, nil
+	}
+
+	negate := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		like := &likeExpr{operand: left, pattern: pattern, negate: negate}
+		if p.acceptKeyword("ESCAPE") {
+			if like.escape, err = p.parseAdditive(); err != nil {
+				return nil, err
+			}
+		}
+		return like, nil
+	case p.acceptKeyword("BETWEEN"):
+		low, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{operand: left, low: low, high: high, negate: negate}, nil
+	case p.acceptKeyword("IN"):
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		in := &inExpr{operand: left, negate: negate}
+		for {
+			item, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return in, nil
+	}
+	if negate {
+		return nil, p.unexpected("expected LIKE, BETWEEN or IN")
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenSymbol || t.text != "+" && t.text != "-" && t.text != "||" {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenSymbol || t.text != "*" && t.text != "/" && t.text != "%" {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.acceptSymbol("-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithmeticExpr{op: "-", left: &literalExpr{value: int64(0)}, right: operand}, nil
+	}
+	p.acceptSymbol("+")
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		if value, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literalExpr{value: value}, nil
+		}
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, parseError(t.pos, fmt.Sprintf("invalid number %q", t.text))
+		}
+		return &literalExpr{value: value}, nil
+	case tokenString:
+		return &literalExpr{value: t.text}, nil
+	case tokenSymbol:
+		if t.text == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expectSymbol(")")
+		}
+	case tokenQuotedIdent:
+		return p.parsePath(t)
+	case tokenIdent:
+		switch keyword := strings.ToUpper(t.text); keyword {
+		case "TRUE", "FALSE":
+			return &literalExpr{value: keyword == "TRUE"}, nil
+		case "NULL", "MISSING":
+			return &literalExpr{value: nil}, nil
+		case "CAST":
+			return p.parseCast()
+		}
+		if p.acceptSymbol("(") {
+			return p.parseCall(t)
+		}
+		if reserved[strings.ToUpper(t.text)] {
+			break
+		}
+		return p.parsePath(t)
+	}
+	p.pos--
+	return nil, p.unexpected("expected an expression")
+}
+
+// parsePath parses a column reference starting with the given name
+func (p *parser) parsePath(first token) (expr, error) {
+	column := &columnExpr{path: []pathElement{{name: first.text, quoted: first.kind == tokenQuotedIdent, index: -1}}}
+	for {
+		if p.acceptSymbol(".") {
+			t := p.next()
+			if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+				p.pos--
+				return nil, p.unexpected("expected a path element")
+			}
+			column.path = append(column.path, pathElement{name: t.text, quoted: t.kind == tokenQuotedIdent, index: -1})
+			continue
+		}
+		if p.acceptSymbol("[") {
+			index, err := p.parseIndex()
+			if err != nil {
+				return nil, err
+			}
+			column.path = append(column.path, pathElement{index: index})
+			if err := p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		break
+	}
+
+	if first.kind == tokenIdent && len(first.text) > 1 && first.text[0] == '_' {
+		if n, err := strconv.Atoi(first.text[1:]); err == nil && n < 1 {
+			return nil, &Error{Code: "InvalidColumnIndex", Message: fmt.Sprintf("The column index %s is invalid, column indexes start at _1", first.text)}
+		}
+	}
+	if p.inSelect && p.aggregateDepth == 0 {
+		p.bareColumn = true
+	}
+	p.query.columns = append(p.query.columns, column)
+	return column, nil
+}
+
+func (p *parser) parseCast() (expr, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	operand, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("AS"); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	var to string
+	switch strings.ToUpper(t.text) {
+	case "INT", "INTEGER", "BIGINT", "SMALLINT":
+		to = "INT"
+	case "FLOAT", "DOUBLE", "REAL", "DECIMAL", "NUMERIC":
+		to = "FLOAT"
+	case "STRING", "VARCHAR", "CHAR", "TEXT":
+		to = "STRING"
+	case "BOOL", "BOOLEAN":
+		to = "BOOL"
+	default:
+		if t.kind != tokenIdent {
+			p.pos--
+			return nil, p.unexpected("expected a type")
+		}
+		return nil, &Error{Code: "UnsupportedSyntax", Message: fmt.Sprintf("Casts to %s are not supported", t.text)}
+	}
+	return &castExpr{operand: operand, to: to}, p.expectSymbol(")")
+}
+
+// parseCall parses the arguments of a function or aggregate call
+func (p *parser) parseCall(name token) (expr, error) {
+	function := strings.ToUpper(name.text)
+	switch function {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		if !p.inSelect {
+			return nil, &Error{Code: "UnsupportedSyntax", Message: "Aggregate functions are only allowed in the SELECT list"}
+		}
+		if p.aggregateDepth > 0 {
+			return nil, &Error{Code: "UnsupportedSyntax", Message: "Aggregate functions cannot be nested"}
+		}
+		aggregate := &aggregateExpr{function: function}
+		if function == "COUNT" && p.acceptSymbol("*") {
+			aggregate.star = true
+		} else {
+			p.aggregateDepth++
+			operand, err := p.parseExpr()
+			p.aggregateDepth--
+			if err != nil {
+				return nil, err
+			}
+			aggregate.operand = operand
+		}
+		p.query.aggregates = append(p.query.aggregates, aggregate)
+		return aggregate, p.expectSymbol(")")
+	case "LOWER", "UPPER", "TRIM", "CHAR_LENGTH", "CHARACTER_LENGTH", "COALESCE":
+	default:
+		return nil, &Error{Code: "UnsupportedFunction", Message: fmt.Sprintf("The function %s is not supported", name.text)}
+	}
+
+	call := &callExpr{function: function}
+	if !p.acceptSymbol(")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+	if function != "COALESCE" && len(call.args) != 1 || len(call.args) == 0 {
+		return nil, &Error{Code: "EvaluatorInvalidArguments", Message: fmt.Sprintf("Wrong number of arguments to %s", name.text)}
+	}
+	return call, nil
+}
@@ -0,0 +1,392 @@
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Algorithm is the only signing algorithm supported
+	Algorithm = "AWS4-HMAC-SHA256"
+
+	// TimeFormat is the layout of X-Amz-Date values
+	TimeFormat = "20060102T150405Z"
+	// DateFormat is the layout of the date component of a credential scope
+	DateFormat = "20060102"
+
+	// UnsignedPayload marks a request whose body is not covered by the signature
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// EmptySHA256 is the hex encoded SHA-256 of an empty payload
+	EmptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// MaxClockSkew is the maximum allowed difference between request time and server time
+	MaxClockSkew = 15 * time.Minute
+
+	// ServiceS3 is the service name used in S3 credential scopes
+	ServiceS3 = "s3"
+
+	terminator = "aws4_request"
+)
+
+// Credential is the parsed Credential component of a signature
+type Credential struct {
+	AccessKey string
+	Date      string
+	Region    string
+	Service   string
+	Terminal  string
+}
+
+// Scope returns the credential scope (date/region/service/aws4_request)
+func (c Credential) Scope() string {
+	return strings.Join([]string{c.Date, c.Region, c.Service, c.Terminal}, "/")
+}
+
+// String returns the credential in its wire format
+func (c Credential) String() string {
+	return c.AccessKey + "/" + c.Scope()
+}
+
+// ParseCredential parses AKID/date/region/service/aws4_request
+func ParseCredential(value string) (Credential, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 5 {
+		return Credential{}, fmt.Errorf("credential %q must have five components", value)
+	}
+	cred := Credential{
+		AccessKey: parts[0],
+		Date:      parts[1],
+		Region:    parts[2],
+		Service:   parts[3],
+		Terminal:  parts[4],
+	}
+	if cred.AccessKey == "" {
+		return Credential{}, fmt.Errorf("credential %q has an empty access key", value)
+	}
+	if _, err := time.Parse(DateFormat, cred.Date); err != nil {
+		return Credential{}, fmt.Errorf("credential %q has an invalid date", value)
+	}
+	if cred.Terminal != terminator {
+		return Credential{}, fmt.Errorf("credential %q must end with %s", value, terminator)
+	}
+	return cred, nil
+}
+
+// Authorization is a parsed AWS4-HMAC-SHA256 Authorization header
+type Authorization struct {
+	Credential    Credential
+	SignedHeaders []string
+	Signature     string
+}
+
+// ParseAuthorization parses an Authorization header of the form
+// AWS4-HMAC-SHA256 Credential=..., SignedHeaders=..., Signature=...
+func ParseAuthorization(header string) (*Authorization, error) {
+	if !strings.HasPrefix(header, Algorithm+" ") {
+		return nil, fmt.Errorf("unsupported authorization algorithm")
+	}
+
+	auth := &Authorization{}
+	var hasCredential bool
+	for _, part := range strings.Split(strings.TrimPrefix(header, Algorithm+" "), ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed authorization component %q", part)
+		}
+		switch key {
+		case "Credential":
+			cred, err := ParseCredential(value)
+			if err != nil {
+				return nil, err
+			}
+			auth.Credential = cred
+			hasCredential = true
+		case "SignedHeaders":
+			auth.SignedHeaders = strings.Split(value, ";")
+		case "Signature":
+			auth.Signature = value
+		}
+	}
+
+	if !hasCredential {
+		return nil, fmt.Errorf("authorization header is missing Credential")
+	}
+	if len(auth.SignedHeaders) == 0 || auth.SignedHeaders[0] == "" {
+		return nil, fmt.Errorf("authorization header is missing SignedHeaders")
+	}
+	if auth.Signature == "" {
+		return nil, fmt.Errorf("authorization header is missing Signature")
+	}
+	return auth, nil
+}
+
+// String formats the authorization back into header form
+func (a *Authorization) String() string {
+	return fmt.Sprintf("%s Credential=%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, a.Credential.String(), strings.Join(a.SignedHeaders, ";"), a.Signature)
+}
+
+// RequestTime returns the signing time of a request from X-Amz-Date or Date
+func RequestTime(r *http.Request) (time.Time, error) {
+	if value := r.Header.Get("X-Amz-Date"); value != "" {
+		return time.Parse(TimeFormat, value)
+	}
+	if value := r.Header.Get("Date"); value != "" {
+		return http.ParseTime(value)
+	}
+	return time.Time{}, fmt.Errorf("request is missing X-Amz-Date")
+}
+
+// CanonicalRequest builds the canonical request string for r
+func CanonicalRequest(r *http.Request, signedHeaders []string, payloadHash string) string {
+	return strings.Join([]string{
+		r.Method,
+		CanonicalURI(r.URL.Path),
+		CanonicalQuery(r.URL.Query()),
+		CanonicalHeaders(r, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// CanonicalURI URI-encodes each path segment. S3 paths are not normalized.
+func CanonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+// CanonicalQuery sorts and encodes the query string, leaving out X-Amz-Signature
+func CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if key == "X-Amz-Signature" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// CanonicalHeaders renders the signed headers as name:value lines
+func CanonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(headerValue(r, name))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// StringToSign builds the string that is signed with the derived signing key
+func StringToSign(t time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	return strings.Join([]string{
+		Algorithm,
+		t.UTC().Format(TimeFormat),
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+}
+
+// SigningKey derives the signing key for a credential scope
+func SigningKey(secretKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, terminator)
+}
+
+// Signature computes the hex encoded signature of stringToSign
+func Signature(signingKey []byte, stringToSign string) string {
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+// SignRequest signs r in place the way the AWS SDKs do. The payload hash is
+// taken from X-Amz-Content-Sha256 and defaults to the hash of an empty body.
+func SignRequest(r *http.Request, accessKey, secretKey, region string, t time.Time) {
+	t = t.UTC()
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = EmptySHA256
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	r.Header.Set("X-Amz-Date", t.Format(TimeFormat))
+
+	cred := Credential{
+		AccessKey: accessKey,
+		Date:      t.Format(DateFormat),
+		Region:    region,
+		Service:   ServiceS3,
+		Terminal:  terminator,
+	}
+	signedHeaders := headersToSign(r)
+	canonical := CanonicalRequest(r, signedHeaders, payloadHash)
+	key := SigningKey(secretKey, cred.Date, cred.Region, cred.Service)
+
+	auth := &Authorization{
+		Credential:    cred,
+		SignedHeaders: signedHeaders,
+		Signature:     Signature(key, StringToSign(t, cred.Scope(), canonical)),
+	}
+	r.Header.Set("Authorization", auth.String())
+}
+
+// headersToSign selects host, content-type, content-md5 and all x-amz-* headers
+func headersToSign(r *http.Request) []string {
+	names := []string{"host"}
+	for name := range r.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "content-md5" {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// headerValue returns the canonical (trimmed, space-collapsed) header value
+func headerValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		if r.Host != "" {
+			return r.Host
+		}
+		return r.URL.Host
+	case "content-length":
+		if value := r.Header.Get("Content-Length"); value != "" {
+			return value
+		}
+		return strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	raw := r.Header.Values(name)
+	values := make([]string, len(raw))
+	for i, value := range raw {
+		values[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(values, ",")
+}
+
+// uriEncode encodes everything except unreserved characters (and '/' when
+// encodeSlash is false) as required by SigV4
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// MaxPresignExpiry is the longest validity accepted for a presigned URL
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// Presigned holds the signing parameters carried in a presigned URL query string
+type Presigned struct {
+	Authorization
+	Date    time.Time
+	Expires time.Duration
+}
+
+// ParsePresigned parses the X-Amz-* query parameters of a presigned URL
+func ParsePresigned(query url.Values) (*Presigned, error) {
+	if query.Get("X-Amz-Algorithm") != Algorithm {
+		return nil, fmt.Errorf("X-Amz-Algorithm only supports %s", Algorithm)
+	}
+
+	cred, err := ParseCredential(query.Get("X-Amz-Credential"))
+	if err != nil {
+		return nil, err
+	}
+	date, err := time.Parse(TimeFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return nil, fmt.Errorf("X-Amz-Date must be in the ISO8601 basic format")
+	}
+	seconds, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || seconds <= 0 {
+		return nil, fmt.Errorf("X-Amz-Expires must be a positive number of seconds")
+	}
+	expires := time.Duration(seconds) * time.Second
+	if expires > MaxPresignExpiry {
+		return nil, fmt.Errorf("X-Amz-Expires must be less than a week (in seconds)")
+	}
+
+	signedHeaders := query.Get("X-Amz-SignedHeaders")
+	signature := query.Get("X-Amz-Signature")
+	if signedHeaders == "" || signature == "" {
+		return nil, fmt.Errorf("X-Amz-SignedHeaders and X-Amz-Signature are required")
+	}
+
+	return &Presigned{
+		Authorization: Authorization{
+			Credential:    cred,
+			SignedHeaders: strings.Split(signedHeaders, ";"),
+			Signature:     signature,
+		},
+		Date:    date,
+		Expires: expires,
+	}, nil
+}
+
+// Presign returns a copy of u carrying query string authentication for method,
+// valid for expires from t. Only the host header is signed.
+func Presign(method string, u *url.URL, accessKey, secretKey, region string, t time.Time, expires time.Duration) *url.URL {
+	t = t.UTC()
+	cred := Credential{
+		AccessKey: accessKey,
+		Date:      t.Format(DateFormat),
+		Region:    region,
+		Service:   ServiceS3,
+		Terminal:  terminator,
+	}
+
+	signed := *u
+	query := signed.Query()
+	query.Set("X-Amz-Algorithm", Algorithm)
+	query.Set("X-Amz-Credential", cred.String())
+	query.Set("X-Amz-Date", t.Format(TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	signed.RawQuery = query.Encode()
+
+	r := &http.Request{Method: method, URL: &signed, Host: signed.Host, Header: http.Header{}}
+	canonical := CanonicalRequest(r, []string{"host"}, UnsignedPayload)
+	key := SigningKey(secretKey, cred.Date, cred.Region, cred.Service)
+
+	query.Set("X-Amz-Signature", Signature(key, StringToSign(t, cred.Scope(), canonical)))
+	signed.RawQuery = query.Encode()
+	return &signed
+}
@@ -0,0 +1,259 @@
+package sigv4
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Payload hashes of aws-chunked uploads, where the body is sent as a series
+// of chunks instead of being hashed up front
+const (
+	// StreamingPayload marks a body whose chunks are each signed
+	StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	// StreamingPayloadTrailer marks signed chunks followed by signed trailing headers
+	StreamingPayloadTrailer = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	// StreamingUnsignedPayloadTrailer marks unsigned chunks followed by trailing headers
+	StreamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	chunkAlgorithm   = "AWS4-HMAC-SHA256-PAYLOAD"
+	trailerAlgorithm = "AWS4-HMAC-SHA256-TRAILER"
+
+	chunkSignatureParam = "chunk-signature="
+	trailerSignature    = "x-amz-trailer-signature"
+
+	// MaxChunkSize bounds the chunks of a signed payload, which are held in
+	// memory until their signature is verified
+	MaxChunkSize = 16 * 1024 * 1024
+)
+
+var (
+	// ErrMalformedChunk is returned for bodies that are not valid aws-chunked encoding
+	ErrMalformedChunk = errors.New("malformed aws-chunked encoding")
+	// ErrChunkSignature is returned when a chunk or trailer signature does not match
+	ErrChunkSignature = errors.New("chunk signature does not match")
+)
+
+// IsStreamingPayload reports whether payloadHash announces an aws-chunked body
+func IsStreamingPayload(payloadHash string) bool {
+	switch payloadHash {
+	case StreamingPayload, StreamingPayloadTrailer, StreamingUnsignedPayloadTrailer:
+		return true
+	}
+	return false
+}
+
+// ChunkSigner computes the chain of signatures of a streaming payload. Each
+// chunk is signed over the signature before it, starting from the seed
+// signature of the request itself.
+type ChunkSigner struct {
+	key       []byte
+	time      time.Time
+	scope     string
+	signature string
+}
+
+// NewChunkSigner starts a signature chain from the seed signature of a request
+func NewChunkSigner(signingKey []byte, t time.Time, scope, seedSignature string) *ChunkSigner {
+	return &ChunkSigner{key: signingKey, time: t.UTC(), scope: scope, signature: seedSignature}
+}
+
+// Chunk returns the signature of the next chunk, which data holds
+func (s *ChunkSigner) Chunk(data []byte) string {
+	hash := sha256.Sum256(data)
+	s.signature = Signature(s.key, strings.Join([]string{
+		chunkAlgorithm,
+		s.time.Format(TimeFormat),
+		s.scope,
+		s.signature,
+		EmptySHA256,
+		hex.EncodeToString(hash[:]),
+	}, "\n"))
+	return s.signature
+}
+
+// Trailer returns the signature of the trailing headers, given in their
+// canonical name:value\n form
+func (s *ChunkSigner) Trailer(trailer []byte) string {
+	hash := sha256.Sum256(trailer)
+	s.signature = Signature(s.key, strings.Join([]string{
+		trailerAlgorithm,
+		s.time.Format(TimeFormat),
+		s.scope,
+		s.signature,
+		hex.EncodeToString(hash[:]),
+	}, "\n"))
+	return s.signature
+}
+
+// ChunkedReader decodes an aws-chunked body. With a signer every chunk, and
+// the trailing headers if any, must carry the next signature of the chain;
+// a signed chunk is only returned once it has been verified. Without one the
+// chunk signatures are ignored.
+type ChunkedReader struct {
+	r       *bufio.Reader
+	signer  *ChunkSigner
+	chunk   []byte // verified data of the current signed chunk
+	remain  int64  // bytes left in the current unsigned chunk
+	trailer http.Header
+	err     error
+}
+
+// NewChunkedReader decodes body, verifying it with signer unless it is nil
+func NewChunkedReader(body io.Reader, signer *ChunkSigner) *ChunkedReader {
+	return &ChunkedReader{r: bufio.NewReader(body), signer: signer, trailer: http.Header{}}
+}
+
+// Trailer returns the trailing headers, which are complete once Read has
+// returned io.EOF
+func (c *ChunkedReader) Trailer() http.Header {
+	return c.trailer
+}
+
+func (c *ChunkedReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 && c.remain == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.err = c.nextChunk()
+	}
+
+	if len(c.chunk) > 0 {
+		n := copy(p, c.chunk)
+		c.chunk = c.chunk[n:]
+		return n, nil
+	}
+
+	if int64(len(p)) > c.remain {
+		p = p[:c.remain]
+	}
+	n, err := c.r.Read(p)
+	c.remain -= int64(n)
+	if c.remain == 0 && err == nil {
+		err = c.readCRLF()
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		c.err, c.remain = err, 0
+	}
+	return n, err
+}
+
+// nextChunk reads the header of the next chunk and, for signed chunks, its
+// data. It returns io.EOF after the final chunk and the trailer.
+func (c *ChunkedReader) nextChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeField, signature, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("%w: invalid chunk size %q", ErrMalformedChunk, sizeField)
+	}
+
+	if c.signer == nil {
+		if size == 0 {
+			return c.readTrailer()
+		}
+		c.remain = size
+		return nil
+	}
+
+	if !strings.HasPrefix(signature, chunkSignatureParam) {
+		return fmt.Errorf("%w: chunk is missing its signature", ErrMalformedChunk)
+	}
+	if size > MaxChunkSize {
+		return fmt.Errorf("%w: chunk of %d bytes exceeds the maximum chunk size", ErrMalformedChunk, size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	expected := c.signer.Chunk(data)
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimPrefix(signature, chunkSignatureParam))) {
+		return ErrChunkSignature
+	}
+
+	if size == 0 {
+		return c.readTrailer()
+	}
+	c.chunk = data
+	return c.readCRLF()
+}
+
+// readTrailer reads the trailing headers after the final chunk, up to the
+// empty line ending the body
+func (c *ChunkedReader) readTrailer() error {
+	var canonical bytes.Buffer
+	var signature string
+	for {
+		line, err := c.readLine()
+		if err == io.ErrUnexpectedEOF {
+			break // some clients leave out the final empty line
+		}
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("%w: malformed trailing header %q", ErrMalformedChunk, line)
+		}
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		if name == trailerSignature {
+			signature = value
+			continue
+		}
+		c.trailer.Add(name, value)
+		fmt.Fprintf(&canonical, "%s:%s\n", name, value)
+	}
+
+	if c.signer != nil && canonical.Len() > 0 {
+		if signature == "" {
+			return fmt.Errorf("%w: trailing headers are missing their signature", ErrMalformedChunk)
+		}
+		if !hmac.Equal([]byte(c.signer.Trailer(canonical.Bytes())), []byte(signature)) {
+			return ErrChunkSignature
+		}
+	}
+	return io.EOF
+}
+
+// readLine reads a CRLF terminated line
+func (c *ChunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", fmt.Errorf("%w: line too long", ErrMalformedChunk)
+	}
+	if err != nil {
+		return "", io.ErrUnexpectedEOF
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+}
+
+// readCRLF consumes the line break ending the data of a chunk
+func (c *ChunkedReader) readCRLF() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return fmt.Errorf("%w: chunk data is longer than its size", ErrMalformedChunk)
+	}
+	return nil
+}
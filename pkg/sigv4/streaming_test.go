@@ -0,0 +1,119 @@
+package sigv4
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// The chunked upload example from the Amazon S3 SigV4 documentation
+const exampleSeedSignature = "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+
+func exampleChunkSigner() *ChunkSigner {
+	scope := Credential{Date: "20130524", Region: exampleRegion, Service: ServiceS3, Terminal: "aws4_request"}.Scope()
+	key := SigningKey(exampleSecretKey, "20130524", exampleRegion, ServiceS3)
+	return NewChunkSigner(key, exampleTime, scope, exampleSeedSignature)
+}
+
+// chunkedBody encodes chunks the way the AWS SDKs do, signing them with signer
+// unless it is nil
+func chunkedBody(signer *ChunkSigner, chunks []string, trailer string) string {
+	var b strings.Builder
+	for _, chunk := range append(chunks, "") {
+		if signer != nil {
+			fmt.Fprintf(&b, "%x;chunk-signature=%s\r\n%s", len(chunk), signer.Chunk([]byte(chunk)), chunk)
+		} else {
+			fmt.Fprintf(&b, "%x\r\n%s", len(chunk), chunk)
+		}
+		if chunk != "" {
+			b.WriteString("\r\n")
+		}
+	}
+	if trailer != "" {
+		b.WriteString(trailer + "\r\n")
+		if signer != nil {
+			b.WriteString("x-amz-trailer-signature:" + signer.Trailer([]byte(trailer+"\n")) + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+func TestChunkSigner_DocumentationExample(t *testing.T) {
+	signer := exampleChunkSigner()
+	want := []struct {
+		size      int
+		signature string
+	}{
+		{65536, "ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648"},
+		{1024, "0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497"},
+		{0, "b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9"},
+	}
+	for _, chunk := range want {
+		if got := signer.Chunk([]byte(strings.Repeat("a", chunk.size))); got != chunk.signature {
+			t.Errorf("chunk of %d bytes: signature = %s, want %s", chunk.size, got, chunk.signature)
+		}
+	}
+}
+
+func TestChunkedReader(t *testing.T) {
+	chunks := []string{strings.Repeat("a", 65536), strings.Repeat("b", 1024)}
+	content := strings.Join(chunks, "")
+	trailer := "x-amz-checksum-crc32:AAAAAA=="
+
+	tests := []struct {
+		name    string
+		body    string
+		signer  *ChunkSigner
+		trailer string
+	}{
+		{"signed", chunkedBody(exampleChunkSigner(), chunks, ""), exampleChunkSigner(), ""},
+		{"signed trailer", chunkedBody(exampleChunkSigner(), chunks, trailer), exampleChunkSigner(), "AAAAAA=="},
+		{"unsigned trailer", chunkedBody(nil, chunks, trailer), nil, "AAAAAA=="},
+		{"signatures ignored", chunkedBody(exampleChunkSigner(), chunks, ""), nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewChunkedReader(strings.NewReader(tt.body), tt.signer)
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != content {
+				t.Errorf("decoded %d bytes, want %d", len(got), len(content))
+			}
+			if value := reader.Trailer().Get("x-amz-checksum-crc32"); value != tt.trailer {
+				t.Errorf("trailer = %q, want %q", value, tt.trailer)
+			}
+		})
+	}
+}
+
+func TestChunkedReader_Invalid(t *testing.T) {
+	chunks := []string{strings.Repeat("a", 65536), strings.Repeat("b", 1024)}
+	valid := chunkedBody(exampleChunkSigner(), chunks, "")
+	trailed := chunkedBody(exampleChunkSigner(), chunks, "x-amz-checksum-crc32:AAAAAA==")
+
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{"tampered chunk", strings.Replace(valid, "bbbb", "bbbc", 1), ErrChunkSignature},
+		{"wrong seed", chunkedBody(NewChunkSigner(nil, exampleTime, "", exampleSeedSignature), chunks, ""), ErrChunkSignature},
+		{"tampered trailer", strings.Replace(trailed, "AAAAAA==", "AAAAAB==", 1), ErrChunkSignature},
+		{"missing signature", strings.Replace(valid, ";chunk-signature=", ";x=", 1), ErrMalformedChunk},
+		{"bad size", "zz;chunk-signature=00\r\n", ErrMalformedChunk},
+		{"truncated", valid[:1000], io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := io.ReadAll(NewChunkedReader(strings.NewReader(tt.body), exampleChunkSigner()))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ReadAll() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
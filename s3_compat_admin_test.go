@@ -0,0 +1,169 @@
+package eightfs_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeKey decodes an access key from an admin API response
+func decodeKey(t *testing.T, body []byte) credentials.Credential {
+	t.Helper()
+	var key credentials.Credential
+	require.NoError(t, json.Unmarshal(body, &key), string(body))
+	return key
+}
+
+// This test covers creating, listing, rotating, disabling and deleting access keys through the admin API.
+func TestAdmin_AccessKeys(t *testing.T) {
+	// The logger writes to the stdout it was created with; capture it to
+	// inspect the audit events
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	logFile, err := os.Create(logPath)
+	require.NoError(t, err)
+	defer logFile.Close()
+	stdout := os.Stdout
+	os.Stdout = logFile
+	r, cfg := newTestRouter(t, nil)
+	os.Stdout = stdout
+	admin := cfg.Auth.DefaultKey
+
+	w := doAs(t, r, admin.AccessKey, admin.SecretKey, "POST", "/api/v1/admin/keys",
+		`{"description": "ci uploads", "buckets": ["ci-*"], "actions": ["s3:PutObject", "s3:GetObject"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	created := decodeKey(t, w.Body.Bytes())
+	assert.Regexp(t, `^AKIA[A-Z2-7]{16}$`, created.AccessKey)
+	assert.Len(t, created.SecretKey, 40)
+	assert.Equal(t, credentials.RoleUser, created.Role)
+	assert.Equal(t, credentials.StatusActive, created.Status)
+
+	w = doSigned(t, r, "PUT", "/ci-artifacts", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("new key works", func(t *testing.T) {
+		w := doAs(t, r, created.AccessKey, created.SecretKey, "PUT", "/ci-artifacts/build.zip", "zip")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("list and get are redacted", func(t *testing.T) {
+		w := doAs(t, r, admin.AccessKey, admin.SecretKey, "GET", "/api/v1/admin/keys", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), created.SecretKey)
+		assert.NotContains(t, w.Body.String(), "secret_key")
+		var list struct {
+			Keys  []credentials.Credential `json:"keys"`
+			Count int                      `json:"count"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		require.Equal(t, 1, list.Count)
+		assert.Equal(t, created.AccessKey, list.Keys[0].AccessKey)
+		assert.Equal(t, []string{"ci-*"}, list.Keys[0].Buckets)
+
+		w = doAs(t, r, admin.AccessKey, admin.SecretKey, "GET", "/api/v1/admin/keys/"+created.AccessKey, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "ci uploads", decodeKey(t, w.Body.Bytes()).Description)
+		assert.NotContains(t, w.Body.String(), created.SecretKey)
+
+		w = doAs(t, r, admin.AccessKey, admin.SecretKey, "GET", "/api/v1/admin/keys/AKIANOSUCHKEY00000", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		w := doAs(t, r, admin.AccessKey, admin.SecretKey, "POST", "/api/v1/admin/keys/"+created.AccessKey+"/rotate", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		rotated := decodeKey(t, w.Body.Bytes())
+		assert.Equal(t, created.AccessKey, rotated.AccessKey)
+		assert.NotEqual(t, created.SecretKey, rotated.SecretKey)
+
+		w = doAs(t, r, created.AccessKey, created.SecretKey, "GET", "/ci-artifacts/build.zip", "")
+		assertS3ErrorCode(t, w, "SignatureDoesNotMatch")
+		w = doAs(t, r, created.AccessKey, rotated.SecretKey, "GET", "/ci-artifacts/build.zip", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		created.SecretKey = rotated.SecretKey
+	})
+
+	t.Run("disable and enable", func(t *testing.T) {
+		w := doAs(t, r, admin.AccessKey, admin.SecretKey, "POST", "/api/v1/admin/keys/"+created.AccessKey+"/disable", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, credentials.StatusDisabled, decodeKey(t, w.Body.Bytes()).Status)
+		w = doAs(t, r, created.AccessKey, created.SecretKey, "GET", "/ci-artifacts/build.zip", "")
+		assertS3ErrorCode(t, w, "InvalidAccessKeyId")
+
+		w = doAs(t, r, admin.AccessKey, admin.SecretKey, "POST", "/api/v1/admin/keys/"+created.AccessKey+"/enable", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doAs(t, r, created.AccessKey, created.SecretKey, "GET", "/ci-artifacts/build.zip", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("admin role required", func(t *testing.T) {
+		w := doAs(t, r, created.AccessKey, created.SecretKey, "GET", "/api/v1/admin/keys", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"ACCESS_DENIED"`)
+
+		w = doAPI(r, "GET", "/api/v1/admin/keys", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// A second admin can manage keys, but not lock itself out
+		w = doAs(t, r, admin.AccessKey, admin.SecretKey, "POST", "/api/v1/admin/keys", `{"role": "admin"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		second := decodeKey(t, w.Body.Bytes())
+		w = doAs(t, r, second.AccessKey, second.SecretKey, "GET", "/api/v1/admin/keys", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doAs(t, r, second.AccessKey, second.SecretKey, "DELETE", "/api/v1/admin/keys/"+second.AccessKey, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		for _, body := range []string{`{"role": "root"}`, `{"actions": ["iam:*"]}`, `{"expires_at": "` + past + `"}`, `{`} {
+			w := doAs(t, r, admin.AccessKey, admin.SecretKey, "POST", "/api/v1/admin/keys", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		w := doAs(t, r, admin.AccessKey, admin.SecretKey, "DELETE", "/api/v1/admin/keys/"+created.AccessKey, "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		w = doAs(t, r, admin.AccessKey, admin.SecretKey, "DELETE", "/api/v1/admin/keys/"+created.AccessKey, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doAs(t, r, created.AccessKey, created.SecretKey, "GET", "/ci-artifacts/build.zip", "")
+		assertS3ErrorCode(t, w, "InvalidAccessKeyId")
+	})
+
+	t.Run("key changes are audited", func(t *testing.T) {
+		data, err := os.ReadFile(logPath)
+		require.NoError(t, err)
+		var actions []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.Contains(line, `"msg":"AUDIT"`) || !strings.Contains(line, `access_key`) {
+				continue
+			}
+			var entry struct {
+				Event string `json:"event"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			var event struct {
+				EventType string `json:"event_type"`
+				Action    string `json:"action"`
+				UserID    string `json:"user_id"`
+				Success   bool   `json:"success"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(entry.Event), &event))
+			if event.EventType != "access_key" {
+				continue
+			}
+			actions = append(actions, event.Action)
+			assert.NotEmpty(t, event.UserID)
+		}
+		assert.Subset(t, actions, []string{"create", "rotate", "disable", "enable", "delete"})
+		assert.NotContains(t, string(data), created.SecretKey)
+	})
+}
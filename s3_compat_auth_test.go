@@ -0,0 +1,160 @@
+package eightfs_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/8fs-io/core/pkg/sigv4"
+	"github.com/stretchr/testify/assert"
+)
+
+// This test covers full SigV4 verification: valid requests, tampering and scope checks.
+func TestS3_SignatureV4_Verification(t *testing.T) {
+	r, cfg := newTestRouter(t, nil)
+	key := cfg.Auth.DefaultKey.AccessKey
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/sig-bkt", nil)
+	signRequest(req, key)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	// Signed payload hash is accepted and stored
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/sig-bkt/signed.txt", strings.NewReader("signed body"))
+	req.Header.Set("Content-Type", "text/plain")
+	signRequest(req, key)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	// UNSIGNED-PAYLOAD skips body verification
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/sig-bkt/unsigned.txt", strings.NewReader("unsigned body"))
+	req.Header.Set("X-Amz-Content-Sha256", sigv4.UnsignedPayload)
+	signRequest(req, key)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	t.Run("tampered path", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/sig-bkt/signed.txt", nil)
+		signRequest(req, key)
+		req.URL.Path = "/sig-bkt/unsigned.txt"
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assertS3ErrorCode(t, w, "SignatureDoesNotMatch")
+	})
+
+	t.Run("tampered query", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/sig-bkt?prefix=signed", nil)
+		signRequest(req, key)
+		req.URL.RawQuery = "prefix=unsigned"
+		r.ServeHTTP(w, req)
+		assertS3ErrorCode(t, w, "SignatureDoesNotMatch")
+	})
+
+	t.Run("tampered signed header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/sig-bkt/meta.txt", strings.NewReader("x"))
+		req.Header.Set("x-amz-meta-owner", "alice")
+		signRequest(req, key)
+		req.Header.Set("x-amz-meta-owner", "mallory")
+		r.ServeHTTP(w, req)
+		assertS3ErrorCode(t, w, "SignatureDoesNotMatch")
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/sig-bkt/body.txt", strings.NewReader("original"))
+		signRequest(req, key)
+		req.Body = io.NopCloser(strings.NewReader("tampered"))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "XAmzContentSHA256Mismatch")
+
+		// The object must not have been stored
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("HEAD", "/sig-bkt/body.txt", nil)
+		signRequest(req, key)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("tampered subresource body", func(t *testing.T) {
+		// The XML decoder stops at the closing tag, so the body must still be
+		// verified to its end
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/sig-bkt/signed.txt?tagging", strings.NewReader(`<Tagging><TagSet><Tag><Key>owner</Key><Value>alice</Value></Tag></TagSet></Tagging>`))
+		signRequest(req, key)
+		req.Body = io.NopCloser(strings.NewReader(`<Tagging><TagSet><Tag><Key>owner</Key><Value>eve</Value></Tag></TagSet></Tagging>`))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "XAmzContentSHA256Mismatch")
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/sig-bkt/signed.txt?tagging", nil)
+		signRequest(req, key)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "eve")
+	})
+
+	t.Run("invalid payload hash", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/sig-bkt/any.txt", strings.NewReader("unverified"))
+		req.Header.Set("X-Amz-Content-Sha256", "foo")
+		signRequest(req, key)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidArgument")
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		signRequestAt(req, key, "not-the-secret", time.Now())
+		r.ServeHTTP(w, req)
+		assertS3ErrorCode(t, w, "SignatureDoesNotMatch")
+	})
+
+	t.Run("clock skew", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		signRequestAt(req, key, testSecretKey, time.Now().Add(-time.Hour))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assertS3ErrorCode(t, w, "RequestTimeTooSkewed")
+	})
+
+	t.Run("wrong region", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Amz-Content-Sha256", sigv4.EmptySHA256)
+		sigv4.SignRequest(req, key, testSecretKey, "eu-west-1", time.Now())
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "AuthorizationHeaderMalformed")
+	})
+
+	t.Run("unknown access key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		signRequest(req, "AKIDUNKNOWN")
+		r.ServeHTTP(w, req)
+		assertS3ErrorCode(t, w, "InvalidAccessKeyId")
+	})
+}
+
+// assertS3ErrorCode checks the Code element of an S3 XML error response
+func assertS3ErrorCode(t *testing.T, w *httptest.ResponseRecorder, code string) {
+	t.Helper()
+	var errResp handlers.ErrorResponse
+	parseXML(t, w.Body.Bytes(), &errResp)
+	assert.Equal(t, code, errResp.Code)
+}
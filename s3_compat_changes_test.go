@@ -0,0 +1,269 @@
+package eightfs_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// changePage is the body of a change feed response
+type changePage struct {
+	Changes []storage.Change `json:"changes"`
+	Count   int              `json:"count"`
+	Cursor  string           `json:"cursor"`
+	HasMore bool             `json:"has_more"`
+}
+
+func listChanges(t *testing.T, r http.Handler, query string) changePage {
+	t.Helper()
+	w := doAPI(r, "GET", "/api/v1/storage/changes"+query, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page changePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	return page
+}
+
+func parseCursor(t *testing.T, cursor string) int64 {
+	t.Helper()
+	sequence, err := strconv.ParseInt(cursor, 10, 64)
+	require.NoError(t, err)
+	return sequence
+}
+
+// changeTypes lists the bucket, key and type of each change in order
+func changeTypes(changes []storage.Change) []string {
+	described := make([]string, 0, len(changes))
+	for _, change := range changes {
+		described = append(described, change.Type+" "+change.Bucket+"/"+change.Key)
+	}
+	return described
+}
+
+// This test covers the change feed: ordering, bucket filters, resuming from
+// cursors, long polls, retention and compaction.
+func TestS3_ChangeFeed(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+	ctx := context.Background()
+
+	for _, bucket := range []string{"/feed-bkt", "/feed-other"} {
+		w := doSigned(t, r, "PUT", bucket, "")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := doSigned(t, r, "PUT", "/feed-bkt/a.txt", "first")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doSigned(t, r, "PUT", "/feed-other/c.txt", "other")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doSigned(t, r, "PUT", "/feed-bkt/a.txt", "second")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	w = doConditional(t, r, "PUT", "/feed-bkt/b.txt", "", map[string]string{"X-Amz-Copy-Source": "/feed-bkt/a.txt"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doSigned(t, r, "PUT", "/feed-bkt/b.txt?tagging", `<Tagging><TagSet><Tag><Key>k</Key><Value>v</Value></Tag></TagSet></Tagging>`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doSigned(t, r, "DELETE", "/feed-bkt/a.txt", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	t.Run("ordered feed", func(t *testing.T) {
+		page := listChanges(t, r, "")
+		assert.Equal(t, []string{
+			"put feed-bkt/a.txt",
+			"put feed-other/c.txt",
+			"put feed-bkt/a.txt",
+			"copy feed-bkt/b.txt",
+			"metadata feed-bkt/b.txt",
+			"delete feed-bkt/a.txt",
+		}, changeTypes(page.Changes))
+		assert.Equal(t, 6, page.Count)
+		assert.False(t, page.HasMore)
+		for i := 1; i < len(page.Changes); i++ {
+			assert.Greater(t, page.Changes[i].Sequence, page.Changes[i-1].Sequence)
+		}
+
+		put := page.Changes[2]
+		assert.Equal(t, etag, put.ETag)
+		assert.Equal(t, int64(6), put.Size)
+		assert.Empty(t, put.VersionID)
+		assert.False(t, put.Time.IsZero())
+		assert.Equal(t, etag, page.Changes[3].ETag)
+		assert.Equal(t, etag, page.Changes[4].ETag)
+		last := page.Changes[len(page.Changes)-1]
+		assert.Equal(t, last.Sequence, parseCursor(t, page.Cursor))
+	})
+
+	t.Run("bucket filter", func(t *testing.T) {
+		page := listChanges(t, r, "?bucket=feed-other")
+		assert.Equal(t, []string{"put feed-other/c.txt"}, changeTypes(page.Changes))
+
+		w := doAPI(r, "GET", "/api/v1/storage/changes?bucket=Bad_Bucket", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("resume from cursor", func(t *testing.T) {
+		page := listChanges(t, r, "?limit=4")
+		require.Len(t, page.Changes, 4)
+		assert.True(t, page.HasMore)
+
+		next := listChanges(t, r, "?limit=4&since="+page.Cursor)
+		assert.Equal(t, []string{"metadata feed-bkt/b.txt", "delete feed-bkt/a.txt"}, changeTypes(next.Changes))
+		assert.False(t, next.HasMore)
+
+		// A caught up reader keeps its cursor
+		caughtUp := listChanges(t, r, "?since="+next.Cursor)
+		assert.Empty(t, caughtUp.Changes)
+		assert.Equal(t, next.Cursor, caughtUp.Cursor)
+
+		for _, query := range []string{"?since=abc", "?since=-1", "?limit=0", "?wait=soon"} {
+			w := doAPI(r, "GET", "/api/v1/storage/changes"+query, "")
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("long poll", func(t *testing.T) {
+		cursor := listChanges(t, r, "").Cursor
+
+		// A poll without changes returns once the wait is over
+		started := time.Now()
+		page := listChanges(t, r, "?wait=1&since="+cursor)
+		assert.Empty(t, page.Changes)
+		assert.Equal(t, cursor, page.Cursor)
+		assert.GreaterOrEqual(t, time.Since(started), time.Second)
+
+		// A waiting poll returns as soon as a change is made
+		done := make(chan *httptest.ResponseRecorder)
+		started = time.Now()
+		go func() {
+			done <- doAPI(r, "GET", "/api/v1/storage/changes?bucket=feed-bkt&wait=10&since="+cursor, "")
+		}()
+		time.Sleep(100 * time.Millisecond)
+		w := doSigned(t, r, "PUT", "/feed-other/ignored.txt", "not this bucket")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doSigned(t, r, "PUT", "/feed-bkt/late.txt", "late")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		select {
+		case w := <-done:
+			require.Equal(t, http.StatusOK, w.Code)
+			var page changePage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			assert.Equal(t, []string{"put feed-bkt/late.txt"}, changeTypes(page.Changes))
+			assert.Less(t, time.Since(started), 5*time.Second)
+		case <-time.After(10 * time.Second):
+			t.Fatal("long poll did not return")
+		}
+	})
+
+	t.Run("delete markers", func(t *testing.T) {
+		w := doSigned(t, r, "PUT", "/feed-bkt?versioning", `<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`)
+		require.Equal(t, http.StatusOK, w.Code)
+		cursor := listChanges(t, r, "").Cursor
+
+		w = doSigned(t, r, "PUT", "/feed-bkt/versioned.txt", "v1")
+		require.Equal(t, http.StatusOK, w.Code)
+		versionID := w.Header().Get("X-Amz-Version-Id")
+		w = doSigned(t, r, "DELETE", "/feed-bkt/versioned.txt", "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		markerID := w.Header().Get("X-Amz-Version-Id")
+		w = doSigned(t, r, "DELETE", "/feed-bkt/versioned.txt?versionId="+markerID, "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		page := listChanges(t, r, "?since="+cursor)
+		require.Len(t, page.Changes, 3)
+		assert.Equal(t, storage.ChangePut, page.Changes[0].Type)
+		assert.Equal(t, versionID, page.Changes[0].VersionID)
+		assert.Equal(t, storage.ChangeDelete, page.Changes[1].Type)
+		assert.Equal(t, markerID, page.Changes[1].VersionID)
+		assert.True(t, page.Changes[1].DeleteMarker)
+		// Removing the marker is a delete of that version, not a new marker
+		assert.Equal(t, storage.ChangeDelete, page.Changes[2].Type)
+		assert.Equal(t, markerID, page.Changes[2].VersionID)
+		assert.False(t, page.Changes[2].DeleteMarker)
+	})
+
+	t.Run("compaction and retention", func(t *testing.T) {
+		c := sharedContainer(t)
+		before := listChanges(t, r, "")
+		oldCursor := strconv.FormatInt(before.Changes[0].Sequence, 10)
+
+		// Compaction drops the writes of a.txt that later changes replaced,
+		// but keeps the copy that the metadata change of b.txt describes
+		removed, err := c.StorageService.CompactChanges(ctx, time.Time{}, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 3, removed)
+		page := listChanges(t, r, "?bucket=feed-bkt&limit=3")
+		assert.Equal(t, []string{"copy feed-bkt/b.txt", "metadata feed-bkt/b.txt", "delete feed-bkt/a.txt"}, changeTypes(page.Changes))
+
+		// Compaction leaves cursors valid
+		listChanges(t, r, "?since="+oldCursor)
+
+		// Retention drops everything recorded before the cutoff, after which
+		// cursors behind it have to start over
+		removed, err = c.StorageService.CompactChanges(ctx, time.Now().Add(time.Second), time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, len(before.Changes)-3, removed)
+
+		w := doAPI(r, "GET", "/api/v1/storage/changes?since="+oldCursor, "")
+		assert.Equal(t, http.StatusGone, w.Code)
+		assert.Contains(t, w.Body.String(), "CURSOR_EXPIRED")
+		assert.Empty(t, listChanges(t, r, "").Changes)
+		assert.Empty(t, listChanges(t, r, "?since="+before.Cursor).Changes)
+
+		// Sequence numbers keep growing after the log was emptied
+		w = doSigned(t, r, "PUT", "/feed-other/after.txt", "after")
+		require.Equal(t, http.StatusOK, w.Code)
+		page = listChanges(t, r, "?since="+before.Cursor)
+		require.Len(t, page.Changes, 1)
+		assert.Greater(t, page.Changes[0].Sequence, parseCursor(t, before.Cursor))
+	})
+
+	t.Run("bucket policy", func(t *testing.T) {
+		cursor := listChanges(t, r, "").Cursor
+		deny := `{"Statement": [{"Effect": "Deny", "Principal": "*", "Action": "s3:ListBucket", "Resource": "arn:aws:s3:::feed-other"}]}`
+		w := doSigned(t, r, "PUT", "/feed-other?policy", deny)
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+		w = doSigned(t, r, "PUT", "/feed-other/hidden.txt", "hidden")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doSigned(t, r, "PUT", "/feed-bkt/visible.txt", "visible")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = doAPI(r, "GET", "/api/v1/storage/changes?bucket=feed-other", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// The denied bucket is left out of the feed across buckets, and the
+		// cursor moves past its changes
+		page := listChanges(t, r, "?since="+cursor)
+		assert.Equal(t, []string{"put feed-bkt/visible.txt"}, changeTypes(page.Changes))
+		assert.Empty(t, listChanges(t, r, "?since="+page.Cursor).Changes)
+
+		// A waiting poll is not ended by a change it may not see
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			done <- doAPI(r, "GET", "/api/v1/storage/changes?wait=10&since="+page.Cursor, "")
+		}()
+		time.Sleep(100 * time.Millisecond)
+		w = doSigned(t, r, "PUT", "/feed-other/hidden-late.txt", "hidden")
+		require.Equal(t, http.StatusOK, w.Code)
+		time.Sleep(100 * time.Millisecond)
+		w = doSigned(t, r, "PUT", "/feed-bkt/visible-late.txt", "visible")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		select {
+		case w := <-done:
+			require.Equal(t, http.StatusOK, w.Code)
+			var late changePage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &late))
+			assert.Equal(t, []string{"put feed-bkt/visible-late.txt"}, changeTypes(late.Changes))
+		case <-time.After(10 * time.Second):
+			t.Fatal("long poll did not return")
+		}
+	})
+}
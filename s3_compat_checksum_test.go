@@ -0,0 +1,160 @@
+package eightfs_test
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func b64(digest []byte) string {
+	return base64.StdEncoding.EncodeToString(digest)
+}
+
+func crc32Digest(data string, table *crc32.Table) string {
+	digest := make([]byte, 4)
+	binary.BigEndian.PutUint32(digest, crc32.Checksum([]byte(data), table))
+	return b64(digest)
+}
+
+// This test covers Content-MD5 and x-amz-checksum-* validation on upload and
+// the checksums returned with x-amz-checksum-mode.
+func TestS3_Checksums(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/checksum-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	content := "checksummed content"
+	md5Sum := md5.Sum([]byte(content))
+	sha1Sum := sha1.Sum([]byte(content))
+	sha256Sum := sha256.Sum256([]byte(content))
+	digests := map[string]string{
+		"CRC32":  crc32Digest(content, crc32.IEEETable),
+		"CRC32C": crc32Digest(content, crc32.MakeTable(crc32.Castagnoli)),
+		"SHA1":   b64(sha1Sum[:]),
+		"SHA256": b64(sha256Sum[:]),
+	}
+
+	t.Run("content-md5", func(t *testing.T) {
+		w := doConditional(t, r, "PUT", "/checksum-bkt/md5.txt", content, map[string]string{"Content-MD5": b64(md5Sum[:])})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		wrong := md5.Sum([]byte("other content"))
+		w = doConditional(t, r, "PUT", "/checksum-bkt/md5.txt", "replaced content", map[string]string{"Content-MD5": b64(wrong[:])})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "BadDigest")
+
+		w = doConditional(t, r, "PUT", "/checksum-bkt/md5.txt", content, map[string]string{"Content-MD5": "not-a-digest"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidDigest")
+
+		// A rejected upload leaves the stored object untouched
+		w = doSigned(t, r, "GET", "/checksum-bkt/md5.txt", "")
+		assert.Equal(t, content, w.Body.String())
+	})
+
+	for algorithm, digest := range digests {
+		t.Run(algorithm, func(t *testing.T) {
+			header := "X-Amz-Checksum-" + algorithm
+			target := "/checksum-bkt/" + algorithm + ".txt"
+
+			w := doConditional(t, r, "PUT", target, content, map[string]string{header: digest})
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, digest, w.Header().Get(header))
+
+			w = doConditional(t, r, "PUT", target, "tampered content", map[string]string{header: digest})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assertS3ErrorCode(t, w, "BadDigest")
+
+			// Only returned when asked for
+			w = doSigned(t, r, "GET", target, "")
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, content, w.Body.String())
+			assert.Empty(t, w.Header().Get(header))
+
+			enabled := map[string]string{"X-Amz-Checksum-Mode": "ENABLED"}
+			w = doConditional(t, r, "GET", target, "", enabled)
+			assert.Equal(t, digest, w.Header().Get(header))
+			w = doConditional(t, r, "HEAD", target, "", enabled)
+			assert.Equal(t, digest, w.Header().Get(header))
+
+			enabled["Range"] = "bytes=0-3"
+			w = doConditional(t, r, "GET", target, "", enabled)
+			require.Equal(t, http.StatusPartialContent, w.Code)
+			assert.Empty(t, w.Header().Get(header))
+		})
+	}
+
+	t.Run("sdk algorithm", func(t *testing.T) {
+		w := doConditional(t, r, "PUT", "/checksum-bkt/computed.txt", content, map[string]string{"X-Amz-Sdk-Checksum-Algorithm": "SHA256"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, digests["SHA256"], w.Header().Get("X-Amz-Checksum-Sha256"))
+
+		w = doConditional(t, r, "HEAD", "/checksum-bkt/computed.txt", "", map[string]string{"X-Amz-Checksum-Mode": "ENABLED"})
+		assert.Equal(t, digests["SHA256"], w.Header().Get("X-Amz-Checksum-Sha256"))
+
+		w = doConditional(t, r, "PUT", "/checksum-bkt/computed.txt", content, map[string]string{"X-Amz-Sdk-Checksum-Algorithm": "MD4"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid headers", func(t *testing.T) {
+		cases := map[string]map[string]string{
+			"two algorithms":   {"X-Amz-Checksum-Crc32": digests["CRC32"], "X-Amz-Checksum-Sha1": digests["SHA1"]},
+			"malformed digest": {"X-Amz-Checksum-Sha256": "AAAA"},
+			"algorithm differs": {
+				"X-Amz-Checksum-Crc32":         digests["CRC32"],
+				"X-Amz-Sdk-Checksum-Algorithm": "SHA1",
+			},
+		}
+		for name, headers := range cases {
+			w := doConditional(t, r, "PUT", "/checksum-bkt/invalid.txt", content, headers)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assertS3ErrorCode(t, w, "InvalidRequest")
+		}
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		w := doConditional(t, r, "PUT", "/checksum-bkt/copied.txt", "", map[string]string{"X-Amz-Copy-Source": "/checksum-bkt/CRC32C.txt"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = doConditional(t, r, "HEAD", "/checksum-bkt/copied.txt", "", map[string]string{"X-Amz-Checksum-Mode": "ENABLED"})
+		assert.Equal(t, digests["CRC32C"], w.Header().Get("X-Amz-Checksum-Crc32c"))
+	})
+
+	t.Run("upload part", func(t *testing.T) {
+		uploadID := initiateUpload(t, r, "checksum-bkt", "parts.bin")
+		target := "/checksum-bkt/parts.bin?partNumber=1&uploadId=" + uploadID
+
+		w := doConditional(t, r, "PUT", target, content, map[string]string{"X-Amz-Checksum-Sha256": digests["SHA256"]})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, digests["SHA256"], w.Header().Get("X-Amz-Checksum-Sha256"))
+
+		w = doConditional(t, r, "PUT", target, content, map[string]string{"X-Amz-Sdk-Checksum-Algorithm": "CRC32"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, digests["CRC32"], w.Header().Get("X-Amz-Checksum-Crc32"))
+
+		w = doConditional(t, r, "PUT", target, "tampered content", map[string]string{"X-Amz-Checksum-Sha256": digests["SHA256"]})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "BadDigest")
+
+		w = doConditional(t, r, "PUT", target, "tampered content", map[string]string{"Content-MD5": b64(md5Sum[:])})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "BadDigest")
+
+		w = doConditional(t, r, "PUT", target, content, map[string]string{"Content-MD5": "not-a-digest"})
+		assertS3ErrorCode(t, w, "InvalidDigest")
+
+		// A rejected part leaves the staged one untouched
+		w = doSigned(t, r, "GET", "/checksum-bkt/parts.bin?uploadId="+uploadID, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "<Size>19</Size>")
+	})
+}
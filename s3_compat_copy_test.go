@@ -0,0 +1,198 @@
+package eightfs_test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This test covers CopyObject: metadata directives, copy-source conditionals and errors.
+func TestS3_CopyObject(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	for _, bucket := range []string{"/copy-src", "/copy-dst"} {
+		w := doSigned(t, r, "PUT", bucket, "")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	w := doConditional(t, r, "PUT", "/copy-src/docs/a b.txt", "copy me", map[string]string{
+		"Content-Type":    "text/plain",
+		"X-Amz-Meta-Team": "ml",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	srcETag := w.Header().Get("ETag")
+
+	// COPY is the default directive, content type and metadata come from the source
+	w = doConditional(t, r, "PUT", "/copy-dst/copied.txt", "", map[string]string{
+		"X-Amz-Copy-Source": "/copy-src/docs/a%20b.txt",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result struct {
+		ETag         string `xml:"ETag"`
+		LastModified string `xml:"LastModified"`
+	}
+	parseXML(t, w.Body.Bytes(), &result)
+	assert.Equal(t, srcETag, result.ETag)
+	assert.NotEmpty(t, result.LastModified)
+
+	w = doSigned(t, r, "GET", "/copy-dst/copied.txt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "copy me", w.Body.String())
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "ml", w.Header().Get("X-Amz-Meta-Team"))
+
+	// REPLACE takes content type and metadata from the request
+	w = doConditional(t, r, "PUT", "/copy-dst/replaced.txt", "", map[string]string{
+		"X-Amz-Copy-Source":        "copy-src/docs/a%20b.txt",
+		"X-Amz-Metadata-Directive": "REPLACE",
+		"Content-Type":             "text/markdown",
+		"X-Amz-Meta-Owner":         "ops",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doSigned(t, r, "HEAD", "/copy-dst/replaced.txt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/markdown", w.Header().Get("Content-Type"))
+	assert.Equal(t, "ops", w.Header().Get("X-Amz-Meta-Owner"))
+	assert.Empty(t, w.Header().Get("X-Amz-Meta-Team"))
+
+	// Copying an object onto itself requires REPLACE
+	w = doConditional(t, r, "PUT", "/copy-src/docs/a b.txt", "", map[string]string{
+		"X-Amz-Copy-Source": "/copy-src/docs/a%20b.txt",
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertS3ErrorCode(t, w, "InvalidRequest")
+
+	w = doConditional(t, r, "PUT", "/copy-src/docs/a b.txt", "", map[string]string{
+		"X-Amz-Copy-Source":        "/copy-src/docs/a%20b.txt",
+		"X-Amz-Metadata-Directive": "REPLACE",
+		"Content-Type":             "text/plain",
+		"X-Amz-Meta-Team":          "research",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doSigned(t, r, "GET", "/copy-src/docs/a%20b.txt", "")
+	assert.Equal(t, "copy me", w.Body.String())
+	assert.Equal(t, "research", w.Header().Get("X-Amz-Meta-Team"))
+
+	t.Run("copy source conditionals", func(t *testing.T) {
+		cases := []struct {
+			header string
+			value  string
+			status int
+		}{
+			{"X-Amz-Copy-Source-If-Match", srcETag, http.StatusOK},
+			{"X-Amz-Copy-Source-If-Match", `"abc"`, http.StatusPreconditionFailed},
+			{"X-Amz-Copy-Source-If-None-Match", srcETag, http.StatusPreconditionFailed},
+			{"X-Amz-Copy-Source-If-None-Match", `"abc"`, http.StatusOK},
+			{"X-Amz-Copy-Source-If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT", http.StatusOK},
+			{"X-Amz-Copy-Source-If-Unmodified-Since", "Mon, 02 Jan 2006 15:04:05 GMT", http.StatusPreconditionFailed},
+		}
+		for _, tc := range cases {
+			w := doConditional(t, r, "PUT", "/copy-dst/conditional.txt", "", map[string]string{
+				"X-Amz-Copy-Source": "/copy-src/docs/a%20b.txt",
+				tc.header:           tc.value,
+			})
+			assert.Equal(t, tc.status, w.Code, tc.header+": "+tc.value)
+			if tc.status == http.StatusPreconditionFailed {
+				assertS3ErrorCode(t, w, "PreconditionFailed")
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		w := doConditional(t, r, "PUT", "/copy-dst/x.txt", "", map[string]string{"X-Amz-Copy-Source": "/copy-src/missing.txt"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = doConditional(t, r, "PUT", "/copy-dst/x.txt", "", map[string]string{"X-Amz-Copy-Source": "/no-such-bucket/a.txt"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = doConditional(t, r, "PUT", "/copy-dst/x.txt", "", map[string]string{"X-Amz-Copy-Source": "just-a-bucket"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidArgument")
+
+		w = doConditional(t, r, "PUT", "/copy-dst/x.txt", "", map[string]string{
+			"X-Amz-Copy-Source":        "/copy-src/docs/a%20b.txt",
+			"X-Amz-Metadata-Directive": "MERGE",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidArgument")
+
+		// Destination preconditions still apply
+		w = doConditional(t, r, "PUT", "/copy-dst/copied.txt", "", map[string]string{
+			"X-Amz-Copy-Source": "/copy-src/docs/a%20b.txt",
+			"If-None-Match":     "*",
+		})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+}
+
+// This test covers UploadPartCopy, including copy source ranges.
+func TestS3_UploadPartCopy(t *testing.T) {
+	r, _ := newTestRouter(t, map[string]string{"MULTIPART_MIN_PART_SIZE": "1024"})
+
+	w := doSigned(t, r, "PUT", "/partcopy-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	source := strings.Repeat("0123456789", 300) // 3000 bytes
+	w = doSigned(t, r, "PUT", "/partcopy-bkt/source.bin", source)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	uploadID := initiateUpload(t, r, "partcopy-bkt", "assembled.bin")
+	ranges := map[int]string{1: "bytes=0-1499", 2: "bytes=1500-2999"}
+	etags := map[int]string{}
+	for n := 1; n <= 2; n++ {
+		w = doConditional(t, r, "PUT", fmt.Sprintf("/partcopy-bkt/assembled.bin?partNumber=%d&uploadId=%s", n, uploadID), "", map[string]string{
+			"X-Amz-Copy-Source":       "/partcopy-bkt/source.bin",
+			"X-Amz-Copy-Source-Range": ranges[n],
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result struct {
+			ETag string `xml:"ETag"`
+		}
+		parseXML(t, w.Body.Bytes(), &result)
+		sum := md5.Sum([]byte(source[(n-1)*1500 : n*1500]))
+		assert.Equal(t, `"`+hex.EncodeToString(sum[:])+`"`, result.ETag)
+		etags[n] = result.ETag
+	}
+
+	// A whole-object copy without a range
+	w = doConditional(t, r, "PUT", "/partcopy-bkt/assembled.bin?partNumber=3&uploadId="+uploadID, "", map[string]string{
+		"X-Amz-Copy-Source": "/partcopy-bkt/source.bin",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result struct {
+		ETag string `xml:"ETag"`
+	}
+	parseXML(t, w.Body.Bytes(), &result)
+	etags[3] = result.ETag
+
+	w = doSigned(t, r, "POST", "/partcopy-bkt/assembled.bin?uploadId="+uploadID, completeBody(etags, 1, 2, 3))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doSigned(t, r, "GET", "/partcopy-bkt/assembled.bin", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, source+source, w.Body.String())
+
+	t.Run("invalid ranges", func(t *testing.T) {
+		id := initiateUpload(t, r, "partcopy-bkt", "other.bin")
+		for _, spec := range []string{"bytes=0-", "bytes=-100", "bytes=10-5", "0-10", "bytes=0-3000"} {
+			w := doConditional(t, r, "PUT", "/partcopy-bkt/other.bin?partNumber=1&uploadId="+id, "", map[string]string{
+				"X-Amz-Copy-Source":       "/partcopy-bkt/source.bin",
+				"X-Amz-Copy-Source-Range": spec,
+			})
+			assert.Equal(t, http.StatusBadRequest, w.Code, spec)
+			assertS3ErrorCode(t, w, "InvalidArgument")
+		}
+
+		w := doConditional(t, r, "PUT", "/partcopy-bkt/other.bin?partNumber=1&uploadId="+id, "", map[string]string{
+			"X-Amz-Copy-Source":          "/partcopy-bkt/source.bin",
+			"X-Amz-Copy-Source-If-Match": `"abc"`,
+		})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+}
@@ -0,0 +1,140 @@
+package eightfs_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doPreflight sends an unsigned CORS preflight request
+func doPreflight(r http.Handler, target, origin, method, headers string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("OPTIONS", target, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method != "" {
+		req.Header.Set("Access-Control-Request-Method", method)
+	}
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// This test covers bucket CORS configuration and cross-origin requests.
+func TestS3_BucketCors(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/cors-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doSigned(t, r, "PUT", "/cors-bkt/photo.jpg", "jpeg")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doSigned(t, r, "GET", "/cors-bkt?cors", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assertS3ErrorCode(t, w, "NoSuchCORSConfiguration")
+
+	// Without a configuration every preflight is refused
+	w = doPreflight(r, "/cors-bkt/photo.jpg", "https://app.example.com", "GET", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assertS3ErrorCode(t, w, "AccessForbidden")
+
+	configuration := `<CORSConfiguration>
+		<CORSRule>
+			<ID>app</ID>
+			<AllowedOrigin>https://app.example.com</AllowedOrigin>
+			<AllowedMethod>GET</AllowedMethod>
+			<AllowedMethod>PUT</AllowedMethod>
+			<AllowedHeader>Content-*</AllowedHeader>
+			<AllowedHeader>x-amz-*</AllowedHeader>
+			<ExposeHeader>ETag</ExposeHeader>
+			<MaxAgeSeconds>3000</MaxAgeSeconds>
+		</CORSRule>
+		<CORSRule>
+			<AllowedOrigin>*</AllowedOrigin>
+			<AllowedMethod>GET</AllowedMethod>
+		</CORSRule>
+	</CORSConfiguration>`
+	w = doSigned(t, r, "PUT", "/cors-bkt?cors", configuration)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doSigned(t, r, "GET", "/cors-bkt?cors", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var got handlers.CORSConfiguration
+	parseXML(t, w.Body.Bytes(), &got)
+	require.Len(t, got.Rules, 2)
+	assert.Equal(t, "app", got.Rules[0].ID)
+	assert.Equal(t, []string{"GET", "PUT"}, got.Rules[0].AllowedMethods)
+	assert.Equal(t, 3000, got.Rules[0].MaxAgeSeconds)
+
+	t.Run("preflight", func(t *testing.T) {
+		w := doPreflight(r, "/cors-bkt/photo.jpg", "https://app.example.com", "PUT", "content-type, X-Amz-Date")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, PUT", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "content-type, X-Amz-Date", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "ETag", w.Header().Get("Access-Control-Expose-Headers"))
+		assert.Equal(t, "3000", w.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+
+		// Other origins fall through to the wildcard rule
+		w = doPreflight(r, "/cors-bkt/photo.jpg", "https://other.example.com", "GET", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+		w = doPreflight(r, "/cors-bkt/photo.jpg", "https://other.example.com", "PUT", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doPreflight(r, "/cors-bkt/photo.jpg", "https://app.example.com", "PUT", "Authorization")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doPreflight(r, "/cors-bkt/photo.jpg", "", "GET", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = doPreflight(r, "/cors-bkt/photo.jpg", "https://app.example.com", "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("response headers", func(t *testing.T) {
+		w := doFrom(t, r, "GET", "/cors-bkt/photo.jpg", "192.0.2.1:1234", map[string]string{"Origin": "https://app.example.com"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "ETag", w.Header().Get("Access-Control-Expose-Headers"))
+
+		w = doFrom(t, r, "DELETE", "/cors-bkt/missing.jpg", "192.0.2.1:1234", map[string]string{"Origin": "https://app.example.com"})
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+		w = doSigned(t, r, "GET", "/cors-bkt/photo.jpg", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("invalid configurations", func(t *testing.T) {
+		cases := map[string]string{
+			"malformed xml":  `<CORSConfiguration><CORSRule>`,
+			"no rules":       `<CORSConfiguration></CORSConfiguration>`,
+			"no origin":      `<CORSConfiguration><CORSRule><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`,
+			"unknown method": `<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>`,
+			"two wildcards":  `<CORSConfiguration><CORSRule><AllowedOrigin>*.*</AllowedOrigin><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`,
+		}
+		for name, body := range cases {
+			w := doSigned(t, r, "PUT", "/cors-bkt?cors", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		}
+	})
+
+	w = doSigned(t, r, "DELETE", "/cors-bkt?cors", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doSigned(t, r, "GET", "/cors-bkt?cors", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doPreflight(r, "/cors-bkt/photo.jpg", "https://app.example.com", "GET", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doSigned(t, r, "PUT", "/missing-bkt?cors", configuration)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,144 @@
+package eightfs_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/8fs-io/core/internal/domain/credentials"
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doAs sends a request signed with the given key pair
+func doAs(t *testing.T, r http.Handler, accessKey, secretKey, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, _ := http.NewRequest(method, target, strings.NewReader(body))
+	signRequestAt(req, accessKey, secretKey, time.Now())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// This test covers access keys from the credential store and their scopes.
+func TestS3_CredentialStore(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	for _, bucket := range []string{"team-data", "team-logs", "finance"} {
+		w := doSigned(t, r, "PUT", "/"+bucket, "")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	c := sharedContainer(t)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	keys := []*credentials.Credential{
+		{AccessKey: "AKIATEAMWRITER0001", SecretKey: "writerSecretKey0000000000000000000000001", Buckets: []string{"team-*"}},
+		{AccessKey: "AKIATEAMREADER0001", SecretKey: "readerSecretKey0000000000000000000000001", Buckets: []string{"team-data"}, Actions: []string{"s3:Get*", "s3:ListBucket"}},
+		{AccessKey: "AKIADISABLED000001", SecretKey: "disabledSecretKey00000000000000000000001", Status: credentials.StatusDisabled},
+		{AccessKey: "AKIAEXPIRED0000001", SecretKey: "expiredSecretKey000000000000000000000001", ExpiresAt: &past},
+	}
+	for _, key := range keys {
+		_, err := c.CredentialService.CreateCredential(ctx, key)
+		require.NoError(t, err, key.AccessKey)
+	}
+	writer, reader := keys[0], keys[1]
+
+	t.Run("bucket scope", func(t *testing.T) {
+		w := doAs(t, r, writer.AccessKey, writer.SecretKey, "PUT", "/team-logs/run.log", "log line")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doAs(t, r, writer.AccessKey, writer.SecretKey, "PUT", "/team-data/a.csv", "a,b")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = doAs(t, r, writer.AccessKey, writer.SecretKey, "PUT", "/finance/q1.csv", "secret")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assertS3ErrorCode(t, w, "AccessDenied")
+	})
+
+	t.Run("action scope", func(t *testing.T) {
+		w := doAs(t, r, reader.AccessKey, reader.SecretKey, "GET", "/team-data/a.csv", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doAs(t, r, reader.AccessKey, reader.SecretKey, "GET", "/team-data?list-type=2", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = doAs(t, r, reader.AccessKey, reader.SecretKey, "PUT", "/team-data/b.csv", "c,d")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doAs(t, r, reader.AccessKey, reader.SecretKey, "DELETE", "/team-data/a.csv", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doAs(t, r, reader.AccessKey, reader.SecretKey, "GET", "/team-logs/run.log", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("copy source outside of scope", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/team-data/stolen.csv", nil)
+		req.Header.Set("X-Amz-Copy-Source", "/finance/q1.csv")
+		signRequestAt(req, writer.AccessKey, writer.SecretKey, time.Now())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("list buckets", func(t *testing.T) {
+		w := doAs(t, r, writer.AccessKey, writer.SecretKey, "GET", "/", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var buckets handlers.ListAllMyBucketsResult
+		parseXML(t, w.Body.Bytes(), &buckets)
+		var names []string
+		for _, bucket := range buckets.Buckets.Bucket {
+			names = append(names, bucket.Name)
+		}
+		assert.ElementsMatch(t, []string{"team-data", "team-logs"}, names)
+
+		w = doAs(t, r, reader.AccessKey, reader.SecretKey, "GET", "/", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("unusable keys", func(t *testing.T) {
+		for _, key := range keys[2:] {
+			w := doAs(t, r, key.AccessKey, key.SecretKey, "GET", "/team-data/a.csv", "")
+			assert.Equal(t, http.StatusUnauthorized, w.Code, key.AccessKey)
+			assertS3ErrorCode(t, w, "InvalidAccessKeyId")
+		}
+
+		w := doAs(t, r, writer.AccessKey, "wrongSecretKey0000000000000000000000000", "GET", "/team-data/a.csv", "")
+		assertS3ErrorCode(t, w, "SignatureDoesNotMatch")
+	})
+
+	t.Run("deleted key", func(t *testing.T) {
+		require.NoError(t, c.CredentialService.DeleteCredential(ctx, writer.AccessKey))
+		w := doAs(t, r, writer.AccessKey, writer.SecretKey, "GET", "/team-data/a.csv", "")
+		assertS3ErrorCode(t, w, "InvalidAccessKeyId")
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		_, err := c.CredentialService.CreateCredential(ctx, &credentials.Credential{AccessKey: reader.AccessKey, SecretKey: reader.SecretKey})
+		assert.Error(t, err)
+		_, err = c.CredentialService.CreateCredential(ctx, &credentials.Credential{AccessKey: "lowercase-key-0001", SecretKey: reader.SecretKey})
+		assert.Error(t, err)
+		_, err = c.CredentialService.CreateCredential(ctx, &credentials.Credential{AccessKey: "AKIASHORTSECRET001", SecretKey: "short"})
+		assert.Error(t, err)
+		_, err = c.CredentialService.CreateCredential(ctx, &credentials.Credential{AccessKey: "AKIABADACTION00001", SecretKey: reader.SecretKey, Actions: []string{"iam:*"}})
+		assert.Error(t, err)
+	})
+
+	// Bucket policies cannot grant more than the key scope
+	t.Run("policy grant", func(t *testing.T) {
+		for _, bucket := range []string{"team-data", "team-logs"} {
+			document := `{"Statement": [{"Effect": "Allow", "Principal": {"AWS": "AKIATEAMREADER0001"}, "Action": ["s3:GetObject", "s3:PutObject"], "Resource": "arn:aws:s3:::` + bucket + `/*"}]}`
+			w := doSigned(t, r, "PUT", "/"+bucket+"?policy", document)
+			require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		}
+
+		w := doAs(t, r, reader.AccessKey, reader.SecretKey, "PUT", "/team-data/uploads/b.csv", "c,d")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assertS3ErrorCode(t, w, "AccessDenied")
+		w = doAs(t, r, reader.AccessKey, reader.SecretKey, "GET", "/team-logs/run.log", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doAs(t, r, reader.AccessKey, reader.SecretKey, "GET", "/team-data/a.csv", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
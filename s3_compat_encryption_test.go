@@ -0,0 +1,285 @@
+package eightfs_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMasterKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // 0123456789abcdef0123456789abcdef
+
+// customerKeyHeaders returns the SSE-C headers sending key under prefix
+func customerKeyHeaders(prefix, key string) map[string]string {
+	sum := md5.Sum([]byte(key))
+	return map[string]string{
+		prefix + "-Algorithm": "AES256",
+		prefix + "-Key":       base64.StdEncoding.EncodeToString([]byte(key)),
+		prefix + "-Key-MD5":   base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+// assertNotOnDisk fails when any file under dir contains data in the clear
+func assertNotOnDisk(t *testing.T, dir, data string) {
+	t.Helper()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		assert.False(t, bytes.Contains(content, []byte(data)), "plaintext found in %s", path)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// This test covers SSE-S3 and SSE-C encryption at rest, including range reads,
+// multipart uploads and copies of encrypted objects.
+func TestS3_ServerSideEncryption(t *testing.T) {
+	r, cfg := newTestRouter(t, map[string]string{
+		"STORAGE_ENCRYPTION_MASTER_KEY": testMasterKey,
+		"MULTIPART_MIN_PART_SIZE":       "1024",
+	})
+
+	w := doSigned(t, r, "PUT", "/sse-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Spans several 64KiB encryption chunks
+	var sb strings.Builder
+	for i := 0; sb.Len() < 200*1024; i++ {
+		fmt.Fprintf(&sb, "line %06d of the secret document\n", i)
+	}
+	content := sb.String()
+	sseS3 := map[string]string{"X-Amz-Server-Side-Encryption": "AES256"}
+
+	t.Run("sse-s3", func(t *testing.T) {
+		w := doConditional(t, r, "PUT", "/sse-bkt/doc.txt", content, sseS3)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "AES256", w.Header().Get("X-Amz-Server-Side-Encryption"))
+		sum := md5.Sum([]byte(content))
+		assert.Equal(t, fmt.Sprintf(`"%x"`, sum), w.Header().Get("ETag"))
+
+		assertNotOnDisk(t, cfg.Storage.BasePath, "line 000042 of the secret document")
+
+		w = doSigned(t, r, "GET", "/sse-bkt/doc.txt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, content, w.Body.String())
+		assert.Equal(t, "AES256", w.Header().Get("X-Amz-Server-Side-Encryption"))
+
+		w = doSigned(t, r, "HEAD", "/sse-bkt/doc.txt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, fmt.Sprint(len(content)), w.Header().Get("Content-Length"))
+		assert.Equal(t, "AES256", w.Header().Get("X-Amz-Server-Side-Encryption"))
+
+		for _, rng := range [][2]int{{0, 9}, {65530, 65545}, {100000, 200000}, {len(content) - 5, len(content) - 1}} {
+			w = getRange(t, r, "GET", "/sse-bkt/doc.txt", fmt.Sprintf("bytes=%d-%d", rng[0], rng[1]))
+			require.Equal(t, http.StatusPartialContent, w.Code)
+			assert.Equal(t, content[rng[0]:rng[1]+1], w.Body.String(), "range %v", rng)
+		}
+		w = getRange(t, r, "GET", "/sse-bkt/doc.txt", "bytes=-7")
+		require.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, content[len(content)-7:], w.Body.String())
+
+		// Objects stored without encryption stay readable
+		w = doSigned(t, r, "PUT", "/sse-bkt/plain.txt", "plain")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Amz-Server-Side-Encryption"))
+		w = doSigned(t, r, "GET", "/sse-bkt/plain.txt", "")
+		assert.Equal(t, "plain", w.Body.String())
+
+		w = doConditional(t, r, "PUT", "/sse-bkt/bad.txt", "data", map[string]string{"X-Amz-Server-Side-Encryption": "aws:kms"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("sse-c", func(t *testing.T) {
+		key := customerKeyHeaders("X-Amz-Server-Side-Encryption-Customer", "customer-key-0123456789abcdefghi")
+		wrongKey := customerKeyHeaders("X-Amz-Server-Side-Encryption-Customer", "another-key-0123456789abcdefghij")
+
+		w := doConditional(t, r, "PUT", "/sse-bkt/private.txt", content, key)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "AES256", w.Header().Get("X-Amz-Server-Side-Encryption-Customer-Algorithm"))
+		assert.Equal(t, key["X-Amz-Server-Side-Encryption-Customer-Key-MD5"], w.Header().Get("X-Amz-Server-Side-Encryption-Customer-Key-MD5"))
+		assert.Empty(t, w.Header().Get("X-Amz-Server-Side-Encryption"))
+
+		w = doConditional(t, r, "GET", "/sse-bkt/private.txt", "", key)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, content, w.Body.String())
+
+		key["Range"] = "bytes=70000-70099"
+		w = doConditional(t, r, "GET", "/sse-bkt/private.txt", "", key)
+		delete(key, "Range")
+		require.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, content[70000:70100], w.Body.String())
+
+		w = doSigned(t, r, "GET", "/sse-bkt/private.txt", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidRequest")
+		w = doConditional(t, r, "GET", "/sse-bkt/private.txt", "", wrongKey)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assertS3ErrorCode(t, w, "AccessDenied")
+
+		w = doConditional(t, r, "HEAD", "/sse-bkt/private.txt", "", key)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doConditional(t, r, "HEAD", "/sse-bkt/private.txt", "", wrongKey)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// A customer key is not applicable to objects stored without one
+		w = doConditional(t, r, "GET", "/sse-bkt/doc.txt", "", key)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		bad := customerKeyHeaders("X-Amz-Server-Side-Encryption-Customer", "too-short")
+		w = doConditional(t, r, "PUT", "/sse-bkt/bad.txt", "data", bad)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		bad = customerKeyHeaders("X-Amz-Server-Side-Encryption-Customer", "customer-key-0123456789abcdefghi")
+		bad["X-Amz-Server-Side-Encryption-Customer-Key-MD5"] = "AAAAAAAAAAAAAAAAAAAAAA=="
+		w = doConditional(t, r, "PUT", "/sse-bkt/bad.txt", "data", bad)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		req := map[string]string{"X-Amz-Server-Side-Encryption": "AES256"}
+		w := doConditional(t, r, "POST", "/sse-bkt/big.bin?uploads", "", req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "AES256", w.Header().Get("X-Amz-Server-Side-Encryption"))
+		var initiated struct {
+			UploadID string `xml:"UploadId"`
+		}
+		parseXML(t, w.Body.Bytes(), &initiated)
+
+		parts := []string{content[:70000], content[70000:75000], content[75000:76000]}
+		etags := map[int]string{}
+		for i, part := range parts {
+			w = doSigned(t, r, "PUT", fmt.Sprintf("/sse-bkt/big.bin?partNumber=%d&uploadId=%s", i+1, initiated.UploadID), part)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			etags[i+1] = w.Header().Get("ETag")
+		}
+		w = doSigned(t, r, "POST", "/sse-bkt/big.bin?uploadId="+initiated.UploadID, completeBody(etags, 1, 2, 3))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "AES256", w.Header().Get("X-Amz-Server-Side-Encryption"))
+
+		w = doSigned(t, r, "GET", "/sse-bkt/big.bin", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, content[:76000], w.Body.String())
+
+		// Across the boundary of the first two parts
+		w = getRange(t, r, "GET", "/sse-bkt/big.bin", "bytes=69990-70009")
+		require.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, content[69990:70010], w.Body.String())
+
+		w = doSigned(t, r, "GET", "/sse-bkt/big.bin?partNumber=2", "")
+		require.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, parts[1], w.Body.String())
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		source := customerKeyHeaders("X-Amz-Copy-Source-Server-Side-Encryption-Customer", "customer-key-0123456789abcdefghi")
+		source["X-Amz-Copy-Source"] = "/sse-bkt/private.txt"
+		source["X-Amz-Server-Side-Encryption"] = "AES256"
+		w := doConditional(t, r, "PUT", "/sse-bkt/copied.txt", "", source)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "AES256", w.Header().Get("X-Amz-Server-Side-Encryption"))
+
+		w = doSigned(t, r, "GET", "/sse-bkt/copied.txt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, content, w.Body.String())
+
+		// The source key is required to read an SSE-C source
+		w = doConditional(t, r, "PUT", "/sse-bkt/copied2.txt", "", map[string]string{"X-Amz-Copy-Source": "/sse-bkt/private.txt"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// Copying onto itself re-encrypts in place
+		w = doConditional(t, r, "PUT", "/sse-bkt/plain.txt", "", map[string]string{
+			"X-Amz-Copy-Source":            "/sse-bkt/plain.txt",
+			"X-Amz-Server-Side-Encryption": "AES256",
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = doSigned(t, r, "HEAD", "/sse-bkt/plain.txt", "")
+		assert.Equal(t, "AES256", w.Header().Get("X-Amz-Server-Side-Encryption"))
+	})
+}
+
+// This test covers reads of an encrypted object racing with overwrites, which
+// must always see the data and the data key of the same version.
+func TestS3_ServerSideEncryption_ConcurrentOverwrite(t *testing.T) {
+	r, _ := newTestRouter(t, map[string]string{
+		"STORAGE_ENCRYPTION_MASTER_KEY": testMasterKey,
+		"STORAGE_ENCRYPTION_DEFAULT":    "true",
+	})
+	w := doSigned(t, r, "PUT", "/race-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	bodies := []string{"first version", "second version, longer"}
+	w = doSigned(t, r, "PUT", "/race-bkt/doc.txt", bodies[0])
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			w := doSigned(t, r, "PUT", "/race-bkt/doc.txt", bodies[i%2])
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+	}()
+	for reader := 0; reader < 4; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				w := doSigned(t, r, "GET", "/race-bkt/doc.txt", "")
+				if assert.Equal(t, http.StatusOK, w.Code) {
+					assert.Contains(t, bodies, w.Body.String())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// This test covers encryption by default and SSE-S3 without a master key.
+func TestS3_ServerSideEncryption_Config(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		r, cfg := newTestRouter(t, map[string]string{
+			"STORAGE_ENCRYPTION_MASTER_KEY": testMasterKey,
+			"STORAGE_ENCRYPTION_DEFAULT":    "true",
+		})
+		w := doSigned(t, r, "PUT", "/default-bkt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doSigned(t, r, "PUT", "/default-bkt/note.txt", "encrypted without asking")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "AES256", w.Header().Get("X-Amz-Server-Side-Encryption"))
+		assertNotOnDisk(t, cfg.Storage.BasePath, "encrypted without asking")
+
+		w = doSigned(t, r, "GET", "/default-bkt/note.txt", "")
+		assert.Equal(t, "encrypted without asking", w.Body.String())
+	})
+
+	t.Run("no master key", func(t *testing.T) {
+		r, _ := newTestRouter(t, map[string]string{
+			"STORAGE_ENCRYPTION_MASTER_KEY": "",
+			"STORAGE_ENCRYPTION_DEFAULT":    "false",
+		})
+		w := doSigned(t, r, "PUT", "/nokey-bkt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doConditional(t, r, "PUT", "/nokey-bkt/note.txt", "data", map[string]string{"X-Amz-Server-Side-Encryption": "AES256"})
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+		assertS3ErrorCode(t, w, "NotImplemented")
+
+		// Customer keys need no master key
+		w = doConditional(t, r, "PUT", "/nokey-bkt/note.txt", "data",
+			customerKeyHeaders("X-Amz-Server-Side-Encryption-Customer", "customer-key-0123456789abcdefghi"))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
@@ -0,0 +1,208 @@
+package eightfs_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/8fs-io/core/internal/config"
+	"github.com/8fs-io/core/internal/domain/credentials"
+	"github.com/8fs-io/core/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJWTSecret = "jwt-test-secret-0123456789abcdef"
+
+// issueToken exchanges a key pair for a bearer token
+func issueToken(t *testing.T, r http.Handler, accessKey, secretKey string, buckets ...string) string {
+	t.Helper()
+	req := map[string]interface{}{"access_key": accessKey, "secret_key": secretKey}
+	if len(buckets) > 0 {
+		req["buckets"] = buckets
+	}
+	body, _ := json.Marshal(req)
+	w := doAPI(r, "POST", "/api/v1/auth/token", string(body))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Bearer", resp.TokenType)
+	assert.Equal(t, int64(3600), resp.ExpiresIn)
+	return resp.AccessToken
+}
+
+// doBearer sends a REST API request with a bearer token
+func doBearer(r http.Handler, token, method, target, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// This test covers token issuance and bearer authentication of the REST and vector APIs.
+func TestAPI_JWTAuth(t *testing.T) {
+	tmp := t.TempDir()
+	r, cfg := newTestRouter(t, map[string]string{
+		"AUTH_DRIVER":    "jwt",
+		"JWT_SECRET":     testJWTSecret,
+		"JWT_TOKEN_TTL":  "1h",
+		"VECTOR_ENABLED": "true",
+		"VECTOR_DB_PATH": filepath.Join(tmp, "vectors.db"),
+	})
+	admin := cfg.Auth.DefaultKey
+
+	// S3 requests are still signed
+	for _, bucket := range []string{"team-data", "team-logs", "finance"} {
+		w := doSigned(t, r, "PUT", "/"+bucket, "")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	c := sharedContainer(t)
+	scoped, err := c.CredentialService.CreateCredential(context.Background(), &credentials.Credential{Buckets: []string{"team-*"}})
+	require.NoError(t, err)
+
+	t.Run("token required", func(t *testing.T) {
+		w := doAPI(r, "GET", "/api/v1/storage/buckets", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"AUTHENTICATION_REQUIRED"`)
+
+		w = doBearer(r, "not-a-token", "GET", "/api/v1/storage/buckets", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"INVALID_CREDENTIALS"`)
+	})
+
+	t.Run("issue and use", func(t *testing.T) {
+		token := issueToken(t, r, admin.AccessKey, admin.SecretKey)
+		w := doBearer(r, token, "GET", "/api/v1/storage/buckets", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = doAPI(r, "POST", "/api/v1/auth/token", `{"access_key": "`+admin.AccessKey+`", "secret_key": "wrong"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = doAPI(r, "POST", "/api/v1/auth/token", `{"access_key": "AKIANOSUCHKEY00000", "secret_key": "wrong"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("bucket scope from claims", func(t *testing.T) {
+		token := issueToken(t, r, scoped.AccessKey, scoped.SecretKey)
+		w := doBearer(r, token, "GET", "/api/v1/storage/buckets/team-data/objects", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = doBearer(r, token, "GET", "/api/v1/storage/buckets/finance/objects", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// Tokens can be narrowed to part of the key's scope, never widened
+		narrow := issueToken(t, r, scoped.AccessKey, scoped.SecretKey, "team-data")
+		w = doBearer(r, narrow, "GET", "/api/v1/storage/buckets/team-data/objects", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doBearer(r, narrow, "GET", "/api/v1/storage/buckets/team-logs/objects", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doAPI(r, "POST", "/api/v1/auth/token", `{"access_key": "`+scoped.AccessKey+`", "secret_key": "`+scoped.SecretKey+`", "buckets": ["*"]}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("presign with token", func(t *testing.T) {
+		narrow := issueToken(t, r, scoped.AccessKey, scoped.SecretKey, "team-data")
+		w := doBearer(r, narrow, "POST", "/api/v1/storage/presign", `{"bucket": "team-data", "key": "a.txt", "method": "PUT"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), scoped.AccessKey)
+		assert.NotContains(t, w.Body.String(), admin.AccessKey)
+
+		w = doBearer(r, narrow, "POST", "/api/v1/storage/presign", `{"bucket": "team-logs", "key": "a.txt"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("vector search scope", func(t *testing.T) {
+		token := issueToken(t, r, scoped.AccessKey, scoped.SecretKey)
+		query, _ := json.Marshal(make([]float64, cfg.Vector.Dimension))
+
+		w := doBearer(r, token, "POST", "/api/v1/vectors/search", `{"query": `+string(query)+`}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doBearer(r, token, "POST", "/api/v1/vectors/search", `{"query": `+string(query)+`, "filter": {"bucket": "finance"}}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doBearer(r, token, "POST", "/api/v1/vectors/search", `{"query": `+string(query)+`, "filter": {"bucket": "team-data"}}`)
+		assert.NotEqual(t, http.StatusForbidden, w.Code, w.Body.String())
+
+		w = doAPI(r, "POST", "/api/v1/vectors/search", `{"query": `+string(query)+`}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		issued := time.Now().Add(-2 * time.Hour)
+		token, err := jwt.Sign(&jwt.Claims{
+			Subject:   admin.AccessKey,
+			IssuedAt:  jwt.NumericDate(issued),
+			ExpiresAt: issued.Add(time.Hour).Unix(),
+		}, []byte(testJWTSecret))
+		require.NoError(t, err)
+
+		w := doBearer(r, token, "GET", "/api/v1/storage/buckets", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"TOKEN_EXPIRED"`)
+	})
+
+	t.Run("forged token", func(t *testing.T) {
+		token, err := jwt.Sign(&jwt.Claims{
+			Subject:   admin.AccessKey,
+			IssuedAt:  jwt.NumericDate(time.Now()),
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		}, []byte("another-secret"))
+		require.NoError(t, err)
+
+		w := doBearer(r, token, "GET", "/api/v1/storage/buckets", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("rotated key", func(t *testing.T) {
+		// Tokens issued within the second before a rotation are revoked too
+		rotating, err := c.CredentialService.CreateCredential(context.Background(), &credentials.Credential{Buckets: []string{"team-*"}})
+		require.NoError(t, err)
+		token := issueToken(t, r, rotating.AccessKey, rotating.SecretKey)
+		rotated, err := c.CredentialService.RotateCredential(context.Background(), rotating.AccessKey)
+		require.NoError(t, err)
+
+		w := doBearer(r, token, "GET", "/api/v1/storage/buckets/team-data/objects", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		token = issueToken(t, r, rotated.AccessKey, rotated.SecretKey)
+		w = doBearer(r, token, "GET", "/api/v1/storage/buckets/team-data/objects", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("disabled key", func(t *testing.T) {
+		token := issueToken(t, r, scoped.AccessKey, scoped.SecretKey)
+		_, err := c.CredentialService.SetCredentialStatus(context.Background(), scoped.AccessKey, credentials.StatusDisabled)
+		require.NoError(t, err)
+
+		w := doBearer(r, token, "GET", "/api/v1/storage/buckets/team-data/objects", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// This test covers the secrets the jwt driver refuses to sign tokens with.
+func TestAPI_JWTSecretValidation(t *testing.T) {
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("AUTH_DRIVER", "jwt")
+
+	for _, secret := range []string{"change-me-in-production", "too-short-secret"} {
+		t.Setenv("JWT_SECRET", secret)
+		_, err := config.Load()
+		assert.Error(t, err, secret)
+	}
+
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	_, err := config.Load()
+	assert.NoError(t, err)
+}
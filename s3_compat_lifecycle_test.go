@@ -0,0 +1,202 @@
+package eightfs_test
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/8fs-io/core/internal/config"
+	"github.com/8fs-io/core/internal/container"
+	"github.com/8fs-io/core/internal/domain/storage"
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sharedContainer opens a second container over the storage of the test
+// router, e.g. to play the role of a background worker
+func sharedContainer(t *testing.T) *container.Container {
+	t.Helper()
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	c, err := container.NewContainer(cfg)
+	require.NoError(t, err)
+	return c
+}
+
+// expiredKeys lists the keys of expired objects in order
+func expiredKeys(expired []storage.ExpiredObject) []string {
+	keys := make([]string, 0, len(expired))
+	for _, object := range expired {
+		keys = append(keys, object.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// This test covers lifecycle configuration and the expiration of objects by prefix, tag and metadata filters.
+func TestS3_BucketLifecycle(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/lc-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doSigned(t, r, "GET", "/lc-bkt?lifecycle", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assertS3ErrorCode(t, w, "NoSuchLifecycleConfiguration")
+
+	config := `<LifecycleConfiguration>
+		<Rule><ID>tmp</ID><Filter><Prefix>tmp/</Prefix></Filter><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule>
+		<Rule><ID>scratch</ID><Filter><Metadata><Key>stage</Key><Value>scratch</Value></Metadata></Filter><Status>Enabled</Status><Expiration><Days>7</Days></Expiration></Rule>
+		<Rule><ID>train</ID><Filter><And><Prefix>data/</Prefix><Tag><Key>dataset</Key><Value>train</Value></Tag></And></Filter><Status>Enabled</Status><Expiration><Days>30</Days></Expiration></Rule>
+		<Rule><ID>off</ID><Filter><Prefix>data/</Prefix></Filter><Status>Disabled</Status><Expiration><Days>1</Days></Expiration></Rule>
+	</LifecycleConfiguration>`
+	w = doSigned(t, r, "PUT", "/lc-bkt?lifecycle", config)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	t.Run("configuration round trip", func(t *testing.T) {
+		w := doSigned(t, r, "GET", "/lc-bkt?lifecycle", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var got handlers.LifecycleConfiguration
+		parseXML(t, w.Body.Bytes(), &got)
+		require.Len(t, got.Rules, 4)
+
+		assert.Equal(t, "tmp/", got.Rules[0].Filter.Prefix)
+		assert.Equal(t, 1, got.Rules[0].Expiration.Days)
+		require.NotNil(t, got.Rules[1].Filter.Metadata)
+		assert.Equal(t, "stage", got.Rules[1].Filter.Metadata.Key)
+		require.NotNil(t, got.Rules[2].Filter.And)
+		assert.Equal(t, "data/", got.Rules[2].Filter.And.Prefix)
+		assert.Equal(t, []handlers.Tag{{Key: "dataset", Value: "train"}}, got.Rules[2].Filter.And.Tags)
+		assert.Equal(t, "Disabled", got.Rules[3].Status)
+	})
+
+	for _, key := range []string{"tmp/a.txt", "tmp/b.txt", "data/keep.txt", "notes.txt"} {
+		w := doSigned(t, r, "PUT", "/lc-bkt/"+key, "content of "+key)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	w = doConditional(t, r, "PUT", "/lc-bkt/logs/run.log", "log", map[string]string{"x-amz-meta-stage": "scratch"})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doConditional(t, r, "PUT", "/lc-bkt/data/train.csv", "a,b", map[string]string{"x-amz-tagging": "dataset=train"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	c := sharedContainer(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("expiration by days", func(t *testing.T) {
+		expired, err := c.StorageService.ExpireObjects(ctx, now)
+		require.NoError(t, err)
+		assert.Empty(t, expired)
+
+		expired, err = c.StorageService.ExpireObjects(ctx, now.AddDate(0, 0, 3))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tmp/a.txt", "tmp/b.txt"}, expiredKeys(expired))
+		assert.Equal(t, "tmp", expired[0].RuleID)
+
+		w := doSigned(t, r, "GET", "/lc-bkt/tmp/a.txt", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		expired, err = c.StorageService.ExpireObjects(ctx, now.AddDate(0, 0, 10))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"logs/run.log"}, expiredKeys(expired))
+
+		expired, err = c.StorageService.ExpireObjects(ctx, now.AddDate(0, 0, 40))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"data/train.csv"}, expiredKeys(expired))
+
+		// Objects outside of every enabled rule stay
+		for _, key := range []string{"data/keep.txt", "notes.txt"} {
+			w := doSigned(t, r, "HEAD", "/lc-bkt/"+key, "")
+			assert.Equal(t, http.StatusOK, w.Code, key)
+		}
+	})
+
+	t.Run("delete configuration", func(t *testing.T) {
+		w := doSigned(t, r, "DELETE", "/lc-bkt?lifecycle", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = doSigned(t, r, "GET", "/lc-bkt?lifecycle", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		expired, err := c.StorageService.ExpireObjects(ctx, now.AddDate(1, 0, 0))
+		require.NoError(t, err)
+		assert.Empty(t, expired)
+	})
+
+	t.Run("invalid configurations", func(t *testing.T) {
+		cases := map[string]struct {
+			body string
+			code string
+		}{
+			"days and date": {
+				`<LifecycleConfiguration><Rule><Status>Enabled</Status><Expiration><Days>1</Days><Date>2030-01-01T00:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+				"MalformedXML",
+			},
+			"date not at midnight": {
+				`<LifecycleConfiguration><Rule><Status>Enabled</Status><Expiration><Date>2030-01-01T10:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+				"InvalidArgument",
+			},
+			"no action": {
+				`<LifecycleConfiguration><Rule><Status>Enabled</Status></Rule></LifecycleConfiguration>`,
+				"InvalidArgument",
+			},
+			"duplicate id": {
+				`<LifecycleConfiguration><Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule><Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>2</Days></Expiration></Rule></LifecycleConfiguration>`,
+				"InvalidArgument",
+			},
+			"bad status": {
+				`<LifecycleConfiguration><Rule><Status>On</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+				"MalformedXML",
+			},
+		}
+		for name, tc := range cases {
+			w := doSigned(t, r, "PUT", "/lc-bkt?lifecycle", tc.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assertS3ErrorCode(t, w, tc.code)
+		}
+	})
+}
+
+// This test covers lifecycle expiration in a versioned bucket and the background worker.
+func TestS3_BucketLifecycle_Worker(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/lcv-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doSigned(t, r, "PUT", "/lcv-bkt?versioning", enableVersioning)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	v1 := putVersion(t, r, "/lcv-bkt/out/result.json", `{"ok":true}`)
+
+	// A date in the past expires matching objects on the next sweep
+	w = doSigned(t, r, "PUT", "/lcv-bkt?lifecycle",
+		`<LifecycleConfiguration><Rule><Filter><Prefix>out/</Prefix></Filter><Status>Enabled</Status><Expiration><Date>2020-01-01T00:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	c := sharedContainer(t)
+	expired, err := c.LifecycleWorker.Sweep(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	// The expired version is kept behind a delete marker
+	w = doSigned(t, r, "GET", "/lcv-bkt/out/result.json", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doSigned(t, r, "GET", "/lcv-bkt/out/result.json?versionId="+v1, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	versions := listVersions(t, r, "lcv-bkt", nil)
+	assert.Len(t, versions.Versions, 1)
+	assert.Len(t, versions.DeleteMarkers, 1)
+
+	// Delete markers are not expired again
+	expired, err = c.LifecycleWorker.Sweep(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+
+	// The worker can only be started once
+	assert.NoError(t, c.LifecycleWorker.Start(context.Background()))
+	assert.Error(t, c.LifecycleWorker.Start(context.Background()))
+	assert.NoError(t, c.LifecycleWorker.Stop())
+}
@@ -18,7 +18,7 @@ func TestS3_List_Delimiter_CommonPrefixes_And_Marker(t *testing.T) {
 	// Create bucket
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("PUT", "/delim-bkt", nil)
-	req.Header.Set("Authorization", authHeader(key))
+	signRequest(req, key)
 	r.ServeHTTP(w, req)
 	assert.Equal(t, 200, w.Code)
 
@@ -32,7 +32,7 @@ func TestS3_List_Delimiter_CommonPrefixes_And_Marker(t *testing.T) {
 	for _, k := range files {
 		w = httptest.NewRecorder()
 		req, _ = http.NewRequest("PUT", "/delim-bkt/"+k, strings.NewReader("x"))
-		req.Header.Set("Authorization", authHeader(key))
+		signRequest(req, key)
 		req.Header.Set("Content-Type", "text/plain")
 		r.ServeHTTP(w, req)
 		assert.Equal(t, 200, w.Code)
@@ -41,7 +41,7 @@ func TestS3_List_Delimiter_CommonPrefixes_And_Marker(t *testing.T) {
 	// Delimiter at root -> expect both contents and common prefixes (implementation returns objects not grouped by delimiter)
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("GET", "/delim-bkt?delimiter=/", nil)
-	req.Header.Set("Authorization", authHeader(key))
+	signRequest(req, key)
 	r.ServeHTTP(w, req)
 	assert.Equal(t, 200, w.Code)
 	var list handlers.ListBucketResult
@@ -57,7 +57,7 @@ func TestS3_List_Delimiter_CommonPrefixes_And_Marker(t *testing.T) {
 	// Delimiter with prefix=foo/ -> should return only objects under that prefix not matching the delimiter.
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("GET", "/delim-bkt?prefix=foo/&delimiter=/", nil)
-	req.Header.Set("Authorization", authHeader(key))
+	signRequest(req, key)
 	r.ServeHTTP(w, req)
 	assert.Equal(t, 200, w.Code)
 	list = handlers.ListBucketResult{}
@@ -72,7 +72,7 @@ func TestS3_List_Delimiter_CommonPrefixes_And_Marker(t *testing.T) {
 	// Pagination with max-keys=1 to force truncation and continuation
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("GET", "/delim-bkt?max-keys=1", nil)
-	req.Header.Set("Authorization", authHeader(key))
+	signRequest(req, key)
 	r.ServeHTTP(w, req)
 	assert.Equal(t, 200, w.Code)
 	list = handlers.ListBucketResult{}
@@ -87,7 +87,7 @@ func TestS3_List_Delimiter_CommonPrefixes_And_Marker(t *testing.T) {
 	if next != "" {
 		w = httptest.NewRecorder()
 		req, _ = http.NewRequest("GET", "/delim-bkt?marker="+next, nil)
-		req.Header.Set("Authorization", authHeader(key))
+		signRequest(req, key)
 		r.ServeHTTP(w, req)
 		assert.Equal(t, 200, w.Code)
 		list = handlers.ListBucketResult{}
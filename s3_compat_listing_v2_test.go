@@ -0,0 +1,154 @@
+package eightfs_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listV2 runs a ListObjectsV2 request and decodes the result
+func listV2(t *testing.T, r http.Handler, bucket string, query url.Values) handlers.ListBucketResultV2 {
+	t.Helper()
+	query.Set("list-type", "2")
+	w := doSigned(t, r, "GET", "/"+bucket+"?"+query.Encode(), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list handlers.ListBucketResultV2
+	parseXML(t, w.Body.Bytes(), &list)
+	return list
+}
+
+// listEntries flattens a listing page into keys and common prefixes
+func listEntries(list handlers.ListBucketResultV2) []string {
+	var entries []string
+	for _, obj := range list.Contents {
+		entries = append(entries, obj.Key)
+	}
+	for _, p := range list.CommonPrefixes {
+		entries = append(entries, p.Prefix)
+	}
+	return entries
+}
+
+// This test covers ListObjectsV2 continuation tokens, start-after, fetch-owner and encoding-type.
+func TestS3_ListObjectsV2(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/listv2-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	keys := []string{"a.txt", "dir1/x.txt", "dir1/y.txt", "dir2/z.txt", "m.txt", "dir3/deep/w.txt", "z.txt"}
+	for _, k := range keys {
+		w = doSigned(t, r, "PUT", "/listv2-bkt/"+k, "x")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	t.Run("pagination without delimiter", func(t *testing.T) {
+		var all []string
+		token := ""
+		pages := 0
+		for {
+			query := url.Values{"max-keys": {"3"}}
+			if token != "" {
+				query.Set("continuation-token", token)
+			}
+			list := listV2(t, r, "listv2-bkt", query)
+			assert.Equal(t, len(list.Contents), list.KeyCount)
+			assert.Equal(t, token, list.ContinuationToken)
+			all = append(all, listEntries(list)...)
+			pages++
+			if !list.IsTruncated {
+				assert.Empty(t, list.NextContinuationToken)
+				break
+			}
+			require.NotEmpty(t, list.NextContinuationToken)
+			token = list.NextContinuationToken
+		}
+		assert.Equal(t, 3, pages)
+		assert.Equal(t, []string{"a.txt", "dir1/x.txt", "dir1/y.txt", "dir2/z.txt", "dir3/deep/w.txt", "m.txt", "z.txt"}, all)
+	})
+
+	t.Run("pagination with delimiter", func(t *testing.T) {
+		// Common prefixes count against max-keys and are never repeated across pages
+		var all []string
+		token := ""
+		for {
+			query := url.Values{"max-keys": {"2"}, "delimiter": {"/"}}
+			if token != "" {
+				query.Set("continuation-token", token)
+			}
+			list := listV2(t, r, "listv2-bkt", query)
+			assert.LessOrEqual(t, list.KeyCount, 2)
+			assert.Equal(t, len(list.Contents)+len(list.CommonPrefixes), list.KeyCount)
+			all = append(all, listEntries(list)...)
+			if !list.IsTruncated {
+				break
+			}
+			token = list.NextContinuationToken
+		}
+		assert.ElementsMatch(t, []string{"a.txt", "dir1/", "dir2/", "dir3/", "m.txt", "z.txt"}, all)
+		assert.Len(t, all, 6)
+	})
+
+	t.Run("start-after", func(t *testing.T) {
+		list := listV2(t, r, "listv2-bkt", url.Values{"start-after": {"dir2/z.txt"}})
+		assert.Equal(t, "dir2/z.txt", list.StartAfter)
+		assert.Equal(t, []string{"dir3/deep/w.txt", "m.txt", "z.txt"}, listEntries(list))
+
+		// A start-after inside a rolled-up prefix skips that prefix
+		list = listV2(t, r, "listv2-bkt", url.Values{"start-after": {"dir1/x.txt"}, "delimiter": {"/"}})
+		assert.Equal(t, []string{"m.txt", "z.txt", "dir2/", "dir3/"}, listEntries(list))
+	})
+
+	t.Run("fetch-owner", func(t *testing.T) {
+		list := listV2(t, r, "listv2-bkt", url.Values{})
+		require.NotEmpty(t, list.Contents)
+		assert.Nil(t, list.Contents[0].Owner)
+
+		list = listV2(t, r, "listv2-bkt", url.Values{"fetch-owner": {"true"}})
+		require.NotNil(t, list.Contents[0].Owner)
+		assert.Equal(t, "8fs-owner", list.Contents[0].Owner.ID)
+	})
+
+	t.Run("encoding-type url", func(t *testing.T) {
+		w := doSigned(t, r, "PUT", "/listv2-bkt/space%20key+plus.txt", "x")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		list := listV2(t, r, "listv2-bkt", url.Values{"encoding-type": {"url"}, "prefix": {"space "}})
+		assert.Equal(t, "url", list.EncodingType)
+		assert.Equal(t, "space+", list.Prefix)
+		require.Len(t, list.Contents, 1)
+		decoded, err := url.QueryUnescape(list.Contents[0].Key)
+		require.NoError(t, err)
+		assert.Equal(t, "space key+plus.txt", decoded)
+
+		w = doSigned(t, r, "GET", "/listv2-bkt?list-type=2&encoding-type=base64", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidArgument")
+	})
+
+	t.Run("invalid continuation token", func(t *testing.T) {
+		w := doSigned(t, r, "GET", "/listv2-bkt?list-type=2&continuation-token=%21%21", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidArgument")
+	})
+
+	t.Run("v1 next marker with delimiter", func(t *testing.T) {
+		w := doSigned(t, r, "GET", "/listv2-bkt?delimiter=/&max-keys=2", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var list handlers.ListBucketResult
+		parseXML(t, w.Body.Bytes(), &list)
+		assert.True(t, list.IsTruncated)
+		assert.Equal(t, "dir1/", list.NextMarker)
+
+		w = doSigned(t, r, "GET", "/listv2-bkt?delimiter=/&max-keys=2&marker=dir1/", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		list = handlers.ListBucketResult{}
+		parseXML(t, w.Body.Bytes(), &list)
+		require.Len(t, list.CommonPrefixes, 2)
+		assert.Equal(t, "dir2/", list.CommonPrefixes[0].Prefix)
+		assert.Equal(t, "dir3/", list.CommonPrefixes[1].Prefix)
+	})
+}
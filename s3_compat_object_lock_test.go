@@ -0,0 +1,196 @@
+package eightfs_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// retentionBody is a PutObjectRetention request body
+func retentionBody(mode string, until time.Time) string {
+	return `<Retention><Mode>` + mode + `</Mode><RetainUntilDate>` + until.UTC().Format(time.RFC3339) + `</RetainUntilDate></Retention>`
+}
+
+// This test covers Object Lock: lock-enabled buckets, retention modes, legal
+// holds, default retention and the governance bypass.
+func TestS3_ObjectLock(t *testing.T) {
+	r, cfg := newTestRouter(t, nil)
+	bypass := map[string]string{"X-Amz-Bypass-Governance-Retention": "true"}
+
+	w := doConditional(t, r, "PUT", "/lock-bkt", "", map[string]string{"X-Amz-Bucket-Object-Lock-Enabled": "true"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	t.Run("bucket configuration", func(t *testing.T) {
+		w := doSigned(t, r, "GET", "/lock-bkt?object-lock", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var config handlers.ObjectLockConfiguration
+		parseXML(t, w.Body.Bytes(), &config)
+		assert.Equal(t, "Enabled", config.ObjectLockEnabled)
+		assert.Nil(t, config.Rule)
+
+		w = doSigned(t, r, "GET", "/lock-bkt?versioning", "")
+		assert.Contains(t, w.Body.String(), "<Status>Enabled</Status>")
+		w = doSigned(t, r, "PUT", "/lock-bkt?versioning", `<VersioningConfiguration><Status>Suspended</Status></VersioningConfiguration>`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assertS3ErrorCode(t, w, "InvalidBucketState")
+
+		w = doSigned(t, r, "PUT", "/plain-bkt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doSigned(t, r, "GET", "/plain-bkt?object-lock", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assertS3ErrorCode(t, w, "ObjectLockConfigurationNotFoundError")
+		w = doSigned(t, r, "PUT", "/plain-bkt?object-lock", `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assertS3ErrorCode(t, w, "InvalidBucketState")
+		w = doConditional(t, r, "PUT", "/plain-bkt/held.txt", "data", map[string]string{"X-Amz-Object-Lock-Legal-Hold": "ON"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidRequest")
+	})
+
+	t.Run("compliance", func(t *testing.T) {
+		until := time.Now().Add(time.Hour).Truncate(time.Second)
+		w := doConditional(t, r, "PUT", "/lock-bkt/audit.log", "entries", map[string]string{
+			"X-Amz-Object-Lock-Mode":              "COMPLIANCE",
+			"X-Amz-Object-Lock-Retain-Until-Date": until.UTC().Format(time.RFC3339),
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		version := w.Header().Get("x-amz-version-id")
+
+		w = doSigned(t, r, "HEAD", "/lock-bkt/audit.log", "")
+		assert.Equal(t, "COMPLIANCE", w.Header().Get("X-Amz-Object-Lock-Mode"))
+		assert.Equal(t, until.UTC().Format(time.RFC3339), w.Header().Get("X-Amz-Object-Lock-Retain-Until-Date"))
+
+		// Not even an admin can bypass compliance retention
+		w = doConditional(t, r, "DELETE", "/lock-bkt/audit.log?versionId="+version, "", bypass)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assertS3ErrorCode(t, w, "AccessDenied")
+		w = doConditional(t, r, "PUT", "/lock-bkt/audit.log?retention&versionId="+version, retentionBody("COMPLIANCE", until.Add(-time.Minute)), bypass)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doSigned(t, r, "PUT", "/lock-bkt/audit.log?retention&versionId="+version, retentionBody("GOVERNANCE", until.Add(time.Hour)))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doSigned(t, r, "PUT", "/lock-bkt/audit.log?retention&versionId="+version, retentionBody("COMPLIANCE", until.Add(time.Hour)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = doSigned(t, r, "GET", "/lock-bkt/audit.log?retention&versionId="+version, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var retention handlers.Retention
+		parseXML(t, w.Body.Bytes(), &retention)
+		assert.Equal(t, "COMPLIANCE", retention.Mode)
+		require.NotNil(t, retention.RetainUntilDate)
+		assert.True(t, retention.RetainUntilDate.Equal(until.Add(time.Hour)))
+
+		// A delete marker hides the object but keeps the locked version
+		w = doSigned(t, r, "DELETE", "/lock-bkt/audit.log", "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "true", w.Header().Get("x-amz-delete-marker"))
+		w = doSigned(t, r, "GET", "/lock-bkt/audit.log?versionId="+version, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "entries", w.Body.String())
+	})
+
+	t.Run("governance", func(t *testing.T) {
+		version := putVersion(t, r, "/lock-bkt/draft.txt", "draft")
+		w := doSigned(t, r, "PUT", "/lock-bkt/draft.txt?retention", retentionBody("GOVERNANCE", time.Now().Add(time.Hour)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = doSigned(t, r, "DELETE", "/lock-bkt/draft.txt?versionId="+version, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assertS3ErrorCode(t, w, "AccessDenied")
+		w = doSigned(t, r, "PUT", "/lock-bkt/draft.txt?retention", `<Retention/>`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// Only admin keys may bypass governance retention
+		admin := cfg.Auth.DefaultKey
+		w = doAs(t, r, admin.AccessKey, admin.SecretKey, "POST", "/api/v1/admin/keys", `{"description": "uploader"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		user := decodeKey(t, w.Body.Bytes())
+		w = doAs(t, r, user.AccessKey, user.SecretKey, "GET", "/lock-bkt/draft.txt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		req, _ := http.NewRequest("DELETE", "/lock-bkt/draft.txt?versionId="+version, nil)
+		req.Header.Set("X-Amz-Bypass-Governance-Retention", "true")
+		signRequestAt(req, user.AccessKey, user.SecretKey, time.Now())
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "object lock")
+
+		w = doConditional(t, r, "DELETE", "/lock-bkt/draft.txt?versionId="+version, "", bypass)
+		assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		w = doSigned(t, r, "GET", "/lock-bkt/draft.txt?versionId="+version, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("legal hold", func(t *testing.T) {
+		version := putVersion(t, r, "/lock-bkt/evidence.bin", "exhibit")
+		w := doSigned(t, r, "GET", "/lock-bkt/evidence.bin?retention", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assertS3ErrorCode(t, w, "NoSuchObjectLockConfiguration")
+
+		w = doSigned(t, r, "PUT", "/lock-bkt/evidence.bin?legal-hold", `<LegalHold><Status>ON</Status></LegalHold>`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = doSigned(t, r, "GET", "/lock-bkt/evidence.bin?legal-hold", "")
+		var hold handlers.LegalHold
+		parseXML(t, w.Body.Bytes(), &hold)
+		assert.Equal(t, "ON", hold.Status)
+		w = doSigned(t, r, "HEAD", "/lock-bkt/evidence.bin", "")
+		assert.Equal(t, "ON", w.Header().Get("X-Amz-Object-Lock-Legal-Hold"))
+
+		w = doConditional(t, r, "DELETE", "/lock-bkt/evidence.bin?versionId="+version, "", bypass)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doSigned(t, r, "PUT", "/lock-bkt/evidence.bin?legal-hold", `<LegalHold><Status>OFF</Status></LegalHold>`)
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doSigned(t, r, "DELETE", "/lock-bkt/evidence.bin?versionId="+version, "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("default retention", func(t *testing.T) {
+		w := doSigned(t, r, "PUT", "/lock-bkt?object-lock", `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>`+
+			`<Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>1</Days></DefaultRetention></Rule></ObjectLockConfiguration>`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = doSigned(t, r, "GET", "/lock-bkt?object-lock", "")
+		var config handlers.ObjectLockConfiguration
+		parseXML(t, w.Body.Bytes(), &config)
+		require.NotNil(t, config.Rule)
+		assert.Equal(t, "GOVERNANCE", config.Rule.DefaultRetention.Mode)
+		assert.Equal(t, 1, config.Rule.DefaultRetention.Days)
+
+		version := putVersion(t, r, "/lock-bkt/report.csv", "a,b")
+		w = doSigned(t, r, "HEAD", "/lock-bkt/report.csv", "")
+		assert.Equal(t, "GOVERNANCE", w.Header().Get("X-Amz-Object-Lock-Mode"))
+		until, err := time.Parse(time.RFC3339, w.Header().Get("X-Amz-Object-Lock-Retain-Until-Date"))
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, 1), until, time.Minute)
+
+		w = doSigned(t, r, "DELETE", "/lock-bkt/report.csv?versionId="+version, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		invalid := []string{
+			`<ObjectLockConfiguration><ObjectLockEnabled>Disabled</ObjectLockEnabled></ObjectLockConfiguration>`,
+			`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>STRICT</Mode><Days>1</Days></DefaultRetention></Rule></ObjectLockConfiguration>`,
+			`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>1</Days><Years>1</Years></DefaultRetention></Rule></ObjectLockConfiguration>`,
+		}
+		for _, body := range invalid {
+			w = doSigned(t, r, "PUT", "/lock-bkt?object-lock", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+
+	t.Run("invalid retention", func(t *testing.T) {
+		w := doConditional(t, r, "PUT", "/lock-bkt/late.txt", "data", map[string]string{
+			"X-Amz-Object-Lock-Mode":              "GOVERNANCE",
+			"X-Amz-Object-Lock-Retain-Until-Date": time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidArgument")
+
+		w = doConditional(t, r, "PUT", "/lock-bkt/late.txt", "data", map[string]string{"X-Amz-Object-Lock-Mode": "GOVERNANCE"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidArgument")
+	})
+}
@@ -0,0 +1,180 @@
+package eightfs_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/8fs-io/core/pkg/eventstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selectResult is a decoded S3 Select response
+type selectResult struct {
+	Records   string
+	Stats     *handlers.SelectStats
+	Progress  *handlers.SelectStats
+	ErrorCode string
+	Ended     bool
+}
+
+func selectRequest(expression, input, output string) string {
+	return `<SelectObjectContentRequest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+		`<Expression>` + expression + `</Expression><ExpressionType>SQL</ExpressionType>` +
+		`<InputSerialization>` + input + `</InputSerialization>` +
+		`<OutputSerialization>` + output + `</OutputSerialization>` +
+		`</SelectObjectContentRequest>`
+}
+
+func decodeSelect(t *testing.T, body []byte) selectResult {
+	t.Helper()
+	var result selectResult
+	decoder := eventstream.NewDecoder(bytes.NewReader(body))
+	for {
+		message, err := decoder.Decode()
+		if err == io.EOF {
+			return result
+		}
+		require.NoError(t, err)
+		require.False(t, result.Ended, "message after the End event")
+
+		if message.Header(":message-type") == "error" {
+			result.ErrorCode = message.Header(":error-code")
+			continue
+		}
+		switch message.Header(":event-type") {
+		case "Records":
+			result.Records += string(message.Payload)
+		case "Stats":
+			result.Stats = &handlers.SelectStats{}
+			parseXML(t, message.Payload, result.Stats)
+		case "Progress":
+			result.Progress = &handlers.SelectStats{}
+			parseXML(t, message.Payload, result.Progress)
+		case "End":
+			result.Ended = true
+		default:
+			t.Fatalf("unexpected event %q", message.Header(":event-type"))
+		}
+	}
+}
+
+// This test covers S3 Select over CSV and JSON Lines objects, the event
+// stream it responds with and the errors reported before and while streaming.
+func TestS3_SelectObjectContent(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/select-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	people := "name,age,city\nAlice,30,Paris\nBob,25,\"New York, NY\"\nCarol,41,Berlin\nDave,35,Paris\n"
+	w = doSigned(t, r, "PUT", "/select-bkt/people.csv", people)
+	require.Equal(t, http.StatusOK, w.Code)
+	orders := `{"id":1,"customer":{"name":"Alice"},"total":12.5}` + "\n" +
+		`{"id":2,"customer":{"name":"Bob"},"total":20}` + "\n" +
+		`{"id":3,"customer":{"name":"Carol"},"total":7.25}` + "\n"
+	w = doSigned(t, r, "PUT", "/select-bkt/orders.jsonl", orders)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	csvInput := `<CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV>`
+	jsonInput := `<JSON><Type>LINES</Type></JSON>`
+
+	t.Run("csv with header", func(t *testing.T) {
+		body := selectRequest("SELECT s.name, s.age FROM S3Object s WHERE s.city = 'Paris' AND CAST(s.age AS INT) &gt; 31",
+			csvInput, `<CSV/>`)
+		w := doSigned(t, r, "POST", "/select-bkt/people.csv?select&select-type=2", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		result := decodeSelect(t, w.Body.Bytes())
+		assert.Equal(t, "Dave,35\n", result.Records)
+		assert.True(t, result.Ended)
+		require.NotNil(t, result.Stats)
+		assert.Equal(t, int64(len(people)), result.Stats.BytesScanned)
+		assert.Equal(t, int64(len(people)), result.Stats.BytesProcessed)
+		assert.Equal(t, int64(len("Dave,35\n")), result.Stats.BytesReturned)
+		assert.Nil(t, result.Progress)
+	})
+
+	t.Run("like, or and limit", func(t *testing.T) {
+		body := selectRequest("SELECT name FROM S3Object WHERE city LIKE 'New%' OR name LIKE 'C%' OR age &lt; 31 LIMIT 2",
+			csvInput, `<JSON/>`)
+		w := doSigned(t, r, "POST", "/select-bkt/people.csv?select&select-type=2", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, `{"name":"Alice"}`+"\n"+`{"name":"Bob"}`+"\n", decodeSelect(t, w.Body.Bytes()).Records)
+	})
+
+	t.Run("aggregates", func(t *testing.T) {
+		body := selectRequest("SELECT COUNT(*), SUM(age), AVG(age), MIN(name) FROM S3Object WHERE city = 'Paris'", csvInput, `<CSV/>`)
+		w := doSigned(t, r, "POST", "/select-bkt/people.csv?select&select-type=2", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "2,65,32.5,Alice\n", decodeSelect(t, w.Body.Bytes()).Records)
+	})
+
+	t.Run("json lines", func(t *testing.T) {
+		body := selectRequest("SELECT s.id, s.customer.name AS who FROM S3Object s WHERE s.total &gt;= 12.5",
+			jsonInput, `<JSON><RecordDelimiter>;</RecordDelimiter></JSON>`)
+		w := doSigned(t, r, "POST", "/select-bkt/orders.jsonl?select&select-type=2", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, `{"id":1,"who":"Alice"};{"id":2,"who":"Bob"};`, decodeSelect(t, w.Body.Bytes()).Records)
+	})
+
+	t.Run("gzip and progress", func(t *testing.T) {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write([]byte(orders))
+		gz.Close()
+		w := doSigned(t, r, "PUT", "/select-bkt/orders.jsonl.gz", compressed.String())
+		require.Equal(t, http.StatusOK, w.Code)
+
+		body := `<SelectObjectContentRequest><Expression>SELECT COUNT(*) FROM S3Object</Expression><ExpressionType>SQL</ExpressionType>` +
+			`<RequestProgress><Enabled>true</Enabled></RequestProgress>` +
+			`<InputSerialization><CompressionType>GZIP</CompressionType>` + jsonInput + `</InputSerialization>` +
+			`<OutputSerialization><CSV/></OutputSerialization></SelectObjectContentRequest>`
+		w = doSigned(t, r, "POST", "/select-bkt/orders.jsonl.gz?select&select-type=2", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		result := decodeSelect(t, w.Body.Bytes())
+		assert.Equal(t, "3\n", result.Records)
+		require.NotNil(t, result.Progress)
+		require.NotNil(t, result.Stats)
+		assert.Equal(t, int64(compressed.Len()), result.Stats.BytesScanned)
+		assert.Equal(t, int64(len(orders)), result.Stats.BytesProcessed)
+	})
+
+	t.Run("request errors", func(t *testing.T) {
+		tests := []struct {
+			target string
+			body   string
+			status int
+			code   string
+		}{
+			{"/select-bkt/people.csv?select", selectRequest("SELECT * FROM S3Object", csvInput, `<CSV/>`), http.StatusBadRequest, "InvalidArgument"},
+			{"/select-bkt/people.csv?select&select-type=2", "<SelectObjectContentRequest>", http.StatusBadRequest, "MalformedXML"},
+			{"/select-bkt/people.csv?select&select-type=2", selectRequest("SELECT * FROM", csvInput, `<CSV/>`), http.StatusBadRequest, "ParseUnexpectedToken"},
+			{"/select-bkt/people.csv?select&select-type=2", selectRequest("SELECT MEDIAN(age) FROM S3Object", csvInput, `<CSV/>`), http.StatusBadRequest, "UnsupportedFunction"},
+			{"/select-bkt/people.csv?select&select-type=2", selectRequest("SELECT * FROM S3Object", `<CSV><FileHeaderInfo>MAYBE</FileHeaderInfo></CSV>`, `<CSV/>`), http.StatusBadRequest, "InvalidFileHeaderInfo"},
+			{"/select-bkt/people.csv?select&select-type=2", selectRequest("SELECT * FROM S3Object", `<Parquet/>`, `<CSV/>`), http.StatusNotImplemented, "NotImplemented"},
+			{"/select-bkt/missing.csv?select&select-type=2", selectRequest("SELECT * FROM S3Object", csvInput, `<CSV/>`), http.StatusNotFound, "OBJECT_NOT_FOUND"},
+		}
+		for _, tt := range tests {
+			w := doSigned(t, r, "POST", tt.target, tt.body)
+			assert.Equal(t, tt.status, w.Code, tt.body)
+			assertS3ErrorCode(t, w, tt.code)
+		}
+	})
+
+	t.Run("error while streaming", func(t *testing.T) {
+		body := selectRequest("SELECT CAST(name AS INT) FROM S3Object", csvInput, `<CSV/>`)
+		w := doSigned(t, r, "POST", "/select-bkt/people.csv?select&select-type=2", body)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		result := decodeSelect(t, w.Body.Bytes())
+		assert.Equal(t, "CastFailed", result.ErrorCode)
+		assert.False(t, result.Ended)
+		assert.Nil(t, result.Stats)
+	})
+}
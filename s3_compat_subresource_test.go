@@ -0,0 +1,151 @@
+package eightfs_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// aclGrants lists the grantee and permission of each grant of an ACL
+func aclGrants(t *testing.T, body []byte) []string {
+	t.Helper()
+	var acl handlers.AccessControlPolicy
+	parseXML(t, body, &acl)
+	assert.Equal(t, "8fs-owner", acl.Owner.ID)
+	grants := make([]string, 0, len(acl.AccessControlList.Grants))
+	for _, grant := range acl.AccessControlList.Grants {
+		grantee := grant.Grantee.ID
+		if grantee == "" {
+			grantee = grant.Grantee.URI
+		}
+		grants = append(grants, grantee+" "+grant.Permission)
+	}
+	return grants
+}
+
+// This test covers HeadBucket, GetBucketLocation, the ACL subresources and
+// the dispatch of subresources 8fs does not implement.
+func TestS3_BucketSubresources(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/sub-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doSigned(t, r, "PUT", "/sub-bkt/doc.txt", "hello")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("head bucket", func(t *testing.T) {
+		w := doSigned(t, r, "HEAD", "/sub-bkt", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "us-east-1", w.Header().Get("X-Amz-Bucket-Region"))
+
+		w = doSigned(t, r, "HEAD", "/missing-bkt", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("location", func(t *testing.T) {
+		w := doSigned(t, r, "GET", "/sub-bkt?location", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var location handlers.LocationConstraint
+		parseXML(t, w.Body.Bytes(), &location)
+		assert.Empty(t, location.Region, "us-east-1 is reported as an empty constraint")
+
+		w = doSigned(t, r, "GET", "/missing-bkt?location", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		regional, _ := newTestRouter(t, map[string]string{"AUTH_ENABLED": "false", "S3_REGION": "eu-west-1"})
+		w = doAPI(regional, "PUT", "/eu-bkt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doAPI(regional, "GET", "/eu-bkt?location", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		parseXML(t, w.Body.Bytes(), &location)
+		assert.Equal(t, "eu-west-1", location.Region)
+		w = doAPI(regional, "HEAD", "/eu-bkt", "")
+		assert.Equal(t, "eu-west-1", w.Header().Get("X-Amz-Bucket-Region"))
+	})
+
+	t.Run("acl", func(t *testing.T) {
+		w := doSigned(t, r, "GET", "/sub-bkt?acl", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, []string{"8fs-owner FULL_CONTROL"}, aclGrants(t, w.Body.Bytes()))
+		assert.Contains(t, w.Body.String(), `xsi:type="CanonicalUser"`)
+
+		w = doSigned(t, r, "GET", "/sub-bkt/doc.txt?acl", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, []string{"8fs-owner FULL_CONTROL"}, aclGrants(t, w.Body.Bytes()))
+
+		// A policy letting anyone read objects makes them public-read
+		w = doSigned(t, r, "PUT", "/sub-bkt?policy", `{
+			"Version": "2012-10-17",
+			"Statement": {"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::sub-bkt/*"}
+		}`)
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		allUsers := "http://acs.amazonaws.com/groups/global/AllUsers"
+
+		w = doSigned(t, r, "GET", "/sub-bkt/doc.txt?acl", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"8fs-owner FULL_CONTROL", allUsers + " READ"}, aclGrants(t, w.Body.Bytes()))
+		w = doSigned(t, r, "GET", "/sub-bkt?acl", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"8fs-owner FULL_CONTROL"}, aclGrants(t, w.Body.Bytes()))
+
+		// Listing and writing by anyone make the bucket public-read-write
+		w = doSigned(t, r, "PUT", "/sub-bkt?policy", `{
+			"Version": "2012-10-17",
+			"Statement": [
+				{"Effect": "Allow", "Principal": "*", "Action": "s3:ListBucket", "Resource": "arn:aws:s3:::sub-bkt"},
+				{"Effect": "Allow", "Principal": "*", "Action": ["s3:GetObject", "s3:PutObject"], "Resource": "arn:aws:s3:::sub-bkt/*"}
+			]
+		}`)
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		w = doSigned(t, r, "GET", "/sub-bkt?acl", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"8fs-owner FULL_CONTROL", allUsers + " READ", allUsers + " WRITE"}, aclGrants(t, w.Body.Bytes()))
+
+		w = doSigned(t, r, "DELETE", "/sub-bkt?policy", "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		w = doSigned(t, r, "GET", "/missing-bkt?acl", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doSigned(t, r, "GET", "/sub-bkt/missing.txt?acl", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("unsupported subresources", func(t *testing.T) {
+		for _, request := range []struct{ method, target string }{
+			{"GET", "/sub-bkt?policyStatus"},
+			{"GET", "/sub-bkt?website"},
+			{"GET", "/sub-bkt?encryption"},
+			{"GET", "/sub-bkt?tagging"},
+			{"PUT", "/sub-bkt?acl"},
+			{"PUT", "/sub-bkt?tagging"},
+			{"DELETE", "/sub-bkt?website"},
+			{"POST", "/sub-bkt"},
+			{"GET", "/sub-bkt/doc.txt?torrent"},
+			{"PUT", "/sub-bkt/doc.txt?acl"},
+			{"POST", "/sub-bkt/doc.txt?restore"},
+		} {
+			w := doSigned(t, r, request.method, request.target, "")
+			assert.Equal(t, http.StatusNotImplemented, w.Code, request.method+" "+request.target)
+			assertS3ErrorCode(t, w, "NotImplemented")
+		}
+
+		// None of them touched the bucket or the object
+		w := doSigned(t, r, "GET", "/sub-bkt/doc.txt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "hello", w.Body.String())
+	})
+
+	t.Run("bucket and object requests", func(t *testing.T) {
+		w := doSigned(t, r, "GET", "/sub-bkt?prefix=doc", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var list handlers.ListBucketResult
+		parseXML(t, w.Body.Bytes(), &list)
+		require.Len(t, list.Contents, 1)
+
+		w = doSigned(t, r, "HEAD", "/", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
@@ -0,0 +1,129 @@
+package eightfs_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getTags reads the tag set of an object as a map
+func getTags(t *testing.T, r http.Handler, target string) map[string]string {
+	t.Helper()
+	w := doSigned(t, r, "GET", target, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tagging handlers.Tagging
+	parseXML(t, w.Body.Bytes(), &tagging)
+	tags := make(map[string]string)
+	for _, tag := range tagging.TagSet.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+// This test covers object tagging: the ?tagging subresource, the x-amz-tagging header and tag validation.
+func TestS3_ObjectTagging(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/tag-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doConditional(t, r, "PUT", "/tag-bkt/data.csv", "a,b\n1,2\n", map[string]string{"x-amz-tagging": "dataset=train&owner=team-x"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	t.Run("header tags", func(t *testing.T) {
+		assert.Equal(t, map[string]string{"dataset": "train", "owner": "team-x"}, getTags(t, r, "/tag-bkt/data.csv?tagging"))
+
+		w := doSigned(t, r, "GET", "/tag-bkt/data.csv", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("x-amz-tagging-count"))
+	})
+
+	t.Run("replace tags without rewriting", func(t *testing.T) {
+		before := doSigned(t, r, "HEAD", "/tag-bkt/data.csv", "")
+
+		body := `<Tagging><TagSet><Tag><Key>dataset</Key><Value>eval</Value></Tag></TagSet></Tagging>`
+		w := doSigned(t, r, "PUT", "/tag-bkt/data.csv?tagging", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, map[string]string{"dataset": "eval"}, getTags(t, r, "/tag-bkt/data.csv?tagging"))
+
+		after := doSigned(t, r, "HEAD", "/tag-bkt/data.csv", "")
+		assert.Equal(t, before.Header().Get("ETag"), after.Header().Get("ETag"))
+		assert.Equal(t, before.Header().Get("Last-Modified"), after.Header().Get("Last-Modified"))
+
+		w = doSigned(t, r, "GET", "/tag-bkt/data.csv", "")
+		assert.Equal(t, "a,b\n1,2\n", w.Body.String())
+	})
+
+	t.Run("delete tags", func(t *testing.T) {
+		w := doSigned(t, r, "DELETE", "/tag-bkt/data.csv?tagging", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, getTags(t, r, "/tag-bkt/data.csv?tagging"))
+
+		// The object itself is untouched
+		w = doSigned(t, r, "HEAD", "/tag-bkt/data.csv", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("x-amz-tagging-count"))
+	})
+
+	t.Run("copy keeps or replaces tags", func(t *testing.T) {
+		w := doConditional(t, r, "PUT", "/tag-bkt/src.txt", "source", map[string]string{"x-amz-tagging": "stage=raw"})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = doConditional(t, r, "PUT", "/tag-bkt/kept.txt", "", map[string]string{"x-amz-copy-source": "/tag-bkt/src.txt"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, map[string]string{"stage": "raw"}, getTags(t, r, "/tag-bkt/kept.txt?tagging"))
+
+		w = doConditional(t, r, "PUT", "/tag-bkt/replaced.txt", "", map[string]string{
+			"x-amz-copy-source":       "/tag-bkt/src.txt",
+			"x-amz-tagging-directive": "REPLACE",
+			"x-amz-tagging":           "stage=clean",
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, map[string]string{"stage": "clean"}, getTags(t, r, "/tag-bkt/replaced.txt?tagging"))
+	})
+
+	t.Run("versioned tags", func(t *testing.T) {
+		w := doSigned(t, r, "PUT", "/tag-bkt?versioning", enableVersioning)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = doConditional(t, r, "PUT", "/tag-bkt/model.bin", "v1", map[string]string{"x-amz-tagging": "release=alpha"})
+		require.Equal(t, http.StatusOK, w.Code)
+		v1 := w.Header().Get("x-amz-version-id")
+		putVersion(t, r, "/tag-bkt/model.bin", "v2")
+
+		body := `<Tagging><TagSet><Tag><Key>release</Key><Value>retired</Value></Tag></TagSet></Tagging>`
+		w = doSigned(t, r, "PUT", "/tag-bkt/model.bin?tagging&versionId="+v1, body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, v1, w.Header().Get("x-amz-version-id"))
+
+		assert.Equal(t, map[string]string{"release": "retired"}, getTags(t, r, "/tag-bkt/model.bin?tagging&versionId="+v1))
+		assert.Empty(t, getTags(t, r, "/tag-bkt/model.bin?tagging"))
+	})
+
+	t.Run("invalid tags", func(t *testing.T) {
+		w := doSigned(t, r, "PUT", "/tag-bkt/data.csv?tagging",
+			`<Tagging><TagSet><Tag><Key>a</Key><Value>1</Value></Tag><Tag><Key>a</Key><Value>2</Value></Tag></TagSet></Tagging>`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidTag")
+
+		w = doSigned(t, r, "PUT", "/tag-bkt/data.csv?tagging", `<Tagging><TagSet><Tag><Key>bad*key</Key><Value>1</Value></Tag></TagSet></Tagging>`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidTag")
+
+		w = doConditional(t, r, "PUT", "/tag-bkt/many.txt", "x", map[string]string{
+			"x-amz-tagging": "a=1&b=2&c=3&d=4&e=5&f=6&g=7&h=8&i=9&j=10&k=11",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidTag")
+
+		w = doConditional(t, r, "PUT", "/tag-bkt/dup.txt", "x", map[string]string{"x-amz-tagging": "a=1&a=2"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidTag")
+
+		w = doSigned(t, r, "GET", "/tag-bkt/missing.txt?tagging", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,248 @@
+package eightfs_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const enableVersioning = `<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`
+
+// putVersion uploads an object and returns the version ID it was stored under
+func putVersion(t *testing.T, r http.Handler, target, body string) string {
+	t.Helper()
+	w := doSigned(t, r, "PUT", target, body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	return w.Header().Get("x-amz-version-id")
+}
+
+// listVersions lists the object versions of a bucket
+func listVersions(t *testing.T, r http.Handler, bucket string, query url.Values) handlers.ListVersionsResult {
+	t.Helper()
+	w := doSigned(t, r, "GET", "/"+bucket+"?versions&"+query.Encode(), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result handlers.ListVersionsResult
+	parseXML(t, w.Body.Bytes(), &result)
+	return result
+}
+
+// This test covers bucket versioning: version IDs, versioned reads, delete markers and version deletes.
+func TestS3_Versioning(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/ver-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Unversioned until configured, and writes carry no version ID
+	w = doSigned(t, r, "GET", "/ver-bkt?versioning", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var config handlers.VersioningConfiguration
+	parseXML(t, w.Body.Bytes(), &config)
+	assert.Empty(t, config.Status)
+	assert.Empty(t, putVersion(t, r, "/ver-bkt/legacy.txt", "legacy"))
+
+	w = doSigned(t, r, "PUT", "/ver-bkt?versioning", `<VersioningConfiguration><Status>On</Status></VersioningConfiguration>`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertS3ErrorCode(t, w, "MalformedXML")
+
+	w = doSigned(t, r, "PUT", "/ver-bkt?versioning", enableVersioning)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doSigned(t, r, "GET", "/ver-bkt?versioning", "")
+	parseXML(t, w.Body.Bytes(), &config)
+	assert.Equal(t, "Enabled", config.Status)
+
+	v1 := putVersion(t, r, "/ver-bkt/prompt.txt", "version one")
+	v2 := putVersion(t, r, "/ver-bkt/prompt.txt", "version two")
+	require.NotEmpty(t, v1)
+	require.NotEmpty(t, v2)
+	assert.NotEqual(t, v1, v2)
+
+	t.Run("versioned reads", func(t *testing.T) {
+		w := doSigned(t, r, "GET", "/ver-bkt/prompt.txt", "")
+		assert.Equal(t, "version two", w.Body.String())
+		assert.Equal(t, v2, w.Header().Get("x-amz-version-id"))
+
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt?versionId="+v1, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "version one", w.Body.String())
+		assert.Equal(t, v1, w.Header().Get("x-amz-version-id"))
+
+		w = doSigned(t, r, "HEAD", "/ver-bkt/prompt.txt?versionId="+v1, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "11", w.Header().Get("Content-Length"))
+
+		// Range reads work against old versions too
+		w = doConditional(t, r, "GET", "/ver-bkt/prompt.txt?versionId="+v1, "", map[string]string{"Range": "bytes=0-6"})
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, "version", w.Body.String())
+
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt?versionId=../../etc", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertS3ErrorCode(t, w, "InvalidArgument")
+
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt?versionId=0123456789abcdef0123456789abcdef", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assertS3ErrorCode(t, w, "NoSuchVersion")
+
+		// Objects written before versioning was enabled are the null version
+		w = doSigned(t, r, "GET", "/ver-bkt/legacy.txt?versionId=null", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "legacy", w.Body.String())
+	})
+
+	t.Run("delete markers", func(t *testing.T) {
+		w := doSigned(t, r, "DELETE", "/ver-bkt/prompt.txt", "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "true", w.Header().Get("x-amz-delete-marker"))
+		marker := w.Header().Get("x-amz-version-id")
+		require.NotEmpty(t, marker)
+
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt?versionId="+marker, "")
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, "true", w.Header().Get("x-amz-delete-marker"))
+		assertS3ErrorCode(t, w, "MethodNotAllowed")
+
+		// Old versions survive the delete
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt?versionId="+v2, "")
+		assert.Equal(t, "version two", w.Body.String())
+
+		list := listVersions(t, r, "ver-bkt", url.Values{"prefix": {"prompt"}})
+		require.Len(t, list.DeleteMarkers, 1)
+		assert.Equal(t, marker, list.DeleteMarkers[0].VersionId)
+		assert.True(t, list.DeleteMarkers[0].IsLatest)
+		require.Len(t, list.Versions, 2)
+		assert.Equal(t, v2, list.Versions[0].VersionId)
+		assert.Equal(t, v1, list.Versions[1].VersionId)
+		assert.False(t, list.Versions[0].IsLatest)
+
+		// Removing the delete marker brings the object back
+		w = doSigned(t, r, "DELETE", "/ver-bkt/prompt.txt?versionId="+marker, "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "true", w.Header().Get("x-amz-delete-marker"))
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "version two", w.Body.String())
+		assert.Equal(t, v2, w.Header().Get("x-amz-version-id"))
+	})
+
+	t.Run("deleting the current version", func(t *testing.T) {
+		w := doSigned(t, r, "DELETE", "/ver-bkt/prompt.txt?versionId="+v2, "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, v2, w.Header().Get("x-amz-version-id"))
+		assert.Empty(t, w.Header().Get("x-amz-delete-marker"))
+
+		// The previous version becomes current
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "version one", w.Body.String())
+
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt?versionId="+v2, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		list := listVersions(t, r, "ver-bkt", url.Values{"prefix": {"prompt"}})
+		require.Len(t, list.Versions, 1)
+		assert.True(t, list.Versions[0].IsLatest)
+		assert.Empty(t, list.DeleteMarkers)
+	})
+
+	t.Run("copy from a version", func(t *testing.T) {
+		v3 := putVersion(t, r, "/ver-bkt/prompt.txt", "version three")
+		w := doConditional(t, r, "PUT", "/ver-bkt/restored.txt", "", map[string]string{
+			"X-Amz-Copy-Source": "/ver-bkt/prompt.txt?versionId=" + v1,
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, v1, w.Header().Get("x-amz-copy-source-version-id"))
+		assert.NotEmpty(t, w.Header().Get("x-amz-version-id"))
+
+		w = doSigned(t, r, "GET", "/ver-bkt/restored.txt", "")
+		assert.Equal(t, "version one", w.Body.String())
+
+		// Copying an old version over the current one needs no metadata change
+		w = doConditional(t, r, "PUT", "/ver-bkt/prompt.txt", "", map[string]string{
+			"X-Amz-Copy-Source": "/ver-bkt/prompt.txt?versionId=" + v1,
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = doSigned(t, r, "GET", "/ver-bkt/prompt.txt?versionId="+v3, "")
+		assert.Equal(t, "version three", w.Body.String())
+	})
+
+	t.Run("list pagination", func(t *testing.T) {
+		all := listVersions(t, r, "ver-bkt", url.Values{})
+		total := len(all.Versions) + len(all.DeleteMarkers)
+		require.Greater(t, total, 3)
+
+		var seen []string
+		query := url.Values{"max-keys": {"2"}}
+		for {
+			page := listVersions(t, r, "ver-bkt", query)
+			for _, v := range page.Versions {
+				seen = append(seen, v.Key+"@"+v.VersionId)
+			}
+			for _, m := range page.DeleteMarkers {
+				seen = append(seen, m.Key+"@"+m.VersionId)
+			}
+			if !page.IsTruncated {
+				break
+			}
+			query.Set("key-marker", page.NextKeyMarker)
+			query.Set("version-id-marker", page.NextVersionIdMarker)
+		}
+		assert.Len(t, seen, total)
+	})
+
+	t.Run("bucket with versions is not empty", func(t *testing.T) {
+		w := doSigned(t, r, "PUT", "/ver-empty", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doSigned(t, r, "PUT", "/ver-empty?versioning", enableVersioning)
+		require.Equal(t, http.StatusOK, w.Code)
+		putVersion(t, r, "/ver-empty/a.txt", "a")
+		w = doSigned(t, r, "DELETE", "/ver-empty/a.txt", "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		w = doSigned(t, r, "DELETE", "/ver-empty", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+// This test covers suspended versioning, where writes replace the null version.
+func TestS3_VersioningSuspended(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+
+	w := doSigned(t, r, "PUT", "/susp-bkt", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doSigned(t, r, "PUT", "/susp-bkt?versioning", enableVersioning)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	kept := putVersion(t, r, "/susp-bkt/adapter.bin", "enabled")
+
+	w = doSigned(t, r, "PUT", "/susp-bkt?versioning", `<VersioningConfiguration><Status>Suspended</Status></VersioningConfiguration>`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, "null", putVersion(t, r, "/susp-bkt/adapter.bin", "suspended one"))
+	assert.Equal(t, "null", putVersion(t, r, "/susp-bkt/adapter.bin", "suspended two"))
+
+	// The enabled-era version is kept, the null version is overwritten in place
+	list := listVersions(t, r, "susp-bkt", url.Values{})
+	require.Len(t, list.Versions, 2)
+	assert.Equal(t, "null", list.Versions[0].VersionId)
+	assert.Equal(t, kept, list.Versions[1].VersionId)
+
+	w = doSigned(t, r, "GET", "/susp-bkt/adapter.bin?versionId=null", "")
+	assert.Equal(t, "suspended two", w.Body.String())
+
+	// A delete while suspended becomes the null delete marker
+	w = doSigned(t, r, "DELETE", "/susp-bkt/adapter.bin", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "null", w.Header().Get("x-amz-version-id"))
+	list = listVersions(t, r, "susp-bkt", url.Values{})
+	require.Len(t, list.DeleteMarkers, 1)
+	require.Len(t, list.Versions, 1)
+	assert.Equal(t, kept, list.Versions[0].VersionId)
+}
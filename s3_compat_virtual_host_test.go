@@ -0,0 +1,105 @@
+package eightfs_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/8fs-io/core/internal/transport/http/handlers"
+	"github.com/8fs-io/core/pkg/sigv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This test covers virtual-hosted-style requests that name the bucket in the host.
+func TestS3_VirtualHostedStyle(t *testing.T) {
+	r, cfg := newTestRouter(t, map[string]string{"SERVER_DOMAIN": "s3.local.8fs"})
+	key := cfg.Auth.DefaultKey.AccessKey
+	host := "http://photos.s3.local.8fs:8080"
+
+	w := doSigned(t, r, "PUT", host+"/", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	t.Run("objects", func(t *testing.T) {
+		w := doSigned(t, r, "PUT", host+"/2024/beach%20day.jpg", "jpeg")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = doSigned(t, r, "PUT", host+"/cover.jpg", "cover")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = doSigned(t, r, "GET", host+"/2024/beach%20day.jpg", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "jpeg", w.Body.String())
+		w = doSigned(t, r, "HEAD", host+"/cover.jpg", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Path-style requests see the same objects
+		w = doSigned(t, r, "GET", "/photos/2024/beach%20day.jpg", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "jpeg", w.Body.String())
+		w = doSigned(t, r, "GET", "http://s3.local.8fs:8080/photos/cover.jpg", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "cover", w.Body.String())
+	})
+
+	t.Run("list", func(t *testing.T) {
+		w := doSigned(t, r, "GET", host+"/?list-type=2&prefix=2024/", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result handlers.ListBucketResult
+		parseXML(t, w.Body.Bytes(), &result)
+		assert.Equal(t, "photos", result.Name)
+		require.Len(t, result.Contents, 1)
+		assert.Equal(t, "2024/beach day.jpg", result.Contents[0].Key)
+	})
+
+	t.Run("signature covers the host", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", host+"/cover.jpg", nil)
+		signRequest(req, key)
+		req.Host = "other.s3.local.8fs:8080"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assertS3ErrorCode(t, w, "SignatureDoesNotMatch")
+	})
+
+	t.Run("presigned", func(t *testing.T) {
+		u, _ := url.Parse(host + "/cover.jpg")
+		signed := sigv4.Presign("GET", u, key, testSecretKey, "us-east-1", time.Now(), time.Hour)
+		req, _ := http.NewRequest("GET", signed.String(), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		body, _ := io.ReadAll(w.Body)
+		assert.Equal(t, "cover", string(body))
+	})
+
+	// Deeper subdomains and an empty label do not name a bucket
+	t.Run("not a bucket label", func(t *testing.T) {
+		for _, target := range []string{"http://cdn.photos.s3.local.8fs/photos/cover.jpg", "http://.s3.local.8fs/photos/cover.jpg"} {
+			w := doSigned(t, r, "GET", target, "")
+			require.Equal(t, http.StatusOK, w.Code, target)
+			assert.Equal(t, "cover", w.Body.String())
+		}
+	})
+
+	t.Run("unknown bucket", func(t *testing.T) {
+		w := doSigned(t, r, "GET", "http://missing.s3.local.8fs/cover.jpg", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doSigned(t, r, "GET", "http://missing.s3.local.8fs/?list-type=2", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		for _, target := range []string{"/2024/beach%20day.jpg", "/cover.jpg"} {
+			w := doSigned(t, r, "DELETE", host+target, "")
+			require.Equal(t, http.StatusNoContent, w.Code)
+		}
+		w := doSigned(t, r, "DELETE", host+"/", "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		w = doSigned(t, r, "GET", "/", "")
+		assert.False(t, strings.Contains(w.Body.String(), "<Name>photos</Name>"))
+	})
+}